@@ -0,0 +1,134 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tiroq/memofy/internal/encrypt"
+	"github.com/tiroq/memofy/internal/recpath"
+)
+
+func cmdKeygen() {
+	fs := flag.NewFlagSet("memofy keygen", flag.ExitOnError)
+	out := fs.String("o", "", "write the private key to this file instead of stdout")
+	parseCommand(fs)
+
+	id, err := encrypt.GenerateIdentity()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Generate key: %v\n", err)
+		os.Exit(1)
+	}
+	pub := id.Recipient().String()
+	content := fmt.Sprintf("# created: %s\n# public key: %s\n%s\n", time.Now().Format(time.RFC3339), pub, id)
+	if *out == "" {
+		fmt.Print(content)
+		fmt.Fprintf(os.Stderr, "Public key: %s\n", pub)
+		return
+	}
+	f, err := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Write key: %v\n", err)
+		os.Exit(1)
+	}
+	if _, err := f.WriteString(content); err != nil {
+		f.Close()
+		fmt.Fprintf(os.Stderr, "Write key: %v\n", err)
+		os.Exit(1)
+	}
+	if err := f.Close(); err != nil {
+		fmt.Fprintf(os.Stderr, "Write key: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Private key written to %s; keep it off the recording machine.\n", *out)
+	fmt.Printf("Public key: %s\n", pub)
+	fmt.Println("Add the public key to encryption.recipients in the config.")
+}
+
+func cmdDecrypt() {
+	fs := flag.NewFlagSet("memofy decrypt", flag.ExitOnError)
+	identityFile := fs.String("i", "", "identity file from memofy keygen (required)")
+	outDir := fs.String("o", ".", "directory to write the decrypted files to")
+	parseCommand(fs)
+	if *identityFile == "" || fs.NArg() < 1 {
+		fmt.Fprintln(os.Stderr, "Usage: memofy decrypt -i <identity-file> [-o <dir>] <session-id|file.enc>...")
+		os.Exit(2)
+	}
+
+	f, err := os.Open(*identityFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Read identity: %v\n", err)
+		os.Exit(1)
+	}
+	ids, err := encrypt.ParseIdentities(f)
+	f.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Read identity %s: %v\n", *identityFile, err)
+		os.Exit(1)
+	}
+	if err := os.MkdirAll(*outDir, 0700); err != nil {
+		fmt.Fprintf(os.Stderr, "Create %s: %v\n", *outDir, err)
+		os.Exit(1)
+	}
+
+	failed := false
+	for _, src := range decryptSources(fs.Args()) {
+		dst := filepath.Join(*outDir, strings.TrimSuffix(filepath.Base(src), recpath.ExtEncrypted))
+		if _, err := os.Stat(dst); err == nil {
+			fmt.Fprintf(os.Stderr, "Skipping %s: %s exists\n", filepath.Base(src), dst)
+			failed = true
+			continue
+		}
+		if err := encrypt.DecryptFile(src, dst, ids...); err != nil {
+			fmt.Fprintf(os.Stderr, "Decrypt failed: %v\n", err)
+			failed = true
+			continue
+		}
+		fmt.Printf("Decrypted %s\n", dst)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// decryptSources returns the encrypted files args name: files given by
+// path, and the files of recordings given by session ID or name.
+func decryptSources(args []string) []string {
+	var out []string
+	var ids []string
+	for _, arg := range args {
+		if recpath.Encrypted(arg) {
+			if _, err := os.Stat(arg); err == nil {
+				out = append(out, arg)
+				continue
+			}
+		}
+		ids = append(ids, arg)
+	}
+	if len(ids) == 0 {
+		return out
+	}
+	ix := openLibrary(false)
+	for _, id := range ids {
+		entries := ix.Find(id)
+		if len(entries) == 0 {
+			fmt.Fprintf(os.Stderr, "No recording %q; see memofy list\n", id)
+			os.Exit(1)
+		}
+		for _, e := range entries {
+			n := len(out)
+			for _, path := range ix.Files(e) {
+				if recpath.Encrypted(path) {
+					out = append(out, path)
+				}
+			}
+			if len(out) == n {
+				fmt.Fprintf(os.Stderr, "%s is not encrypted\n", e.Sidecar)
+			}
+		}
+	}
+	return out
+}
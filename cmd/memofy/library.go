@@ -0,0 +1,298 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/tiroq/memofy/internal/library"
+	"github.com/tiroq/memofy/internal/metadata"
+	"github.com/tiroq/memofy/internal/retention"
+)
+
+// parseCommand parses a subcommand's arguments into fs. The global
+// -c/--config option, read by loadConfig, may appear anywhere and is
+// skipped.
+func parseCommand(fs *flag.FlagSet) {
+	var args []string
+	for i := 2; i < len(os.Args); i++ {
+		if a := os.Args[i]; a == "-c" || a == "--config" {
+			i++
+			continue
+		}
+		args = append(args, os.Args[i])
+	}
+	fs.Parse(args)
+}
+
+// parseDay parses a date (YYYY-MM-DD, local time) or an RFC 3339 time.
+// With endOfDay a bare date means the end of that day.
+func parseDay(s string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", s, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not a date (YYYY-MM-DD) or RFC 3339 time", s)
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// openLibrary opens the index of the configured output directory.
+func openLibrary(rebuild bool) *library.Index {
+	cfg := loadConfig()
+	var ix *library.Index
+	var err error
+	if rebuild {
+		ix, err = library.Rebuild(cfg.Output.Dir)
+	} else {
+		ix, err = library.Open(cfg.Output.Dir)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Library error: %v\n", err)
+		os.Exit(1)
+	}
+	return ix
+}
+
+func cmdList() {
+	fs := flag.NewFlagSet("memofy list", flag.ExitOnError)
+	from := fs.String("from", "", "only recordings started on or after this date")
+	to := fs.String("to", "", "only recordings started on or before this date")
+	app := fs.String("app", "", "only recordings during which this app ran")
+	minDur := fs.Duration("min-duration", 0, "only recordings at least this long (e.g. 5m)")
+	reason := fs.String("reason", "", "only recordings with this finalization reason")
+	hasAudio := fs.Bool("has-audio", false, "only recordings with meaningful audio")
+	noAudio := fs.Bool("no-audio", false, "only recordings without meaningful audio")
+	asJSON := fs.Bool("json", false, "print entries as JSON lines")
+	rebuild := fs.Bool("rebuild", false, "rebuild the index from the sidecars first")
+	parseCommand(fs)
+
+	var f library.Filter
+	var err error
+	if *from != "" {
+		if f.From, err = parseDay(*from, false); err != nil {
+			fmt.Fprintf(os.Stderr, "--from: %v\n", err)
+			os.Exit(2)
+		}
+	}
+	if *to != "" {
+		if f.To, err = parseDay(*to, true); err != nil {
+			fmt.Fprintf(os.Stderr, "--to: %v\n", err)
+			os.Exit(2)
+		}
+	}
+	if *hasAudio && *noAudio {
+		fmt.Fprintln(os.Stderr, "--has-audio and --no-audio are exclusive")
+		os.Exit(2)
+	}
+	if *hasAudio || *noAudio {
+		f.HasAudio = hasAudio
+	}
+	f.App = *app
+	f.MinDuration = *minDur
+	f.Reason = metadata.FinalizationReason(*reason)
+
+	ix := openLibrary(*rebuild)
+	entries := ix.List(f)
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		for _, e := range entries {
+			enc.Encode(e)
+		}
+		return
+	}
+	if len(entries) == 0 {
+		fmt.Println("No recordings.")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SESSION\tSTARTED\tDURATION\tREASON\tAUDIO\tAPPS\tFILE")
+	for _, e := range entries {
+		audio := "no"
+		if e.HasAudio {
+			audio = "yes"
+		}
+		file := e.File
+		if file == "" {
+			file = "(missing) " + e.Sidecar
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			e.SessionID, e.StartedAt.Local().Format("2006-01-02 15:04"),
+			e.Duration().Truncate(time.Second), e.FinalizationReason, audio,
+			strings.Join(e.Apps, ","), file)
+	}
+	w.Flush()
+}
+
+func cmdShow() {
+	fs := flag.NewFlagSet("memofy show", flag.ExitOnError)
+	parseCommand(fs)
+	if fs.NArg() < 1 {
+		fmt.Fprintln(os.Stderr, "Usage: memofy show <session-id>")
+		os.Exit(2)
+	}
+	id := fs.Arg(0)
+
+	ix := openLibrary(false)
+	entries := ix.Find(id)
+	if len(entries) == 0 {
+		fmt.Fprintf(os.Stderr, "No recording %q; see memofy list\n", id)
+		os.Exit(1)
+	}
+	for i, e := range entries {
+		if i > 0 {
+			fmt.Println()
+		}
+		if e.File != "" {
+			fmt.Printf("File:     %s\n", ix.Path(e.File))
+		} else {
+			fmt.Println("File:     (missing)")
+		}
+		fmt.Printf("Metadata: %s\n", ix.Path(e.Sidecar))
+		rec, err := ix.ReadRecording(e)
+		if errors.Is(err, library.ErrEncrypted) {
+			// Only the index entry can be shown without the private key.
+			fmt.Printf("Encrypted; memofy decrypt -i <identity-file> %s\n", id)
+			out, _ := json.MarshalIndent(e, "", "  ")
+			fmt.Println(string(out))
+			continue
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Read metadata: %v\n", err)
+			os.Exit(1)
+		}
+		out, _ := json.MarshalIndent(rec, "", "  ")
+		fmt.Println(string(out))
+	}
+}
+
+// findOne returns the one recording id names, or exits.
+func findOne(ix *library.Index, id string) library.Entry {
+	entries := ix.Find(id)
+	switch len(entries) {
+	case 0:
+		fmt.Fprintf(os.Stderr, "No recording %q; see memofy list\n", id)
+		os.Exit(1)
+	case 1:
+	default:
+		fmt.Fprintf(os.Stderr, "%q matches %d recordings; use the file name instead:\n", id, len(entries))
+		for _, e := range entries {
+			fmt.Fprintf(os.Stderr, "  %s\n", e.Sidecar)
+		}
+		os.Exit(1)
+	}
+	return entries[0]
+}
+
+// updateSidecar rewrites the sidecar of the recording id names. The star
+// and tags of an encrypted recording are kept in its companion instead.
+func updateSidecar(id string, update func(*metadata.Recording)) {
+	ix := openLibrary(false)
+	e := findOne(ix, id)
+	if e.Encrypted {
+		rec := metadata.Recording{Starred: e.Starred, Tags: e.Tags}
+		update(&rec)
+		if err := ix.SetLabels(e, rec.Starred, rec.Tags); err != nil {
+			fmt.Fprintf(os.Stderr, "Library error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("%s: starred=%v tags=%v\n", e.Sidecar, rec.Starred, rec.Tags)
+		return
+	}
+	rec, err := ix.ReadRecording(e)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Read metadata: %v\n", err)
+		os.Exit(1)
+	}
+	update(&rec)
+	path := ix.Path(e.Sidecar)
+	if err := metadata.Write(path, rec); err != nil {
+		fmt.Fprintf(os.Stderr, "Write metadata: %v\n", err)
+		os.Exit(1)
+	}
+	if err := library.Add(ix.Root(), path); err != nil {
+		fmt.Fprintf(os.Stderr, "Library error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("%s: starred=%v tags=%v\n", e.Sidecar, rec.Starred, rec.Tags)
+}
+
+func cmdStar() {
+	fs := flag.NewFlagSet("memofy star", flag.ExitOnError)
+	off := fs.Bool("off", false, "remove the star")
+	parseCommand(fs)
+	if fs.NArg() < 1 {
+		fmt.Fprintln(os.Stderr, "Usage: memofy star [--off] <session-id>")
+		os.Exit(2)
+	}
+	updateSidecar(fs.Arg(0), func(r *metadata.Recording) {
+		r.Starred = !*off
+	})
+}
+
+func cmdTag() {
+	fs := flag.NewFlagSet("memofy tag", flag.ExitOnError)
+	remove := fs.Bool("remove", false, "remove the tags instead of adding them")
+	parseCommand(fs)
+	if fs.NArg() < 2 {
+		fmt.Fprintln(os.Stderr, "Usage: memofy tag [--remove] <session-id> <tag>...")
+		os.Exit(2)
+	}
+	updateSidecar(fs.Arg(0), func(r *metadata.Recording) {
+		for _, tag := range fs.Args()[1:] {
+			tag = strings.TrimSpace(tag)
+			i := slices.Index(r.Tags, tag)
+			switch {
+			case tag == "":
+			case *remove && i >= 0:
+				r.Tags = slices.Delete(r.Tags, i, i+1)
+			case !*remove && i < 0:
+				r.Tags = append(r.Tags, tag)
+			}
+		}
+		if len(r.Tags) == 0 {
+			r.Tags = nil
+		}
+	})
+}
+
+func cmdPrune() {
+	fs := flag.NewFlagSet("memofy prune", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "only list what would be deleted")
+	parseCommand(fs)
+
+	cfg := loadConfig()
+	logger := log.New(io.Discard, "", 0)
+	res, err := retention.Prune(cfg.Output.Dir, cfg.Retention, time.Now(), *dryRun, logger)
+	done := res.Deleted
+	verb := "Deleted"
+	if *dryRun {
+		done = res.Planned
+		verb = "Would delete"
+	}
+	for _, d := range done {
+		fmt.Printf("%s %s (%s, %s)\n", verb, d.Entry.Sidecar, d.Reason, d.Entry.StartedAt.Local().Format("2006-01-02 15:04"))
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Prune failed: %v\n", err)
+		os.Exit(1)
+	}
+	if len(done) == 0 {
+		fmt.Println("Nothing to delete.")
+		return
+	}
+	fmt.Printf("%s %d recording(s), %.1f MB\n", verb, len(done), float64(res.Bytes)/(1024*1024))
+}
@@ -6,23 +6,34 @@
 //
 //	memofy run          Start recording daemon
 //	memofy status       Show current status
+//	memofy mark [label] Mark the current moment of the recording
+//	memofy list         List recordings
+//	memofy show <id>    Show a recording's metadata
+//	memofy prune        Delete recordings past the retention limits
+//	memofy keygen       Create a key pair for encryption
+//	memofy decrypt      Decrypt encrypted recordings
 //	memofy doctor       Check system setup
 //	memofy test-audio   Test audio capture
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"runtime"
+	"strings"
 	"time"
 
 	"github.com/tiroq/memofy/internal/audio"
 	"github.com/tiroq/memofy/internal/autoupdate"
 	"github.com/tiroq/memofy/internal/config"
+	"github.com/tiroq/memofy/internal/control"
+	"github.com/tiroq/memofy/internal/diskguard"
 	"github.com/tiroq/memofy/internal/engine"
 	"github.com/tiroq/memofy/internal/micdetect"
 	"github.com/tiroq/memofy/internal/pidfile"
+	"github.com/tiroq/memofy/internal/upload"
 )
 
 // Version is set at build time via -ldflags.
@@ -39,6 +50,22 @@ func main() {
 		cmdRun()
 	case "status":
 		cmdStatus()
+	case "mark":
+		cmdMark()
+	case "list":
+		cmdList()
+	case "show":
+		cmdShow()
+	case "star":
+		cmdStar()
+	case "tag":
+		cmdTag()
+	case "prune":
+		cmdPrune()
+	case "keygen":
+		cmdKeygen()
+	case "decrypt":
+		cmdDecrypt()
 	case "doctor":
 		cmdDoctor()
 	case "doctor-mic":
@@ -67,6 +94,19 @@ Usage:
 Commands:
   run              Start the recording daemon
   status           Show current recording status
+  mark [label]     Mark the current moment of the recording
+  list [filters]   List recordings (--from, --to, --app, --min-duration,
+                   --reason, --has-audio, --no-audio, --json, --rebuild)
+  show <id>        Show a recording's files and metadata
+  star [--off] <id>
+                   Star a recording so retention never deletes it
+  tag [--remove] <id> <tag>...
+                   Tag a recording; tagged recordings are never deleted
+  prune [--dry-run]
+                   Delete recordings past the retention limits
+  keygen [-o FILE] Create a key pair for encryption.recipients
+  decrypt -i FILE [-o DIR] <id|file.enc>...
+                   Decrypt encrypted recordings and sidecars
   doctor           Check system setup and dependencies
   doctor-mic       Check microphone usage detection
   test-audio       Test audio capture for 5 seconds
@@ -122,13 +162,52 @@ func cmdRun() {
 		logger.Fatalf("Start failed: %v", err)
 	}
 
+	srv, err := control.Listen(control.SocketPath(), controlHandler(eng))
+	if err != nil {
+		logger.Printf("Control socket unavailable, memofy mark and status will not work: %v", err)
+	} else {
+		defer srv.Close()
+	}
+
 	// Platform-specific run loop: macOS starts menu bar UI, Linux waits for signal.
 	platformRunLoop(eng, cfg, Version, logger)
 }
 
+// controlHandler answers requests from other memofy commands.
+func controlHandler(eng *engine.Engine) control.Handler {
+	return func(req control.Request) control.Response {
+		switch req.Command {
+		case "mark":
+			m, err := eng.Mark(req.Label)
+			if err != nil {
+				return control.Response{Error: err.Error()}
+			}
+			at := time.Duration(m.FileSeconds * float64(time.Second)).Truncate(time.Second)
+			return control.Response{OK: true, Message: fmt.Sprintf("Marked %s into the recording", at)}
+		case "status":
+			return control.Response{OK: true, Message: eng.Status()}
+		default:
+			return control.Response{Error: fmt.Sprintf("unknown command %q", req.Command)}
+		}
+	}
+}
+
+func cmdMark() {
+	label := strings.Join(os.Args[2:], " ")
+	resp, err := control.Send(control.SocketPath(), control.Request{Command: "mark", Label: label})
+	if err != nil {
+		if errors.Is(err, control.ErrNotRunning) {
+			fmt.Fprintln(os.Stderr, "memofy is not running; start it with: memofy run")
+		} else {
+			fmt.Fprintf(os.Stderr, "Mark failed: %v\n", err)
+		}
+		os.Exit(1)
+	}
+	fmt.Println(resp.Message)
+}
+
 func cmdStatus() {
 	cfg := loadConfig()
-	logger := log.New(os.Stderr, "", 0)
 
 	fmt.Printf("Platform:     %s/%s\n", runtime.GOOS, runtime.GOARCH)
 	fmt.Printf("Format:       %s\n", cfg.Audio.FormatProfile)
@@ -136,8 +215,62 @@ func cmdStatus() {
 	fmt.Printf("Threshold:    %.4f\n", cfg.Audio.Threshold)
 	fmt.Printf("Silence:      %ds\n", cfg.Audio.SilenceSeconds)
 
-	eng := engine.New(cfg, logger)
-	fmt.Println(eng.Status())
+	// Recording state, channel warnings and the rest are only known to the
+	// running daemon.
+	resp, err := control.Send(control.SocketPath(), control.Request{Command: "status"})
+	switch {
+	case err == nil:
+		fmt.Println(resp.Message)
+		return
+	case errors.Is(err, control.ErrNotRunning):
+		fmt.Println("State:        not running")
+	default:
+		fmt.Printf("State:        unknown (%v)\n", err)
+	}
+	g := diskguard.New(cfg.Output.Dir, cfg.Output.LowDiskMB, cfg.Output.CriticalDiskMB, nil)
+	if cfg.Encryption.Enabled {
+		g.Watch(cfg.Encryption.Staging())
+	}
+	if level, err := g.Check(); err == nil && g.Enabled() {
+		fmt.Printf("Disk:         %s (%s free)\n", level, diskguard.FormatBytes(g.Stats().Free))
+	}
+	if cfg.Upload.Enabled {
+		// The daemon keeps the upload queue in the output directory.
+		st, err := upload.ReadStatus(cfg.Output.Dir)
+		if err != nil {
+			fmt.Printf("Upload:       unknown (%v)\n", err)
+		} else {
+			fmt.Printf("Upload:       %s\n", st)
+		}
+	}
+}
+
+// doctorDisk reports free space in dir, the output or staging directory,
+// against output.low_disk_mb and output.critical_disk_mb. It fails only
+// when recording would be refused.
+func doctorDisk(cfg config.Config, dir string) bool {
+	g := diskguard.New(dir, cfg.Output.LowDiskMB, cfg.Output.CriticalDiskMB, nil)
+	st, err := diskguard.Statfs(dir)
+	if err != nil {
+		fmt.Printf("  Free space: unknown (%v)\n", err)
+		return true
+	}
+	level, _ := g.Check()
+	fmt.Printf("  Free space: %s of %s", diskguard.FormatBytes(st.Free), diskguard.FormatBytes(st.Total))
+	switch level {
+	case diskguard.Low:
+		fmt.Printf(" - WARNING: below output.low_disk_mb (%d MB)", cfg.Output.LowDiskMB)
+		if cfg.Output.LowDiskProfile != "" {
+			fmt.Printf(", new sessions use format %q", cfg.Output.LowDiskProfile)
+		}
+		fmt.Println()
+	case diskguard.Critical:
+		fmt.Printf(" - FAIL: below output.critical_disk_mb (%d MB), recording is paused\n", cfg.Output.CriticalDiskMB)
+		return false
+	default:
+		fmt.Println(" - OK")
+	}
+	return true
 }
 
 func cmdCheckUpdates() {
@@ -191,30 +324,38 @@ func cmdDoctor() {
 	// Check for system audio device
 	cfg := loadConfig()
 	fmt.Print("\nSystem audio device: ")
+	var sysDev *audio.DeviceInfo
 	switch runtime.GOOS {
 	case "darwin":
 		hint := cfg.Platform.MacOSDevice
-		dev := audio.FindSystemAudioDevice(hint)
-		if dev != nil {
-			fmt.Printf("OK - %s\n", dev.Name)
+		sysDev = audio.FindSystemAudioDevice(hint)
+		if sysDev != nil {
+			fmt.Printf("OK - %s\n", sysDev.Name)
 		} else {
 			fmt.Printf("NOT FOUND - Install BlackHole (https://existential.audio/blackhole/)\n")
 			ok = false
 		}
 	case "linux":
 		hint := cfg.Platform.LinuxDevice
-		dev := audio.FindSystemAudioDevice(hint)
-		if dev != nil {
-			fmt.Printf("OK - %s\n", dev.Name)
+		sysDev = audio.FindSystemAudioDevice(hint)
+		if sysDev != nil {
+			fmt.Printf("OK - %s\n", sysDev.Name)
 		} else {
 			fmt.Println("NOT FOUND - Check PulseAudio/PipeWire configuration")
 			ok = false
 		}
+		if len(cfg.Platform.LinuxCaptureApps) > 0 {
+			doctorAppCapture(cfg.Platform.LinuxCaptureApps)
+		}
 	default:
 		fmt.Printf("UNSUPPORTED PLATFORM - %s\n", runtime.GOOS)
 		ok = false
 	}
 
+	if sysDev != nil && sysDev.MaxInputCh > 1 {
+		doctorChannels(sysDev, cfg)
+	}
+
 	// Check output directory
 	fmt.Printf("\nOutput directory: %s\n", cfg.Output.Dir)
 	if err := os.MkdirAll(cfg.Output.Dir, 0755); err != nil {
@@ -223,6 +364,34 @@ func cmdDoctor() {
 	} else {
 		fmt.Println("  OK")
 	}
+	if !doctorDisk(cfg, cfg.Output.Dir) {
+		ok = false
+	}
+	if cfg.Encryption.Enabled {
+		staging := cfg.Encryption.Staging()
+		fmt.Printf("\nEncryption: %d recipient(s), staging in %s\n", len(cfg.Encryption.Recipients), staging)
+		if err := os.MkdirAll(staging, 0700); err != nil {
+			fmt.Printf("  FAIL - cannot create staging dir: %v\n", err)
+			ok = false
+		} else {
+			fmt.Println("  OK - keep the staging dir on tmpfs or a RAM disk so audio in progress never reaches the disk")
+			if !doctorDisk(cfg, staging) {
+				ok = false
+			}
+		}
+	}
+	if cfg.Upload.Enabled {
+		fmt.Print("\nUpload: ")
+		if target, err := upload.NewTarget(cfg.Upload); err != nil {
+			fmt.Printf("FAIL - %v\n", err)
+			ok = false
+		} else if st, err := upload.ReadStatus(cfg.Output.Dir); err != nil {
+			fmt.Printf("%s\n  FAIL - %v\n", target, err)
+			ok = false
+		} else {
+			fmt.Printf("%s\n  OK - %s\n", target, st)
+		}
+	}
 
 	// Check config
 	fmt.Printf("\nConfig file: %s\n", config.DefaultConfigPath())
@@ -257,18 +426,106 @@ func cmdDoctor() {
 	}
 }
 
-func cmdDoctorMic() {
-	if runtime.GOOS != "darwin" {
-		fmt.Printf("platform: %s\n", runtime.GOOS)
-		fmt.Println("mic detection: unsupported (macOS only)")
-		os.Exit(1)
+// doctorAppCapture prints which playback streams each configured per-app
+// capture entry currently maps to.
+func doctorAppCapture(apps []string) {
+	fmt.Printf("\nPer-app capture: %v\n", apps)
+	inputs, err := audio.ListSinkInputs()
+	if err != nil {
+		fmt.Printf("  FAIL - %v\n", err)
+		return
 	}
+	for _, m := range audio.MapSinkInputs(apps, inputs) {
+		if len(m.Inputs) == 0 {
+			fmt.Printf("  %s: no playback streams\n", m.App)
+			continue
+		}
+		for _, si := range m.Inputs {
+			fmt.Printf("  %s: sink-input #%d %q (binary=%s pid=%d sink=%d)\n",
+				m.App, si.Index, si.AppName, si.Binary, si.PID, si.Sink)
+		}
+	}
+}
+
+// doctorChannels captures two seconds from dev and reports per-channel
+// levels. Only a warning: the result depends on what is playing right now.
+func doctorChannels(dev *audio.DeviceInfo, cfg config.Config) {
+	channels := cfg.Audio.Channels
+	if channels > dev.MaxInputCh {
+		channels = dev.MaxInputCh
+	}
+	fmt.Printf("\nChannel check (%d ch, 2 s):\n", channels)
+	stream, err := audio.OpenStream(audio.CaptureConfig{
+		DeviceIndex:     dev.Index,
+		SampleRate:      cfg.Audio.SampleRate,
+		Channels:        channels,
+		FramesPerBuffer: 4096,
+	})
+	if err != nil {
+		fmt.Printf("  SKIPPED - open stream: %v\n", err)
+		return
+	}
+	defer stream.Close()
+	if err := stream.Start(); err != nil {
+		fmt.Printf("  SKIPPED - start stream: %v\n", err)
+		return
+	}
+	defer stream.Stop()
+
+	levels := audio.NewChannelLevels(channels)
+	buf := make([]float32, 4096*channels)
+	end := time.Now().Add(2 * time.Second)
+	for time.Now().Before(end) {
+		if err := stream.Read(buf); err != nil {
+			fmt.Printf("  SKIPPED - read: %v\n", err)
+			return
+		}
+		levels.Add(buf)
+	}
+	printChannelLevels(levels, cfg.Audio.Threshold)
+}
 
-	ver := micdetect.MacOSVersionString()
-	fmt.Printf("macOS: %s\n", ver)
+// printChannelLevels prints per-channel RMS, peak and clip counts followed by
+// any dead/identical channel warnings.
+func printChannelLevels(levels *audio.ChannelLevels, threshold float64) {
+	rms, peak, clips := levels.RMS(), levels.Peak(), levels.Clips()
+	for c := range rms {
+		fmt.Printf("  ch%d: rms=%.6f peak=%.4f clips=%d\n", c+1, rms[c], peak[c], clips[c])
+	}
+	if levels.Channels() < 2 {
+		return
+	}
+	report := levels.Analyze(threshold)
+	switch {
+	case !report.Active:
+		fmt.Println("  No audible signal; play some audio to check the channels.")
+	case report.OK():
+		fmt.Println("  OK")
+	default:
+		for _, w := range report.Warnings() {
+			fmt.Printf("  WARNING - %s\n", w)
+		}
+		fmt.Println("  Check the device routing, or set audio.auto_mono: true to record the live channel only.")
+	}
+}
 
-	if !micdetect.IsSupported() {
-		fmt.Println("mic detection: unsupported (requires macOS 14+)")
+func cmdDoctorMic() {
+	switch runtime.GOOS {
+	case "darwin":
+		fmt.Printf("macOS: %s\n", micdetect.MacOSVersionString())
+		if !micdetect.IsSupported() {
+			fmt.Println("mic detection: unsupported (requires macOS 14+)")
+			os.Exit(1)
+		}
+	case "linux":
+		fmt.Printf("platform: %s\n", runtime.GOOS)
+		if !micdetect.IsSupported() {
+			fmt.Println("mic detection: unsupported (requires pactl from PulseAudio or PipeWire)")
+			os.Exit(1)
+		}
+	default:
+		fmt.Printf("platform: %s\n", runtime.GOOS)
+		fmt.Println("mic detection: unsupported (macOS and Linux only)")
 		os.Exit(1)
 	}
 
@@ -287,6 +544,10 @@ func cmdDoctorMic() {
 
 	fmt.Println("active microphone users:")
 	for _, p := range procs {
+		if p.BundleID == "" {
+			fmt.Printf("  - pid=%d app=%q binary=%s\n", p.PID, p.Name, p.Binary)
+			continue
+		}
 		fmt.Printf("  - pid=%d bundle=%s\n", p.PID, p.BundleID)
 	}
 }
@@ -348,6 +609,7 @@ func cmdTestAudio() {
 
 	buf := make([]float32, 4096*channels)
 	threshold := cfg.Audio.Threshold
+	levels := audio.NewChannelLevels(channels)
 	end := time.After(5 * time.Second)
 
 	fmt.Printf("Threshold: %.4f\n\n", threshold)
@@ -357,6 +619,12 @@ func cmdTestAudio() {
 	for {
 		select {
 		case <-end:
+			fmt.Println()
+			fmt.Println()
+			printChannelLevels(levels, threshold)
+			if n := stream.Overflows(); n > 0 {
+				fmt.Printf("  WARNING - %d input overflow(s): audio was dropped\n", n)
+			}
 			fmt.Println("\nTest complete.")
 			return
 		default:
@@ -367,6 +635,7 @@ func cmdTestAudio() {
 			continue
 		}
 
+		levels.Add(buf)
 		rms := audio.RMS(buf)
 		bar := renderBar(rms, 40)
 		status := "silence"
@@ -0,0 +1,34 @@
+//go:build darwin
+
+package audio
+
+import "errors"
+
+// ErrAppCaptureUnsupported is returned by SetupAppCapture on macOS, where
+// per-application capture would require a process tap (macOS 14.2+).
+var ErrAppCaptureUnsupported = errors.New("per-app capture is only supported on Linux (PulseAudio/PipeWire)")
+
+// AppCapture is not available on macOS.
+type AppCapture struct{}
+
+// SetupAppCapture returns ErrAppCaptureUnsupported on macOS.
+func SetupAppCapture(_ []string) (*AppCapture, error) {
+	return nil, ErrAppCaptureUnsupported
+}
+
+// Refresh is a no-op on macOS.
+func (ac *AppCapture) Refresh() error { return nil }
+
+// Device returns nil on macOS.
+func (ac *AppCapture) Device() *DeviceInfo { return nil }
+
+// Apps returns nil on macOS.
+func (ac *AppCapture) Apps() []string { return nil }
+
+// Close is a no-op on macOS.
+func (ac *AppCapture) Close() error { return nil }
+
+// ListSinkInputs returns ErrAppCaptureUnsupported on macOS.
+func ListSinkInputs() ([]SinkInput, error) {
+	return nil, ErrAppCaptureUnsupported
+}
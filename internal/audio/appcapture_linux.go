@@ -0,0 +1,201 @@
+//go:build linux
+
+package audio
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// appCaptureSink is the name of the null sink that receives the audio of the
+// applications selected for per-app capture. Its monitor source is what
+// memofy records from.
+const appCaptureSink = "memofy_apps"
+
+// AppCapture routes the playback streams of selected applications into a
+// dedicated null sink so that only their audio is recorded. A loopback from
+// the null sink back to the default sink keeps the audio audible to the user.
+//
+// Streams are matched by application name or binary (see SinkInput.Matches).
+// Applications that start playing after SetupAppCapture are picked up by
+// Refresh, which the engine calls on every monitor poll.
+type AppCapture struct {
+	mu         sync.Mutex
+	apps       []string
+	sinkModule int
+	loopModule int
+	moved      map[int]bool // sink-input index → already routed
+	closed     bool
+	// sourceSet records whether Device set PULSE_SOURCE; prevSource and
+	// hadSource hold its previous value for Close to restore.
+	sourceSet   bool
+	prevSource  string
+	hadSource   bool
+	pactlOutput func(args ...string) ([]byte, error)
+}
+
+// SetupAppCapture creates the capture sink for the given applications and
+// moves their current sink-inputs into it. Requires `pactl` (PulseAudio or
+// PipeWire with pipewire-pulse).
+func SetupAppCapture(apps []string) (*AppCapture, error) {
+	if len(apps) == 0 {
+		return nil, fmt.Errorf("no applications configured for per-app capture")
+	}
+	if _, err := exec.LookPath("pactl"); err != nil {
+		return nil, fmt.Errorf("per-app capture requires pactl: %w", err)
+	}
+	ac := &AppCapture{
+		apps:        apps,
+		moved:       make(map[int]bool),
+		pactlOutput: runPactl,
+	}
+
+	out, err := ac.pactlOutput("load-module", "module-null-sink",
+		"sink_name="+appCaptureSink,
+		"sink_properties=device.description=Memofy-Apps")
+	if err != nil {
+		return nil, fmt.Errorf("create capture sink: %w", err)
+	}
+	ac.sinkModule, _ = strconv.Atoi(strings.TrimSpace(string(out)))
+
+	out, err = ac.pactlOutput("load-module", "module-loopback",
+		"source="+appCaptureSink+".monitor",
+		"sink=@DEFAULT_SINK@",
+		"latency_msec=30")
+	if err != nil {
+		ac.Close()
+		return nil, fmt.Errorf("create loopback: %w", err)
+	}
+	ac.loopModule, _ = strconv.Atoi(strings.TrimSpace(string(out)))
+
+	if err := ac.Refresh(); err != nil {
+		ac.Close()
+		return nil, err
+	}
+	return ac, nil
+}
+
+// Refresh moves any newly appeared sink-inputs of the configured applications
+// into the capture sink.
+func (ac *AppCapture) Refresh() error {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	if ac.closed {
+		return nil
+	}
+	inputs, err := listSinkInputs(ac.pactlOutput)
+	if err != nil {
+		return err
+	}
+	for _, m := range MapSinkInputs(ac.apps, inputs) {
+		for _, si := range m.Inputs {
+			if ac.moved[si.Index] {
+				continue
+			}
+			if _, err := ac.pactlOutput("move-sink-input", strconv.Itoa(si.Index), appCaptureSink); err != nil {
+				return fmt.Errorf("move sink-input %d (%s): %w", si.Index, m.App, err)
+			}
+			ac.moved[si.Index] = true
+		}
+	}
+	return nil
+}
+
+// Device returns the input device that captures the per-app sink, or nil
+// if there is none. It re-reads PortAudio's device list, which predates the
+// sink, so no stream may be open.
+//
+// When PortAudio exposes PulseAudio sources directly the monitor shows up as
+// "Monitor of Memofy-Apps". With the ALSA host API only the generic "pulse"
+// device exists; in that case PULSE_SOURCE is pointed at the monitor so the
+// pulse ALSA plugin opens it when the stream is created, until Close.
+func (ac *AppCapture) Device() *DeviceInfo {
+	if err := RefreshDevices(); err != nil {
+		return nil
+	}
+	if dev := FindDevice("Memofy-Apps"); dev != nil {
+		return dev
+	}
+	dev := FindDevice("pulse")
+	if dev == nil {
+		return nil
+	}
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	ac.pointSourceLocked()
+	return dev
+}
+
+// pointSourceLocked points PULSE_SOURCE at the capture sink's monitor,
+// saving the previous value for Close. Caller must hold ac.mu.
+func (ac *AppCapture) pointSourceLocked() {
+	if !ac.sourceSet {
+		ac.prevSource, ac.hadSource = os.LookupEnv("PULSE_SOURCE")
+		ac.sourceSet = true
+	}
+	os.Setenv("PULSE_SOURCE", appCaptureSink+".monitor")
+}
+
+// Apps returns the configured application names.
+func (ac *AppCapture) Apps() []string {
+	return ac.apps
+}
+
+// Close unloads the loopback and capture sink and restores PULSE_SOURCE.
+// PulseAudio/PipeWire move the remaining sink-inputs back to the default
+// sink when their sink disappears.
+func (ac *AppCapture) Close() error {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	if ac.closed {
+		return nil
+	}
+	ac.closed = true
+	if ac.sourceSet {
+		if ac.hadSource {
+			os.Setenv("PULSE_SOURCE", ac.prevSource)
+		} else {
+			os.Unsetenv("PULSE_SOURCE")
+		}
+		ac.sourceSet = false
+	}
+	var firstErr error
+	for _, mod := range []int{ac.loopModule, ac.sinkModule} {
+		if mod <= 0 {
+			continue
+		}
+		if _, err := ac.pactlOutput("unload-module", strconv.Itoa(mod)); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// ListSinkInputs returns all current PulseAudio/PipeWire playback streams.
+func ListSinkInputs() ([]SinkInput, error) {
+	return listSinkInputs(runPactl)
+}
+
+func listSinkInputs(pactl func(args ...string) ([]byte, error)) ([]SinkInput, error) {
+	out, err := pactl("-f", "json", "list", "sink-inputs")
+	if err != nil {
+		return nil, fmt.Errorf("list sink-inputs: %w", err)
+	}
+	return ParseSinkInputs(out)
+}
+
+// runPactl executes pactl and returns its stdout.
+func runPactl(args ...string) ([]byte, error) {
+	out, err := exec.Command("pactl", args...).Output()
+	if err != nil {
+		if ee, ok := err.(*exec.ExitError); ok && len(ee.Stderr) > 0 {
+			return nil, fmt.Errorf("pactl %s: %s", args[0], strings.TrimSpace(string(ee.Stderr)))
+		}
+		return nil, fmt.Errorf("pactl %s: %w", args[0], err)
+	}
+	return out, nil
+}
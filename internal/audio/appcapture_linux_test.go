@@ -0,0 +1,78 @@
+//go:build linux
+
+package audio
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// fakePactl records pactl calls and answers list sink-inputs with
+// pactlSinkInputsJSON.
+type fakePactl struct {
+	calls []string
+}
+
+func (f *fakePactl) run(args ...string) ([]byte, error) {
+	f.calls = append(f.calls, strings.Join(args, " "))
+	if len(args) > 0 && args[len(args)-1] == "sink-inputs" {
+		return []byte(pactlSinkInputsJSON), nil
+	}
+	return nil, nil
+}
+
+func TestAppCaptureCloseRestores(t *testing.T) {
+	for _, prev := range []string{"alsa_input.usb-mic", ""} {
+		t.Setenv("PULSE_SOURCE", prev)
+		if prev == "" {
+			os.Unsetenv("PULSE_SOURCE")
+		}
+		pactl := &fakePactl{}
+		ac := &AppCapture{
+			apps:        []string{"zoom"},
+			sinkModule:  12,
+			loopModule:  13,
+			moved:       make(map[int]bool),
+			pactlOutput: pactl.run,
+		}
+		ac.mu.Lock()
+		ac.pointSourceLocked()
+		ac.pointSourceLocked() // a second Device call must keep the original value
+		ac.mu.Unlock()
+		if got := os.Getenv("PULSE_SOURCE"); got != "memofy_apps.monitor" {
+			t.Fatalf("PULSE_SOURCE = %q, want the capture monitor", got)
+		}
+		if err := ac.Refresh(); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := ac.Close(); err != nil {
+			t.Fatal(err)
+		}
+		got, ok := os.LookupEnv("PULSE_SOURCE")
+		if prev == "" && ok {
+			t.Errorf("PULSE_SOURCE = %q after Close, want it unset as before", got)
+		} else if prev != "" && got != prev {
+			t.Errorf("PULSE_SOURCE = %q after Close, want %q restored", got, prev)
+		}
+		want := []string{
+			"-f json list sink-inputs",
+			"move-sink-input 51 memofy_apps",
+			"unload-module 13",
+			"unload-module 12",
+		}
+		if strings.Join(pactl.calls, "\n") != strings.Join(want, "\n") {
+			t.Errorf("pactl calls = %q, want %q", pactl.calls, want)
+		}
+
+		// Polls racing with Stop find the capture closed.
+		pactl.calls = nil
+		if err := ac.Refresh(); err != nil || len(pactl.calls) != 0 {
+			t.Errorf("Refresh after Close: err %v, calls %q; want a no-op", err, pactl.calls)
+		}
+		if err := ac.Close(); err != nil || len(pactl.calls) != 0 {
+			t.Errorf("second Close: err %v, calls %q; want a no-op", err, pactl.calls)
+		}
+	}
+}
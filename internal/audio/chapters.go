@@ -0,0 +1,83 @@
+package audio
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/tiroq/memofy/internal/recpath"
+)
+
+// Chapter is a named position in a recording.
+type Chapter struct {
+	Start time.Duration
+	Title string
+}
+
+// AddChapters writes chapters into an existing M4A file. Each chapter runs
+// until the next one starts; the last one until total. The file is remuxed
+// with ffmpeg (no re-encoding), which must be installed: afconvert cannot
+// write chapters.
+func AddChapters(m4aPath string, chapters []Chapter, total time.Duration) error {
+	if len(chapters) == 0 {
+		return nil
+	}
+	if _, err := exec.LookPath("ffmpeg"); err != nil {
+		return fmt.Errorf("add chapters: ffmpeg not found")
+	}
+	base := recpath.Stem(m4aPath)
+	metaPath := base + ".ffmeta"
+	if err := os.WriteFile(metaPath, []byte(FFMetadata(chapters, total)), 0644); err != nil {
+		return fmt.Errorf("add chapters: %w", err)
+	}
+	defer os.Remove(metaPath)
+
+	tmp := base + ".chapters.m4a"
+	args := []string{
+		"-i", m4aPath,
+		"-i", metaPath,
+		"-map", "0",
+		"-map_metadata", "1",
+		"-map_chapters", "1",
+		"-c", "copy",
+		"-y",
+		tmp,
+	}
+	output, err := exec.Command("ffmpeg", args...).CombinedOutput()
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("add chapters: ffmpeg failed: %w (output: %s)", err, string(output))
+	}
+	if err := os.Rename(tmp, m4aPath); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("add chapters: %w", err)
+	}
+	return nil
+}
+
+// FFMetadata renders chapters in ffmpeg's FFMETADATA1 format, with
+// millisecond timestamps.
+func FFMetadata(chapters []Chapter, total time.Duration) string {
+	var b strings.Builder
+	b.WriteString(";FFMETADATA1\n")
+	for i, c := range chapters {
+		end := total
+		if i+1 < len(chapters) {
+			end = chapters[i+1].Start
+		}
+		if end < c.Start {
+			end = c.Start
+		}
+		fmt.Fprintf(&b, "[CHAPTER]\nTIMEBASE=1/1000\nSTART=%d\nEND=%d\ntitle=%s\n",
+			c.Start.Milliseconds(), end.Milliseconds(), ffmetaEscape(c.Title))
+	}
+	return b.String()
+}
+
+// ffmetaEscape escapes the characters FFMETADATA treats specially.
+func ffmetaEscape(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, "=", `\=`, ";", `\;`, "#", `\#`, "\n", "\\\n")
+	return r.Replace(s)
+}
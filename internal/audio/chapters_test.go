@@ -0,0 +1,20 @@
+package audio
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFFMetadata(t *testing.T) {
+	chapters := []Chapter{
+		{Start: 0, Title: "Start"},
+		{Start: 90 * time.Second, Title: "action item; owner=me"},
+	}
+	got := FFMetadata(chapters, 5*time.Minute)
+	want := ";FFMETADATA1\n" +
+		"[CHAPTER]\nTIMEBASE=1/1000\nSTART=0\nEND=90000\ntitle=Start\n" +
+		"[CHAPTER]\nTIMEBASE=1/1000\nSTART=90000\nEND=300000\ntitle=action item\\; owner\\=me\n"
+	if got != want {
+		t.Errorf("FFMetadata() =\n%s\nwant\n%s", got, want)
+	}
+}
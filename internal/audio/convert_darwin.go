@@ -6,14 +6,14 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
-	"path/filepath"
-	"strings"
+
+	"github.com/tiroq/memofy/internal/recpath"
 )
 
 // ConvertToM4A converts a WAV file to M4A/AAC using macOS built-in afconvert.
 // Returns the path to the converted file.
 func ConvertToM4A(wavPath string, spec FormatSpec) (string, error) {
-	m4aPath := strings.TrimSuffix(wavPath, filepath.Ext(wavPath)) + ".m4a"
+	m4aPath := recpath.WithExt(wavPath, recpath.ExtM4A)
 
 	// afconvert is built into macOS — no extra dependencies needed.
 	// -d aac@<rate> : AAC codec at target sample rate (rate embedded in format string)
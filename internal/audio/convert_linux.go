@@ -6,14 +6,14 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
-	"path/filepath"
-	"strings"
+
+	"github.com/tiroq/memofy/internal/recpath"
 )
 
 // ConvertToM4A converts a WAV file to M4A/AAC using ffmpeg.
 // Returns the path to the converted file.
 func ConvertToM4A(wavPath string, spec FormatSpec) (string, error) {
-	m4aPath := strings.TrimSuffix(wavPath, filepath.Ext(wavPath)) + ".m4a"
+	m4aPath := recpath.WithExt(wavPath, recpath.ExtM4A)
 
 	args := []string{
 		"-i", wavPath,
@@ -35,6 +35,7 @@ typedef struct {
 	pthread_mutex_t mutex;
 	pthread_cond_t  cond;
 	int       running;
+	int64_t   overruns;       // ring overflows: reader fell behind, oldest audio dropped
 } CAStream;
 
 // ---- device enumeration helpers ----
@@ -157,8 +158,10 @@ static OSStatus ca_render_cb(
 		s->ringBuf[(int)(s->writePos % (int64_t)s->ringCapacity)] = s->renderBuf[i];
 		s->writePos++;
 	}
-	if (s->writePos - s->readPos > (int64_t)s->ringCapacity)
+	if (s->writePos - s->readPos > (int64_t)s->ringCapacity) {
 		s->readPos = s->writePos - (int64_t)s->ringCapacity;
+		s->overruns++;
+	}
 	pthread_cond_signal(&s->cond);
 	pthread_mutex_unlock(&s->mutex);
 	return noErr;
@@ -268,6 +271,13 @@ static int ca_stream_read(CAStream *s, float *out, int frames) {
 	return 0;
 }
 
+static int64_t ca_stream_overruns(CAStream *s) {
+	pthread_mutex_lock(&s->mutex);
+	int64_t n = s->overruns;
+	pthread_mutex_unlock(&s->mutex);
+	return n;
+}
+
 static void ca_stream_stop(CAStream *s) {
 	AudioOutputUnitStop(s->unit);
 	pthread_mutex_lock(&s->mutex);
@@ -430,6 +440,17 @@ func (st *Stream) Close() error {
 	return nil
 }
 
+// Overflows returns the number of ring-buffer overruns (audio dropped because
+// Read was not called fast enough) since the stream was opened.
+func (st *Stream) Overflows() int64 {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.s == nil {
+		return 0
+	}
+	return int64(C.ca_stream_overruns(st.s))
+}
+
 // FramesPerBuffer returns the buffer size in frames.
 func (st *Stream) FramesPerBuffer() int { return st.bufSize }
 
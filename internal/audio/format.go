@@ -1,6 +1,8 @@
 // Package audio — format profile definitions for recording output.
 package audio
 
+import "github.com/tiroq/memofy/internal/recpath"
+
 // FormatProfile identifies a recording quality preset.
 type FormatProfile string
 
@@ -15,44 +17,53 @@ const (
 type FormatSpec struct {
 	Profile     FormatProfile
 	Container   string // "m4a" or "wav"
-	Codec       string // "aac" or "pcm_s16le"
+	Codec       string // "aac" or "pcm_s16le"/"pcm_s24le"/"pcm_f32le"
 	Channels    int
 	SampleRate  int
 	BitrateKbps int
+	// SampleFormat is the WAV sample encoding used while recording ("s16",
+	// "s24" or "f32"). For M4A profiles it only affects the intermediate file.
+	SampleFormat string
+	// Dither enables TPDF dither when quantizing to 16-bit.
+	Dither bool
 }
 
 // FormatSpecs maps each profile to its specification.
 var FormatSpecs = map[FormatProfile]FormatSpec{
 	FormatHigh: {
-		Profile:     FormatHigh,
-		Container:   "m4a",
-		Codec:       "aac",
-		Channels:    1,
-		SampleRate:  32000,
-		BitrateKbps: 64,
+		Profile:      FormatHigh,
+		Container:    "m4a",
+		Codec:        "aac",
+		Channels:     1,
+		SampleRate:   32000,
+		BitrateKbps:  64,
+		SampleFormat: "s16",
 	},
 	FormatBalanced: {
-		Profile:     FormatBalanced,
-		Container:   "m4a",
-		Codec:       "aac",
-		Channels:    1,
-		SampleRate:  24000,
-		BitrateKbps: 48,
+		Profile:      FormatBalanced,
+		Container:    "m4a",
+		Codec:        "aac",
+		Channels:     1,
+		SampleRate:   24000,
+		BitrateKbps:  48,
+		SampleFormat: "s16",
 	},
 	FormatLightweight: {
-		Profile:     FormatLightweight,
-		Container:   "m4a",
-		Codec:       "aac",
-		Channels:    1,
-		SampleRate:  16000,
-		BitrateKbps: 32,
+		Profile:      FormatLightweight,
+		Container:    "m4a",
+		Codec:        "aac",
+		Channels:     1,
+		SampleRate:   16000,
+		BitrateKbps:  32,
+		SampleFormat: "s16",
 	},
 	FormatWAV: {
-		Profile:    FormatWAV,
-		Container:  "wav",
-		Codec:      "pcm_s16le",
-		Channels:   1,
-		SampleRate: 44100,
+		Profile:      FormatWAV,
+		Container:    "wav",
+		Codec:        "pcm_s16le",
+		Channels:     1,
+		SampleRate:   44100,
+		SampleFormat: "s16",
 	},
 }
 
@@ -81,10 +92,37 @@ func IsValidProfile(profile string) bool {
 	return ok
 }
 
+// pcmCodecs maps WAV sample formats to their codec names.
+var pcmCodecs = map[string]string{
+	"s16": "pcm_s16le",
+	"s24": "pcm_s24le",
+	"f32": "pcm_f32le",
+}
+
+// WithSampleFormat returns a copy of the spec recording with the given WAV
+// sample format ("s16", "s24", "f32"; empty means "s16") and dither setting.
+// For WAV profiles the codec is updated to match; unknown formats leave the
+// spec unchanged.
+func (s FormatSpec) WithSampleFormat(format string, dither bool) FormatSpec {
+	if format == "" {
+		format = "s16"
+	}
+	codec, ok := pcmCodecs[format]
+	if !ok {
+		return s
+	}
+	s.SampleFormat = format
+	s.Dither = dither && format == "s16"
+	if s.Container == "wav" {
+		s.Codec = codec
+	}
+	return s
+}
+
 // FileExtension returns the file extension (with leading dot) for the profile.
 func (s FormatSpec) FileExtension() string {
 	if s.Container == "m4a" {
-		return ".m4a"
+		return recpath.ExtM4A
 	}
-	return ".wav"
+	return recpath.ExtWAV
 }
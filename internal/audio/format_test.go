@@ -53,3 +53,34 @@ func TestValidProfiles(t *testing.T) {
 		t.Errorf("expected 4 profiles, got %d", len(profiles))
 	}
 }
+
+func TestWithSampleFormat(t *testing.T) {
+	wavSpec := GetFormatSpec("wav")
+	tests := []struct {
+		format     string
+		dither     bool
+		wantCodec  string
+		wantFormat string
+		wantDither bool
+	}{
+		{"", true, "pcm_s16le", "s16", true},
+		{"s16", false, "pcm_s16le", "s16", false},
+		{"s24", true, "pcm_s24le", "s24", false}, // dither only applies to 16-bit
+		{"f32", false, "pcm_f32le", "f32", false},
+		{"bogus", true, "pcm_s16le", "s16", false}, // unchanged
+	}
+	for _, tt := range tests {
+		got := wavSpec.WithSampleFormat(tt.format, tt.dither)
+		if got.Codec != tt.wantCodec || got.SampleFormat != tt.wantFormat || got.Dither != tt.wantDither {
+			t.Errorf("WithSampleFormat(%q, %v) = codec %q format %q dither %v, want %q %q %v",
+				tt.format, tt.dither, got.Codec, got.SampleFormat, got.Dither,
+				tt.wantCodec, tt.wantFormat, tt.wantDither)
+		}
+	}
+
+	// M4A profiles keep the AAC codec; only the intermediate WAV changes.
+	high := GetFormatSpec("high").WithSampleFormat("f32", false)
+	if high.Codec != "aac" || high.SampleFormat != "f32" {
+		t.Errorf("high with f32: codec %q format %q", high.Codec, high.SampleFormat)
+	}
+}
@@ -0,0 +1,205 @@
+package audio
+
+import (
+	"fmt"
+	"math"
+)
+
+// ClipLevel is the absolute sample value at or above which a sample counts
+// as clipped.
+const ClipLevel = 0.999
+
+// Thresholds used by ChannelLevels.Analyze, relative to the loudest channel.
+const (
+	deadChannelRatio = 0.01  // -40 dB: channel is effectively silent
+	identicalRatio   = 0.001 // -60 dB: difference between channels is noise
+)
+
+// ChannelLevels accumulates per-channel RMS, peak and clip counts over
+// interleaved buffers. The zero value is not usable; use NewChannelLevels.
+type ChannelLevels struct {
+	channels   int
+	frames     int64
+	sumSquares []float64
+	peak       []float64
+	clips      []int64
+	// diffSquares[c] sums (x[c] - x[0])^2, used to detect channels that
+	// carry the same signal as channel 0.
+	diffSquares []float64
+}
+
+// NewChannelLevels returns an accumulator for the given channel count.
+func NewChannelLevels(channels int) *ChannelLevels {
+	if channels < 1 {
+		channels = 1
+	}
+	return &ChannelLevels{
+		channels:    channels,
+		sumSquares:  make([]float64, channels),
+		peak:        make([]float64, channels),
+		clips:       make([]int64, channels),
+		diffSquares: make([]float64, channels),
+	}
+}
+
+// Channels returns the channel count.
+func (l *ChannelLevels) Channels() int { return l.channels }
+
+// Frames returns the number of frames accumulated.
+func (l *ChannelLevels) Frames() int64 { return l.frames }
+
+// Add accumulates an interleaved buffer. A trailing partial frame is ignored.
+func (l *ChannelLevels) Add(samples []float32) {
+	n := len(samples) / l.channels
+	for f := 0; f < n; f++ {
+		frame := samples[f*l.channels : (f+1)*l.channels]
+		for c, s := range frame {
+			v := float64(s)
+			l.sumSquares[c] += v * v
+			a := math.Abs(v)
+			if a > l.peak[c] {
+				l.peak[c] = a
+			}
+			if a >= ClipLevel {
+				l.clips[c]++
+			}
+			if c > 0 {
+				d := v - float64(frame[0])
+				l.diffSquares[c] += d * d
+			}
+		}
+	}
+	l.frames += int64(n)
+}
+
+// Reset clears all accumulated statistics.
+func (l *ChannelLevels) Reset() {
+	l.frames = 0
+	for c := 0; c < l.channels; c++ {
+		l.sumSquares[c] = 0
+		l.peak[c] = 0
+		l.clips[c] = 0
+		l.diffSquares[c] = 0
+	}
+}
+
+// RMS returns the RMS level of each channel.
+func (l *ChannelLevels) RMS() []float64 {
+	out := make([]float64, l.channels)
+	if l.frames == 0 {
+		return out
+	}
+	for c := range out {
+		out[c] = math.Sqrt(l.sumSquares[c] / float64(l.frames))
+	}
+	return out
+}
+
+// Peak returns the peak absolute sample value of each channel.
+func (l *ChannelLevels) Peak() []float64 {
+	return append([]float64(nil), l.peak...)
+}
+
+// Clips returns the number of clipped samples in each channel.
+func (l *ChannelLevels) Clips() []int64 {
+	return append([]int64(nil), l.clips...)
+}
+
+// ChannelReport is the verdict of ChannelLevels.Analyze. Both checks are
+// only made when the loudest channel is at or above the activity threshold.
+type ChannelReport struct {
+	Active       bool  // the loudest channel reached the threshold; a verdict was made
+	DeadChannels []int // 0-based channels silent while others carry audio
+	Identical    bool  // all channels carry the same signal (fake stereo)
+}
+
+// OK reports whether no channel problem was found.
+func (r ChannelReport) OK() bool {
+	return len(r.DeadChannels) == 0 && !r.Identical
+}
+
+// LiveChannel returns the channel worth keeping when recording mono: the
+// only non-dead channel, or channel 0 for identical channels. It returns -1
+// when all channels should be kept.
+func (r ChannelReport) LiveChannel(channels int) int {
+	if r.Identical {
+		return 0
+	}
+	if channels > 1 && len(r.DeadChannels) == channels-1 {
+		dead := make(map[int]bool, len(r.DeadChannels))
+		for _, c := range r.DeadChannels {
+			dead[c] = true
+		}
+		for c := 0; c < channels; c++ {
+			if !dead[c] {
+				return c
+			}
+		}
+	}
+	return -1
+}
+
+// Warnings returns human-readable descriptions of the problems found, with
+// 1-based channel numbers.
+func (r ChannelReport) Warnings() []string {
+	var out []string
+	for _, c := range r.DeadChannels {
+		out = append(out, fmt.Sprintf("channel %d is silent while others carry audio", c+1))
+	}
+	if r.Identical {
+		out = append(out, "all channels are identical (fake stereo)")
+	}
+	return out
+}
+
+// Analyze checks for dead and identical channels. active is the RMS level
+// the loudest channel must reach for a verdict; below it everything is
+// simply quiet and a report with Active unset is returned.
+func (l *ChannelLevels) Analyze(active float64) ChannelReport {
+	var r ChannelReport
+	if l.channels < 2 || l.frames == 0 {
+		return r
+	}
+	rms := l.RMS()
+	loudest := 0.0
+	for _, v := range rms {
+		if v > loudest {
+			loudest = v
+		}
+	}
+	if loudest < active {
+		return r
+	}
+	r.Active = true
+	for c, v := range rms {
+		if v < loudest*deadChannelRatio {
+			r.DeadChannels = append(r.DeadChannels, c)
+		}
+	}
+	if len(r.DeadChannels) > 0 {
+		return r
+	}
+	r.Identical = true
+	for c := 1; c < l.channels; c++ {
+		diff := math.Sqrt(l.diffSquares[c] / float64(l.frames))
+		if diff > loudest*identicalRatio {
+			r.Identical = false
+			break
+		}
+	}
+	return r
+}
+
+// ExtractChannel copies channel ch of an interleaved buffer into dst,
+// growing it as needed, and returns the mono result.
+func ExtractChannel(dst, samples []float32, channels, ch int) []float32 {
+	n := len(samples) / channels
+	if cap(dst) < n {
+		dst = make([]float32, n)
+	}
+	dst = dst[:n]
+	for f := 0; f < n; f++ {
+		dst[f] = samples[f*channels+ch]
+	}
+	return dst
+}
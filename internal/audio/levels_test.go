@@ -0,0 +1,116 @@
+package audio
+
+import (
+	"math"
+	"testing"
+)
+
+// stereo interleaves two generator functions into n frames.
+func stereo(n int, left, right func(i int) float32) []float32 {
+	buf := make([]float32, 2*n)
+	for i := 0; i < n; i++ {
+		buf[2*i] = left(i)
+		buf[2*i+1] = right(i)
+	}
+	return buf
+}
+
+func sine(amp float64, freq float64) func(int) float32 {
+	return func(i int) float32 {
+		return float32(amp * math.Sin(2*math.Pi*freq*float64(i)/48000))
+	}
+}
+
+func silent(int) float32 { return 0 }
+
+func TestChannelLevels_RMSPeakClips(t *testing.T) {
+	l := NewChannelLevels(2)
+	l.Add(stereo(48000, sine(0.5, 440), func(i int) float32 {
+		if i%1000 == 0 {
+			return 1.0
+		}
+		return 0
+	}))
+
+	rms := l.RMS()
+	if math.Abs(rms[0]-0.5/math.Sqrt2) > 0.01 {
+		t.Errorf("left RMS = %f, want ~%f", rms[0], 0.5/math.Sqrt2)
+	}
+	if peak := l.Peak(); math.Abs(peak[0]-0.5) > 0.001 || peak[1] != 1.0 {
+		t.Errorf("peaks = %v", peak)
+	}
+	if clips := l.Clips(); clips[0] != 0 || clips[1] != 48 {
+		t.Errorf("clips = %v, want [0 48]", clips)
+	}
+	if l.Frames() != 48000 {
+		t.Errorf("frames = %d, want 48000", l.Frames())
+	}
+}
+
+func TestChannelLevels_Analyze(t *testing.T) {
+	tests := []struct {
+		name      string
+		buf       []float32
+		dead      []int
+		identical bool
+		live      int
+	}{
+		{"healthy stereo", stereo(4800, sine(0.3, 440), sine(0.3, 660)), nil, false, -1},
+		{"dead right", stereo(4800, sine(0.3, 440), silent), []int{1}, false, 0},
+		{"dead left", stereo(4800, silent, sine(0.3, 440)), []int{0}, false, 1},
+		{"fake stereo", stereo(4800, sine(0.3, 440), sine(0.3, 440)), nil, true, 0},
+		{"all quiet", stereo(4800, silent, silent), nil, false, -1},
+		{"quiet but present", stereo(4800, sine(0.3, 440), sine(0.01, 440)), nil, false, -1},
+	}
+	for _, tc := range tests {
+		l := NewChannelLevels(2)
+		l.Add(tc.buf)
+		r := l.Analyze(0.02)
+		if len(r.DeadChannels) != len(tc.dead) || (len(tc.dead) > 0 && r.DeadChannels[0] != tc.dead[0]) {
+			t.Errorf("%s: dead = %v, want %v", tc.name, r.DeadChannels, tc.dead)
+		}
+		if r.Identical != tc.identical {
+			t.Errorf("%s: identical = %v, want %v", tc.name, r.Identical, tc.identical)
+		}
+		if got := r.LiveChannel(2); got != tc.live {
+			t.Errorf("%s: live channel = %d, want %d", tc.name, got, tc.live)
+		}
+		if r.Active != (tc.name != "all quiet") {
+			t.Errorf("%s: active = %v", tc.name, r.Active)
+		}
+		if r.OK() != (len(r.Warnings()) == 0) {
+			t.Errorf("%s: OK() disagrees with Warnings() %v", tc.name, r.Warnings())
+		}
+	}
+}
+
+func TestChannelLevels_MonoNeverWarns(t *testing.T) {
+	l := NewChannelLevels(1)
+	l.Add(make([]float32, 100))
+	if r := l.Analyze(0); !r.OK() {
+		t.Errorf("mono report = %+v, want OK", r)
+	}
+}
+
+func TestChannelLevels_Reset(t *testing.T) {
+	l := NewChannelLevels(2)
+	l.Add(stereo(100, sine(0.5, 440), sine(0.5, 440)))
+	l.Reset()
+	if l.Frames() != 0 || l.RMS()[0] != 0 || l.Peak()[0] != 0 {
+		t.Errorf("Reset left state: frames=%d rms=%v peak=%v", l.Frames(), l.RMS(), l.Peak())
+	}
+}
+
+func TestExtractChannel(t *testing.T) {
+	in := []float32{1, 2, 3, 4, 5, 6}
+	got := ExtractChannel(nil, in, 2, 1)
+	want := []float32{2, 4, 6}
+	if len(got) != len(want) {
+		t.Fatalf("len = %d, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("sample %d = %f, want %f", i, got[i], want[i])
+		}
+	}
+}
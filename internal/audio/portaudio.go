@@ -12,25 +12,55 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"unsafe"
 )
 
-var initOnce sync.Once
+var (
+	initMu      sync.Mutex
+	initialized bool
+)
 
 // Init initializes PortAudio. Must be called before any other audio functions.
+// It may be called again after Terminate.
 func Init() error {
-	var initErr error
-	initOnce.Do(func() {
-		if err := C.Pa_Initialize(); err != C.paNoError {
-			initErr = fmt.Errorf("portaudio init: %s", C.GoString(C.Pa_GetErrorText(err)))
-		}
-	})
-	return initErr
+	initMu.Lock()
+	defer initMu.Unlock()
+	if initialized {
+		return nil
+	}
+	if err := C.Pa_Initialize(); err != C.paNoError {
+		return fmt.Errorf("portaudio init: %s", C.GoString(C.Pa_GetErrorText(err)))
+	}
+	initialized = true
+	return nil
 }
 
 // Terminate releases PortAudio resources. Call once at program exit.
 func Terminate() {
-	C.Pa_Terminate()
+	initMu.Lock()
+	defer initMu.Unlock()
+	if initialized {
+		C.Pa_Terminate()
+		initialized = false
+	}
+}
+
+// RefreshDevices re-reads the device list, which PortAudio only builds in
+// Pa_Initialize, so that devices created since then can be found. No
+// stream may be open.
+func RefreshDevices() error {
+	initMu.Lock()
+	defer initMu.Unlock()
+	if initialized {
+		C.Pa_Terminate()
+		initialized = false
+	}
+	if err := C.Pa_Initialize(); err != C.paNoError {
+		return fmt.Errorf("portaudio init: %s", C.GoString(C.Pa_GetErrorText(err)))
+	}
+	initialized = true
+	return nil
 }
 
 // ListInputDevices returns all available audio input devices.
@@ -100,6 +130,7 @@ type Stream struct {
 	bufSize    int
 	mu         sync.Mutex
 	running    bool
+	overflows  atomic.Int64
 }
 
 // OpenStream opens a PortAudio input stream for the given device.
@@ -159,6 +190,11 @@ func (s *Stream) Start() error {
 func (s *Stream) Read(buf []float32) error {
 	frames := len(buf) / s.channels
 	err := C.Pa_ReadStream(s.stream, unsafe.Pointer(&buf[0]), C.ulong(frames))
+	if err == C.paInputOverflowed {
+		// Samples were dropped before this buffer, but buf holds valid data.
+		s.overflows.Add(1)
+		return nil
+	}
 	if err != C.paNoError {
 		return fmt.Errorf("read stream: %s", C.GoString(C.Pa_GetErrorText(err)))
 	}
@@ -199,6 +235,10 @@ func (s *Stream) Close() error {
 	return nil
 }
 
+// Overflows returns the number of input overflows (audio dropped because
+// Read was not called fast enough) since the stream was opened.
+func (s *Stream) Overflows() int64 { return s.overflows.Load() }
+
 // FramesPerBuffer returns the buffer size in frames.
 func (s *Stream) FramesPerBuffer() int { return s.bufSize }
 
@@ -0,0 +1,117 @@
+package audio
+
+// Resampler converts interleaved audio between sample rates by linear
+// interpolation. It keeps state across calls so consecutive buffers join
+// without discontinuities. Quality is adequate for speech; it is used only
+// when a mid-session device switch lands on a device with a different rate.
+type Resampler struct {
+	from, to int
+	channels int
+	step     float64   // input frames advanced per output frame
+	pos      float64   // read position relative to the first frame of the next input
+	last     []float32 // final frame of the previous input (position -1)
+	primed   bool
+	out      []float32
+}
+
+// NewResampler returns a resampler from one rate to another.
+func NewResampler(from, to, channels int) *Resampler {
+	if channels < 1 {
+		channels = 1
+	}
+	return &Resampler{
+		from:     from,
+		to:       to,
+		channels: channels,
+		step:     float64(from) / float64(to),
+		last:     make([]float32, channels),
+	}
+}
+
+// From returns the input sample rate.
+func (r *Resampler) From() int { return r.from }
+
+// To returns the output sample rate.
+func (r *Resampler) To() int { return r.to }
+
+// Channels returns the channel count.
+func (r *Resampler) Channels() int { return r.channels }
+
+// Process resamples an interleaved buffer. The returned slice is reused by
+// the next call.
+func (r *Resampler) Process(in []float32) []float32 {
+	ch := r.channels
+	n := len(in) / ch
+	if n == 0 {
+		return r.out[:0]
+	}
+	if r.from == r.to {
+		r.out = append(r.out[:0], in[:n*ch]...)
+		return r.out
+	}
+	if !r.primed {
+		// Start exactly on the first input frame.
+		copy(r.last, in[:ch])
+		r.pos = 0
+		r.primed = true
+	}
+
+	// frame returns input frame i, where -1 is the last frame of the
+	// previous call.
+	frame := func(i, c int) float32 {
+		if i < 0 {
+			return r.last[c]
+		}
+		return in[i*ch+c]
+	}
+
+	r.out = r.out[:0]
+	for r.pos <= float64(n-1) {
+		i := int(r.pos)
+		if r.pos < 0 {
+			i = -1
+		}
+		frac := float32(r.pos - float64(i))
+		for c := 0; c < ch; c++ {
+			a := frame(i, c)
+			b := a
+			if i+1 < n {
+				b = frame(i+1, c)
+			}
+			r.out = append(r.out, a+(b-a)*frac)
+		}
+		r.pos += r.step
+	}
+	copy(r.last, in[(n-1)*ch:n*ch])
+	r.pos -= float64(n)
+	return r.out
+}
+
+// Remix converts an interleaved buffer from inCh to outCh channels: mono is
+// duplicated to every output channel, a downmix to mono averages all input
+// channels, and other layouts keep the first outCh channels (repeating
+// input channels when upmixing). dst is grown as needed and returned.
+func Remix(dst, in []float32, inCh, outCh int) []float32 {
+	n := len(in) / inCh
+	if cap(dst) < n*outCh {
+		dst = make([]float32, n*outCh)
+	}
+	dst = dst[:n*outCh]
+	for f := 0; f < n; f++ {
+		src := in[f*inCh : (f+1)*inCh]
+		out := dst[f*outCh : (f+1)*outCh]
+		switch {
+		case outCh == 1:
+			var sum float32
+			for _, s := range src {
+				sum += s
+			}
+			out[0] = sum / float32(inCh)
+		default:
+			for c := range out {
+				out[c] = src[c%inCh]
+			}
+		}
+	}
+	return dst
+}
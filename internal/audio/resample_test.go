@@ -0,0 +1,72 @@
+package audio
+
+import (
+	"math"
+	"testing"
+)
+
+func TestResamplerLength(t *testing.T) {
+	tests := []struct{ from, to int }{
+		{48000, 44100},
+		{44100, 48000},
+		{16000, 48000},
+		{44100, 44100},
+	}
+	for _, tc := range tests {
+		r := NewResampler(tc.from, tc.to, 2)
+		in := make([]float32, tc.from/100*2)
+		total := 0
+		for i := 0; i < 100; i++ {
+			total += len(r.Process(in)) / 2
+		}
+		// One second of input must give one second of output, less the
+		// frames held back until the next input frame arrives.
+		if d := tc.to - total; d < 0 || d > tc.to/tc.from+1 {
+			t.Errorf("%d→%d: %d frames out, want %d", tc.from, tc.to, total, tc.to)
+		}
+	}
+}
+
+func TestResamplerContinuity(t *testing.T) {
+	// A ramp resampled in small buffers must stay a ramp across boundaries.
+	r := NewResampler(44100, 48000, 1)
+	in := make([]float32, 64)
+	var out []float32
+	next := 0
+	for b := 0; b < 20; b++ {
+		for i := range in {
+			in[i] = float32(next) / 44100
+			next++
+		}
+		out = append(out, r.Process(in)...)
+	}
+	step := 1.0 / 48000
+	for i := 1; i < len(out); i++ {
+		if d := float64(out[i] - out[i-1]); math.Abs(d-step) > 1e-6 {
+			t.Fatalf("frame %d: step %g, want %g", i, d, step)
+		}
+	}
+}
+
+func TestRemix(t *testing.T) {
+	stereo := []float32{0.2, 0.4, -0.6, 0.2}
+	if got := Remix(nil, stereo, 2, 1); !floatsEqual(got, []float32{0.3, -0.2}) {
+		t.Errorf("stereo→mono = %v", got)
+	}
+	mono := []float32{0.5, -0.5}
+	if got := Remix(nil, mono, 1, 2); !floatsEqual(got, []float32{0.5, 0.5, -0.5, -0.5}) {
+		t.Errorf("mono→stereo = %v", got)
+	}
+}
+
+func floatsEqual(a, b []float32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if math.Abs(float64(a[i]-b[i])) > 1e-6 {
+			return false
+		}
+	}
+	return true
+}
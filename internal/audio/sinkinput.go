@@ -0,0 +1,94 @@
+package audio
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SinkInput describes one PulseAudio/PipeWire playback stream (a "sink-input"),
+// as reported by `pactl -f json list sink-inputs`.
+type SinkInput struct {
+	Index   int
+	Sink    int
+	AppName string // application.name, e.g. "ZOOM VoiceEngine"
+	Binary  string // application.process.binary, e.g. "zoom"
+	AppID   string // application.id, e.g. "com.slack.Slack"
+	PID     int    // application.process.id (0 if unknown)
+	Media   string // media.name, e.g. "Playback" or a browser tab title
+}
+
+// pactlSinkInput mirrors the subset of the pactl JSON schema we rely on.
+// Numeric properties are reported as strings by pactl.
+type pactlSinkInput struct {
+	Index      int               `json:"index"`
+	Sink       int               `json:"sink"`
+	Properties map[string]string `json:"properties"`
+}
+
+// ParseSinkInputs decodes the JSON output of `pactl -f json list sink-inputs`.
+func ParseSinkInputs(data []byte) ([]SinkInput, error) {
+	var raw []pactlSinkInput
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parse sink-inputs: %w", err)
+	}
+	out := make([]SinkInput, 0, len(raw))
+	for _, r := range raw {
+		pid, _ := strconv.Atoi(r.Properties["application.process.id"])
+		out = append(out, SinkInput{
+			Index:   r.Index,
+			Sink:    r.Sink,
+			AppName: r.Properties["application.name"],
+			Binary:  r.Properties["application.process.binary"],
+			AppID:   r.Properties["application.id"],
+			PID:     pid,
+			Media:   r.Properties["media.name"],
+		})
+	}
+	return out, nil
+}
+
+// Matches reports whether the sink-input belongs to the given application.
+// app is compared case-insensitively as a substring of the application name,
+// process binary and application ID, so "zoom" matches both "ZOOM VoiceEngine"
+// and the "zoom" binary, and "teams" matches a browser stream titled
+// "Microsoft Teams".
+func (si SinkInput) Matches(app string) bool {
+	app = strings.ToLower(strings.TrimSpace(app))
+	if app == "" {
+		return false
+	}
+	for _, field := range []string{si.AppName, si.Binary, si.AppID, si.Media} {
+		if field != "" && strings.Contains(strings.ToLower(field), app) {
+			return true
+		}
+	}
+	return false
+}
+
+// AppMapping pairs a configured application with the sink-inputs that
+// currently belong to it. Used by `memofy doctor` to show the routing.
+type AppMapping struct {
+	App    string
+	Inputs []SinkInput
+}
+
+// MapSinkInputs groups sink-inputs by the first configured app they match.
+// Every configured app appears in the result, in order, even when nothing
+// matches it, so callers can report "not playing" apps.
+func MapSinkInputs(apps []string, inputs []SinkInput) []AppMapping {
+	mappings := make([]AppMapping, len(apps))
+	for i, app := range apps {
+		mappings[i].App = app
+	}
+	for _, si := range inputs {
+		for i, app := range apps {
+			if si.Matches(app) {
+				mappings[i].Inputs = append(mappings[i].Inputs, si)
+				break
+			}
+		}
+	}
+	return mappings
+}
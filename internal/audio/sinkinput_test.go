@@ -0,0 +1,86 @@
+package audio
+
+import "testing"
+
+// pactlSinkInputsJSON is trimmed output of `pactl -f json list sink-inputs`
+// on PipeWire 1.0 with Zoom, Firefox (Teams tab) and Spotify playing.
+const pactlSinkInputsJSON = `[
+  {"index":51,"driver":"PipeWire","owner_module":"4294967295","client":"88","sink":57,
+   "sample_specification":"s16le 2ch 48000Hz","corked":false,"mute":false,
+   "properties":{"application.name":"ZOOM VoiceEngine","application.process.id":"41210",
+                 "application.process.binary":"zoom","media.name":"playStream"}},
+  {"index":77,"driver":"PipeWire","owner_module":"4294967295","client":"93","sink":57,
+   "sample_specification":"float32le 2ch 48000Hz","corked":false,"mute":false,
+   "properties":{"application.name":"Firefox","application.process.id":"3381",
+                 "application.process.binary":"firefox","media.name":"Microsoft Teams"}},
+  {"index":80,"driver":"PipeWire","owner_module":"4294967295","client":"97","sink":57,
+   "sample_specification":"s16le 2ch 44100Hz","corked":false,"mute":false,
+   "properties":{"application.name":"spotify","application.process.id":"5120",
+                 "application.process.binary":"spotify","application.id":"com.spotify.Client",
+                 "media.name":"Spotify"}}
+]`
+
+func TestParseSinkInputs(t *testing.T) {
+	inputs, err := ParseSinkInputs([]byte(pactlSinkInputsJSON))
+	if err != nil {
+		t.Fatalf("ParseSinkInputs: %v", err)
+	}
+	if len(inputs) != 3 {
+		t.Fatalf("got %d sink-inputs, want 3", len(inputs))
+	}
+	zoom := inputs[0]
+	if zoom.Index != 51 || zoom.Sink != 57 {
+		t.Errorf("zoom index/sink = %d/%d, want 51/57", zoom.Index, zoom.Sink)
+	}
+	if zoom.AppName != "ZOOM VoiceEngine" || zoom.Binary != "zoom" || zoom.PID != 41210 {
+		t.Errorf("zoom props = %+v", zoom)
+	}
+	if inputs[2].AppID != "com.spotify.Client" {
+		t.Errorf("spotify app id = %q", inputs[2].AppID)
+	}
+}
+
+func TestParseSinkInputs_Invalid(t *testing.T) {
+	if _, err := ParseSinkInputs([]byte("Failed to connect")); err == nil {
+		t.Error("expected error for non-JSON pactl output")
+	}
+}
+
+func TestSinkInputMatches(t *testing.T) {
+	inputs, _ := ParseSinkInputs([]byte(pactlSinkInputsJSON))
+	tests := []struct {
+		idx  int
+		app  string
+		want bool
+	}{
+		{0, "zoom", true},
+		{0, "ZOOM", true},
+		{1, "teams", true}, // browser tab title
+		{1, "firefox", true},
+		{2, "zoom", false},
+		{2, "", false},
+		{2, "  ", false},
+	}
+	for _, tc := range tests {
+		if got := inputs[tc.idx].Matches(tc.app); got != tc.want {
+			t.Errorf("%s.Matches(%q) = %v, want %v", inputs[tc.idx].AppName, tc.app, got, tc.want)
+		}
+	}
+}
+
+func TestMapSinkInputs(t *testing.T) {
+	inputs, _ := ParseSinkInputs([]byte(pactlSinkInputsJSON))
+	m := MapSinkInputs([]string{"zoom", "teams", "slack"}, inputs)
+	if len(m) != 3 {
+		t.Fatalf("got %d mappings, want 3", len(m))
+	}
+	if len(m[0].Inputs) != 1 || m[0].Inputs[0].Index != 51 {
+		t.Errorf("zoom mapping = %+v", m[0].Inputs)
+	}
+	if len(m[1].Inputs) != 1 || m[1].Inputs[0].Index != 77 {
+		t.Errorf("teams mapping = %+v", m[1].Inputs)
+	}
+	if m[2].App != "slack" || len(m[2].Inputs) != 0 {
+		t.Errorf("slack mapping should be empty, got %+v", m[2])
+	}
+}
@@ -0,0 +1,474 @@
+// Package calendar reads iCalendar (.ics) files and answers which meeting
+// was taking place at a given time. It understands the subset of RFC 5545
+// that calendar exports use for meetings: VEVENT with DTSTART/DTEND or
+// DURATION, TZID parameters, RRULE, EXDATE and RECURRENCE-ID overrides.
+// VTIMEZONE definitions are not interpreted; TZID values must be IANA names
+// (otherwise local time is assumed).
+package calendar
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Occurrence is a single instance of an event.
+type Occurrence struct {
+	UID       string
+	Summary   string
+	Organizer string // common name, or the address when no CN is given
+	Attendees int
+	Start     time.Time
+	End       time.Time
+	AllDay    bool
+}
+
+// Boundary is a time at which at least one event starts or ends.
+type Boundary struct {
+	At     time.Time
+	Starts []Occurrence // events starting at At
+	Ends   []Occurrence // events ending at At
+}
+
+// Calendar is a set of events loaded from one or more files.
+type Calendar struct {
+	events []*event
+}
+
+// event is a parsed VEVENT. Recurring events expand into occurrences.
+type event struct {
+	uid        string
+	summary    string
+	organizer  string
+	attendees  int
+	start      time.Time
+	duration   time.Duration
+	allDay     bool
+	rrule      *rrule
+	exdates    map[int64]bool // unix seconds of excluded starts
+	recurrence time.Time      // RECURRENCE-ID: the instance this event replaces
+	overridden map[int64]bool // starts of instances replaced by overrides
+}
+
+// Load reads and merges the given .ics files.
+func Load(paths ...string) (*Calendar, error) {
+	c := &Calendar{}
+	for _, p := range paths {
+		f, err := os.Open(p)
+		if err != nil {
+			return nil, fmt.Errorf("open calendar: %w", err)
+		}
+		parsed, err := Parse(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: %w", p, err)
+		}
+		c.events = append(c.events, parsed.events...)
+	}
+	c.linkOverrides()
+	return c, nil
+}
+
+// Parse reads an iCalendar stream. Cancelled events are dropped.
+func Parse(r io.Reader) (*Calendar, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+	c := &Calendar{}
+	var cur *event
+	cancelled := false
+	depth := 0 // nesting inside the current VEVENT (VALARM etc.)
+	for n, line := range lines {
+		p, err := parseProperty(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n+1, err)
+		}
+		switch {
+		case p.name == "BEGIN" && strings.EqualFold(p.value, "VEVENT"):
+			cur, cancelled, depth = &event{}, false, 0
+			continue
+		case cur == nil:
+			continue
+		case p.name == "BEGIN":
+			depth++
+			continue
+		case p.name == "END" && depth > 0:
+			depth--
+			continue
+		case depth > 0:
+			continue
+		case p.name == "END" && strings.EqualFold(p.value, "VEVENT"):
+			if !cancelled && !cur.start.IsZero() {
+				c.events = append(c.events, cur)
+			}
+			cur = nil
+			continue
+		}
+		if err := cur.apply(p, &cancelled); err != nil {
+			return nil, fmt.Errorf("line %d: %s: %w", n+1, p.name, err)
+		}
+	}
+	c.linkOverrides()
+	return c, nil
+}
+
+// apply sets one VEVENT property.
+func (ev *event) apply(p property, cancelled *bool) error {
+	var err error
+	switch p.name {
+	case "UID":
+		ev.uid = p.value
+	case "SUMMARY":
+		ev.summary = unescapeText(p.value)
+	case "ORGANIZER":
+		ev.organizer = contactName(p)
+	case "ATTENDEE":
+		ev.attendees++
+	case "STATUS":
+		*cancelled = strings.EqualFold(p.value, "CANCELLED")
+	case "DTSTART":
+		ev.start, ev.allDay, err = parseTime(p)
+	case "DTEND":
+		var end time.Time
+		if end, _, err = parseTime(p); err == nil {
+			ev.duration = end.Sub(ev.start)
+		}
+	case "DURATION":
+		ev.duration, err = parseDuration(p.value)
+	case "RRULE":
+		ev.rrule, err = parseRRule(p.value)
+	case "EXDATE":
+		for _, v := range strings.Split(p.value, ",") {
+			var t time.Time
+			if t, _, err = parseTime(property{name: p.name, params: p.params, value: v}); err != nil {
+				return err
+			}
+			if ev.exdates == nil {
+				ev.exdates = make(map[int64]bool)
+			}
+			ev.exdates[t.Unix()] = true
+		}
+	case "RECURRENCE-ID":
+		ev.recurrence, _, err = parseTime(p)
+	}
+	return err
+}
+
+// linkOverrides marks recurring instances replaced by RECURRENCE-ID events.
+func (c *Calendar) linkOverrides() {
+	masters := make(map[string]*event)
+	for _, ev := range c.events {
+		if ev.rrule != nil && ev.recurrence.IsZero() {
+			masters[ev.uid] = ev
+		}
+	}
+	for _, ev := range c.events {
+		if ev.recurrence.IsZero() {
+			continue
+		}
+		if m := masters[ev.uid]; m != nil {
+			if m.overridden == nil {
+				m.overridden = make(map[int64]bool)
+			}
+			m.overridden[ev.recurrence.Unix()] = true
+		}
+	}
+}
+
+// Occurrences returns the occurrences overlapping [from, to), sorted by
+// start time.
+func (c *Calendar) Occurrences(from, to time.Time) []Occurrence {
+	if c == nil {
+		return nil
+	}
+	var out []Occurrence
+	for _, ev := range c.events {
+		ev.expand(from, to, func(start time.Time) {
+			out = append(out, Occurrence{
+				UID:       ev.uid,
+				Summary:   ev.summary,
+				Organizer: ev.organizer,
+				Attendees: ev.attendees,
+				Start:     start,
+				End:       start.Add(ev.length()),
+				AllDay:    ev.allDay,
+			})
+		})
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Start.Before(out[j].Start) })
+	return out
+}
+
+// length returns the event duration, applying the RFC 5545 defaults when
+// neither DTEND nor DURATION was given.
+func (ev *event) length() time.Duration {
+	if ev.duration > 0 {
+		return ev.duration
+	}
+	if ev.allDay {
+		return 24 * time.Hour
+	}
+	return 0
+}
+
+// expand calls fn with the start of every instance overlapping [from, to).
+func (ev *event) expand(from, to time.Time, fn func(time.Time)) {
+	length := ev.length()
+	overlaps := func(s time.Time) bool {
+		return s.Before(to) && (s.Add(length).After(from) || (length == 0 && !s.Before(from)))
+	}
+	if ev.rrule == nil {
+		if overlaps(ev.start) {
+			fn(ev.start)
+		}
+		return
+	}
+	ev.rrule.each(ev.start, func(s time.Time) bool {
+		if !s.Before(to) {
+			return false
+		}
+		if !ev.exdates[s.Unix()] && !ev.overridden[s.Unix()] && overlaps(s) {
+			fn(s)
+		}
+		return true
+	})
+}
+
+// Best returns the timed (not all-day) occurrence that overlaps [start, end)
+// the most, and false when none does.
+func (c *Calendar) Best(start, end time.Time) (Occurrence, bool) {
+	var best Occurrence
+	var bestOverlap time.Duration = -1
+	for _, o := range c.Occurrences(start, end) {
+		if o.AllDay {
+			continue
+		}
+		s, e := o.Start, o.End
+		if s.Before(start) {
+			s = start
+		}
+		if e.After(end) {
+			e = end
+		}
+		if overlap := e.Sub(s); overlap > bestOverlap {
+			best, bestOverlap = o, overlap
+		}
+	}
+	return best, bestOverlap >= 0
+}
+
+// NextBoundary returns the first start or end of a timed occurrence after t,
+// looking up to a week ahead.
+func (c *Calendar) NextBoundary(t time.Time) (Boundary, bool) {
+	var b Boundary
+	for _, o := range c.Occurrences(t, t.Add(7*24*time.Hour)) {
+		if o.AllDay {
+			continue
+		}
+		for _, at := range []time.Time{o.Start, o.End} {
+			if !at.After(t) || (!b.At.IsZero() && at.After(b.At)) {
+				continue
+			}
+			if !at.Equal(b.At) {
+				b = Boundary{At: at}
+			}
+			if at.Equal(o.Start) {
+				b.Starts = append(b.Starts, o)
+			} else {
+				b.Ends = append(b.Ends, o)
+			}
+		}
+	}
+	return b, !b.At.IsZero()
+}
+
+// unfold reads content lines, joining continuation lines (those starting
+// with a space or tab) onto the previous line.
+func unfold(r io.Reader) ([]string, error) {
+	var lines []string
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r")
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read calendar: %w", err)
+	}
+	return lines, nil
+}
+
+// property is one content line: NAME;PARAM=VALUE;...:value
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+func parseProperty(line string) (property, error) {
+	// The value starts at the first colon outside a quoted parameter value.
+	inQuote := false
+	colon := -1
+	for i, r := range line {
+		if r == '"' {
+			inQuote = !inQuote
+		} else if r == ':' && !inQuote {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return property{}, fmt.Errorf("malformed content line %q", line)
+	}
+	p := property{value: line[colon+1:], params: map[string]string{}}
+	parts := splitParams(line[:colon])
+	p.name = strings.ToUpper(parts[0])
+	for _, kv := range parts[1:] {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			p.params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+		}
+	}
+	return p, nil
+}
+
+// splitParams splits "NAME;A=1;B="x;y"" on semicolons outside quotes.
+func splitParams(s string) []string {
+	var out []string
+	inQuote := false
+	last := 0
+	for i, r := range s {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+		case r == ';' && !inQuote:
+			out = append(out, s[last:i])
+			last = i + 1
+		}
+	}
+	return append(out, s[last:])
+}
+
+// parseTime parses a DATE or DATE-TIME value, honouring TZID and the UTC
+// "Z" suffix. Floating times and unknown TZIDs use local time.
+func parseTime(p property) (time.Time, bool, error) {
+	v := strings.TrimSpace(p.value)
+	loc := time.Local
+	if tzid := p.params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+	if p.params["VALUE"] == "DATE" || len(v) == 8 {
+		t, err := time.ParseInLocation("20060102", v, loc)
+		if err != nil {
+			return t, true, fmt.Errorf("invalid date %q", v)
+		}
+		return t, true, nil
+	}
+	if strings.HasSuffix(v, "Z") {
+		t, err := time.Parse("20060102T150405Z", v)
+		if err != nil {
+			return t, false, fmt.Errorf("invalid date-time %q", v)
+		}
+		return t, false, nil
+	}
+	t, err := time.ParseInLocation("20060102T150405", v, loc)
+	if err != nil {
+		return t, false, fmt.Errorf("invalid date-time %q", v)
+	}
+	return t, false, nil
+}
+
+// parseDuration parses an RFC 5545 duration such as PT1H30M or P1D.
+func parseDuration(s string) (time.Duration, error) {
+	orig := s
+	neg := false
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		neg = s[0] == '-'
+		s = s[1:]
+	}
+	if !strings.HasPrefix(s, "P") || len(s) < 3 {
+		return 0, fmt.Errorf("invalid duration %q", orig)
+	}
+	s = s[1:]
+	var d time.Duration
+	inTime := false
+	n := 0
+	digits := false
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			n = n*10 + int(r-'0')
+			digits = true
+			continue
+		case r == 'T':
+			inTime = true
+			continue
+		}
+		if !digits {
+			return 0, fmt.Errorf("invalid duration %q", orig)
+		}
+		unit := map[rune]time.Duration{'W': 7 * 24 * time.Hour, 'D': 24 * time.Hour}
+		if inTime {
+			unit = map[rune]time.Duration{'H': time.Hour, 'M': time.Minute, 'S': time.Second}
+		}
+		u, ok := unit[r]
+		if !ok {
+			return 0, fmt.Errorf("invalid duration %q", orig)
+		}
+		d += time.Duration(n) * u
+		n, digits = 0, false
+	}
+	if digits {
+		return 0, fmt.Errorf("invalid duration %q", orig)
+	}
+	if neg {
+		d = -d
+	}
+	return d, nil
+}
+
+// contactName returns the CN parameter of an ORGANIZER, or its address.
+func contactName(p property) string {
+	if cn := p.params["CN"]; cn != "" {
+		return cn
+	}
+	v := p.value
+	if len(v) > 7 && strings.EqualFold(v[:7], "mailto:") {
+		v = v[7:]
+	}
+	return v
+}
+
+// unescapeText reverses RFC 5545 TEXT escaping.
+func unescapeText(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			switch s[i] {
+			case 'n', 'N':
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(s[i])
+			}
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
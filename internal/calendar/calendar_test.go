@@ -0,0 +1,142 @@
+package calendar
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func utc(s string) time.Time {
+	t, err := time.Parse("2006-01-02 15:04", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func loadFixture(t *testing.T) *Calendar {
+	t.Helper()
+	c, err := Load("testdata/work.ics")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	return c
+}
+
+func TestOccurrences(t *testing.T) {
+	c := loadFixture(t)
+	got := c.Occurrences(utc("2026-03-02 00:00"), utc("2026-03-09 00:00"))
+	want := []struct {
+		summary string
+		start   time.Time
+		end     time.Time
+	}{
+		{"Daily standup, team A", utc("2026-03-02 08:30"), utc("2026-03-02 08:45")}, // Berlin is UTC+1
+		{"Customer call", utc("2026-03-03 15:00"), utc("2026-03-03 16:00")},
+		// Wednesday's standup is excluded (EXDATE).
+		{"Public holiday", time.Date(2026, 3, 5, 0, 0, 0, 0, time.Local), time.Date(2026, 3, 6, 0, 0, 0, 0, time.Local)},
+		{"Standup (moved)", utc("2026-03-06 13:00"), utc("2026-03-06 13:30")}, // replaces Friday's instance
+	}
+	if len(got) != len(want) {
+		for _, o := range got {
+			t.Logf("  %s %s–%s", o.Summary, o.Start.UTC(), o.End.UTC())
+		}
+		t.Fatalf("got %d occurrences, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		o := got[i]
+		if o.Summary != w.summary || !o.Start.Equal(w.start) || !o.End.Equal(w.end) {
+			t.Errorf("occurrence %d = %q %s–%s, want %q %s–%s",
+				i, o.Summary, o.Start.UTC(), o.End.UTC(), w.summary, w.start.UTC(), w.end.UTC())
+		}
+	}
+	standup := got[0]
+	if standup.Organizer != "Doe, Jane" || standup.Attendees != 3 || standup.UID != "standup-1@example.com" {
+		t.Errorf("standup details = %q/%d/%q", standup.Organizer, standup.Attendees, standup.UID)
+	}
+	if !got[2].AllDay {
+		t.Error("holiday should be all-day")
+	}
+	if got[3].Organizer != "jane@example.com" {
+		t.Errorf("organizer without CN = %q, want the address", got[3].Organizer)
+	}
+}
+
+func TestOccurrencesAcrossDST(t *testing.T) {
+	c := loadFixture(t)
+	// Berlin switches to summer time on 2026-03-29; the standup stays at
+	// 09:30 local time, which is now 07:30 UTC. UNTIL ends the series
+	// after Tuesday the 31st.
+	got := c.Occurrences(utc("2026-03-30 00:00"), utc("2026-04-06 00:00"))
+	if len(got) != 1 || !got[0].Start.Equal(utc("2026-03-30 07:30")) {
+		t.Fatalf("got %+v, want a single standup at 07:30 UTC on 2026-03-30", got)
+	}
+}
+
+func TestMonthlyLastFriday(t *testing.T) {
+	c := loadFixture(t)
+	var starts []string
+	for _, o := range c.Occurrences(utc("2026-01-01 00:00"), utc("2026-12-31 00:00")) {
+		if o.Summary == "Monthly review" {
+			starts = append(starts, o.Start.Format("2006-01-02"))
+		}
+	}
+	if got, want := strings.Join(starts, " "), "2026-01-30 2026-02-27 2026-03-27"; got != want {
+		t.Errorf("monthly review on %s, want %s (COUNT=3)", got, want)
+	}
+}
+
+func TestBest(t *testing.T) {
+	c := loadFixture(t)
+	o, ok := c.Best(utc("2026-03-03 14:55"), utc("2026-03-03 16:10"))
+	if !ok || o.Summary != "Customer call" || o.Attendees != 2 {
+		t.Errorf("Best = %+v, %v; want the customer call", o, ok)
+	}
+	// Only the all-day holiday overlaps: no match.
+	if o, ok := c.Best(utc("2026-03-05 10:00"), utc("2026-03-05 11:00")); ok {
+		t.Errorf("Best during the holiday = %q, want none", o.Summary)
+	}
+}
+
+func TestNextBoundary(t *testing.T) {
+	c := loadFixture(t)
+	b, ok := c.NextBoundary(utc("2026-03-02 08:00"))
+	if !ok || !b.At.Equal(utc("2026-03-02 08:30")) || len(b.Starts) != 1 || len(b.Ends) != 0 {
+		t.Fatalf("NextBoundary = %+v, %v; want the standup start", b, ok)
+	}
+	b, ok = c.NextBoundary(b.At)
+	if !ok || !b.At.Equal(utc("2026-03-02 08:45")) || len(b.Ends) != 1 {
+		t.Fatalf("NextBoundary after start = %+v, %v; want the standup end", b, ok)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	bad := []string{
+		"BEGIN:VEVENT\nDTSTART:2026-03-02\nEND:VEVENT",
+		"BEGIN:VEVENT\nDTSTART:20260302T100000Z\nRRULE:FREQ=HOURLY\nEND:VEVENT",
+		"BEGIN:VEVENT\nDTSTART:20260302T100000Z\nDURATION:1H\nEND:VEVENT",
+		"BEGIN:VEVENT\nno colon here\nEND:VEVENT",
+	}
+	for _, s := range bad {
+		if _, err := Parse(strings.NewReader(s)); err == nil {
+			t.Errorf("Parse(%q): expected error", s)
+		}
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := map[string]time.Duration{
+		"PT15M":     15 * time.Minute,
+		"PT1H30M":   90 * time.Minute,
+		"P1D":       24 * time.Hour,
+		"P1W":       7 * 24 * time.Hour,
+		"P1DT2H":    26 * time.Hour,
+		"-PT5M":     -5 * time.Minute,
+		"PT1H0M10S": time.Hour + 10*time.Second,
+	}
+	for in, want := range tests {
+		if got, err := parseDuration(in); err != nil || got != want {
+			t.Errorf("parseDuration(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+}
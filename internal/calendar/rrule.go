@@ -0,0 +1,258 @@
+package calendar
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rrule is a parsed recurrence rule. Supported parts: FREQ (DAILY, WEEKLY,
+// MONTHLY, YEARLY), INTERVAL, COUNT, UNTIL, BYDAY (with ordinals for
+// MONTHLY and YEARLY, e.g. 2TU or -1FR), BYMONTHDAY and BYMONTH. Other
+// parts (BYSETPOS, WKST, ...) are ignored; weeks start on Monday.
+type rrule struct {
+	freq       string
+	interval   int
+	count      int
+	until      time.Time
+	byDay      []weekdayNum
+	byMonthDay []int
+	byMonth    []time.Month
+}
+
+// weekdayNum is a BYDAY entry: every such weekday (n == 0), or the nth one
+// in the month, counting from the end when negative.
+type weekdayNum struct {
+	n   int
+	day time.Weekday
+}
+
+var weekdayCodes = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// maxPeriods bounds expansion of rules that never produce an occurrence.
+const maxPeriods = 50000
+
+func parseRRule(s string) (*rrule, error) {
+	r := &rrule{interval: 1}
+	for _, part := range strings.Split(s, ";") {
+		k, v, ok := strings.Cut(part, "=")
+		if !ok {
+			continue
+		}
+		var err error
+		switch strings.ToUpper(k) {
+		case "FREQ":
+			r.freq = strings.ToUpper(v)
+		case "INTERVAL":
+			r.interval, err = strconv.Atoi(v)
+			if err == nil && r.interval < 1 {
+				err = fmt.Errorf("must be >= 1")
+			}
+		case "COUNT":
+			r.count, err = strconv.Atoi(v)
+		case "UNTIL":
+			r.until, _, err = parseTime(property{value: v, params: map[string]string{}})
+			if err == nil && len(v) == 8 {
+				// A date UNTIL includes that whole day.
+				r.until = r.until.Add(24*time.Hour - time.Second)
+			}
+		case "BYDAY":
+			for _, d := range strings.Split(v, ",") {
+				var wn weekdayNum
+				if wn, err = parseWeekdayNum(d); err != nil {
+					break
+				}
+				r.byDay = append(r.byDay, wn)
+			}
+		case "BYMONTHDAY":
+			for _, d := range strings.Split(v, ",") {
+				var n int
+				if n, err = strconv.Atoi(d); err != nil || n == 0 || n < -31 || n > 31 {
+					err = fmt.Errorf("invalid day %q", d)
+					break
+				}
+				r.byMonthDay = append(r.byMonthDay, n)
+			}
+		case "BYMONTH":
+			for _, m := range strings.Split(v, ",") {
+				var n int
+				if n, err = strconv.Atoi(m); err != nil || n < 1 || n > 12 {
+					err = fmt.Errorf("invalid month %q", m)
+					break
+				}
+				r.byMonth = append(r.byMonth, time.Month(n))
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("RRULE %s: %w", k, err)
+		}
+	}
+	switch r.freq {
+	case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+	default:
+		return nil, fmt.Errorf("RRULE: unsupported FREQ %q", r.freq)
+	}
+	return r, nil
+}
+
+func parseWeekdayNum(s string) (weekdayNum, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if len(s) < 2 {
+		return weekdayNum{}, fmt.Errorf("invalid day %q", s)
+	}
+	day, ok := weekdayCodes[s[len(s)-2:]]
+	if !ok {
+		return weekdayNum{}, fmt.Errorf("invalid day %q", s)
+	}
+	wn := weekdayNum{day: day}
+	if prefix := s[:len(s)-2]; prefix != "" {
+		n, err := strconv.Atoi(prefix)
+		if err != nil || n == 0 {
+			return weekdayNum{}, fmt.Errorf("invalid day %q", s)
+		}
+		wn.n = n
+	}
+	return wn, nil
+}
+
+// each calls fn with the start of every instance in order, beginning with
+// dtstart, until fn returns false or COUNT or UNTIL is reached.
+func (r *rrule) each(dtstart time.Time, fn func(time.Time) bool) {
+	loc := dtstart.Location()
+	y, mo, d := dtstart.Date()
+	hh, mm, ss := dtstart.Clock()
+	at := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, hh, mm, ss, 0, loc)
+	}
+	emitted := 0
+	for k := 0; k < maxPeriods; k++ {
+		var days []time.Time
+		switch r.freq {
+		case "DAILY":
+			t := at(y, mo, d+k*r.interval)
+			if r.monthMatches(t.Month()) && r.weekdayMatches(t.Weekday()) {
+				days = append(days, t)
+			}
+		case "WEEKLY":
+			// Monday of dtstart's week, then k*interval weeks on.
+			monday := d - (int(dtstart.Weekday())+6)%7 + 7*k*r.interval
+			weekdays := r.byDay
+			if len(weekdays) == 0 {
+				weekdays = []weekdayNum{{day: dtstart.Weekday()}}
+			}
+			for _, wd := range weekdays {
+				t := at(y, mo, monday+(int(wd.day)+6)%7)
+				if r.monthMatches(t.Month()) {
+					days = append(days, t)
+				}
+			}
+		case "MONTHLY":
+			first := time.Date(y, mo+time.Month(k*r.interval), 1, 0, 0, 0, 0, loc)
+			if r.monthMatches(first.Month()) {
+				days = r.daysInMonth(first.Year(), first.Month(), d, at)
+			}
+		case "YEARLY":
+			year := y + k*r.interval
+			months := r.byMonth
+			if len(months) == 0 {
+				months = []time.Month{mo}
+			}
+			for _, m := range months {
+				days = append(days, r.daysInMonth(year, m, d, at)...)
+			}
+		}
+		sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+		for _, t := range days {
+			if t.Before(dtstart) {
+				continue
+			}
+			if !r.until.IsZero() && t.After(r.until) {
+				return
+			}
+			if r.count > 0 && emitted >= r.count {
+				return
+			}
+			emitted++
+			if !fn(t) {
+				return
+			}
+		}
+	}
+}
+
+func (r *rrule) monthMatches(m time.Month) bool {
+	if len(r.byMonth) == 0 {
+		return true
+	}
+	for _, bm := range r.byMonth {
+		if bm == m {
+			return true
+		}
+	}
+	return false
+}
+
+// weekdayMatches applies BYDAY as a filter (DAILY rules).
+func (r *rrule) weekdayMatches(wd time.Weekday) bool {
+	if len(r.byDay) == 0 {
+		return true
+	}
+	for _, bd := range r.byDay {
+		if bd.day == wd {
+			return true
+		}
+	}
+	return false
+}
+
+// daysInMonth returns the instances in one month for MONTHLY and YEARLY
+// rules: BYMONTHDAY and BYDAY (intersected when both are given), or the
+// day of month of DTSTART when neither is.
+func (r *rrule) daysInMonth(year int, month time.Month, dtDay int, at func(int, time.Month, int) time.Time) []time.Time {
+	n := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	match := make([]bool, n+1)
+	switch {
+	case len(r.byMonthDay) == 0 && len(r.byDay) == 0:
+		if dtDay <= n {
+			match[dtDay] = true
+		}
+	default:
+		for d := 1; d <= n; d++ {
+			match[d] = (len(r.byMonthDay) == 0 || r.monthDayMatches(d, n)) &&
+				(len(r.byDay) == 0 || r.nthWeekdayMatches(year, month, d, n))
+		}
+	}
+	var out []time.Time
+	for d := 1; d <= n; d++ {
+		if match[d] {
+			out = append(out, at(year, month, d))
+		}
+	}
+	return out
+}
+
+func (r *rrule) monthDayMatches(d, daysInMonth int) bool {
+	for _, md := range r.byMonthDay {
+		if md == d || (md < 0 && daysInMonth+md+1 == d) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *rrule) nthWeekdayMatches(year int, month time.Month, d, daysInMonth int) bool {
+	wd := time.Date(year, month, d, 0, 0, 0, 0, time.UTC).Weekday()
+	nth := (d-1)/7 + 1                  // 1 for the first such weekday of the month
+	fromEnd := -((daysInMonth-d)/7 + 1) // -1 for the last
+	for _, bd := range r.byDay {
+		if bd.day == wd && (bd.n == 0 || bd.n == nth || bd.n == fromEnd) {
+			return true
+		}
+	}
+	return false
+}
@@ -5,8 +5,15 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"time"
 
+	"github.com/tiroq/memofy/internal/encrypt"
+	"github.com/tiroq/memofy/internal/monitor"
+	"github.com/tiroq/memofy/internal/recpath"
+	"github.com/tiroq/memofy/internal/schedule"
+	"github.com/tiroq/memofy/internal/trigger"
 	"gopkg.in/yaml.v3"
 )
 
@@ -19,8 +26,67 @@ type Config struct {
 	Logging    LoggingConfig    `yaml:"logging"`
 	Platform   PlatformConfig   `yaml:"platform"`
 	UI         UIConfig         `yaml:"ui"`
+	Schedule   schedule.Config  `yaml:"schedule"`
+	Calendar   CalendarConfig   `yaml:"calendar"`
+	// Trigger decides when sessions start and stop; see package trigger.
+	// Empty by default, for audio plus the mic (see MicSessionLock).
+	Trigger trigger.Spec `yaml:"trigger"`
+	// AppProfiles override recording settings per detected app. The first
+	// profile matching an app detected when a session starts applies.
+	AppProfiles []AppProfile `yaml:"app_profiles"`
+	// Retention deletes old recordings; see package retention.
+	Retention RetentionConfig `yaml:"retention"`
+	// Encryption encrypts finalized recordings and sidecars; see package
+	// encrypt.
+	Encryption EncryptionConfig `yaml:"encryption"`
+	// Upload copies finalized recordings to remote storage; see package
+	// upload.
+	Upload UploadConfig `yaml:"upload"`
 }
 
+// AppProfile overrides recording settings for sessions that start while
+// one of Apps is detected. A profile without Apps is the fallback for
+// sessions no other profile matches (ad-hoc audio). Zero fields keep the
+// audio and output settings.
+type AppProfile struct {
+	Name           string   `yaml:"name"`
+	Apps           []string `yaml:"apps"` // monitoring.apps names or bundle IDs using the mic
+	FormatProfile  string   `yaml:"format_profile"`
+	Threshold      float64  `yaml:"threshold"` // from the session's start on; audio.threshold starts it
+	ExitThreshold  float64  `yaml:"exit_threshold"`
+	SilenceSeconds int      `yaml:"silence_seconds"`
+	Subdir         string   `yaml:"subdir"` // relative to output.dir
+	Tag            string   `yaml:"tag"`    // appended to the file name
+}
+
+// MatchAppProfile returns the profile for a session starting while apps
+// (app names and mic bundle IDs, see monitor.Snapshot.RunningApps) are
+// detected, and the app that matched. Without a match it returns the
+// fallback profile, if any, with an empty app.
+func (c *Config) MatchAppProfile(apps []string) (*AppProfile, string) {
+	var fallback *AppProfile
+	for i := range c.AppProfiles {
+		p := &c.AppProfiles[i]
+		if len(p.Apps) == 0 {
+			if fallback == nil {
+				fallback = p
+			}
+			continue
+		}
+		for _, want := range p.Apps {
+			for _, app := range apps {
+				if strings.EqualFold(app, want) {
+					return p, app
+				}
+			}
+		}
+	}
+	return fallback, ""
+}
+
+// validTag matches file name tags: letters, digits, '-' and '_'.
+var validTag = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
 // AudioConfig controls audio capture and silence detection.
 type AudioConfig struct {
 	Device              string  `yaml:"device"`                // "auto" or device name substring
@@ -36,6 +102,16 @@ type AudioConfig struct {
 	SampleRate          int     `yaml:"sample_rate"`           // capture sample rate (default 44100)
 	Channels            int     `yaml:"channels"`              // capture channels (default 2)
 	FormatProfile       string  `yaml:"format_profile"`        // high, balanced, lightweight, wav
+	SampleFormat        string  `yaml:"sample_format"`         // WAV sample encoding: s16 (default), s24, f32
+	Dither              bool    `yaml:"dither"`                // TPDF dither when writing 16-bit samples
+	// AutoMono records only the live channel when the capture has a dead
+	// channel or identical channels (fake stereo), halving the file size.
+	AutoMono bool `yaml:"auto_mono"`
+	// GapToleranceMs is how far written audio may fall behind the wall
+	// clock during a recording before the difference is filled with
+	// silence (after read errors or device switches). 0 disables
+	// gap filling.
+	GapToleranceMs int `yaml:"gap_tolerance_ms"`
 }
 
 // SessionConfig controls recording session behavior.
@@ -43,6 +119,25 @@ type SessionConfig struct {
 	MinSessionSeconds               int  `yaml:"min_session_seconds"`
 	DiscardShortSessions            bool `yaml:"discard_short_sessions"`
 	KeepSingleSessionWhileMicActive bool `yaml:"keep_single_session_while_mic_active"`
+	// MaxDuration and MaxSizeMB bound a single file. When either is reached
+	// the recording continues seamlessly in a new part. 0 means unlimited.
+	MaxDuration time.Duration `yaml:"max_duration"` // e.g. "2h"
+	MaxSizeMB   int           `yaml:"max_size_mb"`  // WAV data size per part
+	// A session is flagged degraded in its sidecar when more than
+	// DegradedClipRatio of its samples clipped, or it saw more than
+	// DegradedOverflows input overflows (dropped audio).
+	DegradedClipRatio float64 `yaml:"degraded_clip_ratio"`
+	DegradedOverflows int     `yaml:"degraded_overflows"`
+	// Silences inside a session longer than CompactSilenceSeconds are cut
+	// down to CompactGapSeconds when the recording is finalized; the
+	// sidecar keeps an edit list mapping file time to real time. 0 disables
+	// compaction.
+	CompactSilenceSeconds int `yaml:"compact_silence_seconds"`
+	CompactGapSeconds     int `yaml:"compact_gap_seconds"`
+	// MergeGapSeconds keeps a session ended by silence open this long: if
+	// a new session starts within the window it is appended to the same
+	// file, and conversion waits until the window closes. 0 disables.
+	MergeGapSeconds int `yaml:"merge_gap_seconds"`
 }
 
 // OutputConfig controls where recordings are saved.
@@ -50,6 +145,21 @@ type OutputConfig struct {
 	Dir               string `yaml:"dir"`       // output directory, supports ~ expansion
 	Directory         string `yaml:"directory"` // alias for dir
 	WriteMetadataJSON bool   `yaml:"write_metadata_json"`
+	// CheckpointSeconds is how often the WAV header is rewritten and the file
+	// fsynced while recording, bounding the audio lost on a crash. 0 disables.
+	CheckpointSeconds int `yaml:"checkpoint_seconds"`
+	// Free space thresholds of the output volume in MB; 0 (the default)
+	// disables one. Below LowDiskMB memofy warns and, if LowDiskProfile is
+	// set, records new sessions with it; below CriticalDiskMB the session
+	// is finalized and recording pauses until space recovers.
+	LowDiskMB      int64  `yaml:"low_disk_mb"`
+	CriticalDiskMB int64  `yaml:"critical_disk_mb"`
+	LowDiskProfile string `yaml:"low_disk_profile"` // empty keeps the format
+	// FilenameTemplate names new recordings and DirTemplate places them
+	// below the output directory (and an app profile's subdir), e.g.
+	// "{date}_{title}" and "{year}/{month}". See recpath for the fields.
+	FilenameTemplate string `yaml:"filename_template"`
+	DirTemplate      string `yaml:"dir_template"`
 }
 
 // MonitoringConfig controls meeting app detection.
@@ -60,11 +170,28 @@ type MonitoringConfig struct {
 	KeepSingleSessionWhileMicActive bool `yaml:"keep_single_session_while_mic_active"`
 	PollIntervalMs                  int  `yaml:"poll_interval_ms"`
 	// MicSessionLock holds the current recording session open while the
-	// microphone is in active use, even if BlackHole is silent.
+	// microphone is in active use, even if BlackHole is silent. Without a
+	// trigger policy it also lets the mic start a session.
 	MicSessionLock bool `yaml:"mic_session_lock"`
 	// MicReleaseSeconds is the debounce period after mic goes inactive before
 	// the session lock is released. Prevents split jitter when mic briefly toggles.
 	MicReleaseSeconds int `yaml:"mic_release_seconds"`
+	// Apps are the meeting apps to detect. Listing apps replaces the
+	// defaults (Zoom, Teams and Google Meet).
+	Apps []monitor.AppRule `yaml:"apps"`
+}
+
+// AppRules returns the app rules to monitor: Apps without "zoom" when
+// detect_zoom is off and without "teams" when detect_teams is off.
+func (m MonitoringConfig) AppRules() []monitor.AppRule {
+	var rules []monitor.AppRule
+	for _, r := range m.Apps {
+		if (r.Name == "zoom" && !m.DetectZoom) || (r.Name == "teams" && !m.DetectTeams) {
+			continue
+		}
+		rules = append(rules, r)
+	}
+	return rules
 }
 
 // LoggingConfig controls log file output.
@@ -77,6 +204,120 @@ type LoggingConfig struct {
 type PlatformConfig struct {
 	MacOSDevice string `yaml:"macos_device"` // e.g. "BlackHole"
 	LinuxDevice string `yaml:"linux_device"` // e.g. "default" or "monitor"
+	// LinuxCaptureApps restricts Linux capture to the playback streams of these
+	// applications (matched by name or binary, e.g. "zoom", "teams", "slack")
+	// instead of the whole monitor source. Empty records all desktop audio.
+	LinuxCaptureApps []string `yaml:"linux_capture_apps"`
+}
+
+// CalendarConfig controls ICS calendar integration. Recordings are tagged
+// with the calendar event they overlapped most.
+type CalendarConfig struct {
+	Files         []string `yaml:"files"`          // local .ics files or cached exports, supports ~ expansion
+	ReloadMinutes int      `yaml:"reload_minutes"` // how often the files are re-read
+	// AutoStart force-starts recording when an event begins.
+	AutoStart bool `yaml:"auto_start"`
+	// SplitAtBoundaries ends the current session and starts a new one when
+	// an event starts or ends.
+	SplitAtBoundaries bool `yaml:"split_at_boundaries"`
+}
+
+// RetentionConfig controls deletion of old recordings. Zero limits are
+// off. Starred and tagged recordings are always kept.
+type RetentionConfig struct {
+	// Enabled prunes from `memofy run` every IntervalMinutes; `memofy
+	// prune` applies the rules either way.
+	Enabled         bool               `yaml:"enabled"`
+	IntervalMinutes int                `yaml:"interval_minutes"`
+	MaxAgeDays      int                `yaml:"max_age_days"`
+	Profiles        []RetentionProfile `yaml:"profiles"`     // age limits per profile
+	MaxTotalMB      int64              `yaml:"max_total_mb"` // size of all recordings
+	KeepRecent      int                `yaml:"keep_recent"`  // most recent recordings never deleted
+}
+
+// RetentionProfile sets the age limit of recordings made with an app
+// profile (app_profiles name) or, failing that, a format profile.
+type RetentionProfile struct {
+	Profile    string `yaml:"profile"`
+	MaxAgeDays int    `yaml:"max_age_days"` // 0 keeps them forever
+}
+
+// Interval returns how often `memofy run` prunes.
+func (r RetentionConfig) Interval() time.Duration {
+	if r.IntervalMinutes <= 0 {
+		return time.Hour
+	}
+	return time.Duration(r.IntervalMinutes) * time.Minute
+}
+
+// EncryptionConfig encrypts recordings at rest to public keys. The daemon
+// never needs the private key: `memofy keygen` creates a key pair and
+// `memofy decrypt` opens the files.
+type EncryptionConfig struct {
+	Enabled    bool     `yaml:"enabled"`
+	Recipients []string `yaml:"recipients"` // memofy-pub1-... public keys
+	// StagingDir holds the WAV while it is recorded and converted, so it
+	// should be a tmpfs or RAM disk. Empty uses $XDG_RUNTIME_DIR/memofy.
+	StagingDir string `yaml:"staging_dir"`
+}
+
+// Staging returns the staging directory; empty when none is configured
+// and $XDG_RUNTIME_DIR is unset.
+func (c EncryptionConfig) Staging() string {
+	if c.StagingDir != "" {
+		return ResolvePath(c.StagingDir)
+	}
+	if run := os.Getenv("XDG_RUNTIME_DIR"); run != "" {
+		return filepath.Join(run, "memofy")
+	}
+	return ""
+}
+
+// ParseRecipients parses the recipients' public keys.
+func (c EncryptionConfig) ParseRecipients() ([]*encrypt.Recipient, error) {
+	var out []*encrypt.Recipient
+	for i, s := range c.Recipients {
+		r, err := encrypt.ParseRecipient(s)
+		if err != nil {
+			return nil, fmt.Errorf("encryption.recipients[%d]: %w", i, err)
+		}
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+// UploadConfig copies finalized recordings and their sidecars to an
+// S3-compatible bucket or a WebDAV server. Secrets may name environment
+// variables as $VAR.
+type UploadConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Target  string `yaml:"target"` // "s3" or "webdav"
+	Prefix  string `yaml:"prefix"` // prepended to the path below output.dir
+	// DeleteLocal deletes a recording once all its files are uploaded and
+	// verified.
+	DeleteLocal bool         `yaml:"delete_local"`
+	S3          S3Config     `yaml:"s3"`
+	WebDAV      WebDAVConfig `yaml:"webdav"`
+}
+
+// S3Config is an S3-compatible upload target such as AWS S3 or MinIO.
+type S3Config struct {
+	Endpoint        string `yaml:"endpoint"` // e.g. https://minio.example.com:9000
+	Region          string `yaml:"region"`
+	Bucket          string `yaml:"bucket"`
+	AccessKeyID     string `yaml:"access_key_id"`
+	SecretAccessKey string `yaml:"secret_access_key"`
+	// VirtualHosted puts the bucket in the host name instead of the path
+	// (AWS); path style suits MinIO.
+	VirtualHosted bool `yaml:"virtual_hosted"`
+	PartSizeMB    int  `yaml:"part_size_mb"` // larger files use multipart upload
+}
+
+// WebDAVConfig is a WebDAV upload target such as Nextcloud.
+type WebDAVConfig struct {
+	URL      string `yaml:"url"` // collection to upload into
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
 }
 
 // UIConfig controls UI behavior.
@@ -101,16 +342,24 @@ func Default() Config {
 			SampleRate:          44100,
 			Channels:            2,
 			FormatProfile:       "high",
+			SampleFormat:        "s16",
+			GapToleranceMs:      500,
 		},
 		Session: SessionConfig{
 			MinSessionSeconds:               3,
 			DiscardShortSessions:            true,
 			KeepSingleSessionWhileMicActive: false,
+			DegradedClipRatio:               0.001,
+			DegradedOverflows:               0,
+			CompactGapSeconds:               2,
 		},
 		Output: OutputConfig{
 			Dir:               "~/Recordings/Memofy",
 			Directory:         "~/Recordings/Memofy",
 			WriteMetadataJSON: true,
+			CheckpointSeconds: 10,
+			FilenameTemplate:  recpath.DefaultFilenameTemplate,
+			DirTemplate:       recpath.DefaultDirTemplate,
 		},
 		Monitoring: MonitoringConfig{
 			DetectZoom:                      true,
@@ -120,6 +369,7 @@ func Default() Config {
 			PollIntervalMs:                  5000,
 			MicSessionLock:                  true,
 			MicReleaseSeconds:               20,
+			Apps:                            monitor.DefaultApps(),
 		},
 		Logging: LoggingConfig{
 			File:  "~/.local/share/memofy/memofy.log",
@@ -129,6 +379,18 @@ func Default() Config {
 			MacOSDevice: "BlackHole",
 			LinuxDevice: "default",
 		},
+		Calendar: CalendarConfig{
+			ReloadMinutes: 15,
+		},
+		Retention: RetentionConfig{
+			IntervalMinutes: 60,
+		},
+		Upload: UploadConfig{
+			S3: S3Config{
+				Region:     "us-east-1",
+				PartSizeMB: 16,
+			},
+		},
 		UI: UIConfig{
 			AutoCheckUpdates: true,
 		},
@@ -231,6 +493,146 @@ func (c *Config) Validate() error {
 	if c.Audio.SilenceSeconds < 1 {
 		return fmt.Errorf("audio.silence_seconds must be >= 1 (got %d)", c.Audio.SilenceSeconds)
 	}
+	switch c.Audio.SampleFormat {
+	case "", "s16", "s24", "f32":
+	default:
+		return fmt.Errorf("audio.sample_format must be one of s16, s24, f32 (got %q)", c.Audio.SampleFormat)
+	}
+	if c.Audio.GapToleranceMs < 0 {
+		return fmt.Errorf("audio.gap_tolerance_ms must be >= 0 (got %d)", c.Audio.GapToleranceMs)
+	}
+	if c.Session.MaxDuration < 0 {
+		return fmt.Errorf("session.max_duration must be >= 0 (got %s)", c.Session.MaxDuration)
+	}
+	if c.Session.MaxSizeMB < 0 {
+		return fmt.Errorf("session.max_size_mb must be >= 0 (got %d)", c.Session.MaxSizeMB)
+	}
+	if c.Session.DegradedClipRatio < 0 || c.Session.DegradedClipRatio > 1 {
+		return fmt.Errorf("session.degraded_clip_ratio must be between 0 and 1 (got %f)", c.Session.DegradedClipRatio)
+	}
+	if c.Session.DegradedOverflows < 0 {
+		return fmt.Errorf("session.degraded_overflows must be >= 0 (got %d)", c.Session.DegradedOverflows)
+	}
+	if c.Session.CompactSilenceSeconds < 0 {
+		return fmt.Errorf("session.compact_silence_seconds must be >= 0 (got %d)", c.Session.CompactSilenceSeconds)
+	}
+	if c.Session.CompactSilenceSeconds > 0 && (c.Session.CompactGapSeconds < 0 || c.Session.CompactGapSeconds >= c.Session.CompactSilenceSeconds) {
+		return fmt.Errorf("session.compact_gap_seconds must be >= 0 and less than compact_silence_seconds (got %d)", c.Session.CompactGapSeconds)
+	}
+	seen := make(map[string]bool)
+	for i, r := range c.Monitoring.Apps {
+		if err := r.Validate(); err != nil {
+			return fmt.Errorf("monitoring.apps[%d]: %w", i, err)
+		}
+		if seen[r.Name] {
+			return fmt.Errorf("monitoring.apps[%d]: duplicate name %q", i, r.Name)
+		}
+		seen[r.Name] = true
+	}
+	profiles := make(map[string]bool)
+	fallbacks := 0
+	for i, p := range c.AppProfiles {
+		switch {
+		case p.Name == "":
+			return fmt.Errorf("app_profiles[%d].name must be set", i)
+		case profiles[p.Name]:
+			return fmt.Errorf("app_profiles[%d]: duplicate name %q", i, p.Name)
+		}
+		profiles[p.Name] = true
+		if len(p.Apps) == 0 {
+			if fallbacks++; fallbacks > 1 {
+				return fmt.Errorf("app_profiles[%d]: only one profile may have no apps (got %q)", i, p.Name)
+			}
+		}
+		switch p.FormatProfile {
+		case "", "high", "balanced", "lightweight", "wav":
+		default:
+			return fmt.Errorf("app_profiles[%d].format_profile must be one of high, balanced, lightweight, wav (got %q)", i, p.FormatProfile)
+		}
+		if p.Threshold < 0 || p.ExitThreshold < 0 {
+			return fmt.Errorf("app_profiles[%d] thresholds must be >= 0 (got %v, %v)", i, p.Threshold, p.ExitThreshold)
+		}
+		if p.SilenceSeconds < 0 {
+			return fmt.Errorf("app_profiles[%d].silence_seconds must be >= 0 (got %d)", i, p.SilenceSeconds)
+		}
+		if p.Subdir != "" && !filepath.IsLocal(p.Subdir) {
+			return fmt.Errorf("app_profiles[%d].subdir must be a path inside output.dir (got %q)", i, p.Subdir)
+		}
+		if p.Tag != "" && !validTag.MatchString(p.Tag) {
+			return fmt.Errorf("app_profiles[%d].tag may only contain letters, digits, '-' and '_' (got %q)", i, p.Tag)
+		}
+	}
+	if err := c.Schedule.Validate(); err != nil {
+		return err
+	}
+	if err := c.Trigger.Validate(); err != nil {
+		return err
+	}
+	r := c.Retention
+	if r.IntervalMinutes < 0 {
+		return fmt.Errorf("retention.interval_minutes must be >= 0 (got %d)", r.IntervalMinutes)
+	}
+	if r.MaxAgeDays < 0 {
+		return fmt.Errorf("retention.max_age_days must be >= 0 (got %d)", r.MaxAgeDays)
+	}
+	if r.MaxTotalMB < 0 {
+		return fmt.Errorf("retention.max_total_mb must be >= 0 (got %d)", r.MaxTotalMB)
+	}
+	if r.KeepRecent < 0 {
+		return fmt.Errorf("retention.keep_recent must be >= 0 (got %d)", r.KeepRecent)
+	}
+	retained := make(map[string]bool)
+	for i, p := range r.Profiles {
+		switch {
+		case p.Profile == "":
+			return fmt.Errorf("retention.profiles[%d].profile must be set", i)
+		case retained[p.Profile]:
+			return fmt.Errorf("retention.profiles[%d]: duplicate profile %q", i, p.Profile)
+		case p.MaxAgeDays < 0:
+			return fmt.Errorf("retention.profiles[%d].max_age_days must be >= 0 (got %d)", i, p.MaxAgeDays)
+		}
+		retained[p.Profile] = true
+	}
+	if enc := c.Encryption; enc.Enabled {
+		if len(enc.Recipients) == 0 {
+			return fmt.Errorf("encryption.recipients must list at least one public key when encryption is enabled; see memofy keygen")
+		}
+		if _, err := enc.ParseRecipients(); err != nil {
+			return err
+		}
+		if enc.Staging() == "" {
+			return fmt.Errorf("encryption.staging_dir must be set when encryption is enabled and $XDG_RUNTIME_DIR is unset")
+		}
+	}
+	if err := c.Upload.validate(); err != nil {
+		return err
+	}
+	if c.Session.MergeGapSeconds < 0 {
+		return fmt.Errorf("session.merge_gap_seconds must be >= 0 (got %d)", c.Session.MergeGapSeconds)
+	}
+	if c.Calendar.ReloadMinutes < 0 {
+		return fmt.Errorf("calendar.reload_minutes must be >= 0 (got %d)", c.Calendar.ReloadMinutes)
+	}
+	if c.Output.CheckpointSeconds < 0 {
+		return fmt.Errorf("output.checkpoint_seconds must be >= 0 (got %d)", c.Output.CheckpointSeconds)
+	}
+	if c.Output.LowDiskMB < 0 || c.Output.CriticalDiskMB < 0 {
+		return fmt.Errorf("output.low_disk_mb and output.critical_disk_mb must be >= 0 (got %d, %d)", c.Output.LowDiskMB, c.Output.CriticalDiskMB)
+	}
+	if c.Output.LowDiskMB > 0 && c.Output.CriticalDiskMB > c.Output.LowDiskMB {
+		return fmt.Errorf("output.critical_disk_mb must not exceed output.low_disk_mb (got %d > %d)", c.Output.CriticalDiskMB, c.Output.LowDiskMB)
+	}
+	switch c.Output.LowDiskProfile {
+	case "", "high", "balanced", "lightweight", "wav":
+	default:
+		return fmt.Errorf("output.low_disk_profile must be one of high, balanced, lightweight, wav (got %q)", c.Output.LowDiskProfile)
+	}
+	if strings.TrimSpace(c.Output.FilenameTemplate) == "" {
+		c.Output.FilenameTemplate = recpath.DefaultFilenameTemplate
+	}
+	if _, err := recpath.NewResolver(c.Output.FilenameTemplate, c.Output.DirTemplate); err != nil {
+		return fmt.Errorf("output: %w", err)
+	}
 	if c.Audio.SampleRate <= 0 {
 		c.Audio.SampleRate = 44100
 	}
@@ -274,3 +676,33 @@ func ResolvePath(path string) string {
 	}
 	return path
 }
+
+// validate checks the upload settings when uploads are enabled.
+func (u UploadConfig) validate() error {
+	if !u.Enabled {
+		return nil
+	}
+	switch u.Target {
+	case "s3":
+		s := u.S3
+		if s.Endpoint == "" || s.Bucket == "" {
+			return fmt.Errorf("upload.s3.endpoint and upload.s3.bucket must be set")
+		}
+		if !strings.HasPrefix(s.Endpoint, "http://") && !strings.HasPrefix(s.Endpoint, "https://") {
+			return fmt.Errorf("upload.s3.endpoint must be an http or https URL (got %q)", s.Endpoint)
+		}
+		if s.AccessKeyID == "" || s.SecretAccessKey == "" {
+			return fmt.Errorf("upload.s3.access_key_id and upload.s3.secret_access_key must be set")
+		}
+		if s.PartSizeMB < 5 {
+			return fmt.Errorf("upload.s3.part_size_mb must be >= 5 (got %d)", s.PartSizeMB)
+		}
+	case "webdav":
+		if !strings.HasPrefix(u.WebDAV.URL, "http://") && !strings.HasPrefix(u.WebDAV.URL, "https://") {
+			return fmt.Errorf("upload.webdav.url must be an http or https URL (got %q)", u.WebDAV.URL)
+		}
+	default:
+		return fmt.Errorf("upload.target must be s3 or webdav (got %q)", u.Target)
+	}
+	return nil
+}
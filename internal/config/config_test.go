@@ -2,7 +2,11 @@ package config
 
 import (
 	"os"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/tiroq/memofy/internal/encrypt"
 )
 
 func TestDefault(t *testing.T) {
@@ -258,3 +262,456 @@ func TestDefaultConfigPath(t *testing.T) {
 		t.Error("DefaultConfigPath should not be empty")
 	}
 }
+
+func TestValidateSampleFormat(t *testing.T) {
+	for _, f := range []string{"", "s16", "s24", "f32"} {
+		cfg := Default()
+		cfg.Audio.SampleFormat = f
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("sample_format %q should be valid: %v", f, err)
+		}
+	}
+	cfg := Default()
+	cfg.Audio.SampleFormat = "s32"
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for sample_format=s32")
+	}
+}
+
+func TestValidateCheckpointSeconds(t *testing.T) {
+	cfg := Default()
+	cfg.Output.CheckpointSeconds = -1
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for negative output.checkpoint_seconds")
+	}
+	cfg.Output.CheckpointSeconds = 0
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("checkpoint_seconds 0 (disabled) should be valid: %v", err)
+	}
+}
+
+func TestLoadSessionLimits(t *testing.T) {
+	content := `
+session:
+  max_duration: 90m
+  max_size_mb: 2048
+`
+	tmp := t.TempDir() + "/config.yaml"
+	if err := os.WriteFile(tmp, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(tmp)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Session.MaxDuration != 90*time.Minute {
+		t.Errorf("max_duration: got %s, want 1h30m0s", cfg.Session.MaxDuration)
+	}
+	if cfg.Session.MaxSizeMB != 2048 {
+		t.Errorf("max_size_mb: got %d, want 2048", cfg.Session.MaxSizeMB)
+	}
+
+	cfg.Session.MaxDuration = -time.Second
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for negative session.max_duration")
+	}
+}
+
+func TestValidateDegradedTolerances(t *testing.T) {
+	cfg := Default()
+	cfg.Session.DegradedClipRatio = 1.5
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for session.degraded_clip_ratio > 1")
+	}
+	cfg = Default()
+	cfg.Session.DegradedOverflows = -1
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for negative session.degraded_overflows")
+	}
+}
+
+func TestValidateGapTolerance(t *testing.T) {
+	cfg := Default()
+	if cfg.Audio.GapToleranceMs != 500 {
+		t.Errorf("gap_tolerance_ms: got %d, want 500", cfg.Audio.GapToleranceMs)
+	}
+	cfg.Audio.GapToleranceMs = -1
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for negative audio.gap_tolerance_ms")
+	}
+	cfg.Audio.GapToleranceMs = 0
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("gap_tolerance_ms 0 (disabled) should be valid: %v", err)
+	}
+}
+
+func TestValidateCompaction(t *testing.T) {
+	cfg := Default()
+	cfg.Session.CompactSilenceSeconds = 20
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("compact_silence_seconds 20 with default gap should be valid: %v", err)
+	}
+	cfg.Session.CompactGapSeconds = 20
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for compact_gap_seconds >= compact_silence_seconds")
+	}
+	cfg = Default()
+	cfg.Session.CompactSilenceSeconds = -1
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for negative session.compact_silence_seconds")
+	}
+}
+
+func TestValidateMergeGap(t *testing.T) {
+	cfg := Default()
+	if cfg.Session.MergeGapSeconds != 0 {
+		t.Errorf("merge_gap_seconds should default to 0 (off), got %d", cfg.Session.MergeGapSeconds)
+	}
+	cfg.Session.MergeGapSeconds = -1
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for negative session.merge_gap_seconds")
+	}
+	cfg.Session.MergeGapSeconds = 120
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("merge_gap_seconds 120 should be valid: %v", err)
+	}
+}
+
+func TestLoadSchedule(t *testing.T) {
+	content := `
+schedule:
+  enabled: true
+  timezone: UTC
+  windows:
+    - days: [weekdays]
+      start: "09:00"
+      end: "18:00"
+  exceptions:
+    - date: 2026-12-25
+      allow: false
+`
+	tmp := t.TempDir() + "/config.yaml"
+	if err := os.WriteFile(tmp, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(tmp)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !cfg.Schedule.Enabled || len(cfg.Schedule.Windows) != 1 || len(cfg.Schedule.Exceptions) != 1 {
+		t.Errorf("schedule not loaded: %+v", cfg.Schedule)
+	}
+
+	cfg.Schedule.Windows[0].Start = "9am"
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for invalid schedule window")
+	}
+}
+
+func TestLoadMonitoringApps(t *testing.T) {
+	content := `
+monitoring:
+  detect_zoom: false
+  apps:
+    - name: zoom
+      processes: ['zoom\.us']
+    - name: slack
+      processes: ['(^|/)Slack$']
+      bundle_ids: [com.tinyspeck.slackmacgap]
+`
+	tmp := t.TempDir() + "/config.yaml"
+	if err := os.WriteFile(tmp, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(tmp)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(cfg.Monitoring.Apps) != 2 {
+		t.Fatalf("apps should replace the defaults, got %+v", cfg.Monitoring.Apps)
+	}
+	rules := cfg.Monitoring.AppRules()
+	if len(rules) != 1 || rules[0].Name != "slack" {
+		t.Errorf("AppRules() with detect_zoom off = %+v, want only slack", rules)
+	}
+
+	cfg.Monitoring.Apps = append(cfg.Monitoring.Apps, cfg.Monitoring.Apps[1])
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for duplicate app name")
+	}
+	cfg.Monitoring.Apps = cfg.Monitoring.Apps[:2]
+	cfg.Monitoring.Apps[1].Processes = []string{"("}
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for invalid process pattern")
+	}
+}
+
+func TestDefaultMonitoringApps(t *testing.T) {
+	cfg := Default()
+	var names []string
+	for _, r := range cfg.Monitoring.AppRules() {
+		names = append(names, r.Name)
+	}
+	if strings.Join(names, ",") != "zoom,teams,meet" {
+		t.Errorf("default app rules = %v, want zoom, teams, meet", names)
+	}
+}
+
+func TestMatchAppProfile(t *testing.T) {
+	content := `
+app_profiles:
+  - name: teams-archive
+    apps: [teams, com.microsoft.teams2]
+    format_profile: high
+    silence_seconds: 20
+    subdir: teams
+    tag: teams
+  - name: adhoc
+    format_profile: lightweight
+    silence_seconds: 600
+`
+	tmp := t.TempDir() + "/config.yaml"
+	if err := os.WriteFile(tmp, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(tmp)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	tests := []struct {
+		apps      []string
+		want, app string
+	}{
+		{[]string{"zoom", "teams"}, "teams-archive", "teams"},
+		{[]string{"COM.MICROSOFT.TEAMS2"}, "teams-archive", "COM.MICROSOFT.TEAMS2"},
+		{[]string{"zoom"}, "adhoc", ""},
+		{nil, "adhoc", ""},
+	}
+	for _, tc := range tests {
+		p, app := cfg.MatchAppProfile(tc.apps)
+		if p == nil || p.Name != tc.want || app != tc.app {
+			t.Errorf("MatchAppProfile(%v) = %v, %q; want %s, %q", tc.apps, p, app, tc.want, tc.app)
+		}
+	}
+
+	cfg.AppProfiles = cfg.AppProfiles[:1]
+	if p, _ := cfg.MatchAppProfile([]string{"zoom"}); p != nil {
+		t.Errorf("no fallback: MatchAppProfile = %v, want nil", p)
+	}
+}
+
+func TestValidateAppProfiles(t *testing.T) {
+	for _, p := range []AppProfile{
+		{Apps: []string{"teams"}},
+		{Name: "x", FormatProfile: "ultra"},
+		{Name: "x", Threshold: -1},
+		{Name: "x", SilenceSeconds: -5},
+		{Name: "x", Subdir: "../elsewhere"},
+		{Name: "x", Subdir: "/tmp"},
+		{Name: "x", Tag: "a b"},
+	} {
+		cfg := Default()
+		cfg.AppProfiles = []AppProfile{p}
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("Validate(%+v) = nil, want error", p)
+		}
+	}
+	cfg := Default()
+	cfg.AppProfiles = []AppProfile{{Name: "a"}, {Name: "b"}}
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for two fallback profiles")
+	}
+	cfg.AppProfiles = []AppProfile{{Name: "a", Apps: []string{"zoom"}}, {Name: "a", Apps: []string{"teams"}}}
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for duplicate profile names")
+	}
+	cfg.AppProfiles = []AppProfile{{Name: "a", Apps: []string{"zoom"}, Subdir: "calls/zoom", Tag: "zoom_call"}}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("valid profile: %v", err)
+	}
+}
+
+func TestLoadTrigger(t *testing.T) {
+	content := `
+trigger:
+  kind: any_of
+  policies:
+    - kind: audio
+    - kind: app
+      app: zoom
+      in_call: true
+`
+	tmp := t.TempDir() + "/config.yaml"
+	if err := os.WriteFile(tmp, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(tmp)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Trigger.Kind != "any_of" || len(cfg.Trigger.Policies) != 2 || !cfg.Trigger.Policies[1].InCall {
+		t.Errorf("trigger not loaded: %+v", cfg.Trigger)
+	}
+
+	cfg.Trigger.Policies[1].Kind = "sometimes"
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for unknown trigger policy kind")
+	}
+	def := Default()
+	if err := def.Validate(); err != nil {
+		t.Errorf("default config (no trigger) should be valid: %v", err)
+	}
+}
+
+func TestLoadRetention(t *testing.T) {
+	content := `
+retention:
+  enabled: true
+  max_age_days: 90
+  max_total_mb: 20000
+  keep_recent: 10
+  profiles:
+    - profile: interviews
+      max_age_days: 0
+`
+	tmp := t.TempDir() + "/config.yaml"
+	if err := os.WriteFile(tmp, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(tmp)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	r := cfg.Retention
+	if !r.Enabled || r.MaxAgeDays != 90 || r.MaxTotalMB != 20000 || r.KeepRecent != 10 || len(r.Profiles) != 1 {
+		t.Errorf("retention not loaded: %+v", r)
+	}
+	if r.IntervalMinutes != 60 {
+		t.Errorf("interval_minutes: got %d, want default 60", r.IntervalMinutes)
+	}
+
+	cfg.Retention.Profiles = append(cfg.Retention.Profiles, RetentionProfile{Profile: "interviews", MaxAgeDays: 30})
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for duplicate retention profile")
+	}
+	cfg.Retention.Profiles = nil
+	cfg.Retention.MaxAgeDays = -1
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for negative retention.max_age_days")
+	}
+}
+
+func TestValidateDiskThresholds(t *testing.T) {
+	cfg := Default()
+	if cfg.Output.LowDiskMB != 0 || cfg.Output.CriticalDiskMB != 0 || cfg.Output.LowDiskProfile != "" {
+		t.Errorf("disk defaults: got %d/%d/%q, want the guard off", cfg.Output.LowDiskMB, cfg.Output.CriticalDiskMB, cfg.Output.LowDiskProfile)
+	}
+	cfg.Output.LowDiskMB = 2048
+	cfg.Output.CriticalDiskMB = 4096
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for critical_disk_mb above low_disk_mb")
+	}
+	cfg.Output.LowDiskMB = 0
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("critical_disk_mb alone should be valid: %v", err)
+	}
+	cfg.Output.LowDiskProfile = "tiny"
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for unknown low_disk_profile")
+	}
+}
+
+func TestValidateTemplates(t *testing.T) {
+	cfg := Default()
+	if cfg.Output.FilenameTemplate != "{date}_{time}_audio_{profile}_{tag}" || cfg.Output.DirTemplate != "" {
+		t.Errorf("template defaults: got %q, %q", cfg.Output.FilenameTemplate, cfg.Output.DirTemplate)
+	}
+	cfg.Output.FilenameTemplate = ""
+	if err := cfg.Validate(); err != nil || cfg.Output.FilenameTemplate == "" {
+		t.Errorf("empty filename_template should fall back to the default: %v", err)
+	}
+	cfg.Output.DirTemplate = "{year}/{month}"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("dir_template: %v", err)
+	}
+	for _, bad := range []OutputConfig{
+		{FilenameTemplate: "{date}_{colour}"},
+		{FilenameTemplate: "{year}/{date}"},
+		{FilenameTemplate: "{date}", DirTemplate: "../{year}"},
+	} {
+		cfg := Default()
+		cfg.Output.FilenameTemplate, cfg.Output.DirTemplate = bad.FilenameTemplate, bad.DirTemplate
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("expected error for %q, %q", bad.FilenameTemplate, bad.DirTemplate)
+		}
+	}
+}
+
+func TestValidateEncryption(t *testing.T) {
+	id, err := encrypt.GenerateIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := Default()
+	cfg.Encryption.Enabled = true
+	cfg.Encryption.StagingDir = t.TempDir()
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for encryption without recipients")
+	}
+	cfg.Encryption.Recipients = []string{id.Recipient().String()}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("valid encryption config: %v", err)
+	}
+	cfg.Encryption.Recipients = []string{id.String()}
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for a private key as recipient")
+	}
+
+	t.Setenv("XDG_RUNTIME_DIR", "/run/user/1000")
+	if got := (EncryptionConfig{}).Staging(); got != "/run/user/1000/memofy" {
+		t.Errorf("Staging = %q, want the runtime dir", got)
+	}
+	t.Setenv("XDG_RUNTIME_DIR", "")
+	cfg.Encryption = EncryptionConfig{Enabled: true, Recipients: []string{id.Recipient().String()}}
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error without a staging dir")
+	}
+}
+
+func TestValidateUpload(t *testing.T) {
+	cfg := Default()
+	cfg.Upload.Enabled = true
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for upload without a target")
+	}
+	cfg.Upload.Target = "s3"
+	cfg.Upload.S3.Endpoint = "http://minio.local:9000"
+	cfg.Upload.S3.Bucket = "recordings"
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for s3 without credentials")
+	}
+	cfg.Upload.S3.AccessKeyID, cfg.Upload.S3.SecretAccessKey = "memofy", "$MINIO_SECRET"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("valid s3 config: %v", err)
+	}
+	cfg.Upload.S3.PartSizeMB = 1
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for part_size_mb below the S3 minimum")
+	}
+	cfg.Upload.Target = "webdav"
+	cfg.Upload.WebDAV.URL = "cloud.example.com/dav"
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for a webdav url without scheme")
+	}
+}
+
+func TestValidateCalendarReload(t *testing.T) {
+	cfg := Default()
+	if cfg.Calendar.ReloadMinutes != 15 {
+		t.Errorf("reload_minutes: got %d, want 15", cfg.Calendar.ReloadMinutes)
+	}
+	cfg.Calendar.ReloadMinutes = -1
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected error for negative calendar.reload_minutes")
+	}
+}
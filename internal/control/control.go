@@ -0,0 +1,138 @@
+// Package control lets other memofy processes talk to the running daemon
+// over a Unix socket. Each connection carries one JSON request line and gets
+// one JSON response line back.
+package control
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrNotRunning is returned by Send when no daemon is listening.
+var ErrNotRunning = errors.New("memofy is not running")
+
+// Request is a command sent to the daemon.
+type Request struct {
+	Command string `json:"command"`
+	Label   string `json:"label,omitempty"` // "mark": text attached to the marker
+}
+
+// Response is the daemon's answer. Error is set when the command failed.
+type Response struct {
+	OK      bool   `json:"ok"`
+	Message string `json:"message,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Handler answers one request.
+type Handler func(Request) Response
+
+// timeout bounds each exchange, so a stuck peer cannot block either side.
+const timeout = 5 * time.Second
+
+// SocketPath returns the control socket path, next to the PID file.
+func SocketPath() string {
+	return filepath.Join(os.Getenv("HOME"), ".cache", "memofy", "memofy.sock")
+}
+
+// Server accepts control connections until closed.
+type Server struct {
+	ln      net.Listener
+	handler Handler
+	wg      sync.WaitGroup
+}
+
+// Listen creates the socket at path and serves requests with h in the
+// background. A socket left behind by a previous daemon is replaced; the
+// caller is expected to hold the PID file, so no other daemon is using it.
+func Listen(path string, h Handler) (*Server, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("control socket: %w", err)
+	}
+	os.Remove(path)
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("control socket: %w", err)
+	}
+	// Only the user running memofy may control it.
+	if err := os.Chmod(path, 0600); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("control socket: %w", err)
+	}
+	s := &Server{ln: ln, handler: h}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn)
+		}()
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+	var req Request
+	var resp Response
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err == nil {
+		err = json.Unmarshal(line, &req)
+	}
+	if err != nil {
+		resp = Response{Error: fmt.Sprintf("bad request: %v", err)}
+	} else {
+		resp = s.handler(req)
+	}
+	json.NewEncoder(conn).Encode(resp)
+}
+
+// Close stops accepting connections, waits for requests in flight and
+// removes the socket.
+func (s *Server) Close() error {
+	err := s.ln.Close()
+	s.wg.Wait()
+	return err
+}
+
+// Send delivers req to the daemon listening at path. A failed command is
+// returned as an error carrying the daemon's message.
+func Send(path string, req Request) (Response, error) {
+	conn, err := net.DialTimeout("unix", path, timeout)
+	if err != nil {
+		return Response{}, fmt.Errorf("%w (no control socket at %s)", ErrNotRunning, path)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return Response{}, fmt.Errorf("send %s: %w", req.Command, err)
+	}
+	var resp Response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return Response{}, fmt.Errorf("send %s: %w", req.Command, err)
+	}
+	if !resp.OK {
+		return resp, errors.New(resp.Error)
+	}
+	return resp, nil
+}
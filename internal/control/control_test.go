@@ -0,0 +1,46 @@
+package control
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSendAndServe(t *testing.T) {
+	// Unix socket paths are limited to ~100 bytes; t.TempDir can be longer.
+	dir, err := os.MkdirTemp("", "memofy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "c.sock")
+
+	srv, err := Listen(path, func(req Request) Response {
+		if req.Command != "mark" {
+			return Response{Error: "unknown command " + req.Command}
+		}
+		return Response{OK: true, Message: "marked " + req.Label}
+	})
+	if err != nil {
+		t.Fatalf("Listen() error: %v", err)
+	}
+
+	resp, err := Send(path, Request{Command: "mark", Label: "action item"})
+	if err != nil || resp.Message != "marked action item" {
+		t.Errorf("Send(mark) = %+v, %v", resp, err)
+	}
+	if _, err := Send(path, Request{Command: "bogus"}); err == nil || err.Error() != "unknown command bogus" {
+		t.Errorf("Send(bogus) error = %v", err)
+	}
+
+	if err := srv.Close(); err != nil {
+		t.Errorf("Close() error: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("socket not removed on Close: %v", err)
+	}
+	if _, err := Send(path, Request{Command: "mark"}); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("Send() after Close error = %v, want ErrNotRunning", err)
+	}
+}
@@ -0,0 +1,167 @@
+// Package diskguard watches the free space of the volumes holding the
+// recordings. Below the low threshold new sessions should be recorded
+// more compactly; below the critical threshold recording should stop.
+// A level is only left once free space is 10% above its threshold, so
+// the level does not flap while a session is finalized.
+package diskguard
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Level is how short of space the volume is.
+type Level int
+
+const (
+	OK       Level = iota
+	Low            // free space below the low threshold
+	Critical       // free space below the critical threshold
+)
+
+func (l Level) String() string {
+	switch l {
+	case Low:
+		return "low"
+	case Critical:
+		return "critical"
+	}
+	return "ok"
+}
+
+// Stats are a volume's sizes in bytes.
+type Stats struct {
+	Free  uint64 // available to unprivileged users
+	Total uint64
+}
+
+// StatFunc reads the stats of the volume holding dir.
+type StatFunc func(dir string) (Stats, error)
+
+// Guard tracks the level of the volumes holding its directories, judged
+// by the one with the least free space. It is not safe for concurrent use.
+type Guard struct {
+	dirs          []string
+	low, critical uint64 // bytes; 0 disables the threshold
+	stat          StatFunc
+	level         Level
+	stats         Stats
+	statsDir      string // the directory stats were read for
+}
+
+// New returns a guard for dir with thresholds in MB; 0 disables a
+// threshold. A nil stat uses Statfs.
+func New(dir string, lowMB, criticalMB int64, stat StatFunc) *Guard {
+	if stat == nil {
+		stat = Statfs
+	}
+	return &Guard{
+		dirs:     []string{dir},
+		low:      mb(lowMB),
+		critical: mb(criticalMB),
+		stat:     stat,
+	}
+}
+
+func mb(n int64) uint64 {
+	if n <= 0 {
+		return 0
+	}
+	return uint64(n) * 1024 * 1024
+}
+
+// Watch adds dir to the directories checked, e.g. a staging directory on
+// another volume where recordings are written before they are moved.
+func (g *Guard) Watch(dir string) {
+	if dir != "" && !slices.Contains(g.dirs, dir) {
+		g.dirs = append(g.dirs, dir)
+	}
+}
+
+// Enabled reports whether any threshold is set.
+func (g *Guard) Enabled() bool {
+	return g != nil && (g.low > 0 || g.critical > 0)
+}
+
+// Check reads the free space and returns the new level. On error the
+// level is unchanged.
+func (g *Guard) Check() (Level, error) {
+	if !g.Enabled() {
+		return OK, nil
+	}
+	var (
+		least    Stats
+		leastDir string
+	)
+	for i, dir := range g.dirs {
+		st, err := g.stat(dir)
+		if err != nil {
+			return g.level, fmt.Errorf("free space of %s: %w", dir, err)
+		}
+		if i == 0 || st.Free < least.Free {
+			least, leastDir = st, dir
+		}
+	}
+	g.stats, g.statsDir = least, leastDir
+	g.level = g.levelFor(least.Free)
+	return g.level, nil
+}
+
+// levelFor returns the level for free bytes given the current level.
+func (g *Guard) levelFor(free uint64) Level {
+	// below reports whether free is under limit, raised by 10% while the
+	// guard is at or past lvl.
+	below := func(limit uint64, lvl Level) bool {
+		if limit == 0 {
+			return false
+		}
+		if g.level >= lvl {
+			limit += limit / 10
+		}
+		return free < limit
+	}
+	switch {
+	case below(g.critical, Critical):
+		return Critical
+	case below(g.low, Low):
+		return Low
+	}
+	return OK
+}
+
+// Level returns the level at the last Check.
+func (g *Guard) Level() Level {
+	if g == nil {
+		return OK
+	}
+	return g.level
+}
+
+// Stats returns the stats of Dir read by the last successful Check.
+func (g *Guard) Stats() Stats {
+	if g == nil {
+		return Stats{}
+	}
+	return g.stats
+}
+
+// Dir returns the directory with the least free space at the last
+// successful Check, the one Stats describes.
+func (g *Guard) Dir() string {
+	if g == nil {
+		return ""
+	}
+	return g.statsDir
+}
+
+// FormatBytes formats a size for logs and status, e.g. "1.5 GB".
+func FormatBytes(n uint64) string {
+	const unit = 1024
+	switch {
+	case n >= unit*unit*unit:
+		return fmt.Sprintf("%.1f GB", float64(n)/(unit*unit*unit))
+	case n >= unit*unit:
+		return fmt.Sprintf("%.0f MB", float64(n)/(unit*unit))
+	}
+	return fmt.Sprintf("%d KB", n/unit)
+}
@@ -0,0 +1,115 @@
+package diskguard
+
+import (
+	"errors"
+	"testing"
+)
+
+const mib = 1024 * 1024
+
+// fakeStat returns free MB from *free, or err when set.
+func fakeStat(free *uint64, err *error) StatFunc {
+	return func(string) (Stats, error) {
+		if *err != nil {
+			return Stats{}, *err
+		}
+		return Stats{Free: *free * mib, Total: 100000 * mib}, nil
+	}
+}
+
+func TestCheckLevels(t *testing.T) {
+	var free uint64
+	var statErr error
+	g := New("/rec", 1000, 200, fakeStat(&free, &statErr))
+
+	steps := []struct {
+		freeMB uint64
+		want   Level
+	}{
+		{5000, OK},
+		{999, Low},
+		{1050, Low}, // within the 10% margin
+		{1100, OK},
+		{150, Critical},
+		{210, Critical}, // within the margin
+		{500, Low},
+		{100, Critical},
+		{2000, OK},
+	}
+	for i, s := range steps {
+		free = s.freeMB
+		got, err := g.Check()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != s.want {
+			t.Errorf("step %d: %d MB free: level %s, want %s", i, s.freeMB, got, s.want)
+		}
+	}
+	if g.Stats().Free != 2000*mib {
+		t.Errorf("Stats().Free = %d", g.Stats().Free)
+	}
+}
+
+func TestCheckErrorKeepsLevel(t *testing.T) {
+	free := uint64(100)
+	var statErr error
+	g := New("/rec", 1000, 200, fakeStat(&free, &statErr))
+	if lvl, _ := g.Check(); lvl != Critical {
+		t.Fatalf("level %s, want critical", lvl)
+	}
+	statErr = errors.New("stale NFS handle")
+	if lvl, err := g.Check(); err == nil || lvl != Critical {
+		t.Errorf("on error: level %s err %v, want critical and an error", lvl, err)
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	called := false
+	g := New("/rec", 0, 0, func(string) (Stats, error) {
+		called = true
+		return Stats{}, nil
+	})
+	if g.Enabled() {
+		t.Error("guard without thresholds should be disabled")
+	}
+	if lvl, err := g.Check(); lvl != OK || err != nil || called {
+		t.Errorf("disabled Check: level %s err %v called %v", lvl, err, called)
+	}
+	var nilGuard *Guard
+	if nilGuard.Level() != OK || nilGuard.Enabled() {
+		t.Error("nil guard should be OK and disabled")
+	}
+}
+
+func TestStatfs(t *testing.T) {
+	st, err := Statfs(t.TempDir())
+	if err != nil {
+		t.Skipf("statfs unavailable: %v", err)
+	}
+	if st.Total == 0 || st.Free > st.Total {
+		t.Errorf("Statfs = %+v", st)
+	}
+}
+
+func TestWatchUsesLeastFree(t *testing.T) {
+	free := map[string]uint64{"/rec": 5000, "/staging": 150}
+	g := New("/rec", 1000, 200, func(dir string) (Stats, error) {
+		return Stats{Free: free[dir] * mib}, nil
+	})
+	if lvl, _ := g.Check(); lvl != OK || g.Dir() != "/rec" {
+		t.Fatalf("output only: level %s dir %q, want ok in /rec", lvl, g.Dir())
+	}
+	g.Watch("/staging")
+	g.Watch("/staging")
+	if len(g.dirs) != 2 {
+		t.Errorf("dirs = %v, want each directory once", g.dirs)
+	}
+	if lvl, _ := g.Check(); lvl != Critical || g.Dir() != "/staging" || g.Stats().Free != 150*mib {
+		t.Errorf("with staging: level %s dir %q free %d, want critical in /staging", lvl, g.Dir(), g.Stats().Free/mib)
+	}
+	free["/staging"] = 8000
+	if lvl, _ := g.Check(); lvl != OK || g.Dir() != "/rec" {
+		t.Errorf("staging freed: level %s dir %q, want ok in /rec", lvl, g.Dir())
+	}
+}
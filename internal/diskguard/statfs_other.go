@@ -0,0 +1,10 @@
+//go:build !darwin && !linux
+
+package diskguard
+
+import "errors"
+
+// Statfs is not implemented on this platform.
+func Statfs(dir string) (Stats, error) {
+	return Stats{}, errors.New("free space is only available on macOS and Linux")
+}
@@ -0,0 +1,15 @@
+//go:build darwin || linux
+
+package diskguard
+
+import "syscall"
+
+// Statfs reads the volume stats with statfs(2).
+func Statfs(dir string) (Stats, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return Stats{}, err
+	}
+	bsize := uint64(st.Bsize)
+	return Stats{Free: uint64(st.Bavail) * bsize, Total: uint64(st.Blocks) * bsize}, nil
+}
@@ -0,0 +1,442 @@
+// Package encrypt seals recordings and sidecars for public-key recipients,
+// so the daemon can encrypt with public keys only and the private key
+// never has to be on the recording machine.
+//
+// The format follows age's design with stdlib primitives. A random 16-byte
+// file key is wrapped for each recipient with X25519, HKDF-SHA256 and
+// AES-256-GCM; the header is authenticated with an HMAC under the file
+// key; the payload is AES-256-GCM in 64 KiB chunks whose nonces carry a
+// counter and a last-chunk flag, so truncation and reordering are
+// detected:
+//
+//	memofy-encrypted/v1
+//	-> X25519 <ephemeral public key> <wrapped file key>
+//	--- <header MAC>
+//	<payload nonce><chunk>...
+//
+// Binary fields in the header are unpadded base64.
+package encrypt
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	intro       = "memofy-encrypted/v1\n"
+	stanzaType  = "X25519"
+	macPrefix   = "---"
+	fileKeySize = 16
+	nonceSize   = 16
+	chunkSize   = 64 * 1024
+	tagSize     = 16
+	maxStanzas  = 64
+	wrapLabel   = "memofy-encrypted/v1/X25519"
+)
+
+// Key prefixes. A recipient goes into the config; an identity stays with
+// whoever decrypts.
+const (
+	RecipientPrefix = "memofy-pub1-"
+	IdentityPrefix  = "MEMOFY-SECRET-KEY-1-"
+)
+
+var b64 = base64.RawStdEncoding
+
+// ErrNoIdentity is returned when none of the identities can open a file.
+var ErrNoIdentity = errors.New("no identity matches any recipient of the file")
+
+// Recipient is a public key files are encrypted to.
+type Recipient struct {
+	key *ecdh.PublicKey
+}
+
+// ParseRecipient parses a "memofy-pub1-..." public key.
+func ParseRecipient(s string) (*Recipient, error) {
+	raw, ok := strings.CutPrefix(strings.TrimSpace(s), RecipientPrefix)
+	if !ok {
+		return nil, fmt.Errorf("recipient must start with %q", RecipientPrefix)
+	}
+	b, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, fmt.Errorf("recipient: %w", err)
+	}
+	key, err := ecdh.X25519().NewPublicKey(b)
+	if err != nil {
+		return nil, fmt.Errorf("recipient: %w", err)
+	}
+	return &Recipient{key: key}, nil
+}
+
+// String returns the recipient in the form ParseRecipient accepts.
+func (r *Recipient) String() string {
+	return RecipientPrefix + base64.RawURLEncoding.EncodeToString(r.key.Bytes())
+}
+
+// Identity is a private key that opens files encrypted to its recipient.
+type Identity struct {
+	key *ecdh.PrivateKey
+}
+
+// GenerateIdentity returns a new random identity.
+func GenerateIdentity() (*Identity, error) {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &Identity{key: key}, nil
+}
+
+// ParseIdentity parses a "MEMOFY-SECRET-KEY-1-..." private key.
+func ParseIdentity(s string) (*Identity, error) {
+	raw, ok := strings.CutPrefix(strings.TrimSpace(s), IdentityPrefix)
+	if !ok {
+		return nil, fmt.Errorf("identity must start with %q", IdentityPrefix)
+	}
+	b, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, fmt.Errorf("identity: %w", err)
+	}
+	key, err := ecdh.X25519().NewPrivateKey(b)
+	if err != nil {
+		return nil, fmt.Errorf("identity: %w", err)
+	}
+	return &Identity{key: key}, nil
+}
+
+// ParseIdentities reads identities, one per line, from an identity file.
+// Blank lines and lines starting with '#' are skipped.
+func ParseIdentities(r io.Reader) ([]*Identity, error) {
+	var ids []*Identity
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		id, err := ParseIdentity(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		ids = append(ids, id)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, errors.New("no identities found")
+	}
+	return ids, nil
+}
+
+// String returns the identity in the form ParseIdentity accepts.
+func (id *Identity) String() string {
+	return IdentityPrefix + base64.RawURLEncoding.EncodeToString(id.key.Bytes())
+}
+
+// Recipient returns the public key of id.
+func (id *Identity) Recipient() *Recipient {
+	return &Recipient{key: id.key.PublicKey()}
+}
+
+// Encrypt returns a writer that encrypts to recipients and writes the
+// result to dst. Close must be called to write the final chunk; it does
+// not close dst.
+func Encrypt(dst io.Writer, recipients ...*Recipient) (io.WriteCloser, error) {
+	if len(recipients) == 0 {
+		return nil, errors.New("no recipients")
+	}
+	fileKey := make([]byte, fileKeySize)
+	if _, err := rand.Read(fileKey); err != nil {
+		return nil, err
+	}
+	var hdr bytes.Buffer
+	hdr.WriteString(intro)
+	for _, r := range recipients {
+		eph, wrapped, err := wrap(fileKey, r)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&hdr, "-> %s %s %s\n", stanzaType, b64.EncodeToString(eph), b64.EncodeToString(wrapped))
+	}
+	hdr.WriteString(macPrefix)
+	mac := headerMAC(fileKey, hdr.Bytes())
+	fmt.Fprintf(&hdr, " %s\n", b64.EncodeToString(mac))
+
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	hdr.Write(nonce)
+	if _, err := dst.Write(hdr.Bytes()); err != nil {
+		return nil, err
+	}
+	aead, err := payloadAEAD(fileKey, nonce)
+	if err != nil {
+		return nil, err
+	}
+	return &writer{dst: dst, aead: aead, buf: make([]byte, 0, chunkSize)}, nil
+}
+
+// wrap encrypts fileKey to r with a fresh ephemeral key and returns the
+// ephemeral public key and the wrapped key.
+func wrap(fileKey []byte, r *Recipient) (eph, wrapped []byte, err error) {
+	ephKey, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	shared, err := ephKey.ECDH(r.key)
+	if err != nil {
+		return nil, nil, err
+	}
+	eph = ephKey.PublicKey().Bytes()
+	aead, err := wrapAEAD(shared, eph, r.key.Bytes())
+	if err != nil {
+		return nil, nil, err
+	}
+	// Each wrapping key is used once, so a zero nonce is safe.
+	return eph, aead.Seal(nil, make([]byte, aead.NonceSize()), fileKey, nil), nil
+}
+
+// unwrap recovers the file key from a stanza, or fails if the stanza is
+// not for id.
+func unwrap(eph, wrapped []byte, id *Identity) ([]byte, error) {
+	ephKey, err := ecdh.X25519().NewPublicKey(eph)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := id.key.ECDH(ephKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := wrapAEAD(shared, eph, id.key.PublicKey().Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return aead.Open(nil, make([]byte, aead.NonceSize()), wrapped, nil)
+}
+
+func wrapAEAD(shared, eph, recipient []byte) (cipher.AEAD, error) {
+	salt := append(append([]byte(nil), eph...), recipient...)
+	return newGCM(hkdf(shared, salt, wrapLabel, 32))
+}
+
+func payloadAEAD(fileKey, nonce []byte) (cipher.AEAD, error) {
+	return newGCM(hkdf(fileKey, nonce, "payload", 32))
+}
+
+func headerMAC(fileKey, header []byte) []byte {
+	h := hmac.New(sha256.New, hkdf(fileKey, nil, "header", 32))
+	h.Write(header)
+	return h.Sum(nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// hkdf is HKDF-SHA256 (RFC 5869) for outputs of up to 32 bytes.
+func hkdf(secret, salt []byte, info string, n int) []byte {
+	if salt == nil {
+		salt = make([]byte, sha256.Size)
+	}
+	ext := hmac.New(sha256.New, salt)
+	ext.Write(secret)
+	prk := ext.Sum(nil)
+	exp := hmac.New(sha256.New, prk)
+	exp.Write([]byte(info))
+	exp.Write([]byte{1})
+	return exp.Sum(nil)[:n]
+}
+
+// chunkNonce is the payload nonce of chunk n: a big-endian counter and a
+// flag byte that is 1 for the last chunk.
+func chunkNonce(n uint64, last bool) []byte {
+	nonce := make([]byte, 12)
+	for i := 10; i >= 3; i-- {
+		nonce[i] = byte(n)
+		n >>= 8
+	}
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// writer buffers one chunk. A full chunk is sealed only once more data
+// arrives, so Close always has a last chunk to flag.
+type writer struct {
+	dst    io.Writer
+	aead   cipher.AEAD
+	buf    []byte
+	n      uint64
+	closed bool
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("encrypt: write after close")
+	}
+	total := len(p)
+	for len(p) > 0 {
+		if len(w.buf) == chunkSize {
+			if err := w.flush(false); err != nil {
+				return total - len(p), err
+			}
+		}
+		k := copy(w.buf[len(w.buf):chunkSize], p)
+		w.buf = w.buf[:len(w.buf)+k]
+		p = p[k:]
+	}
+	return total, nil
+}
+
+func (w *writer) flush(last bool) error {
+	out := w.aead.Seal(nil, chunkNonce(w.n, last), w.buf, nil)
+	w.n++
+	w.buf = w.buf[:0]
+	_, err := w.dst.Write(out)
+	return err
+}
+
+// Close seals the last chunk.
+func (w *writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	return w.flush(true)
+}
+
+// Decrypt returns a reader of the plaintext of src, opened with the first
+// identity that matches a recipient. Reads fail if the payload was
+// modified or truncated.
+func Decrypt(src io.Reader, identities ...*Identity) (io.Reader, error) {
+	br := bufio.NewReaderSize(src, chunkSize+tagSize)
+	var hdr bytes.Buffer
+	line, err := br.ReadString('\n')
+	if err != nil || line != intro {
+		return nil, errors.New("not a memofy encrypted file")
+	}
+	hdr.WriteString(line)
+
+	var fileKey, mac []byte
+	for i := 0; ; i++ {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("read header: %w", err)
+		}
+		if rest, ok := strings.CutPrefix(line, macPrefix+" "); ok {
+			hdr.WriteString(macPrefix)
+			if mac, err = b64.DecodeString(strings.TrimSuffix(rest, "\n")); err != nil {
+				return nil, fmt.Errorf("header MAC: %w", err)
+			}
+			break
+		}
+		if i == maxStanzas {
+			return nil, errors.New("too many recipients in header")
+		}
+		hdr.WriteString(line)
+		f := strings.Fields(line)
+		if len(f) != 4 || f[0] != "->" || f[1] != stanzaType || fileKey != nil {
+			continue
+		}
+		eph, err1 := b64.DecodeString(f[2])
+		wrapped, err2 := b64.DecodeString(f[3])
+		if err1 != nil || err2 != nil {
+			return nil, errors.New("malformed recipient stanza")
+		}
+		for _, id := range identities {
+			if k, err := unwrap(eph, wrapped, id); err == nil && len(k) == fileKeySize {
+				fileKey = k
+				break
+			}
+		}
+	}
+	if fileKey == nil {
+		return nil, ErrNoIdentity
+	}
+	if !hmac.Equal(mac, headerMAC(fileKey, hdr.Bytes())) {
+		return nil, errors.New("header MAC mismatch")
+	}
+	nonce := make([]byte, nonceSize)
+	if _, err := io.ReadFull(br, nonce); err != nil {
+		return nil, fmt.Errorf("read payload nonce: %w", err)
+	}
+	aead, err := payloadAEAD(fileKey, nonce)
+	if err != nil {
+		return nil, err
+	}
+	return &reader{src: br, aead: aead, buf: make([]byte, chunkSize+tagSize)}, nil
+}
+
+type reader struct {
+	src  *bufio.Reader
+	aead cipher.AEAD
+	buf  []byte
+	out  []byte // decrypted, not yet returned
+	n    uint64
+	done bool
+	err  error
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		if r.done {
+			return 0, io.EOF
+		}
+		r.err = r.next()
+	}
+	k := copy(p, r.out)
+	r.out = r.out[k:]
+	return k, nil
+}
+
+// next decrypts the following chunk. A chunk is the last one when the
+// input ends after it.
+func (r *reader) next() error {
+	k, err := io.ReadFull(r.src, r.buf)
+	last := false
+	switch {
+	case err == io.ErrUnexpectedEOF || err == io.EOF:
+		last = true
+	case err != nil:
+		return err
+	default:
+		if _, err := r.src.Peek(1); err == io.EOF {
+			last = true
+		}
+	}
+	if k < tagSize {
+		return errors.New("encrypted file is truncated")
+	}
+	plain, err := r.aead.Open(r.buf[:0], chunkNonce(r.n, last), r.buf[:k], nil)
+	if err != nil {
+		return errors.New("encrypted file is truncated or corrupted")
+	}
+	if last && len(plain) == 0 && r.n > 0 {
+		return errors.New("encrypted file has an empty last chunk")
+	}
+	r.n++
+	r.out = plain
+	r.done = last
+	return nil
+}
@@ -0,0 +1,144 @@
+package encrypt
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func identity(t *testing.T) *Identity {
+	t.Helper()
+	id, err := GenerateIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func seal(t *testing.T, plain []byte, recipients ...*Recipient) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := Encrypt(&buf, recipients...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(plain); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func open(sealed []byte, ids ...*Identity) ([]byte, error) {
+	r, err := Decrypt(bytes.NewReader(sealed), ids...)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func TestRoundTrip(t *testing.T) {
+	alice, bob := identity(t), identity(t)
+	for _, n := range []int{0, 1, chunkSize - 1, chunkSize, chunkSize + 1, 3*chunkSize + 17} {
+		plain := make([]byte, n)
+		rand.Read(plain)
+		sealed := seal(t, plain, alice.Recipient(), bob.Recipient())
+		for _, id := range []*Identity{alice, bob} {
+			got, err := open(sealed, id)
+			if err != nil {
+				t.Fatalf("%d bytes: %v", n, err)
+			}
+			if !bytes.Equal(got, plain) {
+				t.Fatalf("%d bytes: plaintext differs", n)
+			}
+		}
+	}
+}
+
+func TestKeyEncoding(t *testing.T) {
+	id := identity(t)
+	parsed, err := ParseIdentity(id.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := ParseRecipient(parsed.Recipient().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.String() != id.Recipient().String() {
+		t.Errorf("recipient %s, want %s", r, id.Recipient())
+	}
+	ids, err := ParseIdentities(strings.NewReader("# created 2026-10-18\n# public key: " + r.String() + "\n" + id.String() + "\n"))
+	if err != nil || len(ids) != 1 {
+		t.Fatalf("ParseIdentities = %d, %v", len(ids), err)
+	}
+	for _, bad := range []string{"", "memofy-pub1-", "memofy-pub1-AAAA", id.String()} {
+		if _, err := ParseRecipient(bad); err == nil {
+			t.Errorf("ParseRecipient(%q): expected error", bad)
+		}
+	}
+}
+
+func TestWrongIdentity(t *testing.T) {
+	sealed := seal(t, []byte("meeting"), identity(t).Recipient())
+	if _, err := open(sealed, identity(t)); !errors.Is(err, ErrNoIdentity) {
+		t.Errorf("err = %v, want ErrNoIdentity", err)
+	}
+}
+
+func TestTampering(t *testing.T) {
+	id := identity(t)
+	plain := make([]byte, 2*chunkSize+100)
+	sealed := seal(t, plain, id.Recipient())
+	hdrLen := bytes.Index(sealed, []byte("\n---")) + 1
+	hdrLen += bytes.IndexByte(sealed[hdrLen:], '\n') + 1 + nonceSize
+
+	tests := map[string][]byte{
+		"flipped payload bit": func() []byte {
+			b := bytes.Clone(sealed)
+			b[len(b)-5] ^= 1
+			return b
+		}(),
+		"truncated at chunk boundary": sealed[:hdrLen+chunkSize+tagSize],
+		"truncated mid chunk":         sealed[:len(sealed)-50],
+		"header changed": func() []byte {
+			b := bytes.Clone(sealed)
+			return bytes.Replace(b, []byte("-> X25519"), []byte("-> X25519 "), 1)
+		}(),
+	}
+	for name, b := range tests {
+		if _, err := open(b, id); err == nil {
+			t.Errorf("%s: decrypted without error", name)
+		}
+	}
+}
+
+func TestFiles(t *testing.T) {
+	dir := t.TempDir()
+	id := identity(t)
+	src := filepath.Join(dir, "a.m4a")
+	os.WriteFile(src, []byte("audio"), 0644)
+	if err := EncryptFile(src, src+".enc", id.Recipient()); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "out.m4a")
+	if err := DecryptFile(src+".enc", out, identity(t)); err == nil {
+		t.Fatal("decrypted with the wrong identity")
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Errorf("failed decryption left %s", out)
+	}
+	if err := DecryptFile(src+".enc", out, id); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(out); string(b) != "audio" {
+		t.Errorf("decrypted %q", b)
+	}
+}
@@ -0,0 +1,74 @@
+package encrypt
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// EncryptFile encrypts src to dst for recipients. dst appears complete or
+// not at all: it is written to a temporary file, synced and renamed. src
+// is left in place.
+func EncryptFile(src, dst string, recipients ...*Recipient) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	return writeAtomic(dst, func(out io.Writer) error {
+		w, err := Encrypt(out, recipients...)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(w, in); err != nil {
+			return err
+		}
+		return w.Close()
+	})
+}
+
+// DecryptFile decrypts src to dst with the first matching identity. A
+// failed or tampered decryption leaves no dst behind.
+func DecryptFile(src, dst string, identities ...*Identity) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	r, err := Decrypt(in, identities...)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filepath.Base(src), err)
+	}
+	return writeAtomic(dst, func(out io.Writer) error {
+		if _, err := io.Copy(out, r); err != nil {
+			return fmt.Errorf("%s: %w", filepath.Base(src), err)
+		}
+		return nil
+	})
+}
+
+// writeAtomic writes dst through a temporary file in the same directory.
+// Like the temporary file, dst is readable by the owner only.
+func writeAtomic(dst string, write func(io.Writer) error) error {
+	f, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	if err := write(f); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
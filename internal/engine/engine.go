@@ -2,20 +2,30 @@
 package engine
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/tiroq/memofy/internal/audio"
+	"github.com/tiroq/memofy/internal/calendar"
 	"github.com/tiroq/memofy/internal/config"
+	"github.com/tiroq/memofy/internal/diskguard"
+	"github.com/tiroq/memofy/internal/encrypt"
 	"github.com/tiroq/memofy/internal/metadata"
 	"github.com/tiroq/memofy/internal/monitor"
+	"github.com/tiroq/memofy/internal/recpath"
+	"github.com/tiroq/memofy/internal/retention"
+	"github.com/tiroq/memofy/internal/schedule"
 	"github.com/tiroq/memofy/internal/statemachine"
+	"github.com/tiroq/memofy/internal/trigger"
+	"github.com/tiroq/memofy/internal/upload"
 	"github.com/tiroq/memofy/internal/wav"
 )
 
@@ -26,9 +36,7 @@ import (
 // deviceSwitchReq is sent from pollMonitor to loop() via deviceSwitchCh.
 type deviceSwitchReq struct {
 	device   *audio.DeviceInfo // non-nil to open a new capture device
-	startRec bool              // true when the new device is a dedicated meeting device;
-	// triggers ForceStartRecording after the switch so the call
-	// is captured even if BlackHole is currently silent.
+	startRec bool              // force-start recording after the switch, even if BlackHole is silent
 }
 
 // Engine is the main recording controller.
@@ -37,6 +45,8 @@ type Engine struct {
 	sm               *statemachine.StateMachine
 	mon              *monitor.Monitor
 	stream           *audio.Stream
+	captureRate      int // sample rate of stream; 0 while none is open
+	captureChannels  int // channels of stream; 0 while none is open
 	writer           *wav.Writer
 	logger           *log.Logger
 	mu               sync.Mutex
@@ -54,21 +64,77 @@ type Engine struct {
 	initDevice       *audio.DeviceInfo           // the device selected at Start(); used to switch back after meetings
 	micInactiveSince time.Time                   // non-zero while mic is inactive; drives the fallback timeout
 	sessionDiag      metadata.SessionDiagnostics // per-session capture diagnostics
+	seriesID         string                      // shared by all parts of a rolled-over session
+	partIndex        int                         // 1-based index of the current part; 0 until the first rollover
+	finalizing       sync.WaitGroup              // parts being finalized in the background after rollover
+	chanLevels       *audio.ChannelLevels        // per-part channel statistics
+	winLevels        *audio.ChannelLevels        // channel statistics for the current 5 s log window
+	channelReport    audio.ChannelReport         // latest channel verdict made on audible input
+	monoChannel      int                         // source channel written to a mono file (audio.auto_mono), -1 for all
+	monoBuf          []float32                   // scratch buffer for the mono downmix
+	partRate         int                         // sample rate of the current part's file
+	partDevice       string                      // capture device when the current part was opened
+	partChannels     int                         // capture channels of the current part, before any mono downmix
+	partClock        time.Time                   // wall-clock time of the part's first frame; zero until the next write anchors it
+	partFrames       int64                       // frames in the current part, including inserted silence
+	gapCause         string                      // why the stream was interrupted since the last write, if it was
+	resampler        *audio.Resampler            // converts a switched-to device's rate to partRate (loop goroutine only)
+	remixBuf         []float32                   // scratch buffer for channel remixing (loop goroutine only)
+	silenceBuf       []float32                   // zeros written by fillGap (loop goroutine only)
+	appCapture       *audio.AppCapture           // non-nil when per-app capture (Linux) is active
+	schedule         *schedule.Schedule          // allowed recording windows; nil records at any time
+	scheduleOpen     bool                        // schedule verdict at the last check
+	calendar         *calendar.Calendar          // events from calendar.files; nil when none are configured
+	calendarLoaded   time.Time                   // when calendar.files were last read
+	calendarChecked  time.Time                   // end of the span already scanned for event boundaries
+	splitPending     bool                        // an event boundary passed; loop() splits the session
+	held             *heldSession                // last session, kept open for session.merge_gap_seconds
+	stateMu          sync.Mutex                  // guards stateEvents; never held while taking e.mu
+	stateEvents      []metadata.TimelineEvent    // state transitions not yet added to the timeline
+	policy           trigger.Policy              // decides when sessions start and stop; see defaultPolicy
+	profile          *config.AppProfile          // app profile of the current session; nil for none
+	profileApp       string                      // the detected app that selected profile
+	disk             *diskguard.Guard            // free space of output.dir (loop goroutine only)
+	diskLevel        diskguard.Level             // disk level at the last check
+	diskStats        diskguard.Stats             // volume stats at the last check
+	diskChecked      time.Time                   // when free space was last read
+	diskErr          string                      // last error reading free space, logged once
+	paths            *recpath.Resolver           // names new recordings from output.filename_template
+	host             string                      // short host name for {host}
+	recipients       []*encrypt.Recipient        // encryption.recipients; nil when encryption is off
+	stagingDir       string                      // where recordings are written while encryption is on
+	uploader         *upload.Uploader            // upload.enabled; nil when upload is off
 }
 
+// diskCheckInterval is how often loop() reads the free space of output.dir.
+const diskCheckInterval = 5 * time.Second
+
 // StatusSnapshot is a point-in-time view of engine state for the UI.
 type StatusSnapshot struct {
-	State          string
-	DeviceName     string
-	CurrentFile    string
-	RecordingStart time.Time
-	SilenceElapsed time.Duration
-	FormatProfile  string
-	ZoomRunning    bool
-	TeamsRunning   bool
-	MeetRunning    bool
-	MicActive      bool
-	LastError      string
+	State           string
+	DeviceName      string
+	CurrentFile     string
+	RecordingStart  time.Time
+	SilenceElapsed  time.Duration
+	FormatProfile   string
+	AppProfile      string                      // app profile of the current session, if any
+	Apps            map[string]monitor.AppState // running meeting apps by name
+	MicActive       bool
+	ChannelWarnings []string // dead or identical channels in the live capture
+	// ScheduleOpen reports whether the schedule currently allows recording
+	// (always true without a schedule); NextScheduleChange is when that
+	// flips, zero if never.
+	ScheduleOpen       bool
+	NextScheduleChange time.Time
+	// DiskLevel is "ok", "low" or "critical" (see output.low_disk_mb);
+	// DiskFree the free bytes at the last check, 0 when not checked.
+	DiskLevel string
+	DiskFree  uint64
+	// UploadPending counts recordings not yet uploaded, UploadError is the
+	// most recent upload failure; both are zero when upload is off.
+	UploadPending int
+	UploadError   string
+	LastError     string
 }
 
 // New creates a new Engine with the given configuration.
@@ -89,14 +155,39 @@ func New(cfg config.Config, logger *log.Logger) *Engine {
 	if exitTh > 0 && exitTh < enterTh {
 		sm.SetThresholds(enterTh, exitTh)
 	}
+	sched, err := schedule.Compile(cfg.Schedule)
+	if err != nil {
+		logger.Printf("[schedule] invalid schedule, recording at any time: %v", err)
+	}
+	policy, err := trigger.Compile(cfg.Trigger)
+	if err != nil {
+		logger.Printf("[trigger] invalid trigger policy, using the default: %v", err)
+	}
+	if policy == nil {
+		policy = defaultPolicy(cfg.Monitoring)
+	}
+	paths, err := recpath.NewResolver(cfg.Output.FilenameTemplate, cfg.Output.DirTemplate)
+	if err != nil {
+		logger.Printf("[output] invalid file name templates, using the defaults: %v", err)
+		paths = recpath.Default()
+	}
+	host, _ := os.Hostname()
+	host, _, _ = strings.Cut(host, ".")
 	return &Engine{
 		cfg:            cfg,
 		sm:             sm,
-		mon:            monitor.New(),
+		mon:            monitor.New(cfg.Monitoring.AppRules()),
 		logger:         logger,
 		stopCh:         make(chan struct{}),
-		formatSpec:     audio.GetFormatSpec(cfg.Audio.FormatProfile),
+		formatSpec:     formatSpecFor(cfg.Audio),
 		deviceSwitchCh: make(chan deviceSwitchReq, 1),
+		monoChannel:    -1,
+		schedule:       sched,
+		policy:         policy,
+		scheduleOpen:   true,
+		paths:          paths,
+		host:           host,
+		disk:           diskguard.New(cfg.Output.Dir, cfg.Output.LowDiskMB, cfg.Output.CriticalDiskMB, nil),
 	}
 }
 
@@ -113,10 +204,16 @@ func (e *Engine) SetFormatProfile(profile string) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 	e.cfg.Audio.FormatProfile = profile
-	e.formatSpec = audio.GetFormatSpec(profile)
+	e.formatSpec = formatSpecFor(e.cfg.Audio)
 	e.logger.Printf("Format profile changed to: %s", profile)
 }
 
+// formatSpecFor resolves the output format for the configured profile,
+// applying the configured WAV sample format and dither.
+func formatSpecFor(ac config.AudioConfig) audio.FormatSpec {
+	return audio.GetFormatSpec(ac.FormatProfile).WithSampleFormat(ac.SampleFormat, ac.Dither)
+}
+
 // FormatProfile returns the current format profile name.
 func (e *Engine) FormatProfile() string {
 	e.mu.Lock()
@@ -136,12 +233,41 @@ func (e *Engine) Start() error {
 	if err := os.MkdirAll(e.outputDir, 0755); err != nil {
 		return fmt.Errorf("create output dir: %w", err)
 	}
+	if e.cfg.Encryption.Enabled {
+		if err := e.initEncryption(); err != nil {
+			return err
+		}
+	}
+	if e.cfg.Upload.Enabled {
+		e.initUpload()
+	}
+	// Collect recordings left unfinalized by a previous run before any new
+	// session can create its own marker; repair them in the background.
+	var orphans []string
+	for _, dir := range []string{e.outputDir, e.stagingDir} {
+		if dir == "" {
+			continue
+		}
+		found, err := metadata.FindInProgress(dir)
+		if err != nil {
+			e.logger.Printf("[recover] scan failed: %v", err)
+		}
+		orphans = append(orphans, found...)
+	}
+	if len(orphans) > 0 {
+		e.logger.Printf("[recover] found %d unfinalized recording(s)", len(orphans))
+		e.finalizing.Add(1)
+		go e.recoverOrphans(orphans)
+	}
+	if len(e.cfg.Calendar.Files) > 0 {
+		e.loadCalendar()
+	}
 	if err := audio.Init(); err != nil {
 		return fmt.Errorf("audio init: %w", err)
 	}
 	dev, err := e.findDevice()
 	if err != nil {
-		audio.Terminate()
+		e.releaseAudio()
 		return err
 	}
 	e.logger.Printf("Using device: %s (idx=%d ch=%d rate=%.0f)",
@@ -163,24 +289,33 @@ func (e *Engine) Start() error {
 		FramesPerBuffer: 4096,
 	})
 	if err != nil {
-		audio.Terminate()
+		e.releaseAudio()
 		return fmt.Errorf("open stream: %w", err)
 	}
-	e.stream = stream
+	e.mu.Lock()
+	e.setStreamLocked(stream)
+	e.mu.Unlock()
 	if err := stream.Start(); err != nil {
 		stream.Close()
-		audio.Terminate()
+		e.mu.Lock()
+		e.setStreamLocked(nil)
+		e.mu.Unlock()
+		e.releaseAudio()
 		return fmt.Errorf("start stream: %w", err)
 	}
 	e.mu.Lock()
 	e.running = true
 	e.stopCh = make(chan struct{})
 	e.mu.Unlock()
-	e.sm.SetOnStateChange(func(from, to statemachine.State) {
-		e.logger.Printf("State: %s -> %s", from, to)
-	})
+	e.sm.SetOnStateChange(e.onStateChange)
 	go e.loop()
 	go e.pollMonitor()
+	if e.cfg.Retention.Enabled {
+		go e.pruneLoop()
+	}
+	if e.uploader != nil {
+		go e.uploader.Run(e.stopCh)
+	}
 	e.logger.Printf("Started (threshold=%.4f silence=%ds format=%s)",
 		e.cfg.Audio.Threshold, e.cfg.Audio.SilenceSeconds, e.cfg.Audio.FormatProfile)
 	return nil
@@ -197,14 +332,77 @@ func (e *Engine) Stop() {
 	close(e.stopCh)
 	e.mu.Unlock()
 	e.finalizeRecording(metadata.ReasonShutdown)
+	e.flushHeld()
+	e.finalizing.Wait()
 	if e.stream != nil {
 		e.stream.Stop()
 		e.stream.Close()
 	}
+	e.closeAppCapture()
 	audio.Terminate()
 	e.logger.Println("Engine stopped")
 }
 
+// releaseAudio tears down per-app capture, which findDevice may have set
+// up, and PortAudio when Start fails after audio.Init, so that a retried
+// Start begins from a clean slate. No goroutine uses them yet.
+func (e *Engine) releaseAudio() {
+	e.closeAppCapture()
+	audio.Terminate()
+}
+
+// closeAppCapture tears down per-app capture, if any, and forgets it so
+// that a later Start sets it up afresh. pollMonitor may still be polling
+// while Stop runs: it reads the field under e.mu, and a Refresh after
+// Close does nothing.
+func (e *Engine) closeAppCapture() {
+	e.mu.Lock()
+	ac := e.appCapture
+	e.appCapture = nil
+	e.mu.Unlock()
+	if ac == nil {
+		return
+	}
+	if err := ac.Close(); err != nil {
+		e.logger.Printf("[appcapture] teardown failed: %v", err)
+	}
+}
+
+// SetThresholds changes the enter and exit RMS thresholds while running.
+// An exit threshold of 0, or one above enter, disables hysteresis as
+// audio.exit_threshold does. The change is noted in the session timeline.
+func (e *Engine) SetThresholds(enter, exit float64) {
+	e.mu.Lock()
+	e.cfg.Audio.Threshold = enter
+	e.cfg.Audio.ExitThreshold = exit
+	e.recordEventLocked(metadata.TimelineThreshold, fmt.Sprintf("threshold=%.4f exit_threshold=%.4f", enter, exit))
+	// An app profile's own thresholds stay in effect for its session.
+	e.applyProfileLocked(e.profile, e.profileApp)
+	e.mu.Unlock()
+	e.logger.Printf("Thresholds changed: threshold=%.4f exit_threshold=%.4f", enter, exit)
+}
+
+// ErrNotRecording is returned by Mark when no session is being recorded.
+var ErrNotRecording = errors.New("not recording: markers can only be added while a session is being recorded")
+
+// Mark adds a marker with an optional label at the current position of the
+// session being recorded. It is stored in the sidecar and in the file.
+func (e *Engine) Mark(label string) (metadata.Marker, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.writer == nil {
+		return metadata.Marker{}, ErrNotRecording
+	}
+	m := metadata.Marker{At: time.Now(), SampleOffset: e.partFrames, Label: label}
+	if e.partRate > 0 {
+		m.FileSeconds = float64(e.partFrames) / float64(e.partRate)
+	}
+	e.sessionDiag.Markers = append(e.sessionDiag.Markers, m)
+	e.logger.Printf("[mark] %q at %s in %s", label,
+		time.Duration(m.FileSeconds*float64(time.Second)).Truncate(time.Second), filepath.Base(e.currentFile))
+	return m, nil
+}
+
 // Status returns a human-readable status string.
 func (e *Engine) Status() string {
 	e.mu.Lock()
@@ -215,18 +413,39 @@ func (e *Engine) Status() string {
 	if state == statemachine.StateRecording || state == statemachine.StateSilenceWait {
 		s += fmt.Sprintf(" | File: %s", filepath.Base(e.currentFile))
 		s += fmt.Sprintf(" | Duration: %s", time.Since(e.recordStart).Truncate(time.Second))
+		if e.profile != nil {
+			s += " | Profile: " + e.profile.Name
+		}
 	}
 	if state == statemachine.StateSilenceWait {
 		s += fmt.Sprintf(" | Silence: %s", e.sm.SilenceElapsed().Truncate(time.Second))
 	}
-	if snap.ZoomRunning {
-		s += " | Zoom"
+	for _, app := range snap.RunningApps() {
+		if st, ok := snap.Apps[app]; ok {
+			s += " | " + app
+			if st.InCall {
+				s += " (call)"
+			}
+		}
 	}
-	if snap.TeamsRunning {
-		s += " | Teams"
+	if e.schedule != nil {
+		verdict := "closed"
+		if e.schedule.Allowed(time.Now()) {
+			verdict = "open"
+		}
+		s += " | Schedule: " + verdict
+		if next, ok := e.schedule.Next(time.Now()); ok {
+			s += " until " + next.In(e.schedule.Location()).Format("Mon 15:04")
+		}
 	}
-	if snap.MeetRunning {
-		s += " | Meet"
+	if e.diskLevel != diskguard.OK {
+		s += fmt.Sprintf(" | Disk: %s (%s free)", e.diskLevel, diskguard.FormatBytes(e.diskStats.Free))
+	}
+	if e.uploader != nil {
+		s += " | Upload: " + e.uploader.Status().String()
+	}
+	for _, w := range e.channelReport.Warnings() {
+		s += " | WARNING: " + w
 	}
 	return s
 }
@@ -237,21 +456,179 @@ func (e *Engine) GetStatus() StatusSnapshot {
 	defer e.mu.Unlock()
 	state := e.sm.CurrentState()
 	snap := e.mon.Current()
+	now := time.Now()
+	next, _ := e.schedule.Next(now)
+	var up upload.Status
+	if e.uploader != nil {
+		up = e.uploader.Status()
+	}
 	return StatusSnapshot{
-		State:          string(state),
-		DeviceName:     e.deviceName,
-		CurrentFile:    e.currentFile,
-		RecordingStart: e.recordStart,
-		SilenceElapsed: e.sm.SilenceElapsed(),
-		FormatProfile:  e.cfg.Audio.FormatProfile,
-		ZoomRunning:    snap.ZoomRunning,
-		TeamsRunning:   snap.TeamsRunning,
-		MeetRunning:    snap.MeetRunning,
-		MicActive:      snap.MicActive,
-		LastError:      e.lastError,
+		State:              string(state),
+		DeviceName:         e.deviceName,
+		CurrentFile:        e.currentFile,
+		RecordingStart:     e.recordStart,
+		SilenceElapsed:     e.sm.SilenceElapsed(),
+		FormatProfile:      e.cfg.Audio.FormatProfile,
+		AppProfile:         profileName(e.profile),
+		Apps:               snap.Apps,
+		MicActive:          snap.MicActive,
+		ChannelWarnings:    e.channelReport.Warnings(),
+		ScheduleOpen:       e.schedule.Allowed(now),
+		NextScheduleChange: next,
+		DiskLevel:          e.diskLevel.String(),
+		DiskFree:           e.diskStats.Free,
+		UploadPending:      up.Pending,
+		UploadError:        up.LastError,
+		LastError:          e.lastError,
 	}
 }
 
+// checkSchedule evaluates the recording schedule at now and reports whether
+// recording is allowed. When the allowed window has just closed, an active
+// session is finalized with ReasonScheduleClosed and the state machine is
+// reset so it cannot arm until the window reopens.
+func (e *Engine) checkSchedule(now time.Time) bool {
+	if e.schedule == nil {
+		return true
+	}
+	allowed := e.schedule.Allowed(now)
+	e.mu.Lock()
+	changed := allowed != e.scheduleOpen
+	e.scheduleOpen = allowed
+	e.mu.Unlock()
+	if !changed {
+		return allowed
+	}
+	until := "further notice"
+	if next, ok := e.schedule.Next(now); ok {
+		until = next.In(e.schedule.Location()).Format("Mon 15:04")
+	}
+	if allowed {
+		e.logger.Printf("[schedule] recording window open until %s", until)
+		return true
+	}
+	e.logger.Printf("[schedule] recording window closed until %s", until)
+	if st := e.sm.CurrentState(); st == statemachine.StateRecording || st == statemachine.StateSilenceWait {
+		e.finalizeRecording(metadata.ReasonScheduleClosed)
+	}
+	e.sm.Reset()
+	return false
+}
+
+// checkDisk reads the free space of output.dir, and of the staging dir
+// while encryption is on, at most every diskCheckInterval and reports
+// whether recording may go on. When space falls below the
+// output.critical_disk_mb of either, an active session is finalized
+// with ReasonLowDisk and the state machine is reset so it cannot arm
+// until space recovers. Below output.low_disk_mb new sessions use
+// output.low_disk_profile (see sessionProfileLocked). Called from loop().
+func (e *Engine) checkDisk(now time.Time) bool {
+	e.mu.Lock()
+	prev := e.diskLevel
+	due := e.disk.Enabled() && now.Sub(e.diskChecked) >= diskCheckInterval
+	if due {
+		e.diskChecked = now
+	}
+	e.mu.Unlock()
+	if !due {
+		return prev != diskguard.Critical
+	}
+	level, err := e.disk.Check()
+	stats, dir := e.disk.Stats(), e.disk.Dir()
+	e.mu.Lock()
+	e.diskLevel, e.diskStats = level, stats
+	logErr := err != nil && err.Error() != e.diskErr
+	if err != nil {
+		e.diskErr = err.Error()
+	} else {
+		e.diskErr = ""
+	}
+	e.mu.Unlock()
+	if logErr {
+		e.logger.Printf("[disk] %v", err)
+	}
+	if level == prev {
+		return level != diskguard.Critical
+	}
+	free := diskguard.FormatBytes(stats.Free)
+	switch level {
+	case diskguard.OK:
+		e.logger.Printf("[disk] free space recovered: %s free in %s", free, dir)
+	case diskguard.Low:
+		if prev == diskguard.Critical {
+			e.logger.Printf("[disk] free space recovered above output.critical_disk_mb: %s free, recording resumes", free)
+		}
+		msg := fmt.Sprintf("[disk] WARNING: low disk space: %s free in %s (output.low_disk_mb=%d)", free, dir, e.cfg.Output.LowDiskMB)
+		if e.cfg.Output.LowDiskProfile != "" {
+			msg += fmt.Sprintf("; new sessions use format %q", e.cfg.Output.LowDiskProfile)
+		}
+		e.logger.Print(msg)
+	case diskguard.Critical:
+		e.logger.Printf("[disk] CRITICAL: %s free in %s (output.critical_disk_mb=%d); recording paused until space recovers",
+			free, dir, e.cfg.Output.CriticalDiskMB)
+		if st := e.sm.CurrentState(); st == statemachine.StateRecording || st == statemachine.StateSilenceWait {
+			e.finalizeRecording(metadata.ReasonLowDisk)
+		}
+		e.sm.Reset()
+	}
+	return level != diskguard.Critical
+}
+
+// recordOverflow notes n input overflows (audio dropped by the capture
+// backend before the last buffer) in the current session's diagnostics.
+func (e *Engine) recordOverflow(n int64) {
+	e.mu.Lock()
+	recording := e.writer != nil
+	if recording {
+		e.sessionDiag.RecordOverflow(time.Now(), n)
+	}
+	total := e.sessionDiag.InputOverflows
+	e.mu.Unlock()
+	if recording {
+		e.logger.Printf("[audio] input overflow: audio dropped (%d this session)", total)
+	}
+}
+
+// checkChannels analyzes the channel levels of the last log window and logs
+// when a dead or identical channel appears or goes away. Quiet windows carry
+// no information and keep the previous verdict.
+func (e *Engine) checkChannels() {
+	e.mu.Lock()
+	if e.winLevels == nil {
+		e.mu.Unlock()
+		return
+	}
+	report := e.winLevels.Analyze(e.cfg.Audio.Threshold)
+	rms := e.winLevels.RMS()
+	e.winLevels.Reset()
+	if !report.Active {
+		e.mu.Unlock()
+		return
+	}
+	prev := e.channelReport
+	e.channelReport = report
+	e.mu.Unlock()
+
+	was, now := strings.Join(prev.Warnings(), "; "), strings.Join(report.Warnings(), "; ")
+	if was == now {
+		return
+	}
+	if now == "" {
+		e.logger.Printf("[channels] channel levels back to normal (rms=%s)", formatLevels(rms))
+		return
+	}
+	e.logger.Printf("[channels] WARNING: %s (rms=%v)", now, formatLevels(rms))
+}
+
+// formatLevels renders per-channel levels compactly for the log.
+func formatLevels(levels []float64) string {
+	parts := make([]string, len(levels))
+	for i, v := range levels {
+		parts[i] = fmt.Sprintf("%.4f", v)
+	}
+	return "[" + strings.Join(parts, " ") + "]"
+}
+
 // isMicActive returns whether any meeting app is currently using the microphone.
 func (e *Engine) isMicActive() bool {
 	e.mu.Lock()
@@ -268,6 +645,10 @@ func (e *Engine) loop() {
 
 	var lastReadErrLog time.Time // rate-limit unexpected Read errors to 1/s
 
+	// Input overflow baseline; reset when a device switch replaces the stream.
+	var overflowStream *audio.Stream
+	var overflowBase int64
+
 	for {
 		select {
 		case <-e.stopCh:
@@ -301,6 +682,9 @@ func (e *Engine) loop() {
 				}
 			default:
 				// Unexpected read failure — rate-limit to 1 log per second.
+				e.mu.Lock()
+				e.gapCause = "read_error"
+				e.mu.Unlock()
 				if time.Since(lastReadErrLog) >= time.Second {
 					e.logger.Printf("Read error: %v", err)
 					lastReadErrLog = time.Now()
@@ -310,45 +694,106 @@ func (e *Engine) loop() {
 			}
 			continue
 		}
+		if e.stream != overflowStream {
+			overflowStream, overflowBase = e.stream, e.stream.Overflows()
+		} else if n := e.stream.Overflows(); n > overflowBase {
+			e.recordOverflow(n - overflowBase)
+			overflowBase = n
+		}
+
 		rms := audio.RMS(buf)
 		if rms > peakRMS {
 			peakRMS = rms
 		}
 
-		// Track per-session diagnostics.
-		e.mu.Lock()
-		if e.writer != nil {
-			e.sessionDiag.FramesReceived += int64(len(buf) / e.stream.Channels())
-			e.sessionDiag.RecordRMS(rms)
-			if e.sessionDiag.FirstAudioTimestamp.IsZero() && rms > 0 {
-				e.sessionDiag.FirstAudioTimestamp = time.Now()
-			}
-			if rms > 0 {
-				e.sessionDiag.LastAudioTimestamp = time.Now()
-			}
-		}
-		e.mu.Unlock()
+		threshold, exitThreshold := e.trackBuffer(buf, rms)
 
 		if time.Since(lastRMSLog) >= 5*time.Second {
 			state := e.sm.CurrentState()
 			micActive := e.isMicActive()
-			e.logger.Printf("[audio] peak_rms=%.6f threshold=%.4f exit_threshold=%.4f state=%s mic_active=%v", peakRMS, e.cfg.Audio.Threshold, e.cfg.Audio.ExitThreshold, state, micActive)
+			e.logger.Printf("[audio] peak_rms=%.6f threshold=%.4f exit_threshold=%.4f state=%s mic_active=%v", peakRMS, threshold, exitThreshold, state, micActive)
 			peakRMS = 0
 			lastRMSLog = time.Now()
+			e.checkChannels()
 		}
-		// BlackHole RMS is the sole trigger for recording start/stop decisions.
-		// Mic activity is handled via the state machine's session lock, not by
-		// overriding the threshold here.
-		action := e.sm.ProcessAudio(rms, e.cfg.Audio.Threshold)
-		switch action {
-		case statemachine.ActionStartRecording:
-			e.startRecording()
-			e.writeAudio(buf) // write the buffer that triggered recording
-		case statemachine.ActionContinue:
-			e.writeAudio(buf)
-		case statemachine.ActionStopRecording:
-			e.finalizeRecording(metadata.ReasonSilenceTimeout)
-			e.sm.Reset()
+		e.handleBuffer(buf, rms, threshold)
+	}
+}
+
+// trackBuffer adds a captured buffer to the channel statistics and the
+// session diagnostics and returns the current thresholds. Called from
+// loop().
+func (e *Engine) trackBuffer(buf []float32, rms float64) (threshold, exitThreshold float64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	channels := e.captureChannels
+	if e.winLevels == nil || e.winLevels.Channels() != channels {
+		e.winLevels = audio.NewChannelLevels(channels)
+	}
+	e.winLevels.Add(buf)
+	if e.chanLevels != nil && e.chanLevels.Channels() == channels {
+		e.chanLevels.Add(buf)
+	}
+	if e.writer != nil {
+		e.sessionDiag.FramesReceived += int64(len(buf) / channels)
+		e.sessionDiag.RecordRMS(rms)
+		if e.sessionDiag.FirstAudioTimestamp.IsZero() && rms > 0 {
+			e.sessionDiag.FirstAudioTimestamp = time.Now()
+		}
+		if rms > 0 {
+			e.sessionDiag.LastAudioTimestamp = time.Now()
+		}
+	}
+	return e.thresholdsLocked()
+}
+
+// handleBuffer starts, continues or ends the session for a captured
+// buffer and writes the buffer to it. Called from loop().
+func (e *Engine) handleBuffer(buf []float32, rms, threshold float64) {
+	// Outside the schedule or with the disk nearly full the state machine
+	// is never fed, so it cannot arm.
+	if !e.checkSchedule(time.Now()) || !e.checkDisk(time.Now()) {
+		return
+	}
+	e.mu.Lock()
+	split := e.splitPending
+	e.splitPending = false
+	e.mu.Unlock()
+	if split {
+		e.splitSession()
+	}
+	// The trigger policy judges the BlackHole RMS together with the mic and
+	// the meeting apps (see decide).
+	action := e.decide(rms, threshold)
+	switch action {
+	case statemachine.ActionStartRecording:
+		e.startRecording()
+		e.writeAudio(buf) // write the buffer that triggered recording
+	case statemachine.ActionContinue:
+		e.writeAudio(buf)
+	case statemachine.ActionStopRecording:
+		e.finalizeRecording(metadata.ReasonSilenceTimeout)
+		e.sm.Reset()
+	}
+}
+
+// pruneLoop applies the retention rules at start and every
+// retention.interval_minutes. The session being recorded has no sidecar
+// yet, so it is never a candidate.
+func (e *Engine) pruneLoop() {
+	ticker := time.NewTicker(e.cfg.Retention.Interval())
+	defer ticker.Stop()
+	for {
+		res, err := retention.Prune(e.outputDir, e.cfg.Retention, time.Now(), false, e.logger)
+		if err != nil {
+			e.logger.Printf("[retention] prune failed: %v", err)
+		} else if len(res.Deleted) > 0 {
+			e.logger.Printf("[retention] deleted %d recording(s), %.1f MB freed", len(res.Deleted), float64(res.Bytes)/(1024*1024))
+		}
+		select {
+		case <-e.stopCh:
+			return
+		case <-ticker.C:
 		}
 	}
 }
@@ -360,24 +805,31 @@ func (e *Engine) pollMonitor() {
 	}
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
+	micLock := false
 	for {
 		select {
 		case <-e.stopCh:
 			return
 		case <-ticker.C:
+			e.mu.Lock()
+			ac := e.appCapture
+			e.mu.Unlock()
+			if ac != nil {
+				// Route streams of capture apps that started playing since the last poll.
+				if err := ac.Refresh(); err != nil {
+					e.logger.Printf("[appcapture] refresh failed: %v", err)
+				}
+			}
 			snap := e.mon.Poll()
 			prev := e.monSnapshot
 			e.mu.Lock()
 			e.monSnapshot = snap
+			e.noteAppsLocked(prev, snap)
 			e.mu.Unlock()
 			// Log only when something actually changed.
-			if snap.ZoomRunning != prev.ZoomRunning ||
-				snap.ZoomInCall != prev.ZoomInCall ||
-				snap.TeamsRunning != prev.TeamsRunning ||
-				snap.MeetRunning != prev.MeetRunning ||
-				snap.MicActive != prev.MicActive {
-				e.logger.Printf("[monitor] zoom_open=%v zoom_call=%v teams_open=%v meet=%v mic_active=%v mic_bundles=%v",
-					snap.ZoomRunning, snap.ZoomInCall, snap.TeamsRunning, snap.MeetRunning, snap.MicActive, snap.MicBundleIDs)
+			if !snap.Equal(prev) {
+				e.logger.Printf("[monitor] apps=%v in_call=%v mic_active=%v mic_bundles=%v",
+					snap.RunningApps(), snap.InCallApps(), snap.MicActive, snap.MicBundleIDs)
 			}
 			if !prev.MicActive && snap.MicActive {
 				e.logger.Printf("[monitor] mic became active — activating session lock and starting recording")
@@ -390,8 +842,9 @@ func (e *Engine) pollMonitor() {
 				// When none exists, record from the current device (typically BlackHole).
 				// Either way, start recording immediately via the device-switch channel:
 				// a nil device means "keep current device but still force-start".
+				// The trigger policy decides whether the mic starts a session.
 				meetDev := audio.FindMeetingAudioDeviceForBundles(snap.MicBundleIDs)
-				req := deviceSwitchReq{device: meetDev, startRec: true}
+				req := deviceSwitchReq{device: meetDev, startRec: e.policy.Decide(e.triggerSignals(time.Now())) == trigger.Start}
 				select {
 				case e.deviceSwitchCh <- req:
 					// BlackHole's AUHAL render callback is not driven when no system audio
@@ -435,6 +888,16 @@ func (e *Engine) pollMonitor() {
 					}
 				}
 			}
+			if lock := e.sm.MicLockActive(); lock != micLock {
+				micLock = lock
+				detail := "off"
+				if lock {
+					detail = "on"
+				}
+				e.mu.Lock()
+				e.recordEventLocked(metadata.TimelineMicLock, detail)
+				e.mu.Unlock()
+			}
 			// Fallback: if loop() is still blocked even after the switch-back (e.g.
 			// BlackHole is also idle), finalize directly from pollMonitor once the
 			// combined release-debounce + silence window has elapsed.
@@ -457,6 +920,8 @@ func (e *Engine) pollMonitor() {
 					e.mu.Unlock()
 				}
 			}
+			e.checkCalendar(time.Now())
+			e.checkTrigger(time.Now())
 		}
 	}
 }
@@ -465,77 +930,794 @@ func (e *Engine) startRecording() {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 	now := time.Now()
-	e.recordStart = now
-	e.sessionDiag = metadata.SessionDiagnostics{} // reset diagnostics for new session
+	if e.resumeHeldLocked(now) {
+		return
+	}
+	e.seriesID = now.Format("20060102T150405")
+	e.partIndex = 0
+	e.applyProfileLocked(e.sessionProfileLocked())
+	if err := e.openPartLocked(now); err != nil {
+		e.logger.Printf("Failed to create WAV: %v", err)
+		e.applyProfileLocked(nil, "")
+		e.sm.Reset()
+	}
+}
+
+// lowDiskProfile names the profile sessionProfileLocked makes up for
+// sessions no app profile matches while disk space is low.
+const lowDiskProfile = "low_disk"
 
-	profile := e.cfg.Audio.FormatProfile
+// profileAppsLocked returns the apps an app profile is matched against:
+// the apps in a call and the bundle IDs using the mic, or the running apps
+// when none is, so that Teams idling in the background does not claim an
+// ad-hoc session. Caller must hold e.mu.
+func (e *Engine) profileAppsLocked() []string {
+	if apps := e.monSnapshot.ActiveApps(); len(apps) > 0 {
+		return apps
+	}
+	return e.monSnapshot.RunningApps()
+}
+
+// sessionProfileLocked returns the app profile for a session starting now
+// and the app that selected it. While disk space is low, the profile's
+// format is replaced by output.low_disk_profile. Caller must hold e.mu.
+func (e *Engine) sessionProfileLocked() (*config.AppProfile, string) {
+	p, app := e.cfg.MatchAppProfile(e.profileAppsLocked())
+	format := e.cfg.Output.LowDiskProfile
+	if e.diskLevel == diskguard.OK || format == "" {
+		return p, app
+	}
+	low := config.AppProfile{Name: lowDiskProfile}
+	if p != nil {
+		low = *p
+	}
+	low.FormatProfile = format
+	return &low, app
+}
+
+// applyProfileLocked makes p the app profile of the current session (nil
+// for none) and gives the state machine its thresholds and silence split.
+// Caller must hold e.mu.
+func (e *Engine) applyProfileLocked(p *config.AppProfile, app string) {
+	if p != e.profile && p != nil {
+		e.logger.Printf("[profile] using app profile %q (app=%q)", p.Name, app)
+	}
+	e.profile, e.profileApp = p, app
+	enter, exit := e.thresholdsLocked()
+	if exit <= 0 || exit >= enter {
+		exit = 0
+	}
+	e.sm.SetThresholds(enter, exit)
+	e.sm.SetSilenceDuration(time.Duration(e.silenceSecondsLocked()) * time.Second)
+}
+
+// thresholdsLocked returns the enter and exit thresholds in effect: the
+// app profile's where it sets them, the audio settings otherwise. Caller
+// must hold e.mu.
+func (e *Engine) thresholdsLocked() (enter, exit float64) {
+	enter, exit = e.cfg.Audio.Threshold, e.cfg.Audio.ExitThreshold
+	if p := e.profile; p != nil {
+		if p.Threshold > 0 {
+			enter = p.Threshold
+		}
+		if p.ExitThreshold > 0 {
+			exit = p.ExitThreshold
+		}
+	}
+	return enter, exit
+}
+
+// silenceSecondsLocked returns the silence split in effect. Caller must
+// hold e.mu.
+func (e *Engine) silenceSecondsLocked() int {
+	if p := e.profile; p != nil && p.SilenceSeconds > 0 {
+		return p.SilenceSeconds
+	}
+	return e.cfg.Audio.SilenceSeconds
+}
+
+// formatSpecLocked returns the output format in effect. Caller must hold
+// e.mu.
+func (e *Engine) formatSpecLocked() audio.FormatSpec {
+	if p := e.profile; p != nil && p.FormatProfile != "" {
+		ac := e.cfg.Audio
+		ac.FormatProfile = p.FormatProfile
+		return formatSpecFor(ac)
+	}
+	return e.formatSpec
+}
+
+func profileName(p *config.AppProfile) string {
+	if p == nil {
+		return ""
+	}
+	return p.Name
+}
+
+// sessionAppLocked returns the meeting app a new session is named after:
+// the app that selected the app profile, else the first app in a call,
+// else the first running one. Bundle IDs using the mic are not app names
+// and never name a session on their own. Caller must hold e.mu.
+func (e *Engine) sessionAppLocked() string {
+	if e.profileApp != "" {
+		return e.profileApp
+	}
+	if apps := e.monSnapshot.InCallApps(); len(apps) > 0 {
+		return apps[0]
+	}
+	if apps := e.monSnapshot.AppNames(); len(apps) > 0 {
+		return apps[0]
+	}
+	return ""
+}
+
+// openPartLocked creates the WAV file for a new recording part starting at
+// now and makes it the current writer. Caller must hold e.mu.
+func (e *Engine) openPartLocked(now time.Time) error {
+	spec := e.formatSpecLocked()
+	profile := string(spec.Profile)
 	if profile == "" {
 		profile = "high"
 	}
+	var sub string
+	fields := recpath.Fields{
+		Time:       now,
+		Profile:    profile,
+		Device:     e.deviceName,
+		App:        e.sessionAppLocked(),
+		AppProfile: profileName(e.profile),
+		Host:       e.host,
+	}
+	if p := e.profile; p != nil {
+		sub = p.Subdir
+		fields.Tag = p.Tag
+	}
+	if o, ok := e.calendar.Best(now, now.Add(time.Second)); ok {
+		fields.Title = o.Summary
+	}
 
-	// Always record to WAV first; convert on finalize if M4A profile.
-	filename := fmt.Sprintf("%s_audio_%s.wav",
-		now.Format("2006-01-02_150405"), profile)
-	path := filepath.Join(e.outputDir, filename)
-	w, err := wav.Create(path, e.stream.SampleRate(), e.stream.Channels())
+	// Always record to WAV first; convert on finalize if M4A profile. A
+	// rollover within the same second as the previous part gets a "_2"
+	// suffix from the resolver. With encryption the WAV is written to the
+	// staging directory.
+	var staging string
+	if e.stagingDir != "" {
+		staging = filepath.Join(e.stagingDir, sub)
+	}
+	path, err := e.paths.StagedPath(filepath.Join(e.outputDir, sub), staging, fields, recpath.ExtWAV)
 	if err != nil {
-		e.logger.Printf("Failed to create WAV: %v", err)
-		e.sm.Reset()
-		return
+		return err
+	}
+	sampleFormat, err := wav.ParseSampleFormat(spec.SampleFormat)
+	if err != nil {
+		e.logger.Printf("Invalid sample format, using 16-bit: %v", err)
+	}
+	// With auto_mono, keep only the live channel of a capture already known
+	// to have a dead channel or identical channels.
+	channels := e.captureChannels
+	e.monoChannel = -1
+	if e.cfg.Audio.AutoMono && channels > 1 {
+		if c := e.channelReport.LiveChannel(channels); c >= 0 {
+			e.monoChannel = c
+			e.logger.Printf("[channels] auto_mono: recording channel %d only (%s)", c+1, strings.Join(e.channelReport.Warnings(), "; "))
+		}
+	}
+	fileChannels := channels
+	if e.monoChannel >= 0 {
+		fileChannels = 1
+	}
+	checkpoint := time.Duration(e.cfg.Output.CheckpointSeconds) * time.Second
+	w, err := wav.Create(path, e.captureRate, fileChannels,
+		wav.WithSampleFormat(sampleFormat), wav.WithDither(spec.Dither),
+		wav.WithCheckpointInterval(checkpoint))
+	if err != nil {
+		return err
+	}
+	marker := metadata.InProgress{
+		StartedAt:     now,
+		FormatProfile: profile,
+		DeviceName:    e.deviceName,
+		AppVersion:    e.version,
+	}
+	if err := metadata.WriteInProgress(path, marker); err != nil {
+		e.logger.Printf("[recover] failed to write in-progress marker: %v", err)
 	}
 	e.writer = w
 	e.currentFile = path
-	e.logger.Printf("Recording started: %s (format=%s)", filename, profile)
+	e.recordStart = now
+	e.partRate = e.captureRate
+	e.partDevice = e.deviceName
+	e.partChannels = channels
+	e.partClock = time.Time{}
+	e.partFrames = 0
+	e.sessionDiag = metadata.SessionDiagnostics{} // reset diagnostics for new part
+	e.chanLevels = audio.NewChannelLevels(channels)
+	// Open the timeline with the transitions that started the session,
+	// the meeting apps already running and the mic lock.
+	e.takeStateEventsLocked()
+	e.noteAppsLocked(monitor.Snapshot{}, e.monSnapshot)
+	if e.sm.MicLockActive() {
+		e.recordEventLocked(metadata.TimelineMicLock, "on")
+	}
+	e.logger.Printf("Recording started: %s (format=%s sample_format=%s)", filepath.Base(path), profile, sampleFormat)
+	return nil
 }
 
 func (e *Engine) writeAudio(samples []float32) {
 	e.mu.Lock()
 	w := e.writer
-	ch := 0
-	if e.stream != nil {
-		ch = e.stream.Channels()
+	ch, rate := e.captureChannels, e.captureRate
+	partCh, partRate := e.partChannels, e.partRate
+	mono := e.monoChannel
+	cause := e.gapCause
+	e.gapCause = ""
+	if w != nil {
+		e.takeStateEventsLocked()
 	}
 	e.mu.Unlock()
-	if w == nil {
+	if w == nil || partCh == 0 {
 		return
 	}
-	if err := w.Write(samples); err != nil {
+	in, resampled := e.conformAudio(samples, ch, rate, partCh, partRate)
+	frames := int64(len(in) / partCh)
+	e.fillGap(w, frames, cause)
+
+	out := in
+	if mono >= 0 && partCh > 1 {
+		// monoBuf is only touched from the loop goroutine.
+		e.monoBuf = audio.ExtractChannel(e.monoBuf, in, partCh, mono)
+		out = e.monoBuf
+	}
+	if err := w.Write(out); err != nil {
 		e.logger.Printf("Write error: %v", err)
 		return
 	}
 	// Track write diagnostics.
-	frames := int64(len(samples))
-	if ch > 0 {
-		frames = int64(len(samples)) / int64(ch)
-	}
-	bytesWritten := int64(len(samples)) * 2 // 16-bit PCM = 2 bytes per sample
+	bytesWritten := int64(len(out)) * int64(w.BytesPerSample())
 	e.mu.Lock()
+	e.partFrames += frames
 	e.sessionDiag.FramesWritten += frames
 	e.sessionDiag.BytesWritten += bytesWritten
+	if resampled {
+		e.sessionDiag.Resampled = true
+	}
 	e.mu.Unlock()
+
+	if e.partLimitReached(w) {
+		e.rollover()
+	}
 }
 
-func (e *Engine) finalizeRecording(reason metadata.FinalizationReason) {
+// conformAudio converts a captured buffer to the current part's channel
+// count and sample rate. They only differ after a mid-session device switch;
+// the file keeps the geometry it was created with. It reports whether the
+// buffer was resampled.
+func (e *Engine) conformAudio(samples []float32, ch, rate, partCh, partRate int) ([]float32, bool) {
+	out := samples
+	if ch > 0 && ch != partCh {
+		e.remixBuf = audio.Remix(e.remixBuf, out, ch, partCh)
+		out = e.remixBuf
+	}
+	if rate <= 0 || rate == partRate {
+		e.resampler = nil
+		return out, false
+	}
+	r := e.resampler
+	if r == nil || r.From() != rate || r.To() != partRate || r.Channels() != partCh {
+		r = audio.NewResampler(rate, partRate, partCh)
+		e.resampler = r
+		e.logger.Printf("[engine] resampling capture from %d Hz to the recording's %d Hz", rate, partRate)
+	}
+	return r.Process(out), true
+}
+
+// fillGap keeps the current part aligned with the wall clock. Before a
+// buffer of n frames is written after a discontinuity (cause is set: a Read
+// failed or the stream was switched or restarted), it compares the part's
+// length with the monotonic time elapsed since its first frame. When the
+// file has fallen behind by more than audio.gap_tolerance_ms, the
+// difference is written as silence and recorded as a gap in the sidecar.
+// Between discontinuities the anchor only follows the device clock's drift,
+// so a device running slightly slow never produces gaps.
+func (e *Engine) fillGap(w *wav.Writer, n int64, cause string) {
+	now := time.Now()
 	e.mu.Lock()
-	w := e.writer
-	file := e.currentFile
-	start := e.recordStart
-	snap := e.monSnapshot
-	spec := e.formatSpec
-	diag := e.sessionDiag
+	rate, clock, written := e.partRate, e.partClock, e.partFrames
+	if clock.IsZero() {
+		// The buffer about to be written ends now.
+		e.partClock = now.Add(-framesDuration(written+n, rate))
+		e.mu.Unlock()
+		return
+	}
+	if cause == "" {
+		e.partClock = driftAnchor(clock, now, written+n, rate)
+		e.mu.Unlock()
+		return
+	}
+	e.mu.Unlock()
+
+	// A gap longer than the silence timeout would have ended the session had
+	// it been captured as silence, so at most that much is filled.
+	maxFill := int64(e.cfg.Audio.SilenceSeconds) * int64(rate)
+	behind, fill := gapFrames(now.Sub(clock), written+n, rate, e.cfg.Audio.GapToleranceMs, maxFill)
+	if fill == 0 {
+		return
+	}
+	if err := e.writeSilence(w, fill); err != nil {
+		e.logger.Printf("[gap] failed to fill gap: %v", err)
+		return
+	}
+	gap := metadata.GapEvent{
+		At:              now,
+		OffsetSeconds:   float64(written) / float64(rate),
+		DurationSeconds: float64(behind) / float64(rate),
+		FilledSeconds:   float64(fill) / float64(rate),
+		Cause:           cause,
+	}
+	e.mu.Lock()
+	e.partFrames += fill
+	// Re-anchor past any remainder that was not filled.
+	e.partClock = e.partClock.Add(framesDuration(behind-fill, rate))
+	e.sessionDiag.FramesWritten += fill
+	e.sessionDiag.BytesWritten += fill * int64(w.Channels()*w.BytesPerSample())
+	e.sessionDiag.RecordGap(gap)
+	e.mu.Unlock()
+	e.logger.Printf("[gap] %.2fs of audio missing at %.2fs (cause=%s), filled %.2fs with silence",
+		gap.DurationSeconds, gap.OffsetSeconds, cause, gap.FilledSeconds)
+}
+
+// driftSmoothing is how many buffers the part's anchor takes to follow
+// the device clock: large enough to average out the jitter of single
+// buffers, small enough to track a drift of hundreds of ppm.
+const driftSmoothing = 64
+
+// driftAnchor moves a part's anchor clock a step towards where a part of
+// written frames, ending at now, would start, so the anchor follows a
+// device clock running slower or faster than the system clock.
+func driftAnchor(clock, now time.Time, written int64, rate int) time.Time {
+	offset := now.Sub(clock) - framesDuration(written, rate)
+	return clock.Add(offset / driftSmoothing)
+}
+
+// gapFrames returns how many frames a part is behind the wall clock after
+// elapsed time with written frames, and how many of them to fill with
+// silence: none within toleranceMs, otherwise all of them up to maxFill.
+func gapFrames(elapsed time.Duration, written int64, rate, toleranceMs int, maxFill int64) (behind, fill int64) {
+	if toleranceMs <= 0 || rate <= 0 {
+		return 0, 0
+	}
+	behind = int64(elapsed.Seconds()*float64(rate)) - written
+	if behind*1000 <= int64(toleranceMs)*int64(rate) {
+		return behind, 0
+	}
+	fill = behind
+	if maxFill > 0 && fill > maxFill {
+		fill = maxFill
+	}
+	return behind, fill
+}
+
+// writeSilence appends n frames of silence to w.
+func (e *Engine) writeSilence(w *wav.Writer, n int64) error {
+	const chunk = 4096
+	ch := int64(w.Channels())
+	if len(e.silenceBuf) < chunk*int(ch) {
+		e.silenceBuf = make([]float32, chunk*int(ch))
+	}
+	for n > 0 {
+		k := n
+		if k > chunk {
+			k = chunk
+		}
+		if err := w.Write(e.silenceBuf[:k*ch]); err != nil {
+			return err
+		}
+		n -= k
+	}
+	return nil
+}
+
+// framesDuration converts a frame count at rate to a duration.
+func framesDuration(frames int64, rate int) time.Duration {
+	if rate <= 0 {
+		return 0
+	}
+	return time.Duration(frames) * time.Second / time.Duration(rate)
+}
+
+// partLimitReached reports whether the current part has reached
+// session.max_duration or session.max_size_mb. Duration is measured in
+// written audio, not wall time, so parts have exact lengths.
+func (e *Engine) partLimitReached(w *wav.Writer) bool {
+	maxDur := e.cfg.Session.MaxDuration
+	if maxDur > 0 && w.DurationSeconds() >= maxDur.Seconds() {
+		return true
+	}
+	maxBytes := int64(e.cfg.Session.MaxSizeMB) * 1024 * 1024
+	return maxBytes > 0 && w.DataBytes() >= maxBytes
+}
+
+// rollover continues the recording in a new part. The next part is opened
+// before the current one is detached, and both happen between two writes on
+// the loop goroutine, so no samples are lost between parts. The finished
+// part is finalized (closed, converted, sidecar written) in the background.
+func (e *Engine) rollover() {
+	e.mu.Lock()
+	if e.writer == nil {
+		e.mu.Unlock()
+		return
+	}
+	clock, frames, rate := e.partClock, e.partFrames, e.partRate
+	prev := e.detachLocked()
+	if e.partIndex == 0 {
+		e.partIndex = 1
+	}
+	prev.part = e.partIndex
+	e.partIndex++
+	if err := e.openPartLocked(prev.end); err != nil {
+		// Keep recording into the current file rather than dropping audio.
+		e.logger.Printf("[rollover] failed to open next part, continuing in %s: %v", filepath.Base(prev.file), err)
+		e.reattachLocked(prev)
+		e.partIndex--
+		e.mu.Unlock()
+		return
+	}
+	// The next part starts exactly where the previous one ended.
+	if !clock.IsZero() {
+		e.partClock = clock.Add(framesDuration(frames, rate))
+	}
+	next := e.currentFile
+	e.mu.Unlock()
+
+	e.logger.Printf("[rollover] part %d of series %s reached its limit, continuing in %s",
+		prev.part, prev.seriesID, filepath.Base(next))
+	e.finalizing.Add(1)
+	go func() {
+		defer e.finalizing.Done()
+		e.finalizeSession(prev, metadata.ReasonRollover)
+	}()
+}
+
+// splitSession ends the current session at a calendar event boundary and
+// continues recording in a new one. As with rollover, the new file is opened
+// before the old one is finalized in the background, so no audio is lost.
+func (e *Engine) splitSession() {
+	e.mu.Lock()
+	if e.writer == nil {
+		e.mu.Unlock()
+		return
+	}
+	prev := e.detachLocked()
+	seriesID, partIndex := e.seriesID, e.partIndex
+	e.seriesID = prev.end.Format("20060102T150405")
+	e.partIndex = 0
+	e.applyProfileLocked(e.sessionProfileLocked())
+	if err := e.openPartLocked(prev.end); err != nil {
+		e.logger.Printf("[calendar] failed to start a new session, continuing in %s: %v", filepath.Base(prev.file), err)
+		e.reattachLocked(prev)
+		e.seriesID, e.partIndex = seriesID, partIndex
+		e.mu.Unlock()
+		return
+	}
+	next := e.currentFile
+	e.mu.Unlock()
+
+	e.logger.Printf("[calendar] event boundary: finalizing %s, continuing in %s",
+		filepath.Base(prev.file), filepath.Base(next))
+	e.finalizing.Add(1)
+	go func() {
+		defer e.finalizing.Done()
+		e.finalizeSession(prev, metadata.ReasonEventBoundary)
+	}()
+}
+
+// reattachLocked makes a detached part current again after the next part
+// failed to open. Caller must hold e.mu.
+func (e *Engine) reattachLocked(sess recordingSession) {
+	e.writer = sess.writer
+	e.currentFile = sess.file
+	e.recordStart = sess.start
+	e.sessionDiag = sess.diag
+	e.monoChannel = sess.mono
+	e.partDevice = sess.device
+	e.applyProfileLocked(sess.profile, sess.profileApp)
+}
+
+// recordingSession is one recording part detached from the engine for
+// finalization.
+type recordingSession struct {
+	writer   *wav.Writer
+	file     string
+	start    time.Time
+	end      time.Time
+	snap     monitor.Snapshot
+	spec     audio.FormatSpec
+	diag     metadata.SessionDiagnostics
+	seriesID string
+	part     int    // 1-based part index, 0 when the session was never split
+	mono     int    // source channel kept in a mono file, -1 for all channels
+	device   string // capture device when the part was opened; switches are in the timeline
+
+	// Thresholds and silence split in effect when the part ended (see
+	// SetThresholds and app profiles).
+	threshold, exitThreshold float64
+	silenceSeconds           int
+
+	profile    *config.AppProfile // app profile of the session, nil for none
+	profileApp string
+}
+
+// detachLocked takes the current part out of the engine so it can be
+// finalized without holding e.mu. Caller must hold e.mu.
+func (e *Engine) detachLocked() recordingSession {
+	if e.writer != nil {
+		e.takeStateEventsLocked()
+	}
+	threshold, exitThreshold := e.thresholdsLocked()
+	sess := recordingSession{
+		writer:   e.writer,
+		file:     e.currentFile,
+		start:    e.recordStart,
+		end:      time.Now(),
+		snap:     e.monSnapshot,
+		spec:     e.formatSpecLocked(),
+		diag:     e.sessionDiag,
+		seriesID: e.seriesID,
+		part:     e.partIndex,
+		mono:     e.monoChannel,
+		device:   e.partDevice,
+
+		threshold:      threshold,
+		exitThreshold:  exitThreshold,
+		silenceSeconds: e.silenceSecondsLocked(),
+		profile:        e.profile,
+		profileApp:     e.profileApp,
+	}
+	if l := e.chanLevels; l != nil && l.Frames() > 0 {
+		report := l.Analyze(threshold)
+		sess.diag.ChannelRMS = l.RMS()
+		sess.diag.ChannelPeak = l.Peak()
+		sess.diag.ChannelClips = l.Clips()
+		sess.diag.DeadChannels = report.DeadChannels
+		sess.diag.IdenticalChannels = report.Identical
+	}
+	if n := len(sess.diag.Segments); n > 0 && sess.diag.Segments[n-1].End.IsZero() {
+		// Close the segment recorded since the last merge.
+		segs := append([]metadata.SessionSegment(nil), sess.diag.Segments...)
+		segs[n-1].End = sess.end
+		if e.partRate > 0 {
+			segs[n-1].DurationSeconds = float64(e.partFrames)/float64(e.partRate) - segs[n-1].FileSeconds
+		}
+		sess.diag.Segments = segs
+	}
 	e.writer = nil
 	e.currentFile = ""
+	e.chanLevels = nil
+	return sess
+}
+
+func (e *Engine) finalizeRecording(reason metadata.FinalizationReason) {
+	e.mu.Lock()
+	levels := e.chanLevels
+	sess := e.detachLocked()
+	e.applyProfileLocked(nil, "")
+	if sess.writer != nil && reason == metadata.ReasonSilenceTimeout && e.cfg.Session.MergeGapSeconds > 0 {
+		e.holdLocked(sess, reason, levels)
+		e.mu.Unlock()
+		return
+	}
 	e.mu.Unlock()
-	if w == nil {
+	if sess.writer == nil {
+		return
+	}
+	e.finalizeSession(sess, reason)
+}
+
+// onStateChange logs a state machine transition and queues it for the
+// session timeline. It runs with the state machine locked while e.mu may
+// be held by a caller, so it must not take e.mu; the next write or the
+// end of the part moves the queue into the timeline.
+func (e *Engine) onStateChange(from, to statemachine.State) {
+	e.logger.Printf("State: %s -> %s", from, to)
+	e.stateMu.Lock()
+	defer e.stateMu.Unlock()
+	if to == statemachine.StateIdle {
+		// Whatever happened before returning to idle belongs to no session.
+		e.stateEvents = e.stateEvents[:0]
+	}
+	e.stateEvents = append(e.stateEvents, metadata.TimelineEvent{
+		At:     time.Now(),
+		Kind:   metadata.TimelineState,
+		Detail: fmt.Sprintf("%s -> %s", from, to),
+	})
+}
+
+// takeStateEventsLocked moves queued state transitions into the current
+// part's timeline. Caller must hold e.mu.
+func (e *Engine) takeStateEventsLocked() {
+	e.stateMu.Lock()
+	events := e.stateEvents
+	e.stateEvents = nil
+	e.stateMu.Unlock()
+	for _, ev := range events {
+		ev.SampleOffset = e.partFrames
+		e.sessionDiag.RecordEvent(ev)
+	}
+}
+
+// recordEventLocked adds an event to the current part's timeline; it does
+// nothing while not recording. Caller must hold e.mu.
+func (e *Engine) recordEventLocked(kind metadata.TimelineKind, detail string) {
+	if e.writer == nil {
+		return
+	}
+	e.takeStateEventsLocked()
+	e.sessionDiag.RecordEvent(metadata.TimelineEvent{
+		At:           time.Now(),
+		SampleOffset: e.partFrames,
+		Kind:         kind,
+		Detail:       detail,
+	})
+}
+
+// noteAppsLocked adds the meeting apps in snap to AppsSeen and the ones
+// that started or stopped since prev to the timeline. Caller must hold e.mu.
+func (e *Engine) noteAppsLocked(prev, snap monitor.Snapshot) {
+	if e.writer == nil {
 		return
 	}
+	for _, app := range snap.RunningApps() {
+		e.sessionDiag.SeeApp(app)
+	}
+	names := make(map[string]bool)
+	for name := range prev.Apps {
+		names[name] = true
+	}
+	for name := range snap.Apps {
+		names[name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	for _, name := range sorted {
+		was, is := prev.Apps[name], snap.Apps[name]
+		switch {
+		case is.Running && !was.Running:
+			e.recordEventLocked(metadata.TimelineApp, name+" started")
+		case was.Running && !is.Running:
+			e.recordEventLocked(metadata.TimelineApp, name+" stopped")
+		}
+		switch {
+		case is.InCall && !was.InCall:
+			e.recordEventLocked(metadata.TimelineApp, name+" call started")
+		case was.InCall && !is.InCall:
+			e.recordEventLocked(metadata.TimelineApp, name+" call ended")
+		}
+	}
+}
+
+// heldSession is a session ended by silence whose writer is kept open for
+// session.merge_gap_seconds, so that a session starting within that window
+// can append to it. Its conversion and sidecar are deferred until then.
+type heldSession struct {
+	sess         recordingSession
+	reason       metadata.FinalizationReason
+	timer        *time.Timer
+	partRate     int
+	partChannels int
+	partFrames   int64
+	levels       *audio.ChannelLevels
+}
+
+// holdLocked parks a detached session for merging and schedules its
+// finalization when the merge window closes. Caller must hold e.mu.
+func (e *Engine) holdLocked(sess recordingSession, reason metadata.FinalizationReason, levels *audio.ChannelLevels) {
+	if prev := e.held; prev != nil {
+		// Cannot happen while sessions merge, but never lose a session.
+		e.held = nil
+		prev.timer.Stop()
+		go func() {
+			defer e.finalizing.Done()
+			e.finalizeSession(prev.sess, prev.reason)
+		}()
+	}
+	// Make the file recoverable while it waits.
+	if err := sess.writer.Checkpoint(); err != nil {
+		e.logger.Printf("[merge] checkpoint failed: %v", err)
+	}
+	window := time.Duration(e.cfg.Session.MergeGapSeconds) * time.Second
+	e.held = &heldSession{
+		sess:         sess,
+		reason:       reason,
+		partRate:     e.partRate,
+		partChannels: e.partChannels,
+		partFrames:   e.partFrames,
+		levels:       levels,
+	}
+	e.finalizing.Add(1)
+	e.held.timer = time.AfterFunc(window, e.flushHeld)
+	e.logger.Printf("[merge] holding %s for %s in case recording resumes", filepath.Base(sess.file), window)
+}
+
+// flushHeld finalizes the held session, if any: the merge window closed or
+// the engine is stopping.
+func (e *Engine) flushHeld() {
+	e.mu.Lock()
+	h := e.held
+	e.held = nil
+	e.mu.Unlock()
+	if h == nil {
+		return
+	}
+	h.timer.Stop()
+	defer e.finalizing.Done()
+	e.finalizeSession(h.sess, h.reason)
+}
+
+// resumeHeldLocked continues the held session, appending the new audio to
+// its file, and reports whether it did. The time between the two sessions
+// is not recorded; the sidecar lists each segment with its wall-clock
+// start and end and its position in the file. Caller must hold e.mu.
+func (e *Engine) resumeHeldLocked(now time.Time) bool {
+	h := e.held
+	if h == nil {
+		return false
+	}
+	e.held = nil
+	h.timer.Stop()
+	e.finalizing.Done()
+
+	sess := h.sess
+	e.reattachLocked(sess)
+	e.seriesID, e.partIndex = sess.seriesID, sess.part
+	e.partRate, e.partChannels, e.partFrames = h.partRate, h.partChannels, h.partFrames
+	e.partClock = time.Time{} // re-anchored by the next write
+	e.chanLevels = h.levels
+	e.takeStateEventsLocked()
+	if len(e.sessionDiag.Segments) == 0 {
+		e.sessionDiag.Segments = []metadata.SessionSegment{{
+			Start:           sess.start,
+			End:             sess.end,
+			DurationSeconds: float64(h.partFrames) / float64(h.partRate),
+		}}
+	}
+	e.sessionDiag.Segments = append(e.sessionDiag.Segments, metadata.SessionSegment{
+		Start:       now,
+		FileSeconds: float64(h.partFrames) / float64(h.partRate),
+	})
+	e.logger.Printf("[merge] new session %s after the last one ended, appending to %s",
+		now.Sub(sess.end).Truncate(time.Second), filepath.Base(sess.file))
+	return true
+}
+
+// finalizeSession closes a detached part, validates and converts it, and
+// writes its sidecar.
+func (e *Engine) finalizeSession(sess recordingSession, reason metadata.FinalizationReason) {
+	w, file, start, snap, spec, diag := sess.writer, sess.file, sess.start, sess.snap, sess.spec, sess.diag
 	if err := w.Close(); err != nil {
 		e.logger.Printf("Close WAV error: %v", err)
 	}
+	diag.ClippedSamples = w.ClippedSamples()
+	diag.ClipRuns = w.ClipRuns()
+	diag.CheckDegraded(w.DataBytes()/int64(w.BytesPerSample()),
+		e.cfg.Session.DegradedClipRatio, e.cfg.Session.DegradedOverflows)
+	if diag.Degraded {
+		e.logger.Printf("[diag] DEGRADED: %s", strings.Join(diag.DegradedReasons, "; "))
+	}
 
 	// Finalize diagnostics.
-	diag.Finalize(e.cfg.Audio.Threshold * 0.5) // half of enter threshold as minimum meaningful RMS
-	dur := time.Since(start)
+	diag.Finalize(sess.threshold * 0.5) // half of enter threshold as minimum meaningful RMS
+	dur := sess.end.Sub(start)
+	if len(diag.Segments) > 0 {
+		// Merged sessions: the gaps between segments were not recorded.
+		var secs float64
+		for _, seg := range diag.Segments {
+			secs += seg.DurationSeconds
+		}
+		dur = time.Duration(secs * float64(time.Second))
+	}
 
 	// Log session diagnostics.
 	e.logger.Printf("[diag] frames_received=%d frames_written=%d bytes_written=%d rms_peak=%.6f rms_avg=%.6f has_audio=%v",
@@ -553,9 +1735,10 @@ func (e *Engine) finalizeRecording(reason metadata.FinalizationReason) {
 		reason = metadata.ReasonDiscardedEmpty
 	}
 
-	// Check minimum session duration.
+	// Check minimum session duration. Later parts of a rolled-over session
+	// continue a long recording, so a short tail is kept.
 	minDur := time.Duration(e.cfg.Session.MinSessionSeconds) * time.Second
-	if minDur > 0 && dur < minDur && reason != metadata.ReasonDiscardedEmpty {
+	if minDur > 0 && dur < minDur && sess.part <= 1 && reason != metadata.ReasonDiscardedEmpty {
 		e.logger.Printf("[diag] session too short: %s < min %s", dur.Truncate(time.Second), minDur)
 		reason = metadata.ReasonDiscardedShort
 	}
@@ -564,7 +1747,7 @@ func (e *Engine) finalizeRecording(reason metadata.FinalizationReason) {
 	wavValid := validateWAVFile(file)
 	if !wavValid {
 		e.logger.Printf("[diag] WAV integrity check failed for %s", filepath.Base(file))
-		if reason == metadata.ReasonSilenceTimeout || reason == metadata.ReasonShutdown || reason == metadata.ReasonManualStop {
+		if reason.DiscardsInvalidWAV() {
 			reason = metadata.ReasonDiscardedEmpty
 		}
 	}
@@ -572,21 +1755,22 @@ func (e *Engine) finalizeRecording(reason metadata.FinalizationReason) {
 	finalFile := file
 	discarded := reason == metadata.ReasonDiscardedEmpty || reason == metadata.ReasonDiscardedShort
 
+	// Shorten long internal silences before conversion.
+	var edits []metadata.EditSegment
+	var compacted float64
+	if !discarded && wavValid && e.cfg.Session.CompactSilenceSeconds > 0 {
+		edits, compacted = e.compactRecording(file, sess.threshold, sess.exitThreshold)
+	}
+
+	// Place markers in the file as cue points; chapters after conversion.
+	markers, total := e.placeMarkers(file, diag.Markers, edits, !discarded && wavValid)
+
 	// Convert to M4A if the format profile requires it and session is valid.
 	if spec.Container == "m4a" && !discarded {
-		converted, err := audio.ConvertToM4A(file, spec)
-		if err != nil {
-			e.logger.Printf("[diag] FAILURE MODE D: M4A conversion failed, keeping WAV: %v", err)
-			// Keep source WAV — do not discard.
-		} else {
-			// Validate converted file.
-			info, statErr := os.Stat(converted)
-			if statErr != nil || info.Size() < 100 {
-				e.logger.Printf("[diag] FAILURE MODE D: converted M4A is empty or missing (size=%d), keeping WAV", info.Size())
-				os.Remove(converted)
-			} else {
-				finalFile = converted
-				e.logger.Printf("Converted to M4A: %s", filepath.Base(converted))
+		finalFile = e.convertRecording(file, spec)
+		if finalFile != file && len(markers) > 0 {
+			if err := audio.AddChapters(finalFile, markerChapters(markers), total); err != nil {
+				e.logger.Printf("[mark] chapters not written, markers are in the sidecar only: %v", err)
 			}
 		}
 	}
@@ -594,22 +1778,25 @@ func (e *Engine) finalizeRecording(reason metadata.FinalizationReason) {
 	// Write metadata (always, even for discarded sessions — for diagnostics).
 	meta := metadata.Recording{
 		StartedAt:           start,
-		EndedAt:             time.Now(),
+		EndedAt:             sess.end,
+		DurationSecs:        dur.Seconds(),
 		MicActive:           snap.MicActive,
 		MicBundleIDs:        snap.MicBundleIDs,
-		ZoomRunning:         snap.ZoomRunning,
-		TeamsRunning:        snap.TeamsRunning,
-		MeetRunning:         snap.MeetRunning,
+		Apps:                snap.Apps,
+		ZoomRunning:         snap.Running("zoom"),
+		TeamsRunning:        snap.Running("teams"),
+		MeetRunning:         snap.Running("meet"),
 		Platform:            runtime.GOOS,
-		DeviceName:          e.deviceName,
+		DeviceName:          sess.device,
 		FormatProfile:       string(spec.Profile),
 		Container:           spec.Container,
 		Codec:               spec.Codec,
 		SampleRate:          spec.SampleRate,
 		Channels:            spec.Channels,
 		BitrateKbps:         spec.BitrateKbps,
-		Threshold:           e.cfg.Audio.Threshold,
-		SilenceSplitSeconds: e.cfg.Audio.SilenceSeconds,
+		Threshold:           sess.threshold,
+		AppProfile:          appProfileMeta(sess.profile, sess.profileApp),
+		SilenceSplitSeconds: sess.silenceSeconds,
 		SplitReason:         string(reason),
 		FinalizationReason:  reason,
 		AppVersion:          e.version,
@@ -619,7 +1806,36 @@ func (e *Engine) finalizeRecording(reason metadata.FinalizationReason) {
 		RMSPeak:             diag.RMSPeak,
 		RMSAverage:          diag.RMSAverage,
 		HasMeaningfulAudio:  diag.HasMeaningfulAudio,
+		ChannelRMS:          diag.ChannelRMS,
+		ChannelPeak:         diag.ChannelPeak,
+		ChannelClips:        diag.ChannelClips,
+		DeadChannels:        diag.DeadChannels,
+		IdenticalChannels:   diag.IdenticalChannels,
+		ClippedSamples:      diag.ClippedSamples,
+		ClipRuns:            diag.ClipRuns,
+		InputOverflows:      diag.InputOverflows,
+		OverflowEvents:      diag.OverflowEvents,
+		Degraded:            diag.Degraded,
+		DegradedReasons:     diag.DegradedReasons,
+		Gaps:                diag.Gaps,
+		GapSeconds:          diag.GapSeconds,
+		Resampled:           diag.Resampled,
+		EditList:            edits,
+		CompactedSeconds:    compacted,
+		Segments:            diag.Segments,
+		Timeline:            diag.Timeline,
+		AppsSeen:            diag.AppsSeen,
+		Markers:             markers,
 	}
+	if sess.mono >= 0 {
+		ch := sess.mono
+		meta.RecordedChannel = &ch
+	}
+	if sess.part > 0 {
+		meta.SeriesID = sess.seriesID
+		meta.PartIndex = sess.part
+	}
+	meta.Event = e.calendarEvent(start, sess.end)
 	if err := metadata.Write(finalFile, meta); err != nil {
 		e.logger.Printf("Metadata error: %v", err)
 	}
@@ -629,36 +1845,330 @@ func (e *Engine) finalizeRecording(reason metadata.FinalizationReason) {
 		e.logger.Printf("[diag] deleting discarded recording: %s (reason=%s)", filepath.Base(finalFile), reason)
 		os.Remove(finalFile)
 		// Also remove JSON sidecar.
-		jsonPath := strings.TrimSuffix(finalFile, filepath.Ext(finalFile)) + ".json"
-		os.Remove(jsonPath)
+		os.Remove(metadata.SidecarPath(finalFile))
 	} else {
 		e.logger.Printf("Finalized: %s (%s) reason=%s has_audio=%v", filepath.Base(finalFile), dur.Truncate(time.Second), reason, diag.HasMeaningfulAudio)
+		if err := e.publishRecording(finalFile, meta); err != nil {
+			e.logger.Printf("Publish error: %v", err)
+		}
+	}
+	if err := metadata.RemoveInProgress(file); err != nil {
+		e.logger.Printf("[recover] failed to remove in-progress marker: %v", err)
 	}
 }
 
-// validateWAVFile checks basic WAV structural integrity.
-func validateWAVFile(path string) bool {
-	f, err := os.Open(path)
+// loadCalendar reads calendar.files. On failure the previously loaded
+// calendar stays in use.
+func (e *Engine) loadCalendar() {
+	paths := make([]string, len(e.cfg.Calendar.Files))
+	for i, p := range e.cfg.Calendar.Files {
+		paths[i] = config.ResolvePath(p)
+	}
+	cal, err := calendar.Load(paths...)
+	e.mu.Lock()
+	e.calendarLoaded = time.Now()
+	if err == nil {
+		e.calendar = cal
+	}
+	e.mu.Unlock()
 	if err != nil {
-		return false
+		e.logger.Printf("[calendar] load failed: %v", err)
 	}
-	defer f.Close()
+}
 
-	header := make([]byte, 44)
-	n, err := f.Read(header)
-	if err != nil || n < 44 {
-		return false
+// checkCalendar runs on every monitor poll. It reloads calendar.files when
+// due and acts on the first event boundary passed since the previous poll:
+// with calendar.split_at_boundaries an active session is split (by loop()),
+// and with calendar.auto_start recording is force-started when an event
+// begins and nothing is being recorded.
+func (e *Engine) checkCalendar(now time.Time) {
+	if len(e.cfg.Calendar.Files) == 0 {
+		return
+	}
+	e.mu.Lock()
+	reloadDue := e.cfg.Calendar.ReloadMinutes > 0 &&
+		now.Sub(e.calendarLoaded) >= time.Duration(e.cfg.Calendar.ReloadMinutes)*time.Minute
+	e.mu.Unlock()
+	if reloadDue {
+		e.loadCalendar()
+	}
+
+	e.mu.Lock()
+	cal, since := e.calendar, e.calendarChecked
+	e.calendarChecked = now
+	e.mu.Unlock()
+	if cal == nil || since.IsZero() {
+		return
+	}
+	b, ok := cal.NextBoundary(since)
+	if !ok || b.At.After(now) {
+		return
+	}
+
+	state := e.sm.CurrentState()
+	recording := state == statemachine.StateRecording || state == statemachine.StateSilenceWait
+	switch {
+	case recording && e.cfg.Calendar.SplitAtBoundaries:
+		e.mu.Lock()
+		e.splitPending = true
+		e.mu.Unlock()
+	case !recording && len(b.Starts) > 0 && e.cfg.Calendar.AutoStart && e.schedule.Allowed(now):
+		e.logger.Printf("[calendar] %q started, starting recording", b.Starts[0].Summary)
+		e.requestStart("calendar")
+	}
+}
+
+// requestStart asks loop() to force-start recording on the current stream.
+// As for mic activation, a request with a nil device keeps the stream, and
+// stopping it unblocks a Read() waiting on an idle device.
+func (e *Engine) requestStart(source string) {
+	select {
+	case e.deviceSwitchCh <- deviceSwitchReq{startRec: true}:
+		e.mu.Lock()
+		s := e.stream
+		e.mu.Unlock()
+		if s != nil {
+			s.Stop()
+		}
+	default:
+		e.logger.Printf("[%s] start request dropped: channel full", source)
+	}
+}
+
+// setStreamLocked makes s the capture stream (nil for none) and notes its
+// format for the parts recorded from it. Caller must hold e.mu.
+func (e *Engine) setStreamLocked(s *audio.Stream) {
+	e.stream = s
+	e.captureRate, e.captureChannels = 0, 0
+	if s != nil {
+		e.captureRate, e.captureChannels = s.SampleRate(), s.Channels()
+	}
+}
+
+// triggerSignals collects the trigger policy's inputs at now, except Audio,
+// which only loop() knows.
+func (e *Engine) triggerSignals(now time.Time) trigger.Signals {
+	e.mu.Lock()
+	snap := e.monSnapshot
+	recording := e.writer != nil
+	e.mu.Unlock()
+	return trigger.Signals{
+		Now:          now,
+		MicActive:    snap.MicActive,
+		InCall:       snap.InCallApps(),
+		Apps:         snap.RunningApps(),
+		ScheduleOpen: e.schedule.Allowed(now),
+		Recording:    recording,
+	}
+}
+
+// defaultPolicy is the trigger policy without a trigger: block. Audio
+// starts and stops sessions, and a meeting app taking the microphone starts
+// one and holds it through silence, unless monitoring.mic_session_lock is
+// off.
+func defaultPolicy(cfg config.MonitoringConfig) trigger.Policy {
+	if !cfg.MicSessionLock {
+		return trigger.Audio()
+	}
+	return trigger.AnyOf(trigger.Audio(), trigger.Mic())
+}
+
+// decide feeds a buffer's level to the state machine and returns what to
+// do with the buffer. The trigger policy has the last word: it can start a
+// session without audio, keep one past its silence timeout, stop one, or
+// keep audio from starting one.
+func (e *Engine) decide(rms, threshold float64) statemachine.Action {
+	sig := e.triggerSignals(time.Now())
+	if !sig.Recording {
+		if e.policy.Decide(sig) == trigger.Start &&
+			e.sm.ForceStartRecording() == statemachine.ActionStartRecording {
+			e.logger.Printf("[trigger] policy started recording")
+			return statemachine.ActionStartRecording
+		}
+		sig.Audio = true
+		if e.policy.Decide(sig) != trigger.Start {
+			// Audio alone cannot start a session under this policy.
+			if e.sm.CurrentState() == statemachine.StateArming {
+				e.sm.Reset()
+			}
+			return statemachine.ActionNone
+		}
+		return e.sm.ProcessAudio(rms, threshold)
 	}
-	// Check RIFF header.
-	if string(header[0:4]) != "RIFF" || string(header[8:12]) != "WAVE" {
+
+	// While the policy holds the session regardless of audio, silence
+	// cannot end it.
+	e.sm.SetHold(e.policy.Decide(sig) >= trigger.Hold)
+	action := e.sm.ProcessAudio(rms, threshold)
+	sig.Audio = action == statemachine.ActionContinue
+	if d := e.policy.Decide(sig); d < trigger.Hold && action != statemachine.ActionStopRecording {
+		e.logger.Printf("[trigger] policy decided %s, stopping recording", d)
+		e.finalizeRecording(metadata.ReasonTriggerStop)
+		e.sm.Reset()
+		return statemachine.ActionNone
+	}
+	return action
+}
+
+// checkTrigger starts a session the trigger policy asks for without audio,
+// such as a silent Zoom call. loop() evaluates the policy on every buffer,
+// but Read() can block indefinitely on an idle device. A request already
+// queued, such as the mic's device switch, is left to start it.
+func (e *Engine) checkTrigger(now time.Time) {
+	sig := e.triggerSignals(now)
+	if sig.Recording || !sig.ScheduleOpen || len(e.deviceSwitchCh) > 0 || e.policy.Decide(sig) != trigger.Start {
+		return
+	}
+	e.logger.Printf("[trigger] policy asks to record, starting recording")
+	e.requestStart("trigger")
+}
+
+// calendarEvent returns the calendar event that overlapped [start, end)
+// the most, or nil.
+func (e *Engine) calendarEvent(start, end time.Time) *metadata.CalendarEvent {
+	e.mu.Lock()
+	cal := e.calendar
+	e.mu.Unlock()
+	o, ok := cal.Best(start, end)
+	if !ok {
+		return nil
+	}
+	return &metadata.CalendarEvent{
+		UID:       o.UID,
+		Title:     o.Summary,
+		Organizer: o.Organizer,
+		Attendees: o.Attendees,
+		Start:     o.Start,
+		End:       o.End,
+	}
+}
+
+// placeMarkers resolves each marker's position in the finished WAV, after
+// compaction, and, when write is set, stores the markers as cue points. It
+// also returns the file's duration.
+func (e *Engine) placeMarkers(file string, markers []metadata.Marker, edits []metadata.EditSegment, write bool) ([]metadata.Marker, time.Duration) {
+	if len(markers) == 0 {
+		return nil, 0
+	}
+	h, err := wav.ReadHeader(file)
+	if err != nil || h.SampleRate <= 0 || h.BlockAlign() <= 0 {
+		return markers, 0
+	}
+	rate := float64(h.SampleRate)
+	total := time.Duration(float64(h.DataSize/int64(h.BlockAlign())) / rate * float64(time.Second))
+	placed := make([]metadata.Marker, len(markers))
+	cues := make([]wav.Cue, len(markers))
+	for i, m := range markers {
+		m.FileSeconds = metadata.FileSeconds(edits, float64(m.SampleOffset)/rate)
+		placed[i] = m
+		cues[i] = wav.Cue{Frame: int64(m.FileSeconds * rate), Label: m.Label}
+	}
+	if write {
+		if err := wav.WriteCues(file, cues); err != nil {
+			e.logger.Printf("[mark] cue points not written: %v", err)
+		}
+	}
+	return placed, total
+}
+
+// markerChapters turns markers into chapters, each running to the next
+// marker. A leading chapter covers the audio before the first marker.
+func markerChapters(markers []metadata.Marker) []audio.Chapter {
+	var chapters []audio.Chapter
+	if markers[0].FileSeconds > 0 {
+		chapters = append(chapters, audio.Chapter{Title: "Start"})
+	}
+	for i, m := range markers {
+		title := m.Label
+		if title == "" {
+			title = fmt.Sprintf("Marker %d", i+1)
+		}
+		chapters = append(chapters, audio.Chapter{
+			Start: time.Duration(m.FileSeconds * float64(time.Second)),
+			Title: title,
+		})
+	}
+	return chapters
+}
+
+// compactRecording cuts silences longer than session.compact_silence_seconds
+// in a finalized WAV down to session.compact_gap_seconds. It returns the
+// edit list and the seconds removed, or nil when the file was not changed.
+func (e *Engine) compactRecording(file string, enter, exit float64) ([]metadata.EditSegment, float64) {
+	threshold := exit
+	if threshold <= 0 || threshold > enter {
+		threshold = enter
+	}
+	segments, err := wav.Compact(file, wav.CompactOptions{
+		Threshold:  threshold,
+		MinSilence: time.Duration(e.cfg.Session.CompactSilenceSeconds) * time.Second,
+		KeepGap:    time.Duration(e.cfg.Session.CompactGapSeconds) * time.Second,
+	})
+	if err != nil {
+		e.logger.Printf("[compact] %s: keeping uncompacted recording: %v", filepath.Base(file), err)
+		return nil, 0
+	}
+	if len(segments) == 0 {
+		return nil, 0
+	}
+	h, err := wav.ReadHeader(file)
+	if err != nil || h.SampleRate <= 0 {
+		e.logger.Printf("[compact] %s: read compacted header: %v", filepath.Base(file), err)
+		return nil, 0
+	}
+	rate := float64(h.SampleRate)
+	edits := make([]metadata.EditSegment, len(segments))
+	for i, s := range segments {
+		edits[i] = metadata.EditSegment{
+			SourceSeconds:   float64(s.Source) / rate,
+			FileSeconds:     float64(s.Dest) / rate,
+			DurationSeconds: float64(s.Frames) / rate,
+		}
+	}
+	last := segments[len(segments)-1]
+	removed := float64(last.Source-last.Dest) / rate
+	e.logger.Printf("[compact] %s: removed %.1fs of silence, %d segments kept",
+		filepath.Base(file), removed, len(segments))
+	return edits, removed
+}
+
+// convertRecording converts a finalized WAV to M4A and returns the path of
+// the file to keep: the M4A on success, or the original WAV if conversion
+// failed or produced an empty file.
+func (e *Engine) convertRecording(file string, spec audio.FormatSpec) string {
+	converted, err := audio.ConvertToM4A(file, spec)
+	if err != nil {
+		e.logger.Printf("[diag] FAILURE MODE D: M4A conversion failed, keeping WAV: %v", err)
+		// Keep source WAV — do not discard.
+		return file
+	}
+	// Validate converted file.
+	info, statErr := os.Stat(converted)
+	if statErr != nil || info.Size() < 100 {
+		var size int64
+		if info != nil {
+			size = info.Size()
+		}
+		e.logger.Printf("[diag] FAILURE MODE D: converted M4A is empty or missing (size=%d), keeping WAV", size)
+		os.Remove(converted)
+		return file
+	}
+	e.logger.Printf("Converted to M4A: %s", filepath.Base(converted))
+	return converted
+}
+
+// validateWAVFile checks basic WAV structural integrity: a parseable RIFF or
+// RF64 header followed by at least some audio data.
+func validateWAVFile(path string) bool {
+	h, err := wav.ReadHeader(path)
+	if err != nil {
 		return false
 	}
-	// Check data chunk has content beyond header.
 	info, err := os.Stat(path)
 	if err != nil {
 		return false
 	}
-	return info.Size() > 44
+	return info.Size() > h.DataOffset
 }
 
 // handleDeviceSwitch is called exclusively from the loop() goroutine, between
@@ -691,8 +2201,10 @@ func (e *Engine) handleDeviceSwitch(req deviceSwitchReq) []float32 {
 		} else {
 			old := e.stream
 			e.mu.Lock()
-			e.stream = newStream
+			e.setStreamLocked(newStream)
 			e.deviceName = req.device.Name
+			e.gapCause = "device_switch"
+			e.recordEventLocked(metadata.TimelineDevice, req.device.Name)
 			e.mu.Unlock()
 			// Stop then Close old stream. We are between Read() calls so Close()
 			// cannot race with an active Read() — this is the key safety guarantee.
@@ -710,12 +2222,15 @@ func (e *Engine) handleDeviceSwitch(req deviceSwitchReq) []float32 {
 		if err := e.stream.Start(); err != nil {
 			e.logger.Printf("[engine] stream restart failed: %v", err)
 		}
+		e.mu.Lock()
+		e.gapCause = "stream_restart"
+		e.mu.Unlock()
 	}
 	// Force-start recording when requested. When a dedicated device was switched
 	// to, this captures the call even if BlackHole is currently silent. When
 	// device is nil (no meeting device found), recording starts on the current
 	// stream — still the right thing to do when mic usage is detected.
-	if req.startRec {
+	if req.startRec && e.checkSchedule(time.Now()) && e.checkDisk(time.Now()) {
 		if e.sm.ForceStartRecording() == statemachine.ActionStartRecording {
 			e.startRecording()
 		}
@@ -749,6 +2264,24 @@ func (e *Engine) findDevice() (*audio.DeviceInfo, error) {
 		return nil, fmt.Errorf("device %q not found", device)
 	}
 
+	// Per-app capture: record only the configured applications' playback
+	// streams instead of the whole monitor source.
+	if runtime.GOOS == "linux" && len(e.cfg.Platform.LinuxCaptureApps) > 0 {
+		ac, err := audio.SetupAppCapture(e.cfg.Platform.LinuxCaptureApps)
+		if err != nil {
+			e.logger.Printf("Warning: per-app capture unavailable, recording all desktop audio: %v", err)
+		} else if dev := ac.Device(); dev != nil {
+			e.mu.Lock()
+			e.appCapture = ac
+			e.mu.Unlock()
+			e.logger.Printf("Per-app capture active for %v via %q", ac.Apps(), dev.Name)
+			return dev, nil
+		} else {
+			ac.Close()
+			e.logger.Printf("Warning: per-app capture sink has no input device, recording all desktop audio")
+		}
+	}
+
 	var hint string
 	switch runtime.GOOS {
 	case "darwin":
@@ -769,3 +2302,11 @@ func (e *Engine) findDevice() (*audio.DeviceInfo, error) {
 	e.logger.Printf("Warning: BlackHole not found, falling back to default input %q", dev.Name)
 	return dev, nil
 }
+
+// appProfileMeta describes the app profile a session used for its sidecar.
+func appProfileMeta(p *config.AppProfile, app string) *metadata.AppProfile {
+	if p == nil {
+		return nil
+	}
+	return &metadata.AppProfile{Name: p.Name, MatchedApp: app, Subdir: p.Subdir, Tag: p.Tag}
+}
@@ -2,12 +2,19 @@ package engine_test
 
 import (
 	"encoding/binary"
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/tiroq/memofy/internal/config"
+	"github.com/tiroq/memofy/internal/diskguard"
+	"github.com/tiroq/memofy/internal/encrypt"
 	"github.com/tiroq/memofy/internal/engine"
+	"github.com/tiroq/memofy/internal/schedule"
+	"github.com/tiroq/memofy/internal/wav"
 )
 
 // newTestEngine creates an Engine from default config without starting audio.
@@ -30,7 +37,7 @@ func TestNew_InitialState(t *testing.T) {
 	if status.MicActive {
 		t.Error("MicActive should be false before Start()")
 	}
-	if status.ZoomRunning || status.TeamsRunning || status.MeetRunning {
+	if len(status.Apps) != 0 {
 		t.Error("no meeting apps should be reported before Start()")
 	}
 }
@@ -54,6 +61,15 @@ func TestNew_IsMicActiveInitiallyFalse(t *testing.T) {
 	}
 }
 
+// TestMark_NotRecording asserts that a marker made while idle is rejected
+// rather than silently lost.
+func TestMark_NotRecording(t *testing.T) {
+	eng := newTestEngine(t)
+	if _, err := eng.Mark("action item"); !errors.Is(err, engine.ErrNotRecording) {
+		t.Errorf("Mark() while idle: err = %v, want ErrNotRecording", err)
+	}
+}
+
 // --- WAV validation tests ---
 
 func TestValidateWAVFile_ValidWAV(t *testing.T) {
@@ -184,3 +200,230 @@ func TestNew_HysteresisConfigured(t *testing.T) {
 		t.Errorf("state: got %q, want idle", status.State)
 	}
 }
+
+// --- Rollover limit tests ---
+
+func TestPartLimitReached(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxDuration time.Duration
+		maxSizeMB   int
+		seconds     int // audio written at 8 kHz mono 16-bit
+		want        bool
+	}{
+		{"unlimited", 0, 0, 10, false},
+		{"below duration", 5 * time.Second, 0, 4, false},
+		{"at duration", 5 * time.Second, 0, 5, true},
+		{"below size", 0, 1, 60, false}, // 960 KB
+		{"over size", 0, 1, 70, true},   // 1.12 MB
+	}
+	for _, tc := range tests {
+		cfg := config.Default()
+		cfg.Output.Dir = t.TempDir()
+		cfg.Session.MaxDuration = tc.maxDuration
+		cfg.Session.MaxSizeMB = tc.maxSizeMB
+		eng := engine.New(cfg, nil)
+
+		w, err := wav.Create(filepath.Join(cfg.Output.Dir, "part.wav"), 8000, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(make([]float32, 8000*tc.seconds))
+		if got := eng.PartLimitReached(w); got != tc.want {
+			t.Errorf("%s: PartLimitReached = %v, want %v", tc.name, got, tc.want)
+		}
+		w.Close()
+	}
+}
+
+func TestGapFrames(t *testing.T) {
+	const rate = 1000
+	tests := []struct {
+		name       string
+		elapsed    time.Duration
+		written    int64
+		tolerance  int
+		maxFill    int64
+		wantBehind int64
+		wantFill   int64
+	}{
+		{"aligned", 10 * time.Second, 10000, 500, 0, 0, 0},
+		{"ahead", 10 * time.Second, 10100, 500, 0, -100, 0},
+		{"within tolerance", 10 * time.Second, 9600, 500, 0, 400, 0},
+		{"read error", 10 * time.Second, 8000, 500, 0, 2000, 2000},
+		{"capped", 100 * time.Second, 10000, 500, 60000, 90000, 60000},
+		{"disabled", 10 * time.Second, 8000, 0, 0, 0, 0},
+	}
+	for _, tc := range tests {
+		behind, fill := engine.GapFrames(tc.elapsed, tc.written, rate, tc.tolerance, tc.maxFill)
+		if behind != tc.wantBehind || fill != tc.wantFill {
+			t.Errorf("%s: got behind=%d fill=%d, want %d/%d", tc.name, behind, fill, tc.wantBehind, tc.wantFill)
+		}
+	}
+}
+
+func TestDriftAnchor(t *testing.T) {
+	const rate, buf = 48000, 4096
+	// The device delivers 48000 frames in 1.0001 s (100 ppm slow) or in
+	// 0.9999 s (100 ppm fast). Over two hours the anchor must keep the part
+	// within a few milliseconds of the clock, so no gap is ever measured.
+	for _, ppm := range []float64{100, -100} {
+		start := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+		clock := start
+		var written int64
+		for written < 2*3600*rate {
+			written += buf
+			now := start.Add(time.Duration(float64(written) / rate * (1 + ppm/1e6) * float64(time.Second)))
+			clock = engine.DriftAnchor(clock, now, written, rate)
+			behind, _ := engine.GapFrames(now.Sub(clock), written, rate, 500, 0)
+			if behind > rate/100 || behind < -rate/100 {
+				t.Fatalf("%+.0f ppm: %d frames off after %s", ppm, behind, now.Sub(start))
+			}
+		}
+	}
+}
+
+func TestGetStatus_Schedule(t *testing.T) {
+	if s := newTestEngine(t).GetStatus(); !s.ScheduleOpen || !s.NextScheduleChange.IsZero() {
+		t.Errorf("without schedule: open=%v next=%v, want open and no change", s.ScheduleOpen, s.NextScheduleChange)
+	}
+
+	cfg := config.Default()
+	cfg.Output.Dir = t.TempDir()
+	cfg.Schedule = schedule.Config{
+		Enabled:    true,
+		Timezone:   "UTC",
+		Exceptions: []schedule.Exception{{Date: time.Now().UTC().Format("2006-01-02"), Allow: false}},
+	}
+	s := engine.New(cfg, nil).GetStatus()
+	tomorrow := time.Now().UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+	if s.ScheduleOpen {
+		t.Error("schedule blocking today should be closed")
+	}
+	if !s.NextScheduleChange.Equal(tomorrow) {
+		t.Errorf("NextScheduleChange = %v, want %v", s.NextScheduleChange, tomorrow)
+	}
+}
+
+func TestCheckDisk(t *testing.T) {
+	cfg := config.Default()
+	cfg.Output.Dir = t.TempDir()
+	cfg.Output.LowDiskMB = 1000
+	cfg.Output.CriticalDiskMB = 200
+	cfg.Output.LowDiskProfile = "lightweight"
+	eng := engine.New(cfg, nil)
+	free, reads := uint64(5000), 0
+	eng.SetDiskStat(func(string) (diskguard.Stats, error) {
+		reads++
+		return diskguard.Stats{Free: free << 20, Total: 100000 << 20}, nil
+	})
+
+	now := time.Now()
+	if !eng.CheckDisk(now) {
+		t.Fatal("plenty of space should allow recording")
+	}
+	if name, _ := eng.SessionProfile(); name != "" {
+		t.Errorf("session profile with plenty of space = %q, want none", name)
+	}
+
+	// Low: still recording, new sessions use the lighter format.
+	free = 800
+	now = now.Add(10 * time.Second)
+	if !eng.CheckDisk(now) {
+		t.Error("low space should still allow recording")
+	}
+	if s := eng.GetStatus(); s.DiskLevel != "low" || s.DiskFree != 800<<20 {
+		t.Errorf("status: level %q free %d, want low and 800 MB", s.DiskLevel, s.DiskFree)
+	}
+	if !strings.Contains(eng.Status(), "Disk: low (800 MB free)") {
+		t.Errorf("Status() = %q, want the disk level", eng.Status())
+	}
+	if name, format := eng.SessionProfile(); name != "low_disk" || format != "lightweight" {
+		t.Errorf("session profile = %q/%q, want low_disk/lightweight", name, format)
+	}
+
+	// Free space is not read again within the check interval.
+	free = 100
+	if !eng.CheckDisk(now.Add(time.Second)) || reads != 2 {
+		t.Errorf("checked again within the interval (reads=%d)", reads)
+	}
+
+	// Critical: recording refused until space recovers.
+	now = now.Add(10 * time.Second)
+	if eng.CheckDisk(now) {
+		t.Error("critical space should refuse recording")
+	}
+	if s := eng.GetStatus(); s.DiskLevel != "critical" || s.State != "idle" {
+		t.Errorf("status: level %q state %q, want critical and idle", s.DiskLevel, s.State)
+	}
+	free = 5000
+	if !eng.CheckDisk(now.Add(10 * time.Second)) {
+		t.Error("recording should resume once space recovers")
+	}
+}
+
+func TestCheckDisk_Staging(t *testing.T) {
+	id, err := encrypt.GenerateIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Default()
+	cfg.Output.Dir = t.TempDir()
+	cfg.Output.LowDiskMB = 1000
+	cfg.Output.CriticalDiskMB = 200
+	cfg.Encryption = config.EncryptionConfig{
+		Enabled:    true,
+		Recipients: []string{id.Recipient().String()},
+		StagingDir: t.TempDir(),
+	}
+	eng := engine.New(cfg, nil)
+	eng.SetDiskStat(func(dir string) (diskguard.Stats, error) {
+		if dir == cfg.Encryption.StagingDir {
+			return diskguard.Stats{Free: 100 << 20}, nil // a nearly full tmpfs
+		}
+		return diskguard.Stats{Free: 50000 << 20}, nil
+	})
+	if err := eng.InitEncryption(); err != nil {
+		t.Fatal(err)
+	}
+	if eng.CheckDisk(time.Now()) {
+		t.Error("a critical staging volume should pause recording")
+	}
+	if s := eng.GetStatus(); s.DiskLevel != "critical" || s.DiskFree != 100<<20 {
+		t.Errorf("status: level %q free %d, want critical and the staging volume's 100 MB", s.DiskLevel, s.DiskFree)
+	}
+}
+
+func TestSessionProfile_LowDiskKeepsAppProfile(t *testing.T) {
+	cfg := config.Default()
+	cfg.Output.Dir = t.TempDir()
+	cfg.AppProfiles = []config.AppProfile{{Name: "adhoc", FormatProfile: "high", SilenceSeconds: 600}}
+	lowDisk := func(string) (diskguard.Stats, error) {
+		return diskguard.Stats{Free: 1 << 30}, nil // 1 GB
+	}
+
+	// The guard is off by default.
+	eng := engine.New(cfg, nil)
+	eng.SetDiskStat(lowDisk)
+	eng.CheckDisk(time.Now())
+	if s := eng.GetStatus(); s.DiskLevel != "ok" || s.DiskFree != 0 {
+		t.Errorf("default: disk level %q free %d, want ok and unchecked", s.DiskLevel, s.DiskFree)
+	}
+
+	// Without low_disk_profile low space only warns.
+	cfg.Output.LowDiskMB = 2048
+	eng = engine.New(cfg, nil)
+	eng.SetDiskStat(lowDisk)
+	eng.CheckDisk(time.Now())
+	if name, format := eng.SessionProfile(); name != "adhoc" || format != "high" {
+		t.Errorf("no low_disk_profile: session profile = %q/%q, want adhoc/high", name, format)
+	}
+
+	cfg.Output.LowDiskProfile = "lightweight"
+	eng = engine.New(cfg, nil)
+	eng.SetDiskStat(lowDisk)
+	eng.CheckDisk(time.Now())
+	if name, format := eng.SessionProfile(); name != "adhoc" || format != "lightweight" {
+		t.Errorf("session profile = %q/%q, want adhoc/lightweight", name, format)
+	}
+}
@@ -1,6 +1,15 @@
 // export_test.go exposes internal Engine state for white-box tests.
 package engine
 
+import (
+	"time"
+
+	"github.com/tiroq/memofy/internal/audio"
+	"github.com/tiroq/memofy/internal/diskguard"
+	"github.com/tiroq/memofy/internal/monitor"
+	"github.com/tiroq/memofy/internal/wav"
+)
+
 // DeviceSwitchChCap returns the capacity of the device-switch channel so that
 // tests can assert it equals 1 (the invariant that prevents pollMonitor from
 // blocking when the loop is busy).
@@ -11,3 +20,90 @@ func (e *Engine) IsMicActive() bool { return e.isMicActive() }
 
 // ValidateWAVFile exposes the private validateWAVFile function for tests.
 func ValidateWAVFile(path string) bool { return validateWAVFile(path) }
+
+// RecoverOrphan exposes the private recoverOrphan method for tests.
+func (e *Engine) RecoverOrphan(marker string) { e.recoverOrphan(marker) }
+
+// PartLimitReached exposes the private partLimitReached method for tests.
+func (e *Engine) PartLimitReached(w *wav.Writer) bool { return e.partLimitReached(w) }
+
+// GapFrames exposes the private gapFrames function for tests.
+func GapFrames(elapsed time.Duration, written int64, rate, toleranceMs int, maxFill int64) (int64, int64) {
+	return gapFrames(elapsed, written, rate, toleranceMs, maxFill)
+}
+
+// DriftAnchor exposes the private driftAnchor function for tests.
+func DriftAnchor(clock, now time.Time, written int64, rate int) time.Time {
+	return driftAnchor(clock, now, written, rate)
+}
+
+// SetDiskStat replaces the free-space source of the disk guard.
+func (e *Engine) SetDiskStat(stat diskguard.StatFunc) {
+	e.disk = diskguard.New(e.cfg.Output.Dir, e.cfg.Output.LowDiskMB, e.cfg.Output.CriticalDiskMB, stat)
+}
+
+// CheckDisk exposes the private checkDisk method for tests.
+func (e *Engine) CheckDisk(now time.Time) bool { return e.checkDisk(now) }
+
+// SessionProfile returns the name and format of the app profile a session
+// starting now would use.
+func (e *Engine) SessionProfile() (name, format string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	p, _ := e.sessionProfileLocked()
+	if p == nil {
+		return "", ""
+	}
+	return p.Name, p.FormatProfile
+}
+
+// InitEncryption sets up the output directory and encryption as Start does.
+func (e *Engine) InitEncryption() error {
+	e.outputDir = e.cfg.Output.Dir
+	return e.initEncryption()
+}
+
+// InitUpload sets up the output directory and the uploader as Start does,
+// without running it.
+func (e *Engine) InitUpload() {
+	e.outputDir = e.cfg.Output.Dir
+	e.initUpload()
+}
+
+// StartCapture sets up the output directory and marks the engine running
+// as Start does, and stands in for a capture stream of rate and channels,
+// so that Feed can record and Stop can shut down.
+func (e *Engine) StartCapture(rate, channels int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.outputDir = e.cfg.Output.Dir
+	e.captureRate, e.captureChannels = rate, channels
+	e.running = true
+	e.stopCh = make(chan struct{})
+}
+
+// Feed processes a captured buffer as loop() does.
+func (e *Engine) Feed(buf []float32) {
+	rms := audio.RMS(buf)
+	threshold, _ := e.trackBuffer(buf, rms)
+	e.handleBuffer(buf, rms, threshold)
+}
+
+// CheckChannels exposes the private checkChannels method for tests.
+func (e *Engine) CheckChannels() { e.checkChannels() }
+
+// SetMonitorSnapshot makes s the meeting app state pollMonitor last saw
+// and passes the mic state to the session lock.
+func (e *Engine) SetMonitorSnapshot(s monitor.Snapshot) {
+	e.mu.Lock()
+	e.monSnapshot = s
+	e.mu.Unlock()
+	e.sm.SetMicActive(s.MicActive)
+}
+
+// SetDeviceName stands in for a switch to the capture device name.
+func (e *Engine) SetDeviceName(name string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.deviceName = name
+}
@@ -0,0 +1,103 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tiroq/memofy/internal/encrypt"
+	"github.com/tiroq/memofy/internal/library"
+	"github.com/tiroq/memofy/internal/metadata"
+	"github.com/tiroq/memofy/internal/recpath"
+	"github.com/tiroq/memofy/internal/upload"
+)
+
+// initEncryption prepares encryption.enabled: recordings are written to
+// the staging directory and only reach the output directory encrypted.
+func (e *Engine) initEncryption() error {
+	recipients, err := e.cfg.Encryption.ParseRecipients()
+	if err != nil {
+		return err
+	}
+	if len(recipients) == 0 {
+		return fmt.Errorf("encryption is enabled but encryption.recipients is empty")
+	}
+	staging := e.cfg.Encryption.Staging()
+	if staging == "" {
+		return fmt.Errorf("encryption is enabled but no staging directory is set")
+	}
+	if err := os.MkdirAll(staging, 0700); err != nil {
+		return fmt.Errorf("create staging dir: %w", err)
+	}
+	e.recipients, e.stagingDir = recipients, staging
+	// Sessions in progress grow in the staging dir, often a small tmpfs.
+	e.disk.Watch(staging)
+	e.logger.Printf("[encrypt] encrypting recordings to %d recipient(s), staging in %s", len(recipients), staging)
+	return nil
+}
+
+// initUpload prepares upload.enabled. Upload problems never stop
+// recording, so a bad target is logged and uploading stays off.
+func (e *Engine) initUpload() {
+	u, err := upload.New(e.cfg.Upload, e.outputDir, e.logger)
+	if err != nil {
+		e.logger.Printf("[upload] disabled: %v", err)
+		return
+	}
+	e.uploader = u
+	if st := u.Status(); st.Pending > 0 {
+		e.logger.Printf("[upload] %d recording(s) waiting from a previous run", st.Pending)
+	}
+}
+
+// publishRecording adds a finalized recording to the library and queues
+// it for upload. With encryption, the recording and its sidecar are first
+// encrypted to the same place below the output directory and the
+// plaintext is deleted. If encryption fails the plaintext stays where it
+// is, in the staging directory unless it was recovered from the output
+// directory, and nothing is uploaded.
+func (e *Engine) publishRecording(file string, meta metadata.Recording) error {
+	media, sidecar := file, metadata.SidecarPath(file)
+	if e.recipients == nil {
+		if err := library.Add(e.outputDir, file); err != nil {
+			return err
+		}
+	} else {
+		var err error
+		if media, sidecar, err = e.sealRecording(file, meta); err != nil {
+			return err
+		}
+	}
+	if e.uploader != nil {
+		if err := e.uploader.Enqueue(media, sidecar); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sealRecording encrypts a recording and its sidecar into the output
+// directory, indexes them and returns the encrypted paths.
+func (e *Engine) sealRecording(file string, meta metadata.Recording) (string, string, error) {
+	dir := filepath.Dir(file)
+	if rel, err := filepath.Rel(e.stagingDir, file); err == nil && !strings.HasPrefix(rel, "..") {
+		dir = filepath.Join(e.outputDir, filepath.Dir(rel))
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", "", fmt.Errorf("create recording dir: %w", err)
+	}
+	sidecar := metadata.SidecarPath(file)
+	sealed := filepath.Join(dir, filepath.Base(file)+recpath.ExtEncrypted)
+	sealedSidecar := filepath.Join(dir, filepath.Base(sidecar)+recpath.ExtEncrypted)
+	if err := encrypt.EncryptFile(file, sealed, e.recipients...); err != nil {
+		return "", "", fmt.Errorf("encrypt %s: %w", filepath.Base(file), err)
+	}
+	if err := encrypt.EncryptFile(sidecar, sealedSidecar, e.recipients...); err != nil {
+		os.Remove(sealed)
+		return "", "", fmt.Errorf("encrypt %s: %w", filepath.Base(sidecar), err)
+	}
+	os.Remove(file)
+	os.Remove(sidecar)
+	return sealed, sealedSidecar, library.AddSealed(e.outputDir, sealedSidecar, meta)
+}
@@ -0,0 +1,127 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/tiroq/memofy/internal/audio"
+	"github.com/tiroq/memofy/internal/metadata"
+	"github.com/tiroq/memofy/internal/pidfile"
+	"github.com/tiroq/memofy/internal/wav"
+)
+
+// recoverOrphans repairs recordings whose in-progress marker survived a
+// previous run, i.e. memofy was killed or the machine lost power before
+// finalizeRecording ran. The caller counts it in e.finalizing.
+func (e *Engine) recoverOrphans(markers []string) {
+	defer e.finalizing.Done()
+	for _, marker := range markers {
+		e.recoverOrphan(marker)
+	}
+}
+
+// recoverOrphan repairs the WAV header of one unfinalized recording, writes
+// its sidecar with ReasonRecovered and runs the normal conversion step.
+// Recordings with no usable audio follow the discard policy instead.
+func (e *Engine) recoverOrphan(marker string) {
+	file := metadata.RecordingPathForMarker(marker)
+	name := filepath.Base(file)
+
+	m, err := metadata.ReadInProgress(marker)
+	if err != nil {
+		e.logger.Printf("[recover] %s: %v (using defaults)", name, err)
+	}
+	// Another memofy sharing the output directory may still be writing
+	// it. A reused PID after a reboot only delays recovery to the next
+	// start.
+	if m.PID > 0 && m.PID != os.Getpid() && pidfile.IsProcessRunning(m.PID) {
+		e.logger.Printf("[recover] %s: still being recorded by process %d, leaving it alone", name, m.PID)
+		return
+	}
+	if _, err := os.Stat(file); err != nil {
+		// Nothing to repair: the WAV was converted or deleted before the
+		// marker could be removed.
+		e.logger.Printf("[recover] %s: recording missing, removing stale marker", name)
+		os.Remove(marker)
+		return
+	}
+
+	reason := metadata.ReasonRecovered
+	h, err := wav.Repair(file)
+	if err != nil {
+		e.logger.Printf("[recover] %s: header repair failed: %v", name, err)
+		reason = metadata.ReasonDiscardedEmpty
+	} else if h.DataSize == 0 {
+		reason = metadata.ReasonDiscardedEmpty
+	}
+
+	var dur time.Duration
+	var frames int64
+	if h.BlockAlign() > 0 && h.SampleRate > 0 {
+		frames = h.DataSize / int64(h.BlockAlign())
+		dur = time.Duration(float64(frames) / float64(h.SampleRate) * float64(time.Second))
+	}
+	start := m.StartedAt
+	if start.IsZero() {
+		if info, err := os.Stat(file); err == nil {
+			start = info.ModTime().Add(-dur)
+		}
+	}
+
+	minDur := time.Duration(e.cfg.Session.MinSessionSeconds) * time.Second
+	if reason == metadata.ReasonRecovered && minDur > 0 && dur < minDur {
+		reason = metadata.ReasonDiscardedShort
+	}
+	discarded := reason != metadata.ReasonRecovered
+
+	profile := m.FormatProfile
+	if profile == "" {
+		profile = e.cfg.Audio.FormatProfile
+	}
+	sampleFormat, _ := h.SampleFormat()
+	spec := audio.GetFormatSpec(profile).WithSampleFormat(sampleFormat.String(), false)
+
+	finalFile := file
+	if spec.Container == "m4a" && !discarded {
+		finalFile = e.convertRecording(file, spec)
+	}
+
+	meta := metadata.Recording{
+		StartedAt:           start,
+		EndedAt:             start.Add(dur),
+		DurationSecs:        dur.Seconds(),
+		Platform:            runtime.GOOS,
+		DeviceName:          m.DeviceName,
+		FormatProfile:       string(spec.Profile),
+		Container:           spec.Container,
+		Codec:               spec.Codec,
+		SampleRate:          spec.SampleRate,
+		Channels:            spec.Channels,
+		BitrateKbps:         spec.BitrateKbps,
+		Threshold:           e.cfg.Audio.Threshold,
+		SilenceSplitSeconds: e.cfg.Audio.SilenceSeconds,
+		SplitReason:         string(reason),
+		FinalizationReason:  reason,
+		AppVersion:          m.AppVersion,
+		FramesWritten:       frames,
+		BytesWritten:        h.DataSize,
+		HasMeaningfulAudio:  !discarded,
+	}
+	if err := metadata.Write(finalFile, meta); err != nil {
+		e.logger.Printf("[recover] %s: metadata error: %v", name, err)
+	}
+
+	if discarded && e.cfg.Session.DiscardShortSessions {
+		e.logger.Printf("[recover] deleting unrecoverable recording: %s (reason=%s)", name, reason)
+		os.Remove(finalFile)
+		os.Remove(metadata.SidecarPath(finalFile))
+	} else {
+		e.logger.Printf("[recover] recovered %s (%s) reason=%s", filepath.Base(finalFile), dur.Truncate(time.Second), reason)
+		if err := e.publishRecording(finalFile, meta); err != nil {
+			e.logger.Printf("[recover] %s: publish error: %v", name, err)
+		}
+	}
+	os.Remove(marker)
+}
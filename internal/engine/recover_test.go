@@ -0,0 +1,197 @@
+package engine_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tiroq/memofy/internal/config"
+	"github.com/tiroq/memofy/internal/encrypt"
+	"github.com/tiroq/memofy/internal/engine"
+	"github.com/tiroq/memofy/internal/library"
+	"github.com/tiroq/memofy/internal/metadata"
+	"github.com/tiroq/memofy/internal/upload"
+	"github.com/tiroq/memofy/internal/wav"
+)
+
+// writeOrphan leaves a WAV with an unpatched header and an in-progress
+// marker in dir, as a killed recording would.
+func writeOrphan(t *testing.T, dir string, seconds int) string {
+	t.Helper()
+	tmp := filepath.Join(t.TempDir(), "live.wav")
+	w, err := wav.Create(tmp, 8000, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples := make([]float32, 8000*seconds)
+	for i := range samples {
+		samples[i] = 0.1
+	}
+	w.Write(samples)
+	// Snapshot the file before Close() fixes the header.
+	data, _ := os.ReadFile(tmp)
+	w.Close()
+
+	path := filepath.Join(dir, "2026-01-01_100000_audio_wav.wav")
+	os.WriteFile(path, data, 0644)
+	start := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	if err := metadata.WriteInProgress(path, metadata.InProgress{StartedAt: start, FormatProfile: "wav"}); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRecoverOrphan(t *testing.T) {
+	cfg := config.Default()
+	cfg.Output.Dir = t.TempDir()
+	eng := engine.New(cfg, nil)
+
+	path := writeOrphan(t, cfg.Output.Dir, 5)
+	eng.RecoverOrphan(metadata.InProgressPath(path))
+
+	h, err := wav.ReadHeader(path)
+	if err != nil {
+		t.Fatalf("recovered file unreadable: %v", err)
+	}
+	if h.DataSize != 8000*5*2 {
+		t.Errorf("repaired data size = %d, want %d", h.DataSize, 8000*5*2)
+	}
+	if _, err := os.Stat(metadata.InProgressPath(path)); !os.IsNotExist(err) {
+		t.Error("in-progress marker should be removed after recovery")
+	}
+
+	data, err := os.ReadFile(filepath.Join(cfg.Output.Dir, "2026-01-01_100000_audio_wav.json"))
+	if err != nil {
+		t.Fatalf("sidecar not written: %v", err)
+	}
+	var meta metadata.Recording
+	json.Unmarshal(data, &meta)
+	if meta.FinalizationReason != metadata.ReasonRecovered {
+		t.Errorf("finalization_reason = %q, want %q", meta.FinalizationReason, metadata.ReasonRecovered)
+	}
+	if meta.DurationSecs != 5 {
+		t.Errorf("duration = %f, want 5", meta.DurationSecs)
+	}
+}
+
+func TestRecoverOrphan_Encrypted(t *testing.T) {
+	id, err := encrypt.GenerateIdentity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Default()
+	cfg.Output.Dir = t.TempDir()
+	cfg.Encryption = config.EncryptionConfig{
+		Enabled:    true,
+		Recipients: []string{id.Recipient().String()},
+		StagingDir: t.TempDir(),
+	}
+	eng := engine.New(cfg, nil)
+	if err := eng.InitEncryption(); err != nil {
+		t.Fatal(err)
+	}
+
+	path := writeOrphan(t, cfg.Encryption.StagingDir, 5)
+	eng.RecoverOrphan(metadata.InProgressPath(path))
+
+	for _, plain := range []string{path, metadata.SidecarPath(path)} {
+		if _, err := os.Stat(plain); !os.IsNotExist(err) {
+			t.Errorf("plaintext %s left in staging", filepath.Base(plain))
+		}
+	}
+	sealed := filepath.Join(cfg.Output.Dir, "2026-01-01_100000_audio_wav.json.enc")
+	out := filepath.Join(t.TempDir(), "meta.json")
+	if err := encrypt.DecryptFile(sealed, out, id); err != nil {
+		t.Fatalf("decrypt sidecar: %v", err)
+	}
+	meta, err := metadata.Read(out)
+	if err != nil || meta.FinalizationReason != metadata.ReasonRecovered {
+		t.Errorf("decrypted sidecar = %+v, %v", meta, err)
+	}
+
+	ix, err := library.Open(cfg.Output.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := ix.List(library.Filter{})
+	if len(entries) != 1 || !entries[0].Encrypted || entries[0].File != "2026-01-01_100000_audio_wav.wav.enc" || entries[0].DurationSecs != 5 {
+		t.Errorf("index = %+v", entries)
+	}
+}
+
+func TestRecoverOrphan_QueuesUpload(t *testing.T) {
+	cfg := config.Default()
+	cfg.Output.Dir = t.TempDir()
+	cfg.Upload.Enabled = true
+	cfg.Upload.Target = "webdav"
+	cfg.Upload.WebDAV.URL = "http://127.0.0.1:1/dav/"
+	eng := engine.New(cfg, nil)
+	eng.InitUpload()
+
+	path := writeOrphan(t, cfg.Output.Dir, 5)
+	eng.RecoverOrphan(metadata.InProgressPath(path))
+
+	if got := eng.GetStatus().UploadPending; got != 1 {
+		t.Errorf("UploadPending = %d, want 1", got)
+	}
+	st, err := upload.ReadStatus(cfg.Output.Dir)
+	if err != nil || st.Pending != 1 {
+		t.Errorf("queue file: %+v, %v", st, err)
+	}
+}
+
+func TestRecoverOrphan_TooShortIsDiscarded(t *testing.T) {
+	cfg := config.Default()
+	cfg.Output.Dir = t.TempDir()
+	cfg.Session.MinSessionSeconds = 3
+	cfg.Session.DiscardShortSessions = true
+	eng := engine.New(cfg, nil)
+
+	path := writeOrphan(t, cfg.Output.Dir, 1)
+	eng.RecoverOrphan(metadata.InProgressPath(path))
+
+	entries, _ := os.ReadDir(cfg.Output.Dir)
+	if len(entries) != 0 {
+		t.Errorf("short orphan should be deleted with its marker, found %d entries", len(entries))
+	}
+}
+
+func TestRecoverOrphan_MissingRecording(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.Default()
+	cfg.Output.Dir = dir
+	eng := engine.New(cfg, nil)
+
+	path := filepath.Join(dir, "gone.wav")
+	metadata.WriteInProgress(path, metadata.InProgress{})
+	eng.RecoverOrphan(metadata.InProgressPath(path))
+
+	if _, err := os.Stat(metadata.InProgressPath(path)); !os.IsNotExist(err) {
+		t.Error("stale marker should be removed")
+	}
+}
+
+func TestRecoverOrphan_LiveWriterIsLeftAlone(t *testing.T) {
+	cfg := config.Default()
+	cfg.Output.Dir = t.TempDir()
+	eng := engine.New(cfg, nil)
+
+	path := writeOrphan(t, cfg.Output.Dir, 5)
+	// Another running process (the test runner) owns the marker.
+	marker := metadata.InProgress{StartedAt: time.Now(), FormatProfile: "wav", PID: os.Getppid()}
+	if err := metadata.WriteInProgress(path, marker); err != nil {
+		t.Fatal(err)
+	}
+	eng.RecoverOrphan(metadata.InProgressPath(path))
+
+	for _, f := range []string{path, metadata.InProgressPath(path)} {
+		if _, err := os.Stat(f); err != nil {
+			t.Errorf("%s of a live recording touched: %v", filepath.Base(f), err)
+		}
+	}
+	if _, err := os.Stat(metadata.SidecarPath(path)); !os.IsNotExist(err) {
+		t.Error("a live recording should get no sidecar")
+	}
+}
@@ -0,0 +1,390 @@
+package engine_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tiroq/memofy/internal/config"
+	"github.com/tiroq/memofy/internal/engine"
+	"github.com/tiroq/memofy/internal/metadata"
+	"github.com/tiroq/memofy/internal/monitor"
+	"github.com/tiroq/memofy/internal/trigger"
+)
+
+const sessionRate = 8000
+
+// sessionConfig records WAV at once on sound and stops on the first
+// silent buffer once the mic is off, so tests can drive sessions buffer by
+// buffer.
+func sessionConfig(t *testing.T) config.Config {
+	t.Helper()
+	cfg := config.Default()
+	cfg.Output.Dir = t.TempDir()
+	cfg.Audio.FormatProfile = "wav"
+	cfg.Audio.ActivationMs = 0
+	cfg.Audio.SilenceSeconds = 0
+	cfg.Session.MinSessionSeconds = 0
+	cfg.Monitoring.MicReleaseSeconds = 0
+	return cfg
+}
+
+func newSessionEngine(t *testing.T, cfg config.Config) *engine.Engine {
+	t.Helper()
+	eng := engine.New(cfg, nil)
+	eng.StartCapture(sessionRate, 1)
+	return eng
+}
+
+// recording reports whether eng has a session open.
+func recording(eng *engine.Engine) bool {
+	return eng.GetStatus().CurrentFile != ""
+}
+
+// buffer returns 100 ms of audio at level.
+func buffer(level float32) []float32 {
+	buf := make([]float32, sessionRate/10)
+	for i := range buf {
+		buf[i] = level
+	}
+	return buf
+}
+
+var (
+	loud   = buffer(0.3)
+	silent = buffer(0)
+)
+
+// sidecars reads the sidecars written to dir.
+func sidecars(t *testing.T, dir string) []metadata.Recording {
+	t.Helper()
+	paths, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	var recs []metadata.Recording
+	for _, p := range paths {
+		if strings.HasSuffix(p, metadata.InProgressSuffix) {
+			continue
+		}
+		rec, err := metadata.Read(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		recs = append(recs, rec)
+	}
+	return recs
+}
+
+func zoomCall(inCall bool) monitor.Snapshot {
+	return monitor.Snapshot{Apps: map[string]monitor.AppState{"zoom": {Running: true, InCall: inCall}}}
+}
+
+func TestTrigger_PolicyStopsSession(t *testing.T) {
+	cfg := sessionConfig(t)
+	cfg.Trigger = trigger.Spec{Kind: "app", App: "zoom", InCall: true}
+	eng := newSessionEngine(t, cfg)
+
+	eng.SetMonitorSnapshot(zoomCall(true))
+	eng.Feed(silent) // the call starts the session without audio
+	if !recording(eng) {
+		t.Fatal("a Zoom call should start a session")
+	}
+	for i := 0; i < 5; i++ {
+		eng.Feed(loud)
+	}
+	eng.SetMonitorSnapshot(zoomCall(false))
+	eng.Feed(loud)
+	if recording(eng) {
+		t.Fatal("the session should end with the call, audio or not")
+	}
+	recs := sidecars(t, cfg.Output.Dir)
+	if len(recs) != 1 || recs[0].FinalizationReason != metadata.ReasonTriggerStop {
+		t.Errorf("sidecars = %+v, want one with reason trigger_stop", recs)
+	}
+}
+
+func TestTrigger_PolicyHoldsPastSilenceTimeout(t *testing.T) {
+	cfg := sessionConfig(t)
+	cfg.Trigger = trigger.Spec{Kind: "any_of", Policies: []trigger.Spec{
+		{Kind: "audio"},
+		{Kind: "app", App: "zoom", InCall: true},
+	}}
+	eng := newSessionEngine(t, cfg)
+	eng.Feed(loud)
+	eng.Feed(loud)
+	if !recording(eng) {
+		t.Fatal("audio should start a session")
+	}
+	eng.Feed(loud)
+
+	eng.SetMonitorSnapshot(zoomCall(true))
+	for i := 0; i < 5; i++ {
+		eng.Feed(silent)
+		time.Sleep(time.Millisecond)
+	}
+	if !recording(eng) {
+		t.Fatal("the call should hold the session past its silence timeout")
+	}
+	if recs := sidecars(t, cfg.Output.Dir); len(recs) != 0 {
+		t.Errorf("sidecars = %+v, want none while held", recs)
+	}
+
+	eng.SetMonitorSnapshot(monitor.Snapshot{})
+	eng.Feed(silent)
+	eng.Feed(silent)
+	if recording(eng) {
+		t.Fatal("silence should end the session once the call is over")
+	}
+	recs := sidecars(t, cfg.Output.Dir)
+	if len(recs) != 1 || recs[0].FinalizationReason != metadata.ReasonSilenceTimeout {
+		t.Errorf("sidecars = %+v, want one with reason silence_timeout", recs)
+	}
+}
+
+func TestTrigger_NeverStartOnAudioAlone(t *testing.T) {
+	cfg := sessionConfig(t)
+	cfg.Trigger = trigger.Spec{Kind: "any_of", Policies: []trigger.Spec{
+		{Kind: "mic"},
+		{Kind: "hold_while", Policy: &trigger.Spec{Kind: "audio"}},
+	}}
+	eng := newSessionEngine(t, cfg)
+	for i := 0; i < 5; i++ {
+		eng.Feed(loud)
+	}
+	if st := eng.GetStatus(); st.CurrentFile != "" || st.State != "idle" {
+		t.Fatalf("audio alone: file %q state %q, want idle", st.CurrentFile, st.State)
+	}
+
+	eng.SetMonitorSnapshot(monitor.Snapshot{MicActive: true})
+	eng.Feed(loud)
+	if !recording(eng) {
+		t.Fatal("the mic should start a session")
+	}
+	eng.SetMonitorSnapshot(monitor.Snapshot{})
+	eng.Feed(loud)
+	if !recording(eng) {
+		t.Error("audio should keep the session going after the mic stops")
+	}
+}
+
+func TestTrigger_DefaultPolicy(t *testing.T) {
+	cfg := sessionConfig(t)
+	eng := newSessionEngine(t, cfg)
+
+	// The mic starts a session without audio.
+	eng.SetMonitorSnapshot(monitor.Snapshot{MicActive: true})
+	eng.Feed(silent)
+	if !recording(eng) {
+		t.Fatal("the mic should start a session by default")
+	}
+	for i := 0; i < 3; i++ {
+		eng.Feed(silent)
+		time.Sleep(time.Millisecond)
+	}
+	if !recording(eng) {
+		t.Fatal("the mic should hold the session through silence")
+	}
+
+	cfg = sessionConfig(t)
+	cfg.Monitoring.MicSessionLock = false
+	eng = newSessionEngine(t, cfg)
+	eng.SetMonitorSnapshot(monitor.Snapshot{MicActive: true})
+	eng.Feed(silent)
+	if recording(eng) {
+		t.Error("without the mic session lock the mic should not start a session")
+	}
+	eng.Feed(loud)
+	eng.Feed(loud)
+	if !recording(eng) {
+		t.Error("audio should start a session by default")
+	}
+}
+
+func TestSessionProfile_AppInCallBeatsBackgroundApp(t *testing.T) {
+	cfg := sessionConfig(t)
+	cfg.AppProfiles = []config.AppProfile{
+		{Name: "teams-archive", Apps: []string{"teams"}, Tag: "teams"},
+		{Name: "adhoc"},
+	}
+	teamsIdle := monitor.AppState{Running: true}
+	cases := []struct {
+		name     string
+		snap     monitor.Snapshot
+		profile  string
+		matched  string
+		wantFile string
+	}{
+		{"zoom call, teams idle", monitor.Snapshot{Apps: map[string]monitor.AppState{
+			"teams": teamsIdle, "zoom": {Running: true, InCall: true},
+		}}, "adhoc", "", "_audio_wav.wav"},
+		{"teams using the mic", monitor.Snapshot{
+			Apps: map[string]monitor.AppState{"teams": teamsIdle}, MicActive: true, MicBundleIDs: []string{"teams"},
+		}, "teams-archive", "teams", "_teams.wav"},
+		{"teams idle only", monitor.Snapshot{Apps: map[string]monitor.AppState{"teams": teamsIdle}},
+			"teams-archive", "teams", "_teams.wav"},
+	}
+	for _, tc := range cases {
+		cfg.Output.Dir = t.TempDir()
+		eng := newSessionEngine(t, cfg)
+		eng.SetMonitorSnapshot(tc.snap)
+		if name, _ := eng.SessionProfile(); name != tc.profile {
+			t.Errorf("%s: session profile = %q, want %q", tc.name, name, tc.profile)
+		}
+		eng.Feed(loud)
+		eng.Feed(loud)
+		eng.Feed(loud)
+		if st := eng.GetStatus(); st.AppProfile != tc.profile || !strings.HasSuffix(st.CurrentFile, tc.wantFile) {
+			t.Errorf("%s: status profile %q file %q, want %q and a file ending in %q", tc.name, st.AppProfile, st.CurrentFile, tc.profile, tc.wantFile)
+		}
+		eng.SetMonitorSnapshot(monitor.Snapshot{})
+		eng.Feed(silent)
+		eng.Feed(silent)
+		recs := sidecars(t, cfg.Output.Dir)
+		if len(recs) != 1 || recs[0].AppProfile == nil {
+			t.Fatalf("%s: sidecars = %+v, want one with an app profile", tc.name, recs)
+		}
+		if got := recs[0].AppProfile; got.Name != tc.profile || got.MatchedApp != tc.matched {
+			t.Errorf("%s: sidecar app_profile = %+v, want %s matched by %q", tc.name, got, tc.profile, tc.matched)
+		}
+	}
+}
+
+// waitSidecars waits up to a few seconds for n sidecars in dir.
+func waitSidecars(t *testing.T, dir string, n int) []metadata.Recording {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		recs := sidecars(t, dir)
+		if len(recs) >= n || time.Now().After(deadline) {
+			return recs
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func mergeConfig(t *testing.T) config.Config {
+	cfg := sessionConfig(t)
+	cfg.Session.MergeGapSeconds = 1
+	return cfg
+}
+
+// recordSession records n loud buffers and ends the session by silence.
+// The file gets the n buffers and the first silent one.
+func recordSession(eng *engine.Engine, n int) {
+	for i := 0; i < n+1; i++ { // the first buffer only arms
+		eng.Feed(loud)
+	}
+	eng.Feed(silent)
+	eng.Feed(silent)
+}
+
+func TestMerge_ResumeWithinGap(t *testing.T) {
+	cfg := mergeConfig(t)
+	eng := newSessionEngine(t, cfg)
+	recordSession(eng, 5)
+	if recording(eng) {
+		t.Fatal("silence should end the first session")
+	}
+	time.Sleep(300 * time.Millisecond)
+	recordSession(eng, 5)
+	if recs := sidecars(t, cfg.Output.Dir); len(recs) != 0 {
+		t.Fatalf("sidecars = %d within the merge window, want none", len(recs))
+	}
+
+	recs := waitSidecars(t, cfg.Output.Dir, 1)
+	time.Sleep(100 * time.Millisecond)
+	if recs = sidecars(t, cfg.Output.Dir); len(recs) != 1 {
+		t.Fatalf("sidecars = %d, want the two sessions merged into one", len(recs))
+	}
+	rec := recs[0]
+	if len(rec.Segments) != 2 {
+		t.Fatalf("segments = %+v, want 2", rec.Segments)
+	}
+	// Each session wrote 5 loud buffers and the silent one that began its
+	// silence timeout. Buffers are fed faster than real time, so the
+	// wall-clock span (with the break) differs from the recorded audio.
+	want := 2 * 6 * 0.1
+	if d := rec.DurationSecs; d < want-0.01 || d > want+0.01 {
+		t.Errorf("duration_seconds = %.3f, want %.1f: the recorded audio without the break (span %.3f)",
+			d, want, rec.EndedAt.Sub(rec.StartedAt).Seconds())
+	}
+	if rec.Segments[1].FileSeconds != rec.Segments[0].DurationSeconds {
+		t.Errorf("second segment at %.3f s in the file, want right after the first (%.3f s)",
+			rec.Segments[1].FileSeconds, rec.Segments[0].DurationSeconds)
+	}
+}
+
+func TestMerge_ExpiresAfterGap(t *testing.T) {
+	cfg := mergeConfig(t)
+	eng := newSessionEngine(t, cfg)
+	recordSession(eng, 5)
+	if recs := sidecars(t, cfg.Output.Dir); len(recs) != 0 {
+		t.Fatalf("sidecars = %d right after the session, want it held", len(recs))
+	}
+	recs := waitSidecars(t, cfg.Output.Dir, 1)
+	if len(recs) != 1 || recs[0].FinalizationReason != metadata.ReasonSilenceTimeout || len(recs[0].Segments) != 0 {
+		t.Fatalf("sidecars = %+v, want one unmerged silence_timeout recording", recs)
+	}
+
+	recordSession(eng, 5)
+	if recs := waitSidecars(t, cfg.Output.Dir, 2); len(recs) != 2 {
+		t.Errorf("sidecars = %d, want a separate recording after the window closed", len(recs))
+	}
+}
+
+func TestMerge_StopWhileHeld(t *testing.T) {
+	cfg := mergeConfig(t)
+	cfg.Session.MergeGapSeconds = 600
+	eng := newSessionEngine(t, cfg)
+	recordSession(eng, 5)
+	if recs := sidecars(t, cfg.Output.Dir); len(recs) != 0 {
+		t.Fatalf("sidecars = %d, want the session held", len(recs))
+	}
+	eng.Stop() // must not wait for the 10 minute window
+	recs := sidecars(t, cfg.Output.Dir)
+	if len(recs) != 1 || recs[0].FinalizationReason != metadata.ReasonSilenceTimeout {
+		t.Fatalf("sidecars after Stop = %+v, want the held session finalized", recs)
+	}
+	if markers, _ := filepath.Glob(filepath.Join(cfg.Output.Dir, "*"+metadata.InProgressSuffix)); len(markers) != 0 {
+		t.Errorf("in-progress markers left after Stop: %v", markers)
+	}
+}
+
+func TestSession_DeviceNameOfPart(t *testing.T) {
+	cfg := sessionConfig(t)
+	eng := newSessionEngine(t, cfg)
+	eng.SetDeviceName("Microsoft Teams Audio")
+	eng.Feed(loud)
+	eng.Feed(loud)
+	eng.Feed(loud)
+	// The meeting ends: pollMonitor switches back before the session ends.
+	eng.SetDeviceName("BlackHole 2ch")
+	eng.Feed(silent)
+	eng.Feed(silent)
+	recs := sidecars(t, cfg.Output.Dir)
+	if len(recs) != 1 || recs[0].DeviceName != "Microsoft Teams Audio" {
+		t.Fatalf("sidecars = %+v, want device_name of the recorded device", recs)
+	}
+}
+
+func TestStatus_ChannelWarningOfRunningEngine(t *testing.T) {
+	eng := engine.New(sessionConfig(t), nil)
+	eng.StartCapture(sessionRate, 2)
+	defer eng.Stop()
+
+	// Left carries audio, right is dead.
+	buf := make([]float32, sessionRate/10*2)
+	for i := 0; i < len(buf); i += 2 {
+		buf[i] = 0.3
+	}
+	for i := 0; i < 5; i++ {
+		eng.Feed(buf)
+	}
+	eng.CheckChannels()
+
+	if s := eng.Status(); !strings.Contains(s, "WARNING: channel 2 is silent") {
+		t.Errorf("Status() = %q, want the dead channel warning", s)
+	}
+	if w := eng.GetStatus().ChannelWarnings; len(w) != 1 {
+		t.Errorf("ChannelWarnings = %v, want one warning", w)
+	}
+}
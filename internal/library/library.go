@@ -0,0 +1,611 @@
+// Package library indexes the recordings in the output directory. The
+// index is an append-only JSON Lines file next to the recordings: the
+// engine appends an entry when it writes a sidecar, and Sync brings the
+// index up to date with the sidecars on disk, so it can always be rebuilt
+// from the files alone. A later line for the same sidecar replaces an
+// earlier one; a line with Removed set drops it. Open rewrites the file
+// once superseded lines outnumber the live entries.
+package library
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tiroq/memofy/internal/metadata"
+	"github.com/tiroq/memofy/internal/recpath"
+)
+
+// IndexName is the index file name inside the output directory.
+const IndexName = ".memofy-index.jsonl"
+
+// audioExts are the recording extensions looked for next to a sidecar.
+var audioExts = []string{recpath.ExtM4A, recpath.ExtWAV}
+
+// Entry is one recording in the index: the sidecar fields used for
+// listing and filtering. Paths are relative to the output directory.
+type Entry struct {
+	Sidecar string `json:"sidecar"`
+	// File is the recording next to the sidecar; empty when it was
+	// discarded or deleted.
+	File               string                      `json:"file,omitempty"`
+	FileSize           int64                       `json:"file_size,omitempty"`
+	SessionID          string                      `json:"session_id"`
+	StartedAt          time.Time                   `json:"started_at"`
+	EndedAt            time.Time                   `json:"ended_at"`
+	DurationSecs       float64                     `json:"duration_seconds"`
+	FinalizationReason metadata.FinalizationReason `json:"finalization_reason"`
+	HasAudio           bool                        `json:"has_audio"`
+	FormatProfile      string                      `json:"format_profile,omitempty"`
+	Apps               []string                    `json:"apps,omitempty"` // apps running at the end and seen during
+	Event              string                      `json:"event,omitempty"`
+	AppProfile         string                      `json:"app_profile,omitempty"`
+	SeriesID           string                      `json:"series_id,omitempty"`
+	PartIndex          int                         `json:"part_index,omitempty"`
+	Markers            int                         `json:"markers,omitempty"`
+	Starred            bool                        `json:"starred,omitempty"`
+	Tags               []string                    `json:"tags,omitempty"`
+	// Encrypted entries hold no apps or event title; see AddSealed.
+	Encrypted bool `json:"encrypted,omitempty"`
+
+	// SidecarSize and SidecarModTime detect sidecars rewritten since they
+	// were indexed.
+	SidecarSize    int64     `json:"sidecar_size"`
+	SidecarModTime time.Time `json:"sidecar_mod_time"`
+
+	// Removed drops the sidecar from the index.
+	Removed bool `json:"removed,omitempty"`
+}
+
+// Duration returns the recording's duration.
+func (e Entry) Duration() time.Duration {
+	return time.Duration(e.DurationSecs * float64(time.Second))
+}
+
+// HasApp reports whether app was running during the recording, ignoring
+// case.
+func (e Entry) HasApp(app string) bool {
+	for _, a := range e.Apps {
+		if strings.EqualFold(a, app) {
+			return true
+		}
+	}
+	return false
+}
+
+// Index is the set of recordings under one output directory.
+type Index struct {
+	root    string
+	entries map[string]Entry // by sidecar
+	lines   int              // lines in the index file, superseded ones included
+}
+
+// writeMu serializes appends from this process; each append is a single
+// write of whole lines, so appends from other processes do not interleave.
+var writeMu sync.Mutex
+
+// Load reads the index of the output directory root. A missing index is
+// empty; call Sync to fill it from the sidecars.
+func Load(root string) (*Index, error) {
+	ix := &Index{root: root, entries: make(map[string]Entry)}
+	data, err := os.ReadFile(filepath.Join(root, IndexName))
+	if os.IsNotExist(err) {
+		return ix, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read index: %w", err)
+	}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		var e Entry
+		// A torn last line (crash during append) is skipped; Sync re-adds
+		// the sidecar it described.
+		ix.lines++
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil || e.Sidecar == "" {
+			continue
+		}
+		if e.Removed {
+			delete(ix.entries, e.Sidecar)
+		} else {
+			ix.entries[e.Sidecar] = e
+		}
+	}
+	return ix, sc.Err()
+}
+
+// Open loads the index of root, syncs it with the files and compacts it
+// when most of its lines are superseded.
+func Open(root string) (*Index, error) {
+	ix, err := Load(root)
+	if err != nil {
+		return nil, err
+	}
+	if err := ix.Sync(); err != nil {
+		return nil, err
+	}
+	if ix.lines-len(ix.entries) > len(ix.entries) {
+		if err := ix.compact(); err != nil {
+			return nil, err
+		}
+	}
+	return ix, nil
+}
+
+// compact atomically replaces the index file with one line per entry. A
+// line another process appends meanwhile is lost, but the next Sync
+// re-adds its sidecar.
+func (ix *Index) compact() error {
+	rels := make([]string, 0, len(ix.entries))
+	for rel := range ix.entries {
+		rels = append(rels, rel)
+	}
+	sort.Strings(rels)
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, rel := range rels {
+		if err := enc.Encode(ix.entries[rel]); err != nil {
+			return fmt.Errorf("encode index entry: %w", err)
+		}
+	}
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	path := filepath.Join(ix.root, IndexName)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("compact index: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("compact index: %w", err)
+	}
+	ix.lines = len(rels)
+	return nil
+}
+
+// Sync adds sidecars missing from the index or changed since they were
+// indexed, and removes entries whose sidecar is gone. Changes are appended
+// to the index file.
+func (ix *Index) Sync() error {
+	onDisk := make(map[string]bool)
+	var changes []Entry
+	err := filepath.WalkDir(ix.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == ix.root {
+				return err
+			}
+			return nil // unreadable subdirectory
+		}
+		if d.IsDir() || !isSidecar(d.Name()) {
+			return nil
+		}
+		rel, err := filepath.Rel(ix.root, path)
+		if err != nil {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		old, ok := ix.entries[rel]
+		if ok && old.SidecarSize == info.Size() && old.SidecarModTime.Equal(info.ModTime()) && ix.fileCurrent(old) {
+			onDisk[rel] = true
+			return nil
+		}
+		e, err := ix.entryFor(rel)
+		if err != nil {
+			return nil // not a recording sidecar
+		}
+		onDisk[rel] = true
+		changes = append(changes, e)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("scan %s: %w", ix.root, err)
+	}
+	for rel := range ix.entries {
+		if !onDisk[rel] {
+			changes = append(changes, Entry{Sidecar: rel, Removed: true})
+		}
+	}
+	return ix.apply(changes)
+}
+
+// Rebuild replaces the index file with one built from the sidecars alone.
+func Rebuild(root string) (*Index, error) {
+	writeMu.Lock()
+	err := os.Remove(filepath.Join(root, IndexName))
+	writeMu.Unlock()
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("remove index: %w", err)
+	}
+	ix := &Index{root: root, entries: make(map[string]Entry)}
+	if err := ix.Sync(); err != nil {
+		return nil, err
+	}
+	return ix, nil
+}
+
+// Add indexes the sidecar of the recording at recordingPath, which must be
+// inside root. The engine calls it after writing a sidecar.
+func Add(root, recordingPath string) error {
+	rel, err := filepath.Rel(root, metadata.SidecarPath(recordingPath))
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return fmt.Errorf("index %s: not inside %s", recordingPath, root)
+	}
+	ix := &Index{root: root, entries: make(map[string]Entry)}
+	e, err := ix.entryFor(rel)
+	if err != nil {
+		return err
+	}
+	return ix.apply([]Entry{e})
+}
+
+// AddSealed indexes an encrypted recording from r, the sidecar as it was
+// before encryption, since the index cannot read sidecarPath. The entry is
+// also written to the sidecar's plaintext companion, so the index can be
+// rebuilt without the private key. The apps and event title are left out
+// to keep them off the disk in plaintext.
+func AddSealed(root, sidecarPath string, r metadata.Recording) error {
+	rel, err := filepath.Rel(root, sidecarPath)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return fmt.Errorf("index %s: not inside %s", sidecarPath, root)
+	}
+	info, err := os.Stat(sidecarPath)
+	if err != nil {
+		return err
+	}
+	ix := &Index{root: root, entries: make(map[string]Entry)}
+	e := newEntry(rel, info, r)
+	e.Apps, e.Event, e.Encrypted = nil, "", true
+	e.File, e.FileSize = ix.findFile(rel)
+	if err := writeCompanion(sidecarPath, e); err != nil {
+		return err
+	}
+	return ix.apply([]Entry{e})
+}
+
+// SetLabels changes the star and tags of an encrypted recording, whose
+// sidecar cannot be rewritten without the private key, in its companion
+// and the index.
+func (ix *Index) SetLabels(e Entry, starred bool, tags []string) error {
+	if !e.Encrypted {
+		return fmt.Errorf("%s is not encrypted", e.Sidecar)
+	}
+	e.Starred, e.Tags = starred, tags
+	if err := writeCompanion(ix.Path(e.Sidecar), e); err != nil {
+		return err
+	}
+	return ix.apply([]Entry{e})
+}
+
+// CompanionPath returns the plaintext companion of an encrypted sidecar.
+func CompanionPath(sidecar string) string {
+	return recpath.WithExt(sidecar, recpath.ExtCompanion)
+}
+
+// companion is the content of a companion file: the index fields of an
+// encrypted recording that may be kept in plaintext.
+type companion struct {
+	SessionID          string                      `json:"session_id"`
+	StartedAt          time.Time                   `json:"started_at"`
+	EndedAt            time.Time                   `json:"ended_at"`
+	DurationSecs       float64                     `json:"duration_seconds"`
+	FinalizationReason metadata.FinalizationReason `json:"finalization_reason"`
+	HasAudio           bool                        `json:"has_audio"`
+	FormatProfile      string                      `json:"format_profile,omitempty"`
+	AppProfile         string                      `json:"app_profile,omitempty"`
+	SeriesID           string                      `json:"series_id,omitempty"`
+	PartIndex          int                         `json:"part_index,omitempty"`
+	Markers            int                         `json:"markers,omitempty"`
+	Starred            bool                        `json:"starred,omitempty"`
+	Tags               []string                    `json:"tags,omitempty"`
+}
+
+// writeCompanion writes the companion of the encrypted sidecar at path
+// atomically.
+func writeCompanion(path string, e Entry) error {
+	data, err := json.MarshalIndent(companion{
+		SessionID:          e.SessionID,
+		StartedAt:          e.StartedAt,
+		EndedAt:            e.EndedAt,
+		DurationSecs:       e.DurationSecs,
+		FinalizationReason: e.FinalizationReason,
+		HasAudio:           e.HasAudio,
+		FormatProfile:      e.FormatProfile,
+		AppProfile:         e.AppProfile,
+		SeriesID:           e.SeriesID,
+		PartIndex:          e.PartIndex,
+		Markers:            e.Markers,
+		Starred:            e.Starred,
+		Tags:               e.Tags,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	dst := CompanionPath(path)
+	tmp := dst + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("write companion: %w", err)
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("write companion: %w", err)
+	}
+	return nil
+}
+
+// readCompanion fills e from the companion of the encrypted sidecar at
+// path and reports whether there was one.
+func readCompanion(path string, e *Entry) bool {
+	data, err := os.ReadFile(CompanionPath(path))
+	if err != nil {
+		return false
+	}
+	var c companion
+	if err := json.Unmarshal(data, &c); err != nil || c.StartedAt.IsZero() {
+		return false
+	}
+	e.SessionID, e.StartedAt, e.EndedAt, e.DurationSecs = c.SessionID, c.StartedAt, c.EndedAt, c.DurationSecs
+	e.FinalizationReason, e.HasAudio, e.FormatProfile, e.AppProfile = c.FinalizationReason, c.HasAudio, c.FormatProfile, c.AppProfile
+	e.SeriesID, e.PartIndex, e.Markers, e.Starred, e.Tags = c.SeriesID, c.PartIndex, c.Markers, c.Starred, c.Tags
+	return true
+}
+
+// Remove drops an entry from the index after its files were deleted.
+func (ix *Index) Remove(e Entry) error {
+	return ix.apply([]Entry{{Sidecar: e.Sidecar, Removed: true}})
+}
+
+// apply records changes in memory and appends them to the index file.
+func (ix *Index) apply(changes []Entry) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, e := range changes {
+		if err := enc.Encode(e); err != nil {
+			return fmt.Errorf("encode index entry: %w", err)
+		}
+		if e.Removed {
+			delete(ix.entries, e.Sidecar)
+		} else {
+			ix.entries[e.Sidecar] = e
+		}
+	}
+	writeMu.Lock()
+	defer writeMu.Unlock()
+	f, err := os.OpenFile(filepath.Join(ix.root, IndexName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("open index: %w", err)
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return fmt.Errorf("append index: %w", err)
+	}
+	ix.lines += len(changes)
+	return f.Close()
+}
+
+// entryFor reads the sidecar at rel (relative to the root) into an entry.
+// An encrypted sidecar is read from its companion; without one, its entry
+// only has the files and, for the times, the sidecar's modification time.
+func (ix *Index) entryFor(rel string) (Entry, error) {
+	path := filepath.Join(ix.root, rel)
+	info, err := os.Stat(path)
+	if err != nil {
+		return Entry{}, err
+	}
+	if recpath.Encrypted(rel) {
+		e := Entry{
+			Sidecar:        rel,
+			StartedAt:      info.ModTime(),
+			EndedAt:        info.ModTime(),
+			Encrypted:      true,
+			SidecarSize:    info.Size(),
+			SidecarModTime: info.ModTime(),
+		}
+		readCompanion(path, &e)
+		e.File, e.FileSize = ix.findFile(rel)
+		return e, nil
+	}
+	r, err := metadata.Read(path)
+	if err != nil {
+		return Entry{}, err
+	}
+	if r.StartedAt.IsZero() || r.FinalizationReason == "" {
+		return Entry{}, fmt.Errorf("%s: not a recording sidecar", rel)
+	}
+	e := newEntry(rel, info, r)
+	e.File, e.FileSize = ix.findFile(rel)
+	return e, nil
+}
+
+// newEntry returns the entry for the sidecar at rel holding r.
+func newEntry(rel string, info fs.FileInfo, r metadata.Recording) Entry {
+	e := Entry{
+		Sidecar:            rel,
+		SessionID:          r.SessionID,
+		StartedAt:          r.StartedAt,
+		EndedAt:            r.EndedAt,
+		DurationSecs:       r.DurationSecs,
+		FinalizationReason: r.FinalizationReason,
+		HasAudio:           r.HasMeaningfulAudio,
+		FormatProfile:      r.FormatProfile,
+		SeriesID:           r.SeriesID,
+		PartIndex:          r.PartIndex,
+		Markers:            len(r.Markers),
+		Starred:            r.Starred,
+		Tags:               r.Tags,
+		SidecarSize:        info.Size(),
+		SidecarModTime:     info.ModTime(),
+	}
+	if e.SessionID == "" {
+		e.SessionID = r.StartedAt.Format("20060102T150405")
+	}
+	if e.DurationSecs == 0 && !r.EndedAt.IsZero() {
+		e.DurationSecs = r.EndedAt.Sub(r.StartedAt).Seconds()
+	}
+	if r.Event != nil {
+		e.Event = r.Event.Title
+	}
+	if r.AppProfile != nil {
+		e.AppProfile = r.AppProfile.Name
+	}
+	seen := make(map[string]bool)
+	for name, st := range r.Apps {
+		if st.Running && !seen[name] {
+			seen[name] = true
+			e.Apps = append(e.Apps, name)
+		}
+	}
+	for _, app := range r.AppsSeen {
+		if !seen[app] {
+			seen[app] = true
+			e.Apps = append(e.Apps, app)
+		}
+	}
+	sort.Strings(e.Apps)
+	return e
+}
+
+// findFile returns the recording next to the sidecar at rel, if any, and
+// its size.
+func (ix *Index) findFile(rel string) (string, int64) {
+	stem := recpath.Stem(rel)
+	for _, ext := range audioExts {
+		for _, name := range []string{stem + ext, stem + ext + recpath.ExtEncrypted} {
+			if info, err := os.Stat(filepath.Join(ix.root, name)); err == nil {
+				return name, info.Size()
+			}
+		}
+	}
+	return "", 0
+}
+
+// fileCurrent reports whether the entry's recording file is as indexed:
+// still there with the same size, or still missing.
+func (ix *Index) fileCurrent(e Entry) bool {
+	file, size := ix.findFile(e.Sidecar)
+	return file == e.File && size == e.FileSize
+}
+
+// Files returns the absolute paths of an entry's files that exist: every
+// recording with the sidecar's name (a WAV kept beside a failed M4A
+// conversion included), the companion of an encrypted sidecar, then the
+// sidecar.
+func (ix *Index) Files(e Entry) []string {
+	stem := recpath.Stem(e.Sidecar)
+	var files []string
+	for _, ext := range audioExts {
+		for _, name := range []string{stem + ext, stem + ext + recpath.ExtEncrypted} {
+			path := filepath.Join(ix.root, name)
+			if _, err := os.Stat(path); err == nil {
+				files = append(files, path)
+			}
+		}
+	}
+	if companion := CompanionPath(ix.Path(e.Sidecar)); e.Encrypted {
+		if _, err := os.Stat(companion); err == nil {
+			files = append(files, companion)
+		}
+	}
+	return append(files, ix.Path(e.Sidecar))
+}
+
+// isSidecar reports whether name may be a recording sidecar.
+func isSidecar(name string) bool {
+	return (strings.HasSuffix(name, recpath.ExtSidecar) || strings.HasSuffix(name, recpath.ExtSidecar+recpath.ExtEncrypted)) &&
+		!strings.HasSuffix(name, recpath.InProgressSuffix) &&
+		!strings.HasPrefix(name, ".")
+}
+
+// Root returns the output directory the index covers.
+func (ix *Index) Root() string {
+	return ix.root
+}
+
+// Path returns the absolute path of a path relative to the root.
+func (ix *Index) Path(rel string) string {
+	return filepath.Join(ix.root, rel)
+}
+
+// Filter selects recordings. Zero fields match everything.
+type Filter struct {
+	From, To    time.Time // recordings started in [From, To)
+	App         string    // app or bundle ID seen during the recording
+	MinDuration time.Duration
+	Reason      metadata.FinalizationReason
+	HasAudio    *bool
+}
+
+// Match reports whether e passes the filter.
+func (f Filter) Match(e Entry) bool {
+	switch {
+	case !f.From.IsZero() && e.StartedAt.Before(f.From):
+		return false
+	case !f.To.IsZero() && !e.StartedAt.Before(f.To):
+		return false
+	case f.App != "" && !e.HasApp(f.App):
+		return false
+	case f.MinDuration > 0 && e.Duration() < f.MinDuration:
+		return false
+	case f.Reason != "" && e.FinalizationReason != f.Reason:
+		return false
+	case f.HasAudio != nil && e.HasAudio != *f.HasAudio:
+		return false
+	}
+	return true
+}
+
+// List returns the recordings matching f, oldest first.
+func (ix *Index) List(f Filter) []Entry {
+	var out []Entry
+	for _, e := range ix.entries {
+		if f.Match(e) {
+			out = append(out, e)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].StartedAt.Equal(out[j].StartedAt) {
+			return out[i].StartedAt.Before(out[j].StartedAt)
+		}
+		return out[i].Sidecar < out[j].Sidecar
+	})
+	return out
+}
+
+// Find returns the recordings whose session ID or file name (without
+// extension) is id, oldest first. More than one can match when recordings
+// from different subdirectories share a session ID.
+func (ix *Index) Find(id string) []Entry {
+	var out []Entry
+	for _, e := range ix.List(Filter{}) {
+		stem := recpath.Stem(filepath.Base(e.Sidecar))
+		if e.SessionID == id || stem == id {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// ErrEncrypted is returned for the sidecar of an encrypted recording.
+var ErrEncrypted = errors.New("recording is encrypted; use memofy decrypt")
+
+// ReadRecording reads the full sidecar of an entry.
+func (ix *Index) ReadRecording(e Entry) (metadata.Recording, error) {
+	if e.Encrypted {
+		return metadata.Recording{}, ErrEncrypted
+	}
+	return metadata.Read(ix.Path(e.Sidecar))
+}
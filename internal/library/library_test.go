@@ -0,0 +1,311 @@
+package library
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tiroq/memofy/internal/metadata"
+	"github.com/tiroq/memofy/internal/monitor"
+)
+
+var t0 = time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+
+// record writes a recording file and its sidecar under dir.
+func record(t *testing.T, dir, name string, meta metadata.Recording) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("audio"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := metadata.Write(path, meta); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func meta(start time.Time, dur time.Duration, reason metadata.FinalizationReason, apps ...string) metadata.Recording {
+	return metadata.Recording{
+		StartedAt:          start,
+		EndedAt:            start.Add(dur),
+		DurationSecs:       dur.Seconds(),
+		FinalizationReason: reason,
+		HasMeaningfulAudio: reason == metadata.ReasonSilenceTimeout,
+		AppsSeen:           apps,
+	}
+}
+
+func sidecars(entries []Entry) []string {
+	var out []string
+	for _, e := range entries {
+		out = append(out, e.Sidecar)
+	}
+	return out
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRebuildFromFiles(t *testing.T) {
+	dir := t.TempDir()
+	record(t, dir, "b.m4a", meta(t0.Add(time.Hour), 20*time.Minute, metadata.ReasonSilenceTimeout, "zoom"))
+	record(t, dir, "work/a.m4a", meta(t0, 5*time.Minute, metadata.ReasonManualStop))
+	// Not recordings: an in-progress marker and an unrelated JSON file.
+	metadata.WriteInProgress(filepath.Join(dir, "c.wav"), metadata.InProgress{StartedAt: t0})
+	os.WriteFile(filepath.Join(dir, "notes.json"), []byte(`{"title":"x"}`), 0644)
+
+	ix, err := Rebuild(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := sidecars(ix.List(Filter{}))
+	want := []string{filepath.Join("work", "a.json"), "b.json"}
+	if !equal(got, want) {
+		t.Fatalf("List = %v, want %v", got, want)
+	}
+	e := ix.List(Filter{})[1]
+	if e.File != "b.m4a" || !e.HasApp("Zoom") || e.Duration() != 20*time.Minute {
+		t.Errorf("entry = %+v", e)
+	}
+
+	// The index on disk gives the same result without scanning.
+	loaded, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sidecars(loaded.List(Filter{})); !equal(got, want) {
+		t.Errorf("Load List = %v, want %v", got, want)
+	}
+}
+
+func TestSyncPicksUpChanges(t *testing.T) {
+	dir := t.TempDir()
+	a := record(t, dir, "a.m4a", meta(t0, time.Minute, metadata.ReasonManualStop))
+	if _, err := Open(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	// Added by the engine, then a file deleted by hand.
+	b := record(t, dir, "b.m4a", meta(t0.Add(time.Hour), time.Minute, metadata.ReasonManualStop))
+	if err := Add(dir, b); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(metadata.SidecarPath(a))
+	os.Remove(b)
+
+	ix, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := ix.List(Filter{})
+	if len(entries) != 1 || entries[0].Sidecar != "b.json" {
+		t.Fatalf("List = %v, want [b.json]", sidecars(entries))
+	}
+	if entries[0].File != "" {
+		t.Errorf("File = %q for a deleted recording, want empty", entries[0].File)
+	}
+
+	// The removal was recorded, so a plain Load agrees.
+	loaded, _ := Load(dir)
+	if got := sidecars(loaded.List(Filter{})); !equal(got, []string{"b.json"}) {
+		t.Errorf("Load List = %v, want [b.json]", got)
+	}
+}
+
+func TestLoadSkipsTornLine(t *testing.T) {
+	dir := t.TempDir()
+	a := record(t, dir, "a.m4a", meta(t0, time.Minute, metadata.ReasonManualStop))
+	if err := Add(dir, a); err != nil {
+		t.Fatal(err)
+	}
+	f, _ := os.OpenFile(filepath.Join(dir, IndexName), os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString(`{"sidecar":"b.js`)
+	f.Close()
+
+	ix, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sidecars(ix.List(Filter{})); !equal(got, []string{"a.json"}) {
+		t.Errorf("List = %v, want [a.json]", got)
+	}
+}
+
+func TestOpenCompactsIndex(t *testing.T) {
+	dir := t.TempDir()
+	a := record(t, dir, "a.m4a", meta(t0, time.Minute, metadata.ReasonManualStop))
+	record(t, dir, "b.m4a", meta(t0.Add(time.Hour), time.Minute, metadata.ReasonManualStop))
+	ix, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Starring and unstarring a recording supersedes its line each time.
+	e := ix.Find("a")[0]
+	for i := 0; i < 2; i++ {
+		e.Starred = !e.Starred
+		if err := ix.apply([]Entry{e}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lines := func() int {
+		data, _ := os.ReadFile(filepath.Join(dir, IndexName))
+		return bytes.Count(data, []byte("\n"))
+	}
+	if n := lines(); n != 4 {
+		t.Fatalf("index has %d lines, want 4 before compaction is due", n)
+	}
+
+	// A third change makes superseded lines outnumber the live entries.
+	e.Starred = true
+	ix.apply([]Entry{e})
+	os.Remove(metadata.SidecarPath(a))
+	if ix, err = Open(dir); err != nil {
+		t.Fatal(err)
+	}
+	if n := lines(); n != 1 {
+		t.Errorf("index has %d lines after Open, want 1 per live entry", n)
+	}
+	loaded, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sidecars(loaded.List(Filter{})); !equal(got, []string{"b.json"}) {
+		t.Errorf("compacted index lists %v, want [b.json]", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, IndexName+".tmp")); !os.IsNotExist(err) {
+		t.Error("temporary index left behind")
+	}
+}
+
+func TestFilter(t *testing.T) {
+	dir := t.TempDir()
+	record(t, dir, "short.m4a", meta(t0, 30*time.Second, metadata.ReasonDiscardedShort))
+	record(t, dir, "zoom.m4a", meta(t0.Add(24*time.Hour), 40*time.Minute, metadata.ReasonSilenceTimeout, "zoom"))
+	teams := meta(t0.Add(48*time.Hour), 10*time.Minute, metadata.ReasonSilenceTimeout)
+	teams.Apps = map[string]monitor.AppState{"teams": {Running: true, InCall: true}}
+	record(t, dir, "teams.m4a", teams)
+
+	ix, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	yes, no := true, false
+	tests := []struct {
+		name string
+		f    Filter
+		want []string
+	}{
+		{"all", Filter{}, []string{"short.json", "zoom.json", "teams.json"}},
+		{"from", Filter{From: t0.Add(time.Hour)}, []string{"zoom.json", "teams.json"}},
+		{"to exclusive", Filter{To: t0.Add(48 * time.Hour)}, []string{"short.json", "zoom.json"}},
+		{"app from apps seen", Filter{App: "zoom"}, []string{"zoom.json"}},
+		{"app from app state", Filter{App: "teams"}, []string{"teams.json"}},
+		{"min duration", Filter{MinDuration: 10 * time.Minute}, []string{"zoom.json", "teams.json"}},
+		{"reason", Filter{Reason: metadata.ReasonDiscardedShort}, []string{"short.json"}},
+		{"has audio", Filter{HasAudio: &yes}, []string{"zoom.json", "teams.json"}},
+		{"no audio", Filter{HasAudio: &no}, []string{"short.json"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sidecars(ix.List(tt.f)); !equal(got, tt.want) {
+				t.Errorf("List = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFind(t *testing.T) {
+	dir := t.TempDir()
+	m := meta(t0, time.Minute, metadata.ReasonManualStop)
+	m.SessionID = "20260302T100000"
+	record(t, dir, "2026-03-02_1000_memofy.m4a", m)
+
+	ix, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"20260302T100000", "2026-03-02_1000_memofy"} {
+		if got := ix.Find(id); len(got) != 1 {
+			t.Errorf("Find(%q) = %d entries, want 1", id, len(got))
+		}
+	}
+	if got := ix.Find("nope"); len(got) != 0 {
+		t.Errorf("Find(nope) = %v, want none", got)
+	}
+}
+
+func TestEncryptedRecordings(t *testing.T) {
+	dir := t.TempDir()
+	// Stand-ins for the encrypted files: the index never reads them.
+	os.WriteFile(filepath.Join(dir, "a.m4a.enc"), []byte("sealed audio"), 0600)
+	sidecar := filepath.Join(dir, "a.json.enc")
+	os.WriteFile(sidecar, []byte("sealed sidecar"), 0600)
+
+	m := meta(t0, 20*time.Minute, metadata.ReasonSilenceTimeout, "zoom")
+	m.Event = &metadata.CalendarEvent{Title: "1:1"}
+	if err := AddSealed(dir, sidecar, m); err != nil {
+		t.Fatal(err)
+	}
+	ix, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := ix.List(Filter{})
+	if len(entries) != 1 {
+		t.Fatalf("List = %v, want one entry", sidecars(entries))
+	}
+	e := entries[0]
+	if !e.Encrypted || e.File != "a.m4a.enc" || e.Duration() != 20*time.Minute || !e.StartedAt.Equal(t0) {
+		t.Errorf("entry = %+v", e)
+	}
+	if len(e.Apps) != 0 || e.Event != "" {
+		t.Errorf("encrypted entry leaks apps %v or event %q", e.Apps, e.Event)
+	}
+	if _, err := ix.ReadRecording(e); err != ErrEncrypted {
+		t.Errorf("ReadRecording err = %v, want ErrEncrypted", err)
+	}
+	if got := ix.Files(e); len(got) != 3 || got[1] != CompanionPath(sidecar) {
+		t.Errorf("Files = %v, want the audio, the companion and the sidecar", got)
+	}
+	if err := ix.SetLabels(e, true, []string{"legal"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Rebuilt from the files alone, the companion restores the entry.
+	ix, err = Rebuild(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries = ix.List(Filter{})
+	if len(entries) != 1 || !entries[0].Encrypted || entries[0].File != "a.m4a.enc" {
+		t.Fatalf("rebuilt = %+v", entries)
+	}
+	if e := entries[0]; !e.StartedAt.Equal(t0) || e.Duration() != 20*time.Minute || e.FinalizationReason != metadata.ReasonSilenceTimeout ||
+		!e.Starred || len(e.Tags) != 1 || len(e.Apps) != 0 {
+		t.Errorf("rebuilt entry = %+v", e)
+	}
+
+	// Without the companion only the file time is known.
+	os.Remove(CompanionPath(sidecar))
+	ix, err = Rebuild(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries := ix.List(Filter{}); len(entries) != 1 || !entries[0].Encrypted || entries[0].Starred || entries[0].StartedAt.Equal(t0) {
+		t.Errorf("rebuilt without companion = %+v", entries)
+	}
+}
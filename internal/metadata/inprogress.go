@@ -0,0 +1,103 @@
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tiroq/memofy/internal/recpath"
+)
+
+// InProgressSuffix is appended to a recording's base name to form the path of
+// its in-progress marker.
+const InProgressSuffix = recpath.InProgressSuffix
+
+// InProgress is written next to a recording when capture starts and removed
+// once the session is finalized. A marker that survives a restart identifies
+// a recording that was never finalized and needs repair.
+type InProgress struct {
+	StartedAt     time.Time `json:"started_at"`
+	FormatProfile string    `json:"format_profile"`
+	DeviceName    string    `json:"device_name"`
+	PID           int       `json:"pid"`
+	AppVersion    string    `json:"version"`
+}
+
+// InProgressPath returns the marker path for a recording.
+// Given "/path/to/recording.wav", it returns "/path/to/recording.inprogress.json".
+func InProgressPath(wavPath string) string {
+	return recpath.WithExt(wavPath, InProgressSuffix)
+}
+
+// RecordingPathForMarker returns the WAV path an in-progress marker refers to.
+func RecordingPathForMarker(markerPath string) string {
+	return recpath.WithExt(markerPath, recpath.ExtWAV)
+}
+
+// WriteInProgress writes the in-progress marker for wavPath.
+func WriteInProgress(wavPath string, m InProgress) error {
+	if m.PID == 0 {
+		m.PID = os.Getpid()
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal in-progress marker: %w", err)
+	}
+	path := InProgressPath(wavPath)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("write in-progress marker: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("rename in-progress marker: %w", err)
+	}
+	return nil
+}
+
+// ReadInProgress reads an in-progress marker.
+func ReadInProgress(markerPath string) (InProgress, error) {
+	var m InProgress
+	data, err := os.ReadFile(markerPath)
+	if err != nil {
+		return m, fmt.Errorf("read in-progress marker: %w", err)
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, fmt.Errorf("parse in-progress marker: %w", err)
+	}
+	return m, nil
+}
+
+// RemoveInProgress deletes the marker for wavPath. A missing marker is not
+// an error.
+func RemoveInProgress(wavPath string) error {
+	err := os.Remove(InProgressPath(wavPath))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// FindInProgress returns the in-progress markers anywhere under dir (app
+// profile subdirs and output.dir_template directories included), i.e.
+// recordings that were started but never finalized.
+func FindInProgress(dir string) ([]string, error) {
+	var found []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == dir {
+				return err
+			}
+			return nil // unreadable subdirectory
+		}
+		if !d.IsDir() && strings.HasSuffix(d.Name(), InProgressSuffix) {
+			found = append(found, path)
+		}
+		return nil
+	})
+	return found, err
+}
@@ -0,0 +1,59 @@
+package metadata
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestInProgressRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	wavPath := filepath.Join(dir, "2026-01-01_100000_audio_high.wav")
+
+	start := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	if err := WriteInProgress(wavPath, InProgress{StartedAt: start, FormatProfile: "high"}); err != nil {
+		t.Fatalf("WriteInProgress: %v", err)
+	}
+
+	marker := filepath.Join(dir, "2026-01-01_100000_audio_high.inprogress.json")
+	if got := InProgressPath(wavPath); got != marker {
+		t.Errorf("InProgressPath = %q, want %q", got, marker)
+	}
+	if got := RecordingPathForMarker(marker); got != wavPath {
+		t.Errorf("RecordingPathForMarker = %q, want %q", got, wavPath)
+	}
+
+	found, err := FindInProgress(dir)
+	if err != nil || len(found) != 1 || found[0] != marker {
+		t.Fatalf("FindInProgress = %v, %v", found, err)
+	}
+
+	m, err := ReadInProgress(marker)
+	if err != nil {
+		t.Fatalf("ReadInProgress: %v", err)
+	}
+	if !m.StartedAt.Equal(start) || m.FormatProfile != "high" || m.PID != os.Getpid() {
+		t.Errorf("marker = %+v", m)
+	}
+
+	if err := RemoveInProgress(wavPath); err != nil {
+		t.Fatalf("RemoveInProgress: %v", err)
+	}
+	if err := RemoveInProgress(wavPath); err != nil {
+		t.Errorf("second RemoveInProgress should be a no-op, got %v", err)
+	}
+	if found, _ := FindInProgress(dir); len(found) != 0 {
+		t.Errorf("marker still present: %v", found)
+	}
+
+	// App profiles record into subdirectories of the output dir.
+	nested := filepath.Join(dir, "teams", "2026-01-01_110000_audio_high_teams.wav")
+	os.MkdirAll(filepath.Dir(nested), 0755)
+	if err := WriteInProgress(nested, InProgress{StartedAt: start}); err != nil {
+		t.Fatalf("WriteInProgress: %v", err)
+	}
+	if found, _ := FindInProgress(dir); len(found) != 1 || found[0] != InProgressPath(nested) {
+		t.Errorf("FindInProgress in subdir = %v", found)
+	}
+}
@@ -7,10 +7,11 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
-	"strings"
 	"time"
 
 	"github.com/tiroq/memofy/internal/config"
+	"github.com/tiroq/memofy/internal/monitor"
+	"github.com/tiroq/memofy/internal/recpath"
 )
 
 // FinalizationReason describes why a recording session was finalized.
@@ -24,8 +25,41 @@ const (
 	ReasonError          FinalizationReason = "error"
 	ReasonDiscardedShort FinalizationReason = "discarded_short_session"
 	ReasonDiscardedEmpty FinalizationReason = "discarded_empty_audio"
+	// ReasonRecovered marks a recording repaired at startup after memofy
+	// exited without finalizing it (crash, kill, power loss).
+	ReasonRecovered FinalizationReason = "recovered"
+	// ReasonRollover marks a part closed because it reached
+	// session.max_duration or session.max_size_mb; recording continued in
+	// the next part of the same series.
+	ReasonRollover FinalizationReason = "rollover"
+	// ReasonScheduleClosed marks a session finalized because the allowed
+	// window of the configured recording schedule closed.
+	ReasonScheduleClosed FinalizationReason = "schedule_closed"
+	// ReasonEventBoundary marks a session ended because a calendar event
+	// started or ended (calendar.split_at_boundaries); recording continued
+	// in a new session.
+	ReasonEventBoundary FinalizationReason = "event_boundary"
+	// ReasonTriggerStop marks a session ended because the configured
+	// trigger policy stopped holding it (e.g. the meeting app left the call).
+	ReasonTriggerStop FinalizationReason = "trigger_stop"
+	// ReasonLowDisk marks a session finalized because free space in the
+	// output directory fell below output.critical_disk_mb.
+	ReasonLowDisk FinalizationReason = "low_disk"
 )
 
+// DiscardsInvalidWAV reports whether a session finalized for r is
+// discarded as empty when its WAV fails the integrity check. That holds
+// for the regular ends of a session; after an error, a lost device or a
+// recovery the file is kept for inspection.
+func (r FinalizationReason) DiscardsInvalidWAV() bool {
+	switch r {
+	case ReasonSilenceTimeout, ReasonShutdown, ReasonManualStop, ReasonRollover,
+		ReasonScheduleClosed, ReasonEventBoundary, ReasonTriggerStop, ReasonLowDisk:
+		return true
+	}
+	return false
+}
+
 // SessionDiagnostics holds per-session audio capture statistics.
 type SessionDiagnostics struct {
 	FramesReceived      int64     `json:"frames_received"`
@@ -38,6 +72,123 @@ type SessionDiagnostics struct {
 	HasMeaningfulAudio  bool      `json:"has_meaningful_audio"`
 	FirstAudioTimestamp time.Time `json:"first_audio_timestamp,omitempty"`
 	LastAudioTimestamp  time.Time `json:"last_audio_timestamp,omitempty"`
+
+	// Per-channel levels of the captured audio (before any mono downmix).
+	ChannelRMS        []float64 `json:"channel_rms,omitempty"`
+	ChannelPeak       []float64 `json:"channel_peak,omitempty"`
+	ChannelClips      []int64   `json:"channel_clips,omitempty"`
+	DeadChannels      []int     `json:"dead_channels,omitempty"` // 0-based
+	IdenticalChannels bool      `json:"identical_channels,omitempty"`
+
+	// Signal integrity: clipping seen by the WAV writer and input overflows
+	// (audio dropped by the capture backend).
+	ClippedSamples  int64       `json:"clipped_samples"`
+	ClipRuns        int64       `json:"clip_runs"`
+	InputOverflows  int64       `json:"input_overflows"`
+	OverflowEvents  []time.Time `json:"overflow_events,omitempty"` // first MaxOverflowEvents only
+	Degraded        bool        `json:"degraded,omitempty"`
+	DegradedReasons []string    `json:"degraded_reasons,omitempty"`
+
+	// Wall-clock alignment: stretches with no captured audio that were
+	// filled with silence, and whether audio was resampled to the file rate.
+	Gaps       []GapEvent `json:"gaps,omitempty"` // first MaxGapEvents only
+	GapSeconds float64    `json:"gap_seconds,omitempty"`
+	Resampled  bool       `json:"resampled,omitempty"`
+
+	// Segments lists the sessions merged into this recording
+	// (session.merge_gap_seconds); empty when nothing was merged.
+	Segments []SessionSegment `json:"segments,omitempty"`
+
+	// Timeline is the session's events in order (first MaxTimelineEvents
+	// only); AppsSeen every meeting app observed while it was recorded.
+	Timeline []TimelineEvent `json:"timeline,omitempty"`
+	AppsSeen []string        `json:"apps_seen,omitempty"`
+
+	// Markers added with `memofy mark`, in order.
+	Markers []Marker `json:"markers,omitempty"`
+}
+
+// Marker flags a moment in a recording. SampleOffset counts frames
+// recorded before it, like TimelineEvent; FileSeconds is its position in
+// the final file, after any silence compaction.
+type Marker struct {
+	At           time.Time `json:"at"`
+	SampleOffset int64     `json:"sample_offset"`
+	FileSeconds  float64   `json:"file_seconds"`
+	Label        string    `json:"label,omitempty"`
+}
+
+// TimelineKind classifies a TimelineEvent.
+type TimelineKind string
+
+const (
+	TimelineState     TimelineKind = "state"     // state machine transition, e.g. "recording -> silence_wait"
+	TimelineMicLock   TimelineKind = "mic_lock"  // "on" or "off"
+	TimelineDevice    TimelineKind = "device"    // capture device switched to Detail
+	TimelineApp       TimelineKind = "app"       // e.g. "teams started", "zoom call ended"
+	TimelineThreshold TimelineKind = "threshold" // enter/exit thresholds changed
+)
+
+// TimelineEvent is something that happened during a session. SampleOffset
+// is the number of frames recorded before it, so it can be located in the
+// file (before any silence compaction).
+type TimelineEvent struct {
+	At           time.Time    `json:"at"`
+	SampleOffset int64        `json:"sample_offset"`
+	Kind         TimelineKind `json:"kind"`
+	Detail       string       `json:"detail"`
+}
+
+// MaxTimelineEvents caps the timeline events kept per session.
+const MaxTimelineEvents = 500
+
+// RecordEvent appends an event to the session timeline.
+func (d *SessionDiagnostics) RecordEvent(ev TimelineEvent) {
+	if len(d.Timeline) < MaxTimelineEvents {
+		d.Timeline = append(d.Timeline, ev)
+	}
+}
+
+// SeeApp adds app to AppsSeen unless it is already listed.
+func (d *SessionDiagnostics) SeeApp(app string) {
+	for _, a := range d.AppsSeen {
+		if a == app {
+			return
+		}
+	}
+	d.AppsSeen = append(d.AppsSeen, app)
+}
+
+// SessionSegment is one of several sessions appended into a single
+// recording: its wall-clock span and where its audio sits in the file.
+// FileSeconds counts audio before silence compaction, like
+// EditSegment.SourceSeconds.
+type SessionSegment struct {
+	Start           time.Time `json:"start"`
+	End             time.Time `json:"end"`
+	FileSeconds     float64   `json:"file_seconds"`
+	DurationSeconds float64   `json:"duration_seconds"`
+}
+
+// GapEvent is a stretch of wall-clock time with no captured audio, filled
+// with silence to keep the recording aligned with real time.
+type GapEvent struct {
+	At              time.Time `json:"at"`
+	OffsetSeconds   float64   `json:"offset_seconds"`   // position in the file where silence was inserted
+	DurationSeconds float64   `json:"duration_seconds"` // length of the missing audio
+	FilledSeconds   float64   `json:"filled_seconds"`   // silence inserted; less than DurationSeconds when capped
+	Cause           string    `json:"cause"`            // read_error, device_switch or stream_restart
+}
+
+// MaxGapEvents caps the gap events kept per session.
+const MaxGapEvents = 100
+
+// RecordGap adds a filled gap to the diagnostics.
+func (d *SessionDiagnostics) RecordGap(g GapEvent) {
+	d.GapSeconds += g.FilledSeconds
+	if len(d.Gaps) < MaxGapEvents {
+		d.Gaps = append(d.Gaps, g)
+	}
 }
 
 // RecordRMS updates the diagnostics with an RMS reading from a buffer.
@@ -49,6 +200,39 @@ func (d *SessionDiagnostics) RecordRMS(rms float64) {
 	d.RMSCount++
 }
 
+// MaxOverflowEvents caps the overflow timestamps kept per session.
+const MaxOverflowEvents = 100
+
+// RecordOverflow counts n input overflows observed at t.
+func (d *SessionDiagnostics) RecordOverflow(t time.Time, n int64) {
+	d.InputOverflows += n
+	if len(d.OverflowEvents) < MaxOverflowEvents {
+		d.OverflowEvents = append(d.OverflowEvents, t)
+	}
+}
+
+// CheckDegraded flags the session as degraded when clipping or input
+// overflows exceed the tolerances. samples is the number of samples written;
+// maxClipRatio is the tolerated fraction of clipped samples and
+// maxOverflows the tolerated number of overflows.
+func (d *SessionDiagnostics) CheckDegraded(samples int64, maxClipRatio float64, maxOverflows int) {
+	d.Degraded = false
+	d.DegradedReasons = nil
+	if samples > 0 && d.ClippedSamples > 0 {
+		ratio := float64(d.ClippedSamples) / float64(samples)
+		if ratio > maxClipRatio {
+			d.DegradedReasons = append(d.DegradedReasons, fmt.Sprintf(
+				"clipping: %d samples in %d runs (%.3f%%, max %.3f%%)",
+				d.ClippedSamples, d.ClipRuns, ratio*100, maxClipRatio*100))
+		}
+	}
+	if d.InputOverflows > int64(maxOverflows) {
+		d.DegradedReasons = append(d.DegradedReasons, fmt.Sprintf(
+			"input overflows: %d (max %d)", d.InputOverflows, maxOverflows))
+	}
+	d.Degraded = len(d.DegradedReasons) > 0
+}
+
 // Finalize computes derived fields. Call before writing metadata.
 func (d *SessionDiagnostics) Finalize(minRMS float64) {
 	if d.RMSCount > 0 {
@@ -79,10 +263,30 @@ type Recording struct {
 	FinalizationReason  FinalizationReason `json:"finalization_reason"`
 	MicActive           bool               `json:"mic_active,omitempty"`
 	MicBundleIDs        []string           `json:"mic_bundle_ids,omitempty"`
-	ZoomRunning         bool               `json:"zoom_running,omitempty"`
-	TeamsRunning        bool               `json:"teams_running,omitempty"`
-	MeetRunning         bool               `json:"meet_running,omitempty"`
-	AppVersion          string             `json:"version"`
+	// Apps holds the meeting apps running at the end of the session, by
+	// monitoring.apps name. ZoomRunning, TeamsRunning and MeetRunning repeat
+	// the default apps for older readers.
+	Apps         map[string]monitor.AppState `json:"apps,omitempty"`
+	ZoomRunning  bool                        `json:"zoom_running,omitempty"`
+	TeamsRunning bool                        `json:"teams_running,omitempty"`
+	MeetRunning  bool                        `json:"meet_running,omitempty"`
+	AppVersion   string                      `json:"version"`
+
+	// Rollover series: set on every part of a session that was split by
+	// session.max_duration or session.max_size_mb.
+	SeriesID  string `json:"series_id,omitempty"`
+	PartIndex int    `json:"part_index,omitempty"` // 1-based
+
+	// Event is the calendar event the recording overlapped most, if any.
+	Event *CalendarEvent `json:"event,omitempty"`
+
+	// AppProfile is the app profile that applied to the session, if any.
+	AppProfile *AppProfile `json:"app_profile,omitempty"`
+
+	// Starred and Tags are set by the user (`memofy star`, `memofy tag`).
+	// Retention never deletes a starred or tagged recording.
+	Starred bool     `json:"starred,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
 
 	// Session diagnostics
 	FramesReceived     int64   `json:"frames_received"`
@@ -91,6 +295,136 @@ type Recording struct {
 	RMSPeak            float64 `json:"rms_peak"`
 	RMSAverage         float64 `json:"rms_average"`
 	HasMeaningfulAudio bool    `json:"has_meaningful_audio"`
+
+	// Per-channel diagnostics (see SessionDiagnostics).
+	ChannelRMS        []float64 `json:"channel_rms,omitempty"`
+	ChannelPeak       []float64 `json:"channel_peak,omitempty"`
+	ChannelClips      []int64   `json:"channel_clips,omitempty"`
+	DeadChannels      []int     `json:"dead_channels,omitempty"`
+	IdenticalChannels bool      `json:"identical_channels,omitempty"`
+	// Signal integrity (see SessionDiagnostics).
+	ClippedSamples  int64       `json:"clipped_samples"`
+	ClipRuns        int64       `json:"clip_runs"`
+	InputOverflows  int64       `json:"input_overflows"`
+	OverflowEvents  []time.Time `json:"overflow_events,omitempty"`
+	Degraded        bool        `json:"degraded,omitempty"`
+	DegradedReasons []string    `json:"degraded_reasons,omitempty"`
+	Gaps            []GapEvent  `json:"gaps,omitempty"`
+	GapSeconds      float64     `json:"gap_seconds,omitempty"`
+	Resampled       bool        `json:"resampled,omitempty"`
+
+	// Silence compaction (session.compact_silence_seconds): the stretches
+	// of the original recording kept in the file, in order. Use WallClock
+	// to map a file position back to real time.
+	EditList         []EditSegment `json:"edit_list,omitempty"`
+	CompactedSeconds float64       `json:"compacted_seconds,omitempty"` // silence removed
+
+	// Segments lists the sessions merged into this recording, in order
+	// (session.merge_gap_seconds).
+	Segments []SessionSegment `json:"segments,omitempty"`
+
+	// Timeline lists state transitions, mic lock, device switches, meeting
+	// app starts and stops and threshold changes during the recording.
+	// The app fields above describe the moment the recording ended;
+	// AppsSeen lists every meeting app observed while it ran.
+	Timeline []TimelineEvent `json:"timeline,omitempty"`
+	AppsSeen []string        `json:"apps_seen,omitempty"`
+
+	// Markers flag moments during the recording (`memofy mark`). They are
+	// also stored as WAV cue points or M4A chapters.
+	Markers []Marker `json:"markers,omitempty"`
+
+	// RecordedChannel is the source channel kept when audio.auto_mono wrote
+	// a mono file from a dead or fake-stereo capture; nil otherwise.
+	RecordedChannel *int `json:"recorded_channel,omitempty"`
+}
+
+// AppProfile records which app_profiles entry a session used.
+type AppProfile struct {
+	Name string `json:"name"`
+	// MatchedApp is the detected app or bundle ID that selected the
+	// profile; empty for the fallback profile.
+	MatchedApp string `json:"matched_app,omitempty"`
+	Subdir     string `json:"subdir,omitempty"`
+	Tag        string `json:"tag,omitempty"`
+}
+
+// CalendarEvent describes a calendar event a recording belongs to.
+type CalendarEvent struct {
+	UID       string    `json:"uid,omitempty"`
+	Title     string    `json:"title"`
+	Organizer string    `json:"organizer,omitempty"`
+	Attendees int       `json:"attendees"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+}
+
+// EditSegment is one stretch of a compacted recording: DurationSeconds of
+// audio that started SourceSeconds into the original recording and sits at
+// FileSeconds in the file.
+type EditSegment struct {
+	SourceSeconds   float64 `json:"source_seconds"`
+	FileSeconds     float64 `json:"file_seconds"`
+	DurationSeconds float64 `json:"duration_seconds"`
+}
+
+// WallClock returns the wall-clock time of a position in the recording
+// file, following the edit list when silence was compacted and the
+// segment list when sessions were merged.
+func (r Recording) WallClock(fileSeconds float64) time.Time {
+	offset := fileSeconds
+	for i, seg := range r.EditList {
+		if fileSeconds < seg.FileSeconds+seg.DurationSeconds || i == len(r.EditList)-1 {
+			offset = seg.SourceSeconds + (fileSeconds - seg.FileSeconds)
+			break
+		}
+	}
+	start := r.StartedAt
+	for i, seg := range r.Segments {
+		if offset < seg.FileSeconds+seg.DurationSeconds || i == len(r.Segments)-1 {
+			start, offset = seg.Start, offset-seg.FileSeconds
+			break
+		}
+	}
+	return start.Add(time.Duration(offset * float64(time.Second)))
+}
+
+// FileSeconds maps a position in the original recording to the file,
+// following an edit list from silence compaction. A position inside a
+// removed stretch maps to where the cut was made.
+func FileSeconds(edits []EditSegment, sourceSeconds float64) float64 {
+	if len(edits) == 0 {
+		return sourceSeconds
+	}
+	for _, seg := range edits {
+		if sourceSeconds < seg.SourceSeconds {
+			return seg.FileSeconds
+		}
+		if sourceSeconds < seg.SourceSeconds+seg.DurationSeconds {
+			return seg.FileSeconds + (sourceSeconds - seg.SourceSeconds)
+		}
+	}
+	last := edits[len(edits)-1]
+	return last.FileSeconds + last.DurationSeconds
+}
+
+// SidecarPath returns the path of the JSON sidecar of a recording:
+// "/path/to/recording.json" for "/path/to/recording.wav".
+func SidecarPath(recordingPath string) string {
+	return recpath.Sidecar(recordingPath)
+}
+
+// Read reads a JSON sidecar.
+func Read(jsonPath string) (Recording, error) {
+	var meta Recording
+	data, err := os.ReadFile(jsonPath)
+	if err != nil {
+		return meta, fmt.Errorf("read metadata: %w", err)
+	}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return meta, fmt.Errorf("parse metadata %s: %w", filepath.Base(jsonPath), err)
+	}
+	return meta, nil
 }
 
 // Write creates a JSON sidecar file next to the recording.
@@ -114,7 +448,7 @@ func Write(wavPath string, meta Recording) error {
 		return fmt.Errorf("marshal metadata: %w", err)
 	}
 
-	jsonPath := strings.TrimSuffix(wavPath, filepath.Ext(wavPath)) + ".json"
+	jsonPath := SidecarPath(wavPath)
 
 	// Atomic write via temp file
 	tmp := jsonPath + ".tmp"
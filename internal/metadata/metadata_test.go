@@ -3,6 +3,7 @@ package metadata
 import (
 	"encoding/json"
 	"os"
+	"strings"
 	"testing"
 	"time"
 )
@@ -307,6 +308,12 @@ func TestFinalizationReasonConstants(t *testing.T) {
 		ReasonError,
 		ReasonDiscardedShort,
 		ReasonDiscardedEmpty,
+		ReasonRecovered,
+		ReasonRollover,
+		ReasonScheduleClosed,
+		ReasonEventBoundary,
+		ReasonTriggerStop,
+		ReasonLowDisk,
 	}
 	seen := make(map[FinalizationReason]bool)
 	for _, r := range reasons {
@@ -320,6 +327,19 @@ func TestFinalizationReasonConstants(t *testing.T) {
 	}
 }
 
+func TestDiscardsInvalidWAV(t *testing.T) {
+	for _, r := range []FinalizationReason{ReasonSilenceTimeout, ReasonRollover, ReasonTriggerStop, ReasonLowDisk} {
+		if !r.DiscardsInvalidWAV() {
+			t.Errorf("%s: invalid WAV kept, want discarded", r)
+		}
+	}
+	for _, r := range []FinalizationReason{ReasonError, ReasonDeviceLost, ReasonRecovered, ReasonDiscardedShort} {
+		if r.DiscardsInvalidWAV() {
+			t.Errorf("%s: invalid WAV discarded, want kept", r)
+		}
+	}
+}
+
 func TestWriteDiagnosticsFields(t *testing.T) {
 	dir := t.TempDir()
 	wavPath := dir + "/diag_test.wav"
@@ -367,3 +387,181 @@ func TestWriteDiagnosticsFields(t *testing.T) {
 		t.Error("has_meaningful_audio: got false, want true")
 	}
 }
+
+func TestWriteSeriesFields(t *testing.T) {
+	dir := t.TempDir()
+
+	single := dir + "/single.wav"
+	if err := Write(single, Recording{StartedAt: time.Now()}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	data, _ := os.ReadFile(dir + "/single.json")
+	if strings.Contains(string(data), "series_id") || strings.Contains(string(data), "part_index") {
+		t.Errorf("unsplit session should omit series fields:\n%s", data)
+	}
+
+	part := dir + "/part.wav"
+	meta := Recording{
+		StartedAt:          time.Now(),
+		FinalizationReason: ReasonRollover,
+		SeriesID:           "20260101T100000",
+		PartIndex:          2,
+	}
+	if err := Write(part, meta); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	data, _ = os.ReadFile(dir + "/part.json")
+	var got Recording
+	json.Unmarshal(data, &got)
+	if got.SeriesID != "20260101T100000" || got.PartIndex != 2 || got.FinalizationReason != ReasonRollover {
+		t.Errorf("series fields = %q/%d/%s", got.SeriesID, got.PartIndex, got.FinalizationReason)
+	}
+}
+
+func TestSessionDiagnostics_RecordOverflow(t *testing.T) {
+	var d SessionDiagnostics
+	t0 := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < MaxOverflowEvents+5; i++ {
+		d.RecordOverflow(t0.Add(time.Duration(i)*time.Second), 2)
+	}
+	if d.InputOverflows != int64(2*(MaxOverflowEvents+5)) {
+		t.Errorf("InputOverflows = %d, want %d", d.InputOverflows, 2*(MaxOverflowEvents+5))
+	}
+	if len(d.OverflowEvents) != MaxOverflowEvents {
+		t.Errorf("kept %d events, want cap %d", len(d.OverflowEvents), MaxOverflowEvents)
+	}
+	if !d.OverflowEvents[0].Equal(t0) {
+		t.Errorf("first event = %v, want %v", d.OverflowEvents[0], t0)
+	}
+}
+
+func TestSessionDiagnostics_CheckDegraded(t *testing.T) {
+	tests := []struct {
+		name      string
+		clipped   int64
+		overflows int64
+		want      bool
+		reasons   int
+	}{
+		{"clean", 0, 0, false, 0},
+		{"clipping within tolerance", 10, 0, false, 0}, // 0.01%
+		{"clipping over tolerance", 200, 0, true, 1},   // 0.2%
+		{"overflow", 0, 1, true, 1},
+		{"both", 500, 3, true, 2},
+	}
+	for _, tc := range tests {
+		d := SessionDiagnostics{ClippedSamples: tc.clipped, ClipRuns: 1, InputOverflows: tc.overflows}
+		d.CheckDegraded(100000, 0.001, 0)
+		if d.Degraded != tc.want || len(d.DegradedReasons) != tc.reasons {
+			t.Errorf("%s: degraded=%v reasons=%v, want %v with %d reasons",
+				tc.name, d.Degraded, d.DegradedReasons, tc.want, tc.reasons)
+		}
+	}
+}
+
+func TestSessionDiagnostics_RecordGap(t *testing.T) {
+	var d SessionDiagnostics
+	for i := 0; i < MaxGapEvents+3; i++ {
+		d.RecordGap(GapEvent{DurationSeconds: 2, FilledSeconds: 1.5, Cause: "read_error"})
+	}
+	if len(d.Gaps) != MaxGapEvents {
+		t.Errorf("kept %d gaps, want cap %d", len(d.Gaps), MaxGapEvents)
+	}
+	if want := 1.5 * float64(MaxGapEvents+3); d.GapSeconds != want {
+		t.Errorf("GapSeconds = %v, want %v", d.GapSeconds, want)
+	}
+}
+
+func TestSessionDiagnostics_Timeline(t *testing.T) {
+	var d SessionDiagnostics
+	for i := 0; i < MaxTimelineEvents+3; i++ {
+		d.RecordEvent(TimelineEvent{SampleOffset: int64(i), Kind: TimelineState, Detail: "recording -> silence_wait"})
+	}
+	if len(d.Timeline) != MaxTimelineEvents {
+		t.Errorf("kept %d events, want cap %d", len(d.Timeline), MaxTimelineEvents)
+	}
+	for _, app := range []string{"teams", "zoom", "teams", "com.microsoft.teams2"} {
+		d.SeeApp(app)
+	}
+	if got := strings.Join(d.AppsSeen, ","); got != "teams,zoom,com.microsoft.teams2" {
+		t.Errorf("AppsSeen = %s", got)
+	}
+}
+
+func TestRecordingWallClock(t *testing.T) {
+	t0 := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	r := Recording{StartedAt: t0}
+	if got := r.WallClock(90); !got.Equal(t0.Add(90 * time.Second)) {
+		t.Errorf("uncompacted: WallClock(90) = %v", got)
+	}
+
+	// 0-30 s kept, 30-330 s silence cut down to 2 s, 330-400 s kept.
+	r.EditList = []EditSegment{
+		{SourceSeconds: 0, FileSeconds: 0, DurationSeconds: 31},
+		{SourceSeconds: 329, FileSeconds: 31, DurationSeconds: 71},
+	}
+	tests := []struct {
+		file, source float64
+	}{
+		{10, 10},
+		{31, 329},
+		{40, 338},
+		{102, 400},
+	}
+	for _, tc := range tests {
+		want := t0.Add(time.Duration(tc.source * float64(time.Second)))
+		if got := r.WallClock(tc.file); !got.Equal(want) {
+			t.Errorf("WallClock(%v) = %v, want %v", tc.file, got, want)
+		}
+	}
+}
+
+func TestFileSeconds(t *testing.T) {
+	// 0-31 s kept, 31-329 s cut, 329-400 s kept at 31 s in the file.
+	edits := []EditSegment{
+		{SourceSeconds: 0, FileSeconds: 0, DurationSeconds: 31},
+		{SourceSeconds: 329, FileSeconds: 31, DurationSeconds: 71},
+	}
+	tests := []struct{ source, file float64 }{
+		{10, 10},
+		{100, 31}, // inside the cut
+		{338, 40},
+		{500, 102}, // past the end
+	}
+	for _, tc := range tests {
+		if got := FileSeconds(edits, tc.source); got != tc.file {
+			t.Errorf("FileSeconds(%v) = %v, want %v", tc.source, got, tc.file)
+		}
+	}
+	if got := FileSeconds(nil, 42); got != 42 {
+		t.Errorf("FileSeconds without edits = %v, want 42", got)
+	}
+}
+
+func TestRecordingWallClock_MergedSegments(t *testing.T) {
+	t0 := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	t1 := t0.Add(5 * time.Minute)
+	// 60 s recorded, a 4-minute break, then 30 s more in the same file.
+	r := Recording{
+		StartedAt: t0,
+		Segments: []SessionSegment{
+			{Start: t0, End: t0.Add(60 * time.Second), FileSeconds: 0, DurationSeconds: 60},
+			{Start: t1, End: t1.Add(30 * time.Second), FileSeconds: 60, DurationSeconds: 30},
+		},
+	}
+	if got := r.WallClock(30); !got.Equal(t0.Add(30 * time.Second)) {
+		t.Errorf("WallClock(30) = %v", got)
+	}
+	if got := r.WallClock(70); !got.Equal(t1.Add(10 * time.Second)) {
+		t.Errorf("WallClock(70) = %v, want %v", got, t1.Add(10*time.Second))
+	}
+
+	// Compaction removed 20 s of the first segment: file 40 s is source 60 s.
+	r.EditList = []EditSegment{
+		{SourceSeconds: 0, FileSeconds: 0, DurationSeconds: 20},
+		{SourceSeconds: 40, FileSeconds: 20, DurationSeconds: 50},
+	}
+	if got := r.WallClock(45); !got.Equal(t1.Add(5 * time.Second)) {
+		t.Errorf("compacted WallClock(45) = %v, want %v", got, t1.Add(5*time.Second))
+	}
+}
@@ -1,5 +1,6 @@
 // Package micdetect detects which applications are currently using
-// microphone input on macOS 14+ via Core Audio process objects.
+// microphone input: on macOS 14+ via Core Audio process objects, on Linux
+// via the capture streams (source-outputs) of PulseAudio or PipeWire.
 // On unsupported platforms or macOS versions, all functions return
 // explicit errors.
 package micdetect
@@ -8,18 +9,38 @@ import "errors"
 
 // ActiveProcess represents a process with audio activity.
 type ActiveProcess struct {
-	PID           int
-	BundleID      string
+	PID      int
+	BundleID string // macOS bundle ID; on Linux the application.id, if any
+	// Name and Binary are the application name and executable reported by
+	// PulseAudio/PipeWire (Linux only), e.g. "ZOOM VoiceEngine" and "zoom".
+	Name          string
+	Binary        string
 	RunningInput  bool
 	RunningOutput bool
 }
 
+// ID returns the identifier monitors match against: the bundle ID, or on
+// Linux without one, the binary or application name.
+func (p ActiveProcess) ID() string {
+	switch {
+	case p.BundleID != "":
+		return p.BundleID
+	case p.Binary != "":
+		return p.Binary
+	default:
+		return p.Name
+	}
+}
+
 var (
-	// ErrUnsupportedPlatform is returned on non-macOS systems.
-	ErrUnsupportedPlatform = errors.New("mic detection is only supported on macOS")
+	// ErrUnsupportedPlatform is returned on systems other than macOS and Linux.
+	ErrUnsupportedPlatform = errors.New("mic detection is only supported on macOS and Linux")
+	// ErrNoSoundServer is returned on Linux when pactl is not available.
+	ErrNoSoundServer = errors.New("mic detection requires pactl (PulseAudio or PipeWire)")
 	// ErrUnsupportedVersion is returned when macOS version is below 14.
 	ErrUnsupportedVersion = errors.New("mic detection requires macOS 14+")
-	// ErrEnumerationFailed is returned when Core Audio process enumeration fails.
+	// ErrEnumerationFailed is returned when Core Audio process or pactl
+	// stream enumeration fails.
 	ErrEnumerationFailed = errors.New("failed to enumerate audio processes")
 	// ErrPropertyReadFailed is returned when a Core Audio property read fails.
 	ErrPropertyReadFailed = errors.New("failed to read audio process property")
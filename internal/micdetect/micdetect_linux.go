@@ -0,0 +1,77 @@
+//go:build linux
+
+package micdetect
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// runPactl executes pactl and returns its stdout.
+func runPactl(args ...string) ([]byte, error) {
+	out, err := exec.Command("pactl", args...).Output()
+	if err != nil {
+		if ee, ok := err.(*exec.ExitError); ok && len(ee.Stderr) > 0 {
+			return nil, fmt.Errorf("pactl %s: %s", strings.Join(args, " "), strings.TrimSpace(string(ee.Stderr)))
+		}
+		return nil, fmt.Errorf("pactl %s: %w", strings.Join(args, " "), err)
+	}
+	return out, nil
+}
+
+// IsSupported returns true if pactl is installed. Whether a sound server is
+// actually running shows up as an error from ActiveMicUsers.
+func IsSupported() bool {
+	_, err := exec.LookPath("pactl")
+	return err == nil
+}
+
+// MacOSVersionString returns an empty string on Linux.
+func MacOSVersionString() string {
+	return ""
+}
+
+// ActiveMicUsers returns processes currently recording from an input
+// source (not a sink monitor), excluding memofy itself.
+// An empty slice with nil error means no processes are using the microphone.
+func ActiveMicUsers() ([]ActiveProcess, error) {
+	if !IsSupported() {
+		return nil, ErrNoSoundServer
+	}
+	return activeMicUsers(runPactl, os.Getpid())
+}
+
+func activeMicUsers(pactl func(args ...string) ([]byte, error), self int) ([]ActiveProcess, error) {
+	outputs, err := pactl("-f", "json", "list", "source-outputs")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrEnumerationFailed, err)
+	}
+	sources, err := pactl("-f", "json", "list", "sources")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrEnumerationFailed, err)
+	}
+	all, err := parseSourceOutputs(outputs, sources, self)
+	if err != nil {
+		return nil, err
+	}
+	return filterActiveInput(all), nil
+}
+
+// ActiveMicUserBundleIDs returns identifiers of processes using microphone
+// input: the application.id when set, otherwise the binary or application
+// name.
+func ActiveMicUserBundleIDs() ([]string, error) {
+	procs, err := ActiveMicUsers()
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(procs))
+	for _, p := range procs {
+		if id := p.ID(); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
@@ -0,0 +1,38 @@
+//go:build linux
+
+package micdetect
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestActiveMicUsers_Linux(t *testing.T) {
+	var calls []string
+	fake := func(args ...string) ([]byte, error) {
+		calls = append(calls, args[len(args)-1])
+		switch args[len(args)-1] {
+		case "source-outputs":
+			return []byte(pactlSourceOutputsJSON), nil
+		case "sources":
+			return []byte(pactlSourcesJSON), nil
+		}
+		return nil, errors.New("unexpected pactl call")
+	}
+	procs, err := activeMicUsers(fake, 7322)
+	if err != nil {
+		t.Fatalf("activeMicUsers: %v", err)
+	}
+	// Zoom only: Firefox is corked, memofy records a monitor and 7322 is self.
+	if len(procs) != 1 || procs[0].Binary != "zoom" {
+		t.Errorf("activeMicUsers = %+v, want zoom only", procs)
+	}
+	if len(calls) != 2 {
+		t.Errorf("pactl calls = %v", calls)
+	}
+
+	failing := func(args ...string) ([]byte, error) { return nil, errors.New("Connection failure") }
+	if _, err := activeMicUsers(failing, 0); !errors.Is(err, ErrEnumerationFailed) {
+		t.Errorf("error = %v, want ErrEnumerationFailed", err)
+	}
+}
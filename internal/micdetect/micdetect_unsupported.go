@@ -1,23 +1,23 @@
-//go:build !darwin
+//go:build !darwin && !linux
 
 package micdetect
 
-// IsSupported returns false on non-macOS platforms.
+// IsSupported returns false on unsupported platforms.
 func IsSupported() bool {
 	return false
 }
 
-// MacOSVersionString returns an empty string on non-macOS platforms.
+// MacOSVersionString returns an empty string on unsupported platforms.
 func MacOSVersionString() string {
 	return ""
 }
 
-// ActiveMicUsers returns ErrUnsupportedPlatform on non-macOS platforms.
+// ActiveMicUsers returns ErrUnsupportedPlatform on unsupported platforms.
 func ActiveMicUsers() ([]ActiveProcess, error) {
 	return nil, ErrUnsupportedPlatform
 }
 
-// ActiveMicUserBundleIDs returns ErrUnsupportedPlatform on non-macOS platforms.
+// ActiveMicUserBundleIDs returns ErrUnsupportedPlatform on unsupported platforms.
 func ActiveMicUserBundleIDs() ([]string, error) {
 	return nil, ErrUnsupportedPlatform
 }
@@ -0,0 +1,64 @@
+package micdetect
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// pactlSourceOutput mirrors the subset of `pactl -f json list source-outputs`
+// we rely on. Numeric properties are reported as strings by pactl.
+type pactlSourceOutput struct {
+	Index      int               `json:"index"`
+	Source     int               `json:"source"`
+	Corked     bool              `json:"corked"`
+	Properties map[string]string `json:"properties"`
+}
+
+// pactlSource mirrors the subset of `pactl -f json list sources` we rely on.
+type pactlSource struct {
+	Index         int    `json:"index"`
+	Name          string `json:"name"`
+	MonitorOfSink string `json:"monitor_of_sink"`
+}
+
+// parseSourceOutputs turns pactl's capture streams into processes. Streams
+// recording a sink monitor (system audio, such as memofy's own capture) and
+// streams owned by PID self are not microphone use and are skipped. A corked
+// (paused) stream is reported with RunningInput false.
+func parseSourceOutputs(outputsJSON, sourcesJSON []byte, self int) ([]ActiveProcess, error) {
+	var outputs []pactlSourceOutput
+	if err := json.Unmarshal(outputsJSON, &outputs); err != nil {
+		return nil, fmt.Errorf("%w: parse source-outputs: %v", ErrEnumerationFailed, err)
+	}
+	var sources []pactlSource
+	if err := json.Unmarshal(sourcesJSON, &sources); err != nil {
+		return nil, fmt.Errorf("%w: parse sources: %v", ErrEnumerationFailed, err)
+	}
+	monitors := make(map[int]bool)
+	for _, s := range sources {
+		if strings.HasSuffix(s.Name, ".monitor") || (s.MonitorOfSink != "" && s.MonitorOfSink != "n/a") {
+			monitors[s.Index] = true
+		}
+	}
+
+	var procs []ActiveProcess
+	for _, o := range outputs {
+		if monitors[o.Source] {
+			continue
+		}
+		pid, _ := strconv.Atoi(o.Properties["application.process.id"])
+		if pid != 0 && pid == self {
+			continue
+		}
+		procs = append(procs, ActiveProcess{
+			PID:          pid,
+			BundleID:     o.Properties["application.id"],
+			Name:         o.Properties["application.name"],
+			Binary:       o.Properties["application.process.binary"],
+			RunningInput: !o.Corked,
+		})
+	}
+	return procs, nil
+}
@@ -0,0 +1,107 @@
+package micdetect
+
+import (
+	"errors"
+	"testing"
+)
+
+// pactlSourceOutputsJSON is trimmed output of `pactl -f json list
+// source-outputs` on PipeWire 1.0 during a Zoom call, with a paused Firefox
+// (Meet tab) stream, memofy recording the sink monitor and pavucontrol's
+// peak meter on the microphone.
+const pactlSourceOutputsJSON = `[
+  {"index":112,"driver":"PipeWire","owner_module":"4294967295","client":"88","source":58,
+   "sample_specification":"s16le 1ch 48000Hz","corked":false,"mute":false,
+   "properties":{"application.name":"ZOOM VoiceEngine","application.process.id":"41210",
+                 "application.process.binary":"zoom","media.name":"recStream"}},
+  {"index":118,"driver":"PipeWire","owner_module":"4294967295","client":"93","source":58,
+   "sample_specification":"float32le 1ch 48000Hz","corked":true,"mute":false,
+   "properties":{"application.name":"Firefox","application.process.id":"3381",
+                 "application.process.binary":"firefox","media.name":"AudioCallbackDriver"}},
+  {"index":121,"driver":"PipeWire","owner_module":"4294967295","client":"101","source":57,
+   "sample_specification":"s16le 2ch 44100Hz","corked":false,"mute":false,
+   "properties":{"application.name":"ALSA plug-in [memofy]","application.process.id":"7001",
+                 "application.process.binary":"memofy","media.name":"ALSA Capture"}},
+  {"index":125,"driver":"PipeWire","owner_module":"4294967295","client":"104","source":58,
+   "sample_specification":"float32le 1ch 25Hz","corked":false,"mute":false,
+   "properties":{"application.name":"PulseAudio Volume Control","application.process.id":"7322",
+                 "application.process.binary":"pavucontrol","application.id":"org.PulseAudio.pavucontrol",
+                 "media.name":"Peak detect"}}
+]`
+
+// pactlSourcesJSON is the matching trimmed `pactl -f json list sources`.
+const pactlSourcesJSON = `[
+  {"index":57,"state":"RUNNING","name":"alsa_output.pci-0000_00_1f.3.analog-stereo.monitor",
+   "description":"Monitor of Built-in Audio Analog Stereo","monitor_of_sink":"alsa_output.pci-0000_00_1f.3.analog-stereo"},
+  {"index":58,"state":"RUNNING","name":"alsa_input.pci-0000_00_1f.3.analog-stereo",
+   "description":"Built-in Audio Analog Stereo","monitor_of_sink":"n/a"}
+]`
+
+func TestParseSourceOutputs(t *testing.T) {
+	procs, err := parseSourceOutputs([]byte(pactlSourceOutputsJSON), []byte(pactlSourcesJSON), 0)
+	if err != nil {
+		t.Fatalf("parseSourceOutputs: %v", err)
+	}
+	// memofy's monitor capture is not microphone use.
+	if len(procs) != 3 {
+		t.Fatalf("got %d processes, want 3: %+v", len(procs), procs)
+	}
+	zoom := procs[0]
+	if zoom.PID != 41210 || zoom.Binary != "zoom" || zoom.Name != "ZOOM VoiceEngine" || !zoom.RunningInput {
+		t.Errorf("zoom = %+v", zoom)
+	}
+	if procs[1].Binary != "firefox" || procs[1].RunningInput {
+		t.Errorf("corked firefox stream = %+v, want RunningInput false", procs[1])
+	}
+	if procs[2].BundleID != "org.PulseAudio.pavucontrol" {
+		t.Errorf("pavucontrol bundle = %q", procs[2].BundleID)
+	}
+	if got := filterActiveInput(procs); len(got) != 2 {
+		t.Errorf("filterActiveInput kept %d, want 2", len(got))
+	}
+}
+
+func TestParseSourceOutputs_SkipsSelf(t *testing.T) {
+	procs, err := parseSourceOutputs([]byte(pactlSourceOutputsJSON), []byte(`[]`), 41210)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range procs {
+		if p.PID == 41210 {
+			t.Errorf("own stream reported: %+v", p)
+		}
+	}
+	if len(procs) != 3 {
+		t.Errorf("got %d processes, want 3 (monitor unknown without sources)", len(procs))
+	}
+}
+
+func TestParseSourceOutputs_Invalid(t *testing.T) {
+	if _, err := parseSourceOutputs([]byte("Connection failure"), []byte(`[]`), 0); !errors.Is(err, ErrEnumerationFailed) {
+		t.Errorf("error = %v, want ErrEnumerationFailed", err)
+	}
+	if _, err := parseSourceOutputs([]byte(`[]`), []byte("nope"), 0); !errors.Is(err, ErrEnumerationFailed) {
+		t.Errorf("error = %v, want ErrEnumerationFailed", err)
+	}
+	procs, err := parseSourceOutputs([]byte(`[]`), []byte(`[]`), 0)
+	if err != nil || len(procs) != 0 {
+		t.Errorf("no streams = %v, %v", procs, err)
+	}
+}
+
+func TestActiveProcessID(t *testing.T) {
+	tests := []struct {
+		p    ActiveProcess
+		want string
+	}{
+		{ActiveProcess{BundleID: "us.zoom.xos", Binary: "zoom"}, "us.zoom.xos"},
+		{ActiveProcess{Binary: "zoom", Name: "ZOOM VoiceEngine"}, "zoom"},
+		{ActiveProcess{Name: "ZOOM VoiceEngine"}, "ZOOM VoiceEngine"},
+		{ActiveProcess{}, ""},
+	}
+	for _, tc := range tests {
+		if got := tc.p.ID(); got != tc.want {
+			t.Errorf("ID(%+v) = %q, want %q", tc.p, got, tc.want)
+		}
+	}
+}
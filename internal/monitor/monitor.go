@@ -1,30 +1,79 @@
 // Package monitor provides best-effort process detection for meeting apps.
-// These signals are secondary — they only enrich recording metadata and
-// never trigger or stop recording.
+// Which apps it looks for is configured with AppRule; DefaultApps covers
+// Zoom, Teams and Google Meet.
 package monitor
 
 import (
+	"fmt"
 	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 
 	"github.com/tiroq/memofy/internal/micdetect"
 )
 
+// AppRule describes how to detect one meeting app. Patterns are regular
+// expressions matched case-insensitively anywhere in a process's name,
+// executable path or command line (see Process), or in a bundle ID.
+type AppRule struct {
+	Name string `yaml:"name"`
+	// Processes match the app's own processes; any match means running.
+	Processes []string `yaml:"processes,omitempty"`
+	// InCallProcess matches a child process that only exists during a call
+	// (Zoom's CptHost). Without it, the app is in a call while it uses the
+	// microphone.
+	InCallProcess string `yaml:"in_call_process,omitempty"`
+	// BundleIDs are the app's bundle identifiers, compared with the
+	// processes using the microphone.
+	BundleIDs []string `yaml:"bundle_ids,omitempty"`
+	// BrowserHints match browsers that host the app in a tab. A matching
+	// browser counts as the app only while it uses the microphone.
+	BrowserHints []string `yaml:"browser_hints,omitempty"`
+}
+
+// DefaultApps returns the rules for Zoom, Microsoft Teams and Google Meet.
+func DefaultApps() []AppRule {
+	return []AppRule{
+		{
+			Name:          "zoom",
+			Processes:     []string{`zoom\.us`, `(^|/)zoom$`},
+			InCallProcess: `CptHost`, // spawned only during Zoom meetings
+			BundleIDs:     []string{"us.zoom.xos", "us.zoom.videomeeting"},
+		},
+		{
+			Name:      "teams",
+			Processes: []string{`Microsoft Teams`, `(^|/)MSTeams$`, `(^|/)teams$`, `(^|/)teams-for-linux$`},
+			BundleIDs: []string{"com.microsoft.teams2", "com.microsoft.teams"},
+		},
+		{
+			Name:         "meet",
+			Processes:    []string{`Google Meet`, `--app=https://meet\.google\.com`},
+			BrowserHints: []string{`chrome`, `chromium`, `safari`, `firefox`, `microsoft edge`, `edgemac`, `brave`},
+		},
+	}
+}
+
+// Validate reports whether the rule has a name and its patterns compile.
+func (r AppRule) Validate() error {
+	_, err := compileRule(r)
+	return err
+}
+
+// AppState is what the monitor saw of one app.
+type AppState struct {
+	Running bool `json:"running"`
+	InCall  bool `json:"in_call,omitempty"`
+}
+
 // Snapshot holds the current state of monitored processes.
 // All fields are best-effort.
 type Snapshot struct {
-	// ZoomRunning is true when the Zoom app process is running (open, not
-	// necessarily in a call).
-	ZoomRunning bool
-	// ZoomInCall is true when Zoom's call subprocess (CptHost) is active,
-	// which only exists during an active Zoom meeting.
-	ZoomInCall bool
-	// TeamsRunning is true when Microsoft Teams process is running (open,
-	// not necessarily in a call).
-	TeamsRunning bool
-	// MeetRunning is true when a Google Meet tab may be open in a browser.
-	MeetRunning bool
+	// Apps holds the running apps by rule name; apps that are not running
+	// are absent.
+	Apps map[string]AppState
 	// MicActive is a best-effort indicator that at least one known meeting
 	// app is actively accessing the microphone.
 	MicActive bool
@@ -33,27 +82,103 @@ type Snapshot struct {
 	MicBundleIDs []string
 }
 
+// Running reports whether the app named name is running.
+func (s Snapshot) Running(name string) bool {
+	return s.Apps[name].Running
+}
+
+// AppInCall reports whether the app named name appears to be in a call.
+func (s Snapshot) AppInCall(name string) bool {
+	return s.Apps[name].InCall
+}
+
 // InCall returns true if any meeting app appears to be in an active call.
 func (s Snapshot) InCall() bool {
-	return s.ZoomInCall || s.MicActive
+	if s.MicActive {
+		return true
+	}
+	for _, st := range s.Apps {
+		if st.InCall {
+			return true
+		}
+	}
+	return false
+}
+
+// RunningApps returns the names of the running apps in sorted order,
+// followed by the bundle IDs using the microphone, except system noise.
+func (s Snapshot) RunningApps() []string {
+	apps := s.AppNames()
+	for _, id := range s.MicBundleIDs {
+		if !isMicNoiseBundle(id) {
+			apps = append(apps, id)
+		}
+	}
+	return apps
+}
+
+// ActiveApps returns the names of the apps in a call in sorted order,
+// followed by the bundle IDs using the microphone, except system noise.
+func (s Snapshot) ActiveApps() []string {
+	apps := s.InCallApps()
+	for _, id := range s.MicBundleIDs {
+		if !isMicNoiseBundle(id) {
+			apps = append(apps, id)
+		}
+	}
+	return apps
 }
 
-// meetingBundles are bundle IDs of known meeting applications.
-var meetingBundles = []string{
-	"com.microsoft.teams2",
-	"com.microsoft.teams",
-	"us.zoom.xos",
-	"us.zoom.videomeeting",
+// AppNames returns the names of the running apps in sorted order, without
+// bundle IDs.
+func (s Snapshot) AppNames() []string {
+	var apps []string
+	for name, st := range s.Apps {
+		if st.Running {
+			apps = append(apps, name)
+		}
+	}
+	sort.Strings(apps)
+	return apps
+}
+
+// InCallApps returns the names of the apps in a call, in sorted order.
+func (s Snapshot) InCallApps() []string {
+	var apps []string
+	for name, st := range s.Apps {
+		if st.InCall {
+			apps = append(apps, name)
+		}
+	}
+	sort.Strings(apps)
+	return apps
+}
+
+// Equal reports whether s and o show the same apps and microphone state.
+func (s Snapshot) Equal(o Snapshot) bool {
+	if s.MicActive != o.MicActive || len(s.Apps) != len(o.Apps) {
+		return false
+	}
+	for name, st := range s.Apps {
+		if ost, ok := o.Apps[name]; !ok || ost != st {
+			return false
+		}
+	}
+	return true
 }
 
 // micNoiseBundles lists bundle ID prefixes for system/background processes that
 // continuously access the microphone and must not be treated as meeting-app
-// mic usage. MicBundleIDs still contains them for diagnostics; they are only
-// excluded from the MicActive determination.
+// mic usage. MicBundleIDs still contains them for diagnostics; they are
+// excluded from the MicActive determination and from RunningApps.
 var micNoiseBundles = []string{
 	"com.apple.CoreSpeech",            // Siri / on-device speech recognition
 	"com.apple.SpeechRecognitionCore", // system dictation engine
 	"com.apple.accessibility.heard",   // Accessibility "Heard" listening
+	"org.PulseAudio.pavucontrol",      // Linux: volume control peak meters
+	"pavucontrol",                     // same, reported by binary name
+	"com.github.wwmm.easyeffects",     // Linux: effects chain holding the mic open
+	"easyeffects",                     // same, reported by binary name
 }
 
 // isMicNoiseBundle returns true if the bundle ID belongs to a known system
@@ -68,15 +193,67 @@ func isMicNoiseBundle(id string) bool {
 	return false
 }
 
+// rule is an AppRule with its patterns compiled.
+type rule struct {
+	name     string
+	procs    []*regexp.Regexp
+	inCall   *regexp.Regexp
+	bundles  []string
+	browsers []*regexp.Regexp
+}
+
+func compileRule(r AppRule) (rule, error) {
+	if r.Name == "" {
+		return rule{}, fmt.Errorf("app rule needs a name")
+	}
+	if len(r.Processes) == 0 && r.InCallProcess == "" && len(r.BundleIDs) == 0 && len(r.BrowserHints) == 0 {
+		return rule{}, fmt.Errorf("app %q: needs processes, in_call_process, bundle_ids or browser_hints", r.Name)
+	}
+	out := rule{name: r.Name, bundles: r.BundleIDs}
+	var err error
+	if out.procs, err = compilePatterns(r.Processes); err != nil {
+		return rule{}, fmt.Errorf("app %q: processes: %w", r.Name, err)
+	}
+	if out.browsers, err = compilePatterns(r.BrowserHints); err != nil {
+		return rule{}, fmt.Errorf("app %q: browser_hints: %w", r.Name, err)
+	}
+	if r.InCallProcess != "" {
+		if out.inCall, err = regexp.Compile("(?i)" + r.InCallProcess); err != nil {
+			return rule{}, fmt.Errorf("app %q: in_call_process: %w", r.Name, err)
+		}
+	}
+	return out, nil
+}
+
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	res := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := regexp.Compile("(?i)" + p)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
 // Monitor checks for running meeting application processes.
 type Monitor struct {
 	mu       sync.RWMutex
+	rules    []rule
 	snapshot Snapshot
 }
 
-// New creates a new process monitor.
-func New() *Monitor {
-	return &Monitor{}
+// New creates a process monitor for the given rules. Rules that do not
+// validate are skipped; config validation reports them.
+func New(apps []AppRule) *Monitor {
+	m := &Monitor{}
+	for _, a := range apps {
+		if r, err := compileRule(a); err == nil {
+			m.rules = append(m.rules, r)
+		}
+	}
+	return m
 }
 
 // Poll updates the snapshot by checking running processes.
@@ -84,58 +261,100 @@ func (m *Monitor) Poll() Snapshot {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	procs, pids := listProcessesWithPIDs()
+	procs := listProcesses()
 
-	zoomOpen := containsAny(procs, "zoom.us", "zoom")
-	zoomInCall := containsAny(procs, "CptHost") // spawned only during Zoom meetings
-	teamsOpen := containsAny(procs, "Microsoft Teams", "teams")
-	meetOpen := containsAny(procs, "Google Meet", "meet")
-
-	var micActive bool
+	// Prefer mic detection through the audio system (Core Audio on macOS
+	// 14+, PulseAudio/PipeWire on Linux) over lsof.
 	var micBundleIDs []string
-
-	// Prefer Core Audio mic detection (macOS 14+) over lsof.
+	detected := false
 	if micdetect.IsSupported() {
 		if ids, err := micdetect.ActiveMicUserBundleIDs(); err == nil {
 			micBundleIDs = ids
-			// Filter out system/background processes that continuously access
-			// the microphone (e.g. CoreSpeech for Siri). Without this filter
-			// micActive would be permanently true, preventing real meeting-app
-			// transitions from firing.
-			for _, id := range ids {
-				if !isMicNoiseBundle(id) {
-					micActive = true
-					break
-				}
-			}
+			detected = true
 		}
-	} else {
-		// Fallback to lsof for older systems.
-		var micPIDs []string
-		if teamsOpen {
-			micPIDs = append(micPIDs, getPIDs(pids, "teams", "microsoft teams")...)
+	}
+
+	snap := Snapshot{MicBundleIDs: micBundleIDs}
+	for _, r := range m.rules {
+		var micUse bool
+		if detected {
+			micUse = r.usesMic(micBundleIDs)
+		} else {
+			// Fallback to lsof for older systems.
+			micUse = micInUseByPIDs(r.pids(procs))
 		}
-		if zoomInCall {
-			micPIDs = append(micPIDs, getPIDs(pids, "cpthost")...)
+		st := r.state(procs, micUse)
+		if st.Running {
+			if snap.Apps == nil {
+				snap.Apps = make(map[string]AppState)
+			}
+			snap.Apps[r.name] = st
 		}
-		if meetOpen {
-			micPIDs = append(micPIDs, getPIDs(pids, "chrome", "safari", "firefox")...)
+		if !detected && micUse {
+			snap.MicActive = true
 		}
-		micActive = micInUseByPIDs(micPIDs)
 	}
-
-	m.snapshot = Snapshot{
-		ZoomRunning:  zoomOpen || zoomInCall,
-		ZoomInCall:   zoomInCall,
-		TeamsRunning: teamsOpen,
-		MeetRunning:  meetOpen,
-		MicActive:    micActive,
-		MicBundleIDs: micBundleIDs,
+	if detected {
+		// Filter out system/background processes that continuously access
+		// the microphone (e.g. CoreSpeech for Siri). Without this filter
+		// micActive would be permanently true, preventing real meeting-app
+		// transitions from firing.
+		for _, id := range micBundleIDs {
+			if !isMicNoiseBundle(id) {
+				snap.MicActive = true
+				break
+			}
+		}
 	}
 
+	m.snapshot = snap
 	return m.snapshot
 }
 
+// state decides the app's state from the running processes and whether one
+// of its processes or bundle IDs uses the microphone.
+func (r rule) state(procs []Process, micUse bool) AppState {
+	inCallProc := r.inCall != nil && anyProcess(procs, r.inCall)
+	st := AppState{
+		Running: anyProcess(procs, r.procs...) || inCallProc || micUse,
+		InCall:  micUse,
+	}
+	if r.inCall != nil {
+		st.InCall = inCallProc
+	}
+	return st
+}
+
+// usesMic reports whether one of the bundle IDs using the microphone
+// belongs to the app or to one of its browsers. On Linux the IDs are often
+// binary names, so the process patterns are tried as well.
+func (r rule) usesMic(bundleIDs []string) bool {
+	for _, id := range bundleIDs {
+		if isMicNoiseBundle(id) {
+			continue
+		}
+		for _, b := range r.bundles {
+			if strings.EqualFold(id, b) {
+				return true
+			}
+		}
+		if anyMatch([]string{id}, r.procs...) || anyMatch([]string{id}, r.browsers...) {
+			return true
+		}
+	}
+	return false
+}
+
+// pids returns the PIDs of the app's processes and browsers, and of their
+// children (Electron and browser helpers often do the audio).
+func (r rule) pids(procs []Process) []string {
+	patterns := make([]*regexp.Regexp, 0, len(r.procs)+len(r.browsers)+1)
+	patterns = append(patterns, r.procs...)
+	patterns = append(patterns, r.browsers...)
+	patterns = append(patterns, r.inCall)
+	return matchingPIDs(procs, patterns...)
+}
+
 // Current returns the last polled snapshot.
 func (m *Monitor) Current() Snapshot {
 	m.mu.RLock()
@@ -143,41 +362,18 @@ func (m *Monitor) Current() Snapshot {
 	return m.snapshot
 }
 
-// listProcessesWithPIDs returns (name list, pid→name map) for all running processes.
-func listProcessesWithPIDs() ([]string, map[string]string) {
-	out, err := exec.Command("ps", "-eo", "pid=,comm=").Output()
-	if err != nil {
-		return nil, nil
-	}
-	lines := strings.Split(string(out), "\n")
-	names := make([]string, 0, len(lines))
-	pids := make(map[string]string, len(lines))
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
-		}
-		fields := strings.SplitN(line, " ", 2)
-		if len(fields) == 2 {
-			pid, name := strings.TrimSpace(fields[0]), strings.TrimSpace(fields[1])
-			names = append(names, name)
-			pids[pid] = name
+// matchingPIDs returns the PIDs of the processes matching any of the
+// patterns, and of all their descendants. Nil patterns are ignored.
+func matchingPIDs(procs []Process, patterns ...*regexp.Regexp) []string {
+	matched := make(map[int]bool)
+	for _, p := range procs {
+		if p.matches(patterns...) {
+			matched[p.PID] = true
 		}
 	}
-	return names, pids
-}
-
-// getPIDs returns PIDs whose process name contains any of the given hints.
-func getPIDs(pids map[string]string, hints ...string) []string {
 	var out []string
-	for pid, name := range pids {
-		lower := strings.ToLower(name)
-		for _, h := range hints {
-			if strings.Contains(lower, strings.ToLower(h)) {
-				out = append(out, pid)
-				break
-			}
-		}
+	for _, pid := range withDescendants(procs, matched) {
+		out = append(out, strconv.Itoa(pid))
 	}
 	return out
 }
@@ -192,22 +388,46 @@ func micInUseByPIDs(pids []string) bool {
 	if err != nil {
 		return false
 	}
-	lower := strings.ToLower(string(out))
+	return lsofShowsMic(string(out))
+}
+
+// linuxCaptureDevice matches an ALSA capture device node such as
+// /dev/snd/pcmC0D0c (playback nodes end in "p").
+var linuxCaptureDevice = regexp.MustCompile(`/dev/snd/pcmC\d+D\d+c\b`)
+
+// lsofShowsMic reports whether lsof output lists an audio input handle.
+func lsofShowsMic(out string) bool {
+	if linuxCaptureDevice.MatchString(out) {
+		return true
+	}
+	lower := strings.ToLower(out)
 	return strings.Contains(lower, "audio") ||
 		strings.Contains(lower, "coreaudio") ||
 		strings.Contains(lower, "microphone") ||
 		strings.Contains(lower, "audiotoolbox")
 }
 
-// containsAny returns true if any of the hints appear in the process list.
-func containsAny(procs []string, hints ...string) bool {
+// anyProcess returns true if any of the patterns matches a process's name,
+// executable path or command line. Nil patterns are ignored.
+func anyProcess(procs []Process, patterns ...*regexp.Regexp) bool {
+	for _, p := range procs {
+		if p.matches(patterns...) {
+			return true
+		}
+	}
+	return false
+}
+
+// anyMatch returns true if any of the patterns matches a string in the
+// list. Nil patterns are ignored.
+func anyMatch(procs []string, patterns ...*regexp.Regexp) bool {
 	for _, proc := range procs {
 		proc = strings.TrimSpace(proc)
 		if proc == "" {
 			continue
 		}
-		for _, hint := range hints {
-			if strings.Contains(strings.ToLower(proc), strings.ToLower(hint)) {
+		for _, re := range patterns {
+			if re != nil && re.MatchString(proc) {
 				return true
 			}
 		}
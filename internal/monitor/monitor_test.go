@@ -1,6 +1,10 @@
 package monitor
 
-import "testing"
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
 
 func TestIsMicNoiseBundle(t *testing.T) {
 	tests := []struct {
@@ -23,6 +27,10 @@ func TestIsMicNoiseBundle(t *testing.T) {
 		{"com.google.Chrome", false},
 		{"org.mozilla.firefox", false},
 		{"com.apple.Safari", false},
+		// Linux streams, by application.id or binary.
+		{"org.PulseAudio.pavucontrol", true},
+		{"easyeffects", true},
+		{"zoom", false},
 		// Empty / unknown.
 		{"", false},
 		{"com.example.unrelated", false},
@@ -34,45 +42,145 @@ func TestIsMicNoiseBundle(t *testing.T) {
 	}
 }
 
-func TestContainsAny(t *testing.T) {
+func patterns(t *testing.T, ps ...string) []*regexp.Regexp {
+	t.Helper()
+	res, err := compilePatterns(ps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestAnyMatch(t *testing.T) {
 	procs := []string{"Google Chrome", "Safari", "Finder", "zoom.us"}
 	tests := []struct {
-		hints []string
-		want  bool
+		patterns []string
+		want     bool
 	}{
-		{[]string{"zoom.us"}, true},
+		{[]string{`zoom\.us`}, true},
 		{[]string{"zoom"}, true},
-		{[]string{"Google Meet", "meet"}, false}, // Meet runs in browser, no "meet" process
+		{[]string{"Google Meet"}, false}, // Meet runs in browser, no "meet" process
 		{[]string{"Chrome"}, true},
 		{[]string{"Microsoft Teams"}, false},
 		{[]string{"nothing"}, false},
+		{[]string{"^Safari$"}, true},
 	}
 	for _, tc := range tests {
-		if got := containsAny(procs, tc.hints...); got != tc.want {
-			t.Errorf("containsAny(procs, %v) = %v, want %v", tc.hints, got, tc.want)
+		if got := anyMatch(procs, patterns(t, tc.patterns...)...); got != tc.want {
+			t.Errorf("anyMatch(procs, %v) = %v, want %v", tc.patterns, got, tc.want)
 		}
 	}
 }
 
-func TestContainsAny_CaseInsensitive(t *testing.T) {
+func TestAnyMatch_CaseInsensitive(t *testing.T) {
 	procs := []string{"Microsoft Teams"}
-	if !containsAny(procs, "microsoft teams") {
-		t.Error("containsAny should be case-insensitive")
+	if !anyMatch(procs, patterns(t, "microsoft teams")...) {
+		t.Error("anyMatch should be case-insensitive")
 	}
-	if !containsAny(procs, "MICROSOFT TEAMS") {
-		t.Error("containsAny should be case-insensitive (upper)")
+	if !anyMatch(procs, patterns(t, "MICROSOFT TEAMS")...) {
+		t.Error("anyMatch should be case-insensitive (upper)")
 	}
 }
 
-func TestContainsAny_EmptyInputs(t *testing.T) {
-	if containsAny(nil, "zoom") {
+func TestAnyMatch_EmptyInputs(t *testing.T) {
+	if anyMatch(nil, patterns(t, "zoom")...) {
 		t.Error("nil procs should return false")
 	}
-	if containsAny([]string{}, "zoom") {
+	if anyMatch([]string{}, patterns(t, "zoom")...) {
 		t.Error("empty procs should return false")
 	}
-	if containsAny([]string{"zoom"}) {
-		t.Error("no hints should return false")
+	if anyMatch([]string{"zoom"}) {
+		t.Error("no patterns should return false")
+	}
+	if anyMatch([]string{"zoom"}, nil) {
+		t.Error("nil pattern should return false")
+	}
+}
+
+func TestDefaultApps(t *testing.T) {
+	rules := map[string]rule{}
+	for _, a := range DefaultApps() {
+		r, err := compileRule(a)
+		if err != nil {
+			t.Fatalf("default rule %q: %v", a.Name, err)
+		}
+		rules[a.Name] = r
+	}
+	tests := []struct {
+		name   string
+		app    string
+		procs  []string
+		micUse bool
+		want   AppState
+	}{
+		{"zoom open", "zoom", []string{"/Applications/zoom.us.app/Contents/MacOS/zoom.us"}, false, AppState{Running: true}},
+		{"zoom linux", "zoom", []string{"zoom"}, false, AppState{Running: true}},
+		{"zoom in call", "zoom", []string{"zoom.us", "CptHost"}, false, AppState{Running: true, InCall: true}},
+		{"zoom mic alone is not a call", "zoom", []string{"zoom.us"}, true, AppState{Running: true}},
+		{"zoomd is not zoom", "zoom", []string{"/usr/libexec/zoomd"}, false, AppState{}},
+		{"teams open", "teams", []string{"/Applications/Microsoft Teams.app/Contents/MacOS/MSTeams"}, false, AppState{Running: true}},
+		{"teams in call", "teams", []string{"MSTeams"}, true, AppState{Running: true, InCall: true}},
+		{"steamsync is not teams", "teams", []string{"steamsync"}, false, AppState{}},
+		{"meet is not every process with meet in it", "meet", []string{"meetingd", "Google Chrome"}, false, AppState{}},
+		{"meet in browser", "meet", []string{"Google Chrome"}, true, AppState{Running: true, InCall: true}},
+	}
+	for _, tc := range tests {
+		procs := make([]Process, len(tc.procs))
+		for i, name := range tc.procs {
+			procs[i] = Process{PID: i + 1, Name: name}
+		}
+		if got := rules[tc.app].state(procs, tc.micUse); got != tc.want {
+			t.Errorf("%s: state = %+v, want %+v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestRuleUsesMic(t *testing.T) {
+	var zoom, meet rule
+	for _, a := range DefaultApps() {
+		r, _ := compileRule(a)
+		switch a.Name {
+		case "zoom":
+			zoom = r
+		case "meet":
+			meet = r
+		}
+	}
+	if !zoom.usesMic([]string{"com.apple.CoreSpeech", "US.ZOOM.XOS"}) {
+		t.Error("zoom should match its bundle ID, ignoring case")
+	}
+	if zoom.usesMic([]string{"com.google.Chrome"}) {
+		t.Error("zoom should not match a browser")
+	}
+	if !meet.usesMic([]string{"com.google.Chrome"}) {
+		t.Error("meet should match a browser using the mic")
+	}
+	if meet.usesMic([]string{"com.apple.CoreSpeech"}) {
+		t.Error("noise bundles should never count")
+	}
+	// Linux reports binaries rather than bundle IDs.
+	if !zoom.usesMic([]string{"pavucontrol", "zoom"}) {
+		t.Error("zoom should match its binary name")
+	}
+	if !meet.usesMic([]string{"firefox"}) {
+		t.Error("meet should match a browser binary using the mic")
+	}
+}
+
+func TestAppRuleValidate(t *testing.T) {
+	for _, r := range []AppRule{
+		{Processes: []string{"x"}},
+		{Name: "empty"},
+		{Name: "bad", Processes: []string{"("}},
+		{Name: "bad", Processes: []string{"x"}, InCallProcess: "["},
+		{Name: "bad", BrowserHints: []string{"*"}},
+	} {
+		if err := r.Validate(); err == nil {
+			t.Errorf("Validate(%+v) = nil, want error", r)
+		}
+	}
+	if err := (AppRule{Name: "slack", Processes: []string{"(^|/)Slack$"}}).Validate(); err != nil {
+		t.Errorf("Validate(slack) = %v", err)
 	}
 }
 
@@ -83,10 +191,10 @@ func TestSnapshotInCall(t *testing.T) {
 		want bool
 	}{
 		{"no meeting", Snapshot{}, false},
-		{"zoom in call", Snapshot{ZoomInCall: true}, true},
+		{"zoom in call", Snapshot{Apps: map[string]AppState{"zoom": {Running: true, InCall: true}}}, true},
 		{"mic active", Snapshot{MicActive: true}, true},
-		{"zoom open but not in call", Snapshot{ZoomRunning: true}, false},
-		{"teams open but no mic", Snapshot{TeamsRunning: true}, false},
+		{"zoom open but not in call", Snapshot{Apps: map[string]AppState{"zoom": {Running: true}}}, false},
+		{"teams open but no mic", Snapshot{Apps: map[string]AppState{"teams": {Running: true}}}, false},
 	}
 	for _, tc := range tests {
 		if got := tc.snap.InCall(); got != tc.want {
@@ -95,10 +203,63 @@ func TestSnapshotInCall(t *testing.T) {
 	}
 }
 
+func TestSnapshotRunningApps(t *testing.T) {
+	snap := Snapshot{
+		Apps: map[string]AppState{
+			"teams": {Running: true, InCall: true},
+			"meet":  {Running: true},
+		},
+		MicBundleIDs: []string{"com.microsoft.teams2", "com.apple.CoreSpeech"},
+	}
+	got := snap.RunningApps()
+	want := []string{"meet", "teams", "com.microsoft.teams2"}
+	if len(got) != len(want) {
+		t.Fatalf("RunningApps() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("RunningApps()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+	if names := snap.AppNames(); len(names) != 2 || names[0] != "meet" || names[1] != "teams" {
+		t.Errorf("AppNames() = %v, want [meet teams]", names)
+	}
+	if apps := (Snapshot{}).RunningApps(); apps != nil {
+		t.Errorf("RunningApps() with nothing running = %v, want nil", apps)
+	}
+	if in := snap.InCallApps(); len(in) != 1 || in[0] != "teams" {
+		t.Errorf("InCallApps() = %v, want [teams]", in)
+	}
+	if active := snap.ActiveApps(); len(active) != 2 || active[0] != "teams" || active[1] != "com.microsoft.teams2" {
+		t.Errorf("ActiveApps() = %v, want [teams com.microsoft.teams2]", active)
+	}
+	if !snap.Running("meet") || snap.Running("zoom") || !snap.AppInCall("teams") {
+		t.Error("Running/AppInCall disagree with Apps")
+	}
+}
+
+func TestSnapshotEqual(t *testing.T) {
+	a := Snapshot{Apps: map[string]AppState{"zoom": {Running: true}}}
+	b := Snapshot{Apps: map[string]AppState{"zoom": {Running: true}}, MicBundleIDs: []string{"x"}}
+	if !a.Equal(b) {
+		t.Error("snapshots with the same apps should be equal")
+	}
+	b.Apps["zoom"] = AppState{Running: true, InCall: true}
+	if a.Equal(b) {
+		t.Error("call state change should make snapshots differ")
+	}
+	if a.Equal(Snapshot{}) || !(Snapshot{}).Equal(Snapshot{}) {
+		t.Error("Equal mishandles empty snapshots")
+	}
+}
+
 func TestNewMonitor_InitialSnapshot(t *testing.T) {
-	m := New()
+	m := New(DefaultApps())
+	if len(m.rules) != 3 {
+		t.Errorf("New(DefaultApps()) compiled %d rules, want 3", len(m.rules))
+	}
 	snap := m.Current()
-	if snap.ZoomRunning || snap.TeamsRunning || snap.MeetRunning || snap.MicActive {
+	if snap.Apps != nil || snap.MicActive {
 		t.Error("initial snapshot should have all fields false")
 	}
 	if snap.MicBundleIDs != nil {
@@ -140,26 +301,45 @@ func TestMicActiveFiltersNoiseBundles(t *testing.T) {
 	}
 }
 
-func TestGetPIDs(t *testing.T) {
-	pids := map[string]string{
-		"100": "Google Chrome",
-		"101": "Google Chrome Helper",
-		"200": "Microsoft Teams",
-		"300": "Finder",
+func TestMatchingPIDs(t *testing.T) {
+	procs := []Process{
+		{PID: 100, PPID: 1, Name: "chrome", Exe: "/opt/google/chrome/chrome"},
+		{PID: 101, PPID: 100, Name: "chrome", Cmdline: "/opt/google/chrome/chrome --type=utility --utility-sub-type=audio.mojom.AudioService"},
+		{PID: 102, PPID: 101, Name: "helper"},
+		{PID: 200, PPID: 1, Name: "teams-for-linux"},
+		{PID: 300, PPID: 1, Name: "nautilus"},
 	}
 
-	got := getPIDs(pids, "chrome")
-	if len(got) != 2 {
-		t.Errorf("expected 2 Chrome PIDs, got %d: %v", len(got), got)
+	got := matchingPIDs(procs, patterns(t, "chrome")...)
+	if strings.Join(got, ",") != "100,101,102" {
+		t.Errorf("chrome PIDs = %v, want 100,101,102 (with descendants)", got)
 	}
 
-	got = getPIDs(pids, "teams")
+	got = matchingPIDs(procs, patterns(t, "teams")...)
 	if len(got) != 1 {
 		t.Errorf("expected 1 Teams PID, got %d: %v", len(got), got)
 	}
 
-	got = getPIDs(pids, "zoom")
+	got = matchingPIDs(procs, patterns(t, "zoom")...)
 	if len(got) != 0 {
 		t.Errorf("expected 0 Zoom PIDs, got %d: %v", len(got), got)
 	}
 }
+
+func TestLsofShowsMic(t *testing.T) {
+	tests := []struct {
+		name string
+		out  string
+		want bool
+	}{
+		{"alsa capture", "zoom 41210 user 45u CHR 116,6 0t0 /dev/snd/pcmC0D0c\n", true},
+		{"alsa playback only", "zoom 41210 user 44u CHR 116,5 0t0 /dev/snd/pcmC0D0p\n", false},
+		{"core audio", "zoom.us 812 user txt REG /System/Library/Frameworks/CoreAudio.framework/CoreAudio\n", true},
+		{"nothing", "firefox 3381 user cwd DIR /home/user\n", false},
+	}
+	for _, tc := range tests {
+		if got := lsofShowsMic(tc.out); got != tc.want {
+			t.Errorf("%s: lsofShowsMic = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
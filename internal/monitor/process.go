@@ -0,0 +1,158 @@
+package monitor
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Process is one running process as the app rules see it.
+type Process struct {
+	PID  int
+	PPID int
+	// Name is the short process name: comm on Linux (at most 15
+	// characters), the executable path on macOS.
+	Name string
+	// Exe is the executable path, when readable.
+	Exe string
+	// Cmdline is the full command line with arguments joined by spaces,
+	// when readable. It tells apart Electron apps and browser app windows
+	// (--app=https://meet.google.com/...) that share a binary.
+	Cmdline string
+}
+
+// matches reports whether any of the patterns matches the process's name,
+// executable path or command line. Nil patterns are ignored.
+func (p Process) matches(patterns ...*regexp.Regexp) bool {
+	for _, re := range patterns {
+		if re == nil {
+			continue
+		}
+		for _, field := range []string{p.Name, p.Exe, p.Cmdline} {
+			if field != "" && re.MatchString(field) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// withDescendants returns the PIDs in roots and of all their descendants,
+// in ascending order.
+func withDescendants(procs []Process, roots map[int]bool) []int {
+	children := make(map[int][]int)
+	for _, p := range procs {
+		children[p.PPID] = append(children[p.PPID], p.PID)
+	}
+	seen := make(map[int]bool)
+	var queue []int
+	for pid := range roots {
+		queue = append(queue, pid)
+	}
+	for len(queue) > 0 {
+		pid := queue[0]
+		queue = queue[1:]
+		if seen[pid] {
+			continue
+		}
+		seen[pid] = true
+		queue = append(queue, children[pid]...)
+	}
+	out := make([]int, 0, len(seen))
+	for pid := range seen {
+		out = append(out, pid)
+	}
+	sort.Ints(out)
+	return out
+}
+
+// scanProc reads the processes under a /proc tree rooted at root. Processes
+// that exit while being read are skipped; fields the caller may not read
+// (exe of another user's process) are left empty.
+func scanProc(root string) ([]Process, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+	var procs []Process
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil || !e.IsDir() {
+			continue
+		}
+		dir := filepath.Join(root, e.Name())
+		status, err := os.ReadFile(filepath.Join(dir, "status"))
+		if err != nil {
+			continue
+		}
+		p := Process{PID: pid}
+		sc := bufio.NewScanner(bytes.NewReader(status))
+		for sc.Scan() {
+			key, value, ok := strings.Cut(sc.Text(), ":")
+			if !ok {
+				continue
+			}
+			switch key {
+			case "Name":
+				p.Name = strings.TrimSpace(value)
+			case "PPid":
+				p.PPID, _ = strconv.Atoi(strings.TrimSpace(value))
+			}
+		}
+		if comm, err := os.ReadFile(filepath.Join(dir, "comm")); err == nil {
+			p.Name = strings.TrimSpace(string(comm))
+		}
+		if cmdline, err := os.ReadFile(filepath.Join(dir, "cmdline")); err == nil {
+			p.Cmdline = strings.TrimSpace(strings.ReplaceAll(string(cmdline), "\x00", " "))
+		}
+		if exe, err := os.Readlink(filepath.Join(dir, "exe")); err == nil {
+			p.Exe = strings.TrimSuffix(exe, " (deleted)")
+		}
+		procs = append(procs, p)
+	}
+	return procs, nil
+}
+
+// psProcesses lists processes with ps, for systems without /proc.
+func psProcesses() []Process {
+	out, err := exec.Command("ps", "-eo", "pid=,ppid=,comm=").Output()
+	if err != nil {
+		return nil
+	}
+	return parsePS(string(out))
+}
+
+// parsePS parses `ps -eo pid=,ppid=,comm=` output. On macOS comm is the
+// executable path, so it also fills Exe.
+func parsePS(out string) []Process {
+	var procs []Process
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		pid, err1 := strconv.Atoi(fields[0])
+		ppid, err2 := strconv.Atoi(fields[1])
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		// comm may contain spaces ("Microsoft Teams"); take the rest of
+		// the line after the two numeric columns.
+		rest := strings.TrimSpace(line)
+		for i := 0; i < 2; i++ {
+			rest = strings.TrimSpace(rest[strings.IndexAny(rest, " \t"):])
+		}
+		p := Process{PID: pid, PPID: ppid, Name: rest}
+		if strings.HasPrefix(rest, "/") {
+			p.Exe = rest
+		}
+		procs = append(procs, p)
+	}
+	return procs
+}
@@ -0,0 +1,13 @@
+//go:build linux
+
+package monitor
+
+// listProcesses reads /proc, which gives full command lines and executable
+// paths without spawning ps. It falls back to ps if /proc is unavailable.
+func listProcesses() []Process {
+	procs, err := scanProc("/proc")
+	if err != nil {
+		return psProcesses()
+	}
+	return procs
+}
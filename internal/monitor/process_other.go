@@ -0,0 +1,8 @@
+//go:build !linux
+
+package monitor
+
+// listProcesses lists processes with ps.
+func listProcesses() []Process {
+	return psProcesses()
+}
@@ -0,0 +1,123 @@
+package monitor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeProc creates /proc/<pid> entries under root.
+func writeProc(t *testing.T, root, pid, comm, cmdline, ppid, exe string) {
+	t.Helper()
+	dir := filepath.Join(root, pid)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"comm":    comm + "\n",
+		"cmdline": cmdline,
+		"status":  "Name:\t" + comm + "\nUmask:\t0022\nState:\tS (sleeping)\nPPid:\t" + ppid + "\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if exe != "" {
+		if err := os.Symlink(exe, filepath.Join(dir, "exe")); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestScanProc(t *testing.T) {
+	root := t.TempDir()
+	writeProc(t, root, "1", "systemd", "/sbin/init\x00splash\x00", "0", "")
+	writeProc(t, root, "4100", "chrome", "/opt/google/chrome/chrome\x00--app=https://meet.google.com/abc-defg-hij\x00", "1", "/opt/google/chrome/chrome")
+	writeProc(t, root, "4120", "chrome", "/opt/google/chrome/chrome\x00--type=renderer\x00", "4100", "/opt/google/chrome/chrome")
+	writeProc(t, root, "5200", "teams-for-linux", "/usr/bin/teams-for-linux\x00", "1", "/opt/teams-for-linux/teams-for-linux (deleted)")
+	// Not processes.
+	os.MkdirAll(filepath.Join(root, "sys"), 0755)
+	os.WriteFile(filepath.Join(root, "uptime"), []byte("1.0 2.0\n"), 0644)
+	// A process that exited between readdir and read.
+	os.MkdirAll(filepath.Join(root, "9999"), 0755)
+
+	procs, err := scanProc(root)
+	if err != nil {
+		t.Fatalf("scanProc: %v", err)
+	}
+	byPID := make(map[int]Process)
+	for _, p := range procs {
+		byPID[p.PID] = p
+	}
+	if len(procs) != 4 {
+		t.Fatalf("got %d processes, want 4: %+v", len(procs), procs)
+	}
+	meet := byPID[4100]
+	if meet.Name != "chrome" || meet.PPID != 1 || meet.Exe != "/opt/google/chrome/chrome" ||
+		meet.Cmdline != "/opt/google/chrome/chrome --app=https://meet.google.com/abc-defg-hij" {
+		t.Errorf("chrome = %+v", meet)
+	}
+	if byPID[4120].PPID != 4100 {
+		t.Errorf("renderer PPID = %d, want 4100", byPID[4120].PPID)
+	}
+	if byPID[5200].Exe != "/opt/teams-for-linux/teams-for-linux" {
+		t.Errorf("deleted exe = %q", byPID[5200].Exe)
+	}
+	if byPID[1].Exe != "" {
+		t.Errorf("unreadable exe = %q, want empty", byPID[1].Exe)
+	}
+
+	// The default rules see the Meet app window and Teams through the
+	// command line and executable path.
+	for _, a := range DefaultApps() {
+		r, _ := compileRule(a)
+		st := r.state(procs, false)
+		switch a.Name {
+		case "meet", "teams":
+			if !st.Running {
+				t.Errorf("%s not detected from /proc", a.Name)
+			}
+		case "zoom":
+			if st.Running {
+				t.Errorf("zoom detected in %+v", procs)
+			}
+		}
+	}
+
+	if _, err := scanProc(filepath.Join(root, "missing")); err == nil {
+		t.Error("expected error for a missing /proc root")
+	}
+}
+
+func TestParsePS(t *testing.T) {
+	out := `    1     0 /sbin/launchd
+  812     1 /Applications/zoom.us.app/Contents/MacOS/zoom.us
+  830   812 /Applications/zoom.us.app/Contents/Frameworks/cpthost.app/Contents/MacOS/CptHost
+  901     1 Microsoft Teams
+  bad line
+`
+	procs := parsePS(out)
+	if len(procs) != 4 {
+		t.Fatalf("got %d processes, want 4: %+v", len(procs), procs)
+	}
+	if procs[2].PPID != 812 || procs[2].Exe == "" {
+		t.Errorf("CptHost = %+v", procs[2])
+	}
+	if procs[3].Name != "Microsoft Teams" || procs[3].Exe != "" {
+		t.Errorf("Teams = %+v", procs[3])
+	}
+}
+
+func TestWithDescendants(t *testing.T) {
+	procs := []Process{
+		{PID: 1, PPID: 0}, {PID: 10, PPID: 1}, {PID: 11, PPID: 10}, {PID: 12, PPID: 11}, {PID: 20, PPID: 1},
+	}
+	got := withDescendants(procs, map[int]bool{10: true})
+	if len(got) != 3 || got[0] != 10 || got[2] != 12 {
+		t.Errorf("withDescendants(10) = %v, want [10 11 12]", got)
+	}
+	if got := withDescendants(procs, nil); len(got) != 0 {
+		t.Errorf("withDescendants(nil) = %v", got)
+	}
+}
@@ -29,7 +29,7 @@ func New(path string) (*PIDFile, error) {
 		// PID file exists, check if process is running
 		pidStr := strings.TrimSpace(string(data))
 		if existingPID, err := strconv.Atoi(pidStr); err == nil {
-			if isProcessRunning(existingPID) {
+			if IsProcessRunning(existingPID) {
 				return nil, fmt.Errorf("another instance is already running (PID %d)", existingPID)
 			}
 			// Process not running, remove stale PID file
@@ -68,8 +68,8 @@ func (p *PIDFile) Remove() error {
 	return nil
 }
 
-// isProcessRunning checks if a process with the given PID is running
-func isProcessRunning(pid int) bool {
+// IsProcessRunning checks if a process with the given PID is running
+func IsProcessRunning(pid int) bool {
 	// Send signal 0 to check if process exists
 	// This doesn't actually send a signal, just checks if we can
 	process, err := os.FindProcess(pid)
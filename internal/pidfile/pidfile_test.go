@@ -188,12 +188,12 @@ func TestGetPIDFilePath(t *testing.T) {
 
 func TestIsProcessRunning(t *testing.T) {
 	// Test with current process (should be running)
-	if !isProcessRunning(os.Getpid()) {
+	if !IsProcessRunning(os.Getpid()) {
 		t.Error("Current process should be detected as running")
 	}
 
 	// Test with non-existent PID (should not be running)
-	if isProcessRunning(99999) {
+	if IsProcessRunning(99999) {
 		t.Error("Non-existent process should not be detected as running")
 	}
 }
@@ -0,0 +1,291 @@
+// Package recpath names recordings and the files next to them. All files
+// of a recording share one stem: "<stem>.wav" while it is recorded,
+// "<stem>.m4a" once converted, "<stem>.json" for the sidecar and
+// "<stem>.inprogress.json" for the crash marker. With encryption, finalized
+// files get ".enc" appended: "<stem>.m4a.enc", "<stem>.json.enc".
+// Components derive sibling paths with Stem and WithExt only, so they
+// always agree.
+//
+// New recordings are named by a Resolver from output.filename_template and
+// output.dir_template, e.g. "{date}_{time}_audio_{profile}_{tag}". Field
+// values are sanitized for file names; a field that is empty drops out
+// together with the separators around it.
+package recpath
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Extensions of a recording's files.
+const (
+	ExtWAV           = ".wav"
+	ExtM4A           = ".m4a"
+	ExtSidecar       = ".json"
+	InProgressSuffix = ".inprogress.json"
+	ExtEncrypted     = ".enc" // appended to an encrypted file's extension
+	// ExtCompanion is the plaintext companion of an encrypted sidecar,
+	// holding the fields the library index needs.
+	ExtCompanion = ".idx"
+)
+
+// suffixes are the extensions a new recording's stem must be free for.
+var suffixes = []string{
+	ExtWAV, ExtM4A, ExtSidecar, InProgressSuffix,
+	ExtWAV + ExtEncrypted, ExtM4A + ExtEncrypted, ExtSidecar + ExtEncrypted, ExtCompanion,
+}
+
+// Stem returns path without its extension; for an in-progress marker,
+// without ".inprogress.json", and for an encrypted file without both
+// extensions.
+func Stem(path string) string {
+	path = strings.TrimSuffix(path, ExtEncrypted)
+	if strings.HasSuffix(path, InProgressSuffix) {
+		return strings.TrimSuffix(path, InProgressSuffix)
+	}
+	return strings.TrimSuffix(path, filepath.Ext(path))
+}
+
+// WithExt returns the sibling of path with extension ext:
+// WithExt("/r/a.wav", ".m4a") is "/r/a.m4a".
+func WithExt(path, ext string) string {
+	return Stem(path) + ext
+}
+
+// Sidecar returns the JSON sidecar path of a recording.
+func Sidecar(path string) string {
+	return WithExt(path, ExtSidecar)
+}
+
+// Encrypted reports whether path is an encrypted file.
+func Encrypted(path string) bool {
+	return strings.HasSuffix(path, ExtEncrypted)
+}
+
+// Defaults reproduce the original flat layout:
+// 2026-02-12_143015_audio_high.wav.
+const (
+	DefaultFilenameTemplate = "{date}_{time}_audio_{profile}_{tag}"
+	DefaultDirTemplate      = ""
+)
+
+// Fields are the values templates can use.
+type Fields struct {
+	Time       time.Time // session start, local time
+	Profile    string    // format profile
+	Device     string    // capture device
+	App        string    // detected meeting app
+	AppProfile string    // app_profiles name
+	Tag        string    // app profile tag
+	Host       string    // short host name
+	Title      string    // calendar event in progress
+}
+
+var fields = map[string]func(Fields) string{
+	"date":        func(f Fields) string { return f.Time.Format("2006-01-02") },
+	"time":        func(f Fields) string { return f.Time.Format("150405") },
+	"year":        func(f Fields) string { return f.Time.Format("2006") },
+	"month":       func(f Fields) string { return f.Time.Format("01") },
+	"day":         func(f Fields) string { return f.Time.Format("02") },
+	"hour":        func(f Fields) string { return f.Time.Format("15") },
+	"minute":      func(f Fields) string { return f.Time.Format("04") },
+	"weekday":     func(f Fields) string { return strings.ToLower(f.Time.Format("Mon")) },
+	"session_id":  func(f Fields) string { return f.Time.Format("20060102T150405") },
+	"profile":     func(f Fields) string { return f.Profile },
+	"device":      func(f Fields) string { return f.Device },
+	"app":         func(f Fields) string { return f.App },
+	"app_profile": func(f Fields) string { return f.AppProfile },
+	"tag":         func(f Fields) string { return f.Tag },
+	"host":        func(f Fields) string { return f.Host },
+	"title":       func(f Fields) string { return f.Title },
+}
+
+// FieldNames returns the names templates can use, sorted.
+func FieldNames() []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Template is a parsed file or directory name template.
+type Template struct {
+	parts []part
+}
+
+// part is literal text or, when field is set, a placeholder.
+type part struct {
+	text  string
+	field func(Fields) string
+}
+
+// Parse parses a template of literal text and {field} placeholders.
+func Parse(s string) (*Template, error) {
+	t := &Template{}
+	for s != "" {
+		open := strings.IndexByte(s, '{')
+		if open < 0 {
+			t.parts = append(t.parts, part{text: s})
+			break
+		}
+		if open > 0 {
+			t.parts = append(t.parts, part{text: s[:open]})
+		}
+		end := strings.IndexByte(s[open:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed { in %q", s)
+		}
+		name := s[open+1 : open+end]
+		fn, ok := fields[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown field {%s} (known: %s)", name, strings.Join(FieldNames(), ", "))
+		}
+		t.parts = append(t.parts, part{field: fn})
+		s = s[open+end+1:]
+	}
+	return t, nil
+}
+
+// expand fills in f and cleans each path segment.
+func (t *Template) expand(f Fields) []string {
+	var b strings.Builder
+	for _, p := range t.parts {
+		if p.field != nil {
+			b.WriteString(Sanitize(p.field(f)))
+		} else {
+			b.WriteString(p.text)
+		}
+	}
+	var segs []string
+	for _, seg := range strings.Split(b.String(), "/") {
+		if seg = cleanSegment(seg); seg != "" {
+			segs = append(segs, seg)
+		}
+	}
+	return segs
+}
+
+// maxField caps a sanitized field, in runes.
+const maxField = 60
+
+// Sanitize makes s safe inside a file name: letters, digits, '-', '_'
+// and '.' are kept, anything else becomes '_'.
+func Sanitize(s string) string {
+	var b strings.Builder
+	n := 0
+	for _, r := range strings.TrimSpace(s) {
+		if n == maxField {
+			break
+		}
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '.' {
+			r = '_'
+		}
+		b.WriteRune(r)
+		n++
+	}
+	return b.String()
+}
+
+// cleanSegment collapses runs of '_' left by empty or sanitized fields and
+// trims separators from the ends, so "a__b_" becomes "a_b". A segment of
+// dots alone is dropped.
+func cleanSegment(seg string) string {
+	for strings.Contains(seg, "__") {
+		seg = strings.ReplaceAll(seg, "__", "_")
+	}
+	seg = strings.Trim(seg, "_- ")
+	if strings.Trim(seg, ".") == "" {
+		return ""
+	}
+	return strings.TrimLeft(seg, ".")
+}
+
+// Resolver names new recordings.
+type Resolver struct {
+	file, dir *Template
+}
+
+// NewResolver parses the templates. The file name template must not
+// contain a path separator; the directory template must stay inside the
+// output directory.
+func NewResolver(filenameTemplate, dirTemplate string) (*Resolver, error) {
+	if strings.TrimSpace(filenameTemplate) == "" {
+		return nil, fmt.Errorf("filename template is empty")
+	}
+	if strings.ContainsAny(filenameTemplate, `/\`) {
+		return nil, fmt.Errorf("filename template %q contains a path separator; use the directory template", filenameTemplate)
+	}
+	file, err := Parse(filenameTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("filename template: %w", err)
+	}
+	if strings.Contains(dirTemplate, `\`) || strings.HasPrefix(dirTemplate, "/") ||
+		strings.Contains("/"+dirTemplate+"/", "/../") {
+		return nil, fmt.Errorf("directory template %q must stay inside the output directory", dirTemplate)
+	}
+	dir, err := Parse(dirTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("directory template: %w", err)
+	}
+	return &Resolver{file: file, dir: dir}, nil
+}
+
+// Default returns the resolver for the default templates.
+func Default() *Resolver {
+	r, _ := NewResolver(DefaultFilenameTemplate, DefaultDirTemplate)
+	return r
+}
+
+// Path returns the path of a new recording under root with extension
+// ext, creating its directory. When a file with the stem already exists
+// (a rollover in the same second, or a template without the time), "_2",
+// "_3", ... is appended to the stem.
+func (r *Resolver) Path(root string, f Fields, ext string) (string, error) {
+	return r.StagedPath(root, "", f, ext)
+}
+
+// StagedPath is Path for a recording that is written below staging and
+// moved to the same place below root once finalized. The name is free in
+// both trees; the returned path is below staging. An empty staging is
+// the same as Path.
+func (r *Resolver) StagedPath(root, staging string, f Fields, ext string) (string, error) {
+	sub := filepath.Join(r.dir.expand(f)...)
+	roots := []string{root}
+	if staging != "" {
+		roots = append(roots, staging)
+	}
+	for _, base := range roots {
+		if err := os.MkdirAll(filepath.Join(base, sub), 0755); err != nil {
+			return "", fmt.Errorf("create recording dir: %w", err)
+		}
+	}
+	name := strings.Join(r.file.expand(f), "_")
+	if name == "" {
+		name = "recording"
+	}
+	stem := filepath.Join(sub, name)
+	for n := 2; taken(roots, stem); n++ {
+		stem = filepath.Join(sub, fmt.Sprintf("%s_%d", name, n))
+	}
+	return filepath.Join(roots[len(roots)-1], stem) + ext, nil
+}
+
+// taken reports whether any file of a recording with stem exists below
+// one of roots.
+func taken(roots []string, stem string) bool {
+	for _, root := range roots {
+		for _, ext := range suffixes {
+			if _, err := os.Lstat(filepath.Join(root, stem) + ext); err == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
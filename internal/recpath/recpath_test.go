@@ -0,0 +1,145 @@
+package recpath
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2026, 2, 12, 14, 30, 15, 0, time.Local)
+
+func TestSiblings(t *testing.T) {
+	tests := []struct{ in, ext, want string }{
+		{"/r/a.wav", ExtM4A, "/r/a.m4a"},
+		{"/r/a.m4a", ExtSidecar, "/r/a.json"},
+		{"/r/a.inprogress.json", ExtWAV, "/r/a.wav"},
+		{"/r/v1.2 review.wav", ExtSidecar, "/r/v1.2 review.json"},
+		{"/r/a.json", InProgressSuffix, "/r/a.inprogress.json"},
+		{"/r/a.m4a.enc", ExtSidecar + ExtEncrypted, "/r/a.json.enc"},
+	}
+	for _, tt := range tests {
+		if got := WithExt(tt.in, tt.ext); got != tt.want {
+			t.Errorf("WithExt(%q, %q) = %q, want %q", tt.in, tt.ext, got, tt.want)
+		}
+	}
+}
+
+func TestDefaultMatchesLegacyNames(t *testing.T) {
+	dir := t.TempDir()
+	r := Default()
+	got, err := r.Path(dir, Fields{Time: start, Profile: "high"}, ExtWAV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dir, "2026-02-12_143015_audio_high.wav"); got != want {
+		t.Errorf("Path = %q, want %q", got, want)
+	}
+	got, _ = r.Path(dir, Fields{Time: start, Profile: "high", Tag: "teams"}, ExtWAV)
+	if want := filepath.Join(dir, "2026-02-12_143015_audio_high_teams.wav"); got != want {
+		t.Errorf("Path with tag = %q, want %q", got, want)
+	}
+}
+
+func TestTemplates(t *testing.T) {
+	f := Fields{
+		Time:    start,
+		Profile: "balanced",
+		Device:  "BlackHole 2ch",
+		App:     "zoom",
+		Host:    "mbp",
+		Title:   "Q1 planning / budget: final?",
+	}
+	tests := []struct {
+		name, file, dir, want string
+	}{
+		{"date dirs", "{time}_{app}", "{year}/{month}", "2026/02/143015_zoom.wav"},
+		{"sanitized title", "{date}_{title}", "", "2026-02-12_Q1_planning_budget_final.wav"},
+		{"device and host", "{host}-{device}", "", "mbp-BlackHole_2ch.wav"},
+		{"empty field drops out", "{date}_{app_profile}_{profile}", "{app}/{tag}", "zoom/2026-02-12_balanced.wav"},
+		{"weekday", "{weekday}_{session_id}", "", "thu_20260212T143015.wav"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			r, err := NewResolver(tt.file, tt.dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := r.Path(dir, f, ExtWAV)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := filepath.Join(dir, tt.want); got != want {
+				t.Errorf("Path = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestPathAvoidsCollisions(t *testing.T) {
+	dir := t.TempDir()
+	r, _ := NewResolver("{date}", "")
+	f := Fields{Time: start}
+	// Any file of a recording claims the stem: here a converted M4A and
+	// a sidecar left from earlier sessions.
+	os.WriteFile(filepath.Join(dir, "2026-02-12.m4a"), nil, 0644)
+	os.WriteFile(filepath.Join(dir, "2026-02-12_2.json"), nil, 0644)
+	got, err := r.Path(dir, f, ExtWAV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dir, "2026-02-12_3.wav"); got != want {
+		t.Errorf("Path = %q, want %q", got, want)
+	}
+}
+
+func TestStagedPath(t *testing.T) {
+	root, staging := t.TempDir(), t.TempDir()
+	r, _ := NewResolver("{date}", "{year}")
+	f := Fields{Time: start}
+	// The previous part is still being finalized in staging, and an older
+	// recording of the day is encrypted in the output directory.
+	os.MkdirAll(filepath.Join(root, "2026"), 0755)
+	os.WriteFile(filepath.Join(root, "2026", "2026-02-12.m4a.enc"), nil, 0644)
+	os.MkdirAll(filepath.Join(staging, "2026"), 0755)
+	os.WriteFile(filepath.Join(staging, "2026", "2026-02-12_2.wav"), nil, 0644)
+	got, err := r.StagedPath(root, staging, f, ExtWAV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(staging, "2026", "2026-02-12_3.wav"); got != want {
+		t.Errorf("StagedPath = %q, want %q", got, want)
+	}
+}
+
+func TestNewResolverErrors(t *testing.T) {
+	tests := []struct{ file, dir string }{
+		{"", ""},
+		{"{date", ""},
+		{"{nope}", ""},
+		{"{year}/{date}", ""},
+		{"{date}", "../elsewhere"},
+		{"{date}", "/abs"},
+		{"{date}", "{colour}"},
+	}
+	for _, tt := range tests {
+		if _, err := NewResolver(tt.file, tt.dir); err == nil {
+			t.Errorf("NewResolver(%q, %q): expected error", tt.file, tt.dir)
+		}
+	}
+}
+
+func TestDirFieldCannotEscape(t *testing.T) {
+	dir := t.TempDir()
+	r, _ := NewResolver("{date}", "{title}")
+	for _, title := range []string{"..", "../../etc", "/abs"} {
+		got, err := r.Path(dir, Fields{Time: start, Title: title}, ExtWAV)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rel, _ := filepath.Rel(dir, got); !filepath.IsLocal(rel) {
+			t.Errorf("title %q: Path = %q escapes %q", title, got, dir)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+// Package retention deletes old recordings from the output directory.
+// Plan decides what to delete from the library index; Prune deletes it,
+// each recording's media together with its sidecar, and appends every
+// deletion to a log in the output directory.
+//
+// A recording is never deleted when it is starred or tagged, or when it
+// is one of the keep_recent most recent recordings. Of the rest,
+//
+//	max_age_days  deletes recordings older than the limit; a profiles entry
+//	              for the recording's app profile or format profile
+//	              replaces the limit (0 keeps them forever)
+//	max_total_mb  then deletes the oldest recordings until the directory
+//	              fits in the limit
+package retention
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/tiroq/memofy/internal/config"
+	"github.com/tiroq/memofy/internal/library"
+)
+
+// LogName is the deletion log inside the output directory, one JSON object
+// per deleted recording.
+const LogName = ".memofy-deleted.jsonl"
+
+// maxAge returns the age limit for e; 0 means none.
+func maxAge(c config.RetentionConfig, e library.Entry) time.Duration {
+	days := c.MaxAgeDays
+profiles:
+	for _, name := range []string{e.AppProfile, e.FormatProfile} {
+		for _, p := range c.Profiles {
+			if name != "" && p.Profile == name {
+				days = p.MaxAgeDays
+				break profiles
+			}
+		}
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// Reasons a recording is deleted.
+const (
+	ReasonMaxAge   = "max_age"
+	ReasonMaxTotal = "max_total_size"
+)
+
+// Deletion is a recording Plan selected for deletion.
+type Deletion struct {
+	Entry  library.Entry
+	Reason string
+}
+
+// size returns the bytes an entry occupies.
+func size(e library.Entry) int64 {
+	return e.FileSize + e.SidecarSize
+}
+
+// Protected reports whether retention must keep e regardless of limits.
+func Protected(e library.Entry) bool {
+	return e.Starred || len(e.Tags) > 0
+}
+
+// Plan returns the recordings to delete under c at now, oldest first.
+func Plan(entries []library.Entry, c config.RetentionConfig, now time.Time) []Deletion {
+	sorted := append([]library.Entry(nil), entries...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].StartedAt.Before(sorted[j].StartedAt)
+	})
+
+	keep := make([]bool, len(sorted))
+	for i, e := range sorted {
+		keep[i] = Protected(e) || i >= len(sorted)-c.KeepRecent
+	}
+
+	reason := make([]string, len(sorted)) // empty keeps the recording
+	var total int64
+	for i, e := range sorted {
+		total += size(e)
+		if keep[i] {
+			continue
+		}
+		end := e.EndedAt
+		if end.IsZero() {
+			end = e.StartedAt
+		}
+		if limit := maxAge(c, e); limit > 0 && now.Sub(end) > limit {
+			reason[i] = ReasonMaxAge
+			total -= size(e)
+		}
+	}
+	if limit := c.MaxTotalMB * 1024 * 1024; limit > 0 {
+		for i, e := range sorted {
+			if total <= limit {
+				break
+			}
+			if keep[i] || reason[i] != "" {
+				continue
+			}
+			reason[i] = ReasonMaxTotal
+			total -= size(e)
+		}
+	}
+
+	var plan []Deletion
+	for i, e := range sorted {
+		if reason[i] != "" {
+			plan = append(plan, Deletion{Entry: e, Reason: reason[i]})
+		}
+	}
+	return plan
+}
+
+// LogEntry is one line of the deletion log.
+type LogEntry struct {
+	DeletedAt time.Time `json:"deleted_at"`
+	Reason    string    `json:"reason"`
+	SessionID string    `json:"session_id"`
+	StartedAt time.Time `json:"started_at"`
+	Files     []string  `json:"files"` // relative to the output directory
+	Bytes     int64     `json:"bytes"`
+}
+
+// Result summarizes a Prune run.
+type Result struct {
+	Planned []Deletion // everything Plan selected
+	Deleted []Deletion // what was actually deleted; empty for a dry run
+	Bytes   int64      // bytes freed, or that would be freed by a dry run
+}
+
+// Prune applies c to the recordings in root. With dryRun it only plans.
+// A recording whose media cannot be deleted keeps its sidecar and stays
+// in the index; the first such error is returned after the other
+// deletions are done.
+func Prune(root string, c config.RetentionConfig, now time.Time, dryRun bool, logger *log.Logger) (Result, error) {
+	ix, err := library.Open(root)
+	if err != nil {
+		return Result{}, err
+	}
+	res := Result{Planned: Plan(ix.List(library.Filter{}), c, now)}
+	if dryRun {
+		for _, d := range res.Planned {
+			res.Bytes += size(d.Entry)
+		}
+		return res, nil
+	}
+
+	var firstErr error
+	for _, d := range res.Planned {
+		files, err := remove(ix, d.Entry)
+		if err != nil {
+			logger.Printf("[retention] %v", err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		res.Deleted = append(res.Deleted, d)
+		res.Bytes += size(d.Entry)
+		logger.Printf("[retention] deleted %s (%s)", d.Entry.Sidecar, d.Reason)
+		if err := appendLog(root, LogEntry{
+			DeletedAt: now,
+			Reason:    d.Reason,
+			SessionID: d.Entry.SessionID,
+			StartedAt: d.Entry.StartedAt,
+			Files:     files,
+			Bytes:     size(d.Entry),
+		}); err != nil {
+			logger.Printf("[retention] deletion log: %v", err)
+		}
+		if err := ix.Remove(d.Entry); err != nil {
+			logger.Printf("[retention] index: %v", err)
+		}
+	}
+	return res, firstErr
+}
+
+// remove deletes an entry's media and then its sidecar, and returns the
+// deleted paths relative to the root. The sidecar is only removed once all
+// media is gone, so a recording is never left without its metadata.
+func remove(ix *library.Index, e library.Entry) ([]string, error) {
+	files := ix.Files(e)
+	var rels []string
+	for _, path := range files {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("delete %s: %w", filepath.Base(path), err)
+		}
+		rel, _ := filepath.Rel(ix.Root(), path)
+		rels = append(rels, rel)
+	}
+	return rels, nil
+}
+
+func appendLog(root string, entry LogEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(root, LogName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
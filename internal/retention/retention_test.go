@@ -0,0 +1,226 @@
+package retention
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tiroq/memofy/internal/config"
+	"github.com/tiroq/memofy/internal/library"
+	"github.com/tiroq/memofy/internal/metadata"
+)
+
+var now = time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+
+const mb = 1024 * 1024
+
+// entry is a recording that ended days ago and takes sizeMB.
+func entry(name string, days int, sizeMB int64) library.Entry {
+	start := now.Add(-time.Duration(days)*24*time.Hour - time.Hour)
+	return library.Entry{
+		Sidecar:       name + ".json",
+		File:          name + ".m4a",
+		FileSize:      sizeMB * mb,
+		StartedAt:     start,
+		EndedAt:       start.Add(time.Hour),
+		FormatProfile: "high",
+	}
+}
+
+func planned(plan []Deletion) map[string]string {
+	out := make(map[string]string)
+	for _, d := range plan {
+		out[d.Entry.Sidecar] = d.Reason
+	}
+	return out
+}
+
+func TestPlanMaxAge(t *testing.T) {
+	interview := entry("interview", 100, 1)
+	interview.AppProfile = "interviews"
+	starred := entry("starred", 100, 1)
+	starred.Starred = true
+	tagged := entry("tagged", 100, 1)
+	tagged.Tags = []string{"legal"}
+	wav := entry("wav", 20, 1)
+	wav.FormatProfile = "wav"
+	entries := []library.Entry{
+		entry("old", 31, 1), entry("recent", 29, 1),
+		interview, starred, tagged, wav,
+	}
+	c := config.RetentionConfig{
+		MaxAgeDays: 30,
+		Profiles: []config.RetentionProfile{
+			{Profile: "interviews", MaxAgeDays: 0},
+			{Profile: "wav", MaxAgeDays: 7},
+		},
+	}
+	got := planned(Plan(entries, c, now))
+	want := map[string]string{"old.json": ReasonMaxAge, "wav.json": ReasonMaxAge}
+	if len(got) != len(want) {
+		t.Fatalf("Plan = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("Plan[%s] = %q, want %q", k, got[k], v)
+		}
+	}
+}
+
+func TestPlanKeepRecent(t *testing.T) {
+	entries := []library.Entry{entry("a", 50, 1), entry("b", 40, 1), entry("c", 35, 1)}
+	got := planned(Plan(entries, config.RetentionConfig{MaxAgeDays: 30, KeepRecent: 2}, now))
+	if len(got) != 1 || got["a.json"] != ReasonMaxAge {
+		t.Errorf("Plan = %v, want only a.json", got)
+	}
+}
+
+func TestPlanMaxTotal(t *testing.T) {
+	starred := entry("starred", 10, 40)
+	starred.Starred = true
+	entries := []library.Entry{
+		starred, entry("a", 9, 30), entry("b", 8, 30), entry("c", 7, 30), entry("d", 6, 30),
+	}
+	// 160 MB in total; the starred and the newest recording are kept, the
+	// oldest others go until 100 MB fit.
+	got := planned(Plan(entries, config.RetentionConfig{MaxTotalMB: 100, KeepRecent: 1}, now))
+	want := []string{"a.json", "b.json"}
+	if len(got) != len(want) {
+		t.Fatalf("Plan = %v, want %v", got, want)
+	}
+	for _, k := range want {
+		if got[k] != ReasonMaxTotal {
+			t.Errorf("Plan[%s] = %q, want %q", k, got[k], ReasonMaxTotal)
+		}
+	}
+
+	// Age deletions count toward the quota.
+	got = planned(Plan(entries, config.RetentionConfig{MaxTotalMB: 100, MaxAgeDays: 7}, now))
+	if got["a.json"] != ReasonMaxAge || got["b.json"] != ReasonMaxAge || len(got) != 2 {
+		t.Errorf("Plan = %v, want a and b for age only", got)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, days int) string {
+		path := filepath.Join(dir, name+".m4a")
+		os.WriteFile(path, []byte("audio"), 0644)
+		end := now.Add(-time.Duration(days) * 24 * time.Hour)
+		if err := metadata.Write(path, metadata.Recording{
+			StartedAt:          end.Add(-time.Hour),
+			EndedAt:            end,
+			FinalizationReason: metadata.ReasonManualStop,
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	old := write("old", 40)
+	keep := write("keep", 1)
+	// A WAV left beside the M4A by a failed conversion goes too.
+	oldWAV := filepath.Join(dir, "old.wav")
+	os.WriteFile(oldWAV, []byte("wav"), 0644)
+
+	logger := log.New(io.Discard, "", 0)
+	c := config.RetentionConfig{MaxAgeDays: 30}
+
+	res, err := Prune(dir, c, now, true, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Planned) != 1 || len(res.Deleted) != 0 {
+		t.Fatalf("dry run: planned %d, deleted %d", len(res.Planned), len(res.Deleted))
+	}
+	if _, err := os.Stat(old); err != nil {
+		t.Fatalf("dry run deleted %s", old)
+	}
+
+	res, err = Prune(dir, c, now, false, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Deleted) != 1 {
+		t.Fatalf("deleted %d recordings, want 1", len(res.Deleted))
+	}
+	for _, path := range []string{old, oldWAV, metadata.SidecarPath(old)} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s still exists", filepath.Base(path))
+		}
+	}
+	if _, err := os.Stat(metadata.SidecarPath(keep)); err != nil {
+		t.Errorf("kept recording's sidecar: %v", err)
+	}
+
+	f, err := os.Open(filepath.Join(dir, LogName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	var logged []LogEntry
+	for sc.Scan() {
+		var le LogEntry
+		if err := json.Unmarshal(sc.Bytes(), &le); err != nil {
+			t.Fatal(err)
+		}
+		logged = append(logged, le)
+	}
+	if len(logged) != 1 || logged[0].Reason != ReasonMaxAge || len(logged[0].Files) != 3 {
+		t.Errorf("deletion log = %+v", logged)
+	}
+
+	ix, err := library.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ix.List(library.Filter{}); len(got) != 1 || got[0].Sidecar != "keep.json" {
+		t.Errorf("index after prune = %v", got)
+	}
+}
+
+func TestPlanEncryptedAfterRebuild(t *testing.T) {
+	dir := t.TempDir()
+	// Stand-ins for encrypted recordings: the index never reads them.
+	seal := func(name string, days int) library.Entry {
+		os.WriteFile(filepath.Join(dir, name+".m4a.enc"), []byte("sealed audio"), 0600)
+		sidecar := filepath.Join(dir, name+".json.enc")
+		os.WriteFile(sidecar, []byte("sealed sidecar"), 0600)
+		end := now.Add(-time.Duration(days) * 24 * time.Hour)
+		if err := library.AddSealed(dir, sidecar, metadata.Recording{
+			StartedAt:          end.Add(-time.Hour),
+			EndedAt:            end,
+			FinalizationReason: metadata.ReasonManualStop,
+		}); err != nil {
+			t.Fatal(err)
+		}
+		ix, err := library.Load(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ix.Find(name)[0]
+	}
+	starred := seal("starred", 40)
+	seal("old", 40)
+	ix, err := library.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ix.SetLabels(starred, true, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	ix, err = library.Rebuild(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := planned(Plan(ix.List(library.Filter{}), config.RetentionConfig{MaxAgeDays: 30}, now))
+	if len(got) != 1 || got["old.json.enc"] != ReasonMaxAge {
+		t.Errorf("planned %v, want only old.json.enc", got)
+	}
+}
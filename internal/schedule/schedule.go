@@ -0,0 +1,289 @@
+// Package schedule decides when recording is allowed: weekly windows,
+// weekly quiet hours and date-specific exceptions, evaluated in a configured
+// time zone. It has no side effects; callers pass the time to evaluate.
+//
+// Precedence, highest first:
+//
+//	exceptions   a date-specific entry covering the time decides; a blocking
+//	             entry wins over an allowing one on the same date
+//	quiet_hours  recording is never allowed
+//	windows      recording is allowed; with no windows, always allowed
+package schedule
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Config is the schedule: block of the configuration file.
+type Config struct {
+	Enabled    bool        `yaml:"enabled"`
+	Timezone   string      `yaml:"timezone"`    // IANA name, e.g. "Europe/Berlin"; empty = local time
+	Windows    []Window    `yaml:"windows"`     // weekly windows when recording is allowed
+	QuietHours []Window    `yaml:"quiet_hours"` // weekly windows when recording is never allowed
+	Exceptions []Exception `yaml:"exceptions"`  // date-specific overrides (holidays, booked slots)
+}
+
+// Window is a weekly time range. An End before Start wraps past midnight
+// into the next day; "24:00" is accepted as an End.
+type Window struct {
+	Days  []string `yaml:"days"`  // mon..sun, weekdays, weekends or daily; empty = every day
+	Start string   `yaml:"start"` // "HH:MM"
+	End   string   `yaml:"end"`   // "HH:MM"
+}
+
+// Exception overrides the weekly rules on one date.
+type Exception struct {
+	Date  string `yaml:"date"`  // "YYYY-MM-DD"
+	Start string `yaml:"start"` // optional; without Start and End the whole day
+	End   string `yaml:"end"`
+	Allow bool   `yaml:"allow"` // true allows recording, false blocks it
+}
+
+// Validate reports whether the configuration can be compiled.
+func (c Config) Validate() error {
+	_, err := Compile(c)
+	return err
+}
+
+// Schedule is a compiled Config. A nil *Schedule allows everything.
+type Schedule struct {
+	loc        *time.Location
+	windows    []window
+	quiet      []window
+	exceptions []exception
+}
+
+type window struct {
+	days       [7]bool // indexed by time.Weekday
+	start, end int     // minutes since midnight; end may be < start (wrap) or 1440
+}
+
+type exception struct {
+	year       int
+	month      time.Month
+	day        int
+	start, end int
+	allow      bool
+}
+
+// Compile parses a Config. It returns nil, nil when the schedule is disabled.
+func Compile(c Config) (*Schedule, error) {
+	if !c.Enabled {
+		return nil, nil
+	}
+	s := &Schedule{loc: time.Local}
+	if c.Timezone != "" {
+		loc, err := time.LoadLocation(c.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("schedule.timezone: %w", err)
+		}
+		s.loc = loc
+	}
+	for i, w := range c.Windows {
+		cw, err := compileWindow(w)
+		if err != nil {
+			return nil, fmt.Errorf("schedule.windows[%d]: %w", i, err)
+		}
+		s.windows = append(s.windows, cw)
+	}
+	for i, w := range c.QuietHours {
+		cw, err := compileWindow(w)
+		if err != nil {
+			return nil, fmt.Errorf("schedule.quiet_hours[%d]: %w", i, err)
+		}
+		s.quiet = append(s.quiet, cw)
+	}
+	for i, x := range c.Exceptions {
+		cx, err := compileException(x, s.loc)
+		if err != nil {
+			return nil, fmt.Errorf("schedule.exceptions[%d]: %w", i, err)
+		}
+		s.exceptions = append(s.exceptions, cx)
+	}
+	return s, nil
+}
+
+var dayNames = map[string][]time.Weekday{
+	"sun":      {time.Sunday},
+	"mon":      {time.Monday},
+	"tue":      {time.Tuesday},
+	"wed":      {time.Wednesday},
+	"thu":      {time.Thursday},
+	"fri":      {time.Friday},
+	"sat":      {time.Saturday},
+	"weekdays": {time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+	"weekends": {time.Saturday, time.Sunday},
+	"daily":    {time.Sunday, time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday},
+}
+
+func compileWindow(w Window) (window, error) {
+	var cw window
+	if len(w.Days) == 0 {
+		w.Days = []string{"daily"}
+	}
+	for _, d := range w.Days {
+		key := strings.ToLower(strings.TrimSpace(d))
+		if len(key) > 3 && dayNames[key] == nil {
+			key = key[:3] // "monday" → "mon"
+		}
+		days, ok := dayNames[key]
+		if !ok {
+			return cw, fmt.Errorf("unknown day %q", d)
+		}
+		for _, wd := range days {
+			cw.days[wd] = true
+		}
+	}
+	var err error
+	if cw.start, err = parseClock(w.Start, false); err != nil {
+		return cw, fmt.Errorf("start: %w", err)
+	}
+	if cw.end, err = parseClock(w.End, true); err != nil {
+		return cw, fmt.Errorf("end: %w", err)
+	}
+	if cw.start == cw.end {
+		return cw, fmt.Errorf("start and end are both %s", w.Start)
+	}
+	return cw, nil
+}
+
+func compileException(x Exception, loc *time.Location) (exception, error) {
+	d, err := time.ParseInLocation("2006-01-02", x.Date, loc)
+	if err != nil {
+		return exception{}, fmt.Errorf("date %q must be YYYY-MM-DD", x.Date)
+	}
+	cx := exception{year: d.Year(), month: d.Month(), day: d.Day(), end: minutesPerDay, allow: x.Allow}
+	if x.Start == "" && x.End == "" {
+		return cx, nil
+	}
+	if cx.start, err = parseClock(x.Start, false); err != nil {
+		return cx, fmt.Errorf("start: %w", err)
+	}
+	if cx.end, err = parseClock(x.End, true); err != nil {
+		return cx, fmt.Errorf("end: %w", err)
+	}
+	if cx.end <= cx.start {
+		return cx, fmt.Errorf("end %s must be after start %s", x.End, x.Start)
+	}
+	return cx, nil
+}
+
+const minutesPerDay = 24 * 60
+
+// parseClock parses "HH:MM" into minutes since midnight. "24:00" is only
+// accepted as an end time.
+func parseClock(s string, isEnd bool) (int, error) {
+	var h, m int
+	if n, err := fmt.Sscanf(s, "%d:%d", &h, &m); err != nil || n != 2 || len(s) < 4 {
+		return 0, fmt.Errorf("%q must be HH:MM", s)
+	}
+	if isEnd && h == 24 && m == 0 {
+		return minutesPerDay, nil
+	}
+	if h < 0 || h > 23 || m < 0 || m > 59 {
+		return 0, fmt.Errorf("%q is not a valid time of day", s)
+	}
+	return h*60 + m, nil
+}
+
+// Location returns the time zone the schedule is evaluated in.
+func (s *Schedule) Location() *time.Location {
+	if s == nil {
+		return time.Local
+	}
+	return s.loc
+}
+
+// Allowed reports whether recording is allowed at t.
+func (s *Schedule) Allowed(t time.Time) bool {
+	if s == nil {
+		return true
+	}
+	t = t.In(s.loc)
+	minute := t.Hour()*60 + t.Minute()
+
+	decided, allow := false, false
+	y, mo, d := t.Date()
+	for _, x := range s.exceptions {
+		if x.year == y && x.month == mo && x.day == d && minute >= x.start && minute < x.end {
+			if !x.allow {
+				return false
+			}
+			decided, allow = true, true
+		}
+	}
+	if decided {
+		return allow
+	}
+
+	wd := t.Weekday()
+	for _, w := range s.quiet {
+		if w.contains(wd, minute) {
+			return false
+		}
+	}
+	if len(s.windows) == 0 {
+		return true
+	}
+	for _, w := range s.windows {
+		if w.contains(wd, minute) {
+			return true
+		}
+	}
+	return false
+}
+
+// contains reports whether the window covers minute on weekday wd,
+// including the part of a wrapping window that started the day before.
+func (w window) contains(wd time.Weekday, minute int) bool {
+	if w.start < w.end {
+		return w.days[wd] && minute >= w.start && minute < w.end
+	}
+	prev := (wd + 6) % 7
+	return (w.days[wd] && minute >= w.start) || (w.days[prev] && minute < w.end)
+}
+
+// Next returns the first time after t at which Allowed changes, and false
+// when it never does (no rule boundary within the following week or in any
+// later exception). Results have minute precision.
+func (s *Schedule) Next(t time.Time) (time.Time, bool) {
+	if s == nil {
+		return time.Time{}, false
+	}
+	now := s.Allowed(t)
+	for _, c := range s.boundaries(t) {
+		if c.After(t) && s.Allowed(c) != now {
+			return c, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// boundaries returns the sorted instants at which a rule starts or ends,
+// from the day before t to a week after it, plus every later exception.
+func (s *Schedule) boundaries(t time.Time) []time.Time {
+	t = t.In(s.loc)
+	var out []time.Time
+	at := func(y int, mo time.Month, d, minute int) {
+		out = append(out, time.Date(y, mo, d, 0, minute, 0, 0, s.loc))
+	}
+	y, mo, d := t.Date()
+	for i := -1; i <= 8; i++ {
+		at(y, mo, d+i, 0)
+		for _, ws := range [][]window{s.windows, s.quiet} {
+			for _, w := range ws {
+				at(y, mo, d+i, w.start)
+				at(y, mo, d+i, w.end%minutesPerDay)
+			}
+		}
+	}
+	for _, x := range s.exceptions {
+		at(x.year, x.month, x.day, x.start)
+		at(x.year, x.month, x.day, x.end)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Before(out[j]) })
+	return out
+}
@@ -0,0 +1,168 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func mustCompile(t *testing.T, c Config) *Schedule {
+	t.Helper()
+	c.Enabled = true
+	s, err := Compile(c)
+	if err != nil {
+		t.Fatalf("Compile: %v", err)
+	}
+	return s
+}
+
+// at returns a time in UTC on the week of Monday 2026-03-02.
+func at(day time.Weekday, hhmm string) time.Time {
+	clock, _ := time.Parse("15:04", hhmm)
+	offset := (int(day) + 6) % 7 // Monday = 0
+	return time.Date(2026, 3, 2+offset, clock.Hour(), clock.Minute(), 0, 0, time.UTC)
+}
+
+func TestDisabledAllowsEverything(t *testing.T) {
+	s, err := Compile(Config{Windows: []Window{{Start: "09:00", End: "17:00"}}})
+	if err != nil || s != nil {
+		t.Fatalf("Compile(disabled) = %v, %v; want nil, nil", s, err)
+	}
+	if !s.Allowed(at(time.Sunday, "03:00")) {
+		t.Error("nil schedule must allow recording")
+	}
+	if _, ok := s.Next(at(time.Sunday, "03:00")); ok {
+		t.Error("nil schedule has no transitions")
+	}
+}
+
+func TestWorkingHours(t *testing.T) {
+	s := mustCompile(t, Config{
+		Timezone:   "UTC",
+		Windows:    []Window{{Days: []string{"weekdays"}, Start: "09:00", End: "18:00"}},
+		QuietHours: []Window{{Days: []string{"fri"}, Start: "12:00", End: "13:00"}},
+	})
+	tests := []struct {
+		t    time.Time
+		want bool
+	}{
+		{at(time.Monday, "08:59"), false},
+		{at(time.Monday, "09:00"), true},
+		{at(time.Wednesday, "17:59"), true},
+		{at(time.Wednesday, "18:00"), false},
+		{at(time.Friday, "12:30"), false}, // quiet hours
+		{at(time.Friday, "13:00"), true},
+		{at(time.Saturday, "10:00"), false},
+	}
+	for _, tc := range tests {
+		if got := s.Allowed(tc.t); got != tc.want {
+			t.Errorf("Allowed(%s) = %v, want %v", tc.t.Format("Mon 15:04"), got, tc.want)
+		}
+	}
+}
+
+func TestWindowWrapsMidnight(t *testing.T) {
+	s := mustCompile(t, Config{
+		Timezone: "UTC",
+		Windows:  []Window{{Days: []string{"friday"}, Start: "22:00", End: "02:00"}},
+	})
+	if !s.Allowed(at(time.Friday, "23:00")) || !s.Allowed(at(time.Saturday, "01:59")) {
+		t.Error("wrapping window should cover Friday night into Saturday")
+	}
+	if s.Allowed(at(time.Saturday, "02:00")) || s.Allowed(at(time.Thursday, "23:00")) {
+		t.Error("wrapping window must end at 02:00 and only start on Friday")
+	}
+}
+
+func TestExceptions(t *testing.T) {
+	s := mustCompile(t, Config{
+		Timezone: "UTC",
+		Windows:  []Window{{Days: []string{"weekdays"}, Start: "09:00", End: "18:00"}},
+		Exceptions: []Exception{
+			{Date: "2026-03-03", Allow: false},                               // Tuesday: holiday
+			{Date: "2026-03-07", Start: "10:00", End: "11:30", Allow: true},  // Saturday: booked slot
+			{Date: "2026-03-04", Start: "09:00", End: "18:00", Allow: true},  // Wednesday: allowed...
+			{Date: "2026-03-04", Start: "14:00", End: "15:00", Allow: false}, // ...except a private slot
+		},
+	})
+	tests := []struct {
+		t    time.Time
+		want bool
+	}{
+		{at(time.Tuesday, "10:00"), false},
+		{at(time.Saturday, "10:15"), true},
+		{at(time.Saturday, "11:30"), false},
+		{at(time.Wednesday, "14:30"), false},
+		{at(time.Wednesday, "15:00"), true},
+	}
+	for _, tc := range tests {
+		if got := s.Allowed(tc.t); got != tc.want {
+			t.Errorf("Allowed(%s) = %v, want %v", tc.t.Format("Mon 15:04"), got, tc.want)
+		}
+	}
+}
+
+func TestTimezone(t *testing.T) {
+	s := mustCompile(t, Config{
+		Timezone: "America/New_York",
+		Windows:  []Window{{Start: "09:00", End: "17:00"}},
+	})
+	// 13:30 UTC is 08:30 in New York (EST); 14:30 UTC is 09:30.
+	if s.Allowed(time.Date(2026, 1, 15, 13, 30, 0, 0, time.UTC)) {
+		t.Error("08:30 New York time should be outside the window")
+	}
+	if !s.Allowed(time.Date(2026, 1, 15, 14, 30, 0, 0, time.UTC)) {
+		t.Error("09:30 New York time should be inside the window")
+	}
+}
+
+func TestNext(t *testing.T) {
+	s := mustCompile(t, Config{
+		Timezone: "UTC",
+		Windows:  []Window{{Days: []string{"weekdays"}, Start: "09:00", End: "18:00"}},
+	})
+	tests := []struct {
+		from, want time.Time
+	}{
+		{at(time.Monday, "10:00"), at(time.Monday, "18:00")},
+		{at(time.Monday, "18:00"), at(time.Tuesday, "09:00")},
+		{at(time.Friday, "19:00"), at(time.Monday, "09:00").AddDate(0, 0, 7)},
+	}
+	for _, tc := range tests {
+		got, ok := s.Next(tc.from)
+		if !ok || !got.Equal(tc.want) {
+			t.Errorf("Next(%s) = %s, %v; want %s", tc.from, got, ok, tc.want)
+		}
+	}
+
+	always := mustCompile(t, Config{Timezone: "UTC"})
+	if _, ok := always.Next(at(time.Monday, "10:00")); ok {
+		t.Error("schedule without rules should never change")
+	}
+	future := mustCompile(t, Config{
+		Timezone:   "UTC",
+		Exceptions: []Exception{{Date: "2026-12-25", Allow: false}},
+	})
+	got, ok := future.Next(at(time.Monday, "10:00"))
+	if want := time.Date(2026, 12, 25, 0, 0, 0, 0, time.UTC); !ok || !got.Equal(want) {
+		t.Errorf("Next with a distant exception = %s, %v; want %s", got, ok, want)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	bad := []Config{
+		{Timezone: "Mars/Olympus"},
+		{Windows: []Window{{Days: []string{"funday"}, Start: "09:00", End: "17:00"}}},
+		{Windows: []Window{{Start: "9am", End: "17:00"}}},
+		{Windows: []Window{{Start: "24:00", End: "17:00"}}},
+		{Windows: []Window{{Start: "09:00", End: "09:00"}}},
+		{QuietHours: []Window{{Start: "12:00", End: "12:75"}}},
+		{Exceptions: []Exception{{Date: "25/12/2026"}}},
+		{Exceptions: []Exception{{Date: "2026-12-25", Start: "15:00", End: "14:00"}}},
+	}
+	for i, c := range bad {
+		c.Enabled = true
+		if err := c.Validate(); err == nil {
+			t.Errorf("config %d: expected error", i)
+		}
+	}
+}
@@ -81,6 +81,9 @@ type StateMachine struct {
 	micReleaseSince time.Time     // non-zero: release debounce timer is running
 	micReleaseDur   time.Duration // how long to hold after mic goes inactive
 
+	// External hold (SetHold): keeps the current recording alive in silence.
+	held bool
+
 	// Callbacks
 	onStateChange func(from, to State)
 	logFn         func(string, ...any)
@@ -112,6 +115,15 @@ func (sm *StateMachine) SetThresholds(enter, exit float64) {
 	sm.exitThreshold = exit
 }
 
+// SetSilenceDuration changes how long silence must last before a
+// recording stops. A silence already running is measured against the new
+// duration.
+func (sm *StateMachine) SetSilenceDuration(d time.Duration) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.silenceDuration = d
+}
+
 // State returns the current state.
 func (sm *StateMachine) CurrentState() State {
 	sm.mu.RLock()
@@ -239,6 +251,10 @@ func (sm *StateMachine) ProcessAudio(rmsLevel float64, threshold float64) Action
 			sm.logf("state=recording reason=blackhole_active_resumed")
 			return ActionContinue
 		}
+		// An external hold (SetHold) keeps the session open quietly.
+		if sm.held {
+			return ActionContinue
+		}
 		// Mic lock holds the session open regardless of silence duration.
 		if sm.micLockActive {
 			sm.logf("state=silence_wait mic_lock=true silence=%s", time.Since(sm.silenceStart).Truncate(time.Second))
@@ -274,6 +290,7 @@ func (sm *StateMachine) Reset() {
 	sm.armingStart = time.Time{}
 	sm.micLockActive = false
 	sm.micReleaseSince = time.Time{}
+	sm.held = false
 }
 
 // EnterError transitions to the error state.
@@ -375,6 +392,15 @@ func (sm *StateMachine) ForceStartRecording() Action {
 	return ActionStartRecording
 }
 
+// SetHold holds a recording session open through silence, like the mic
+// session lock, for as long as held is true. Used by trigger policies that
+// keep a session going without audio (e.g. a silent Zoom call).
+func (sm *StateMachine) SetHold(held bool) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.held = held
+}
+
 // transition changes state and fires the callback.
 func (sm *StateMachine) transition(to State) {
 	from := sm.state
@@ -105,6 +105,38 @@ func TestSilenceThresholdStopsRecording(t *testing.T) {
 	}
 }
 
+func TestSetHold(t *testing.T) {
+	sm := New(10*time.Millisecond, 0)
+	sm.ProcessAudio(0.05, 0.02) // idle → arming
+	sm.ProcessAudio(0.05, 0.02) // arming → recording
+	sm.SetHold(true)
+	sm.ProcessAudio(0.001, 0.02) // recording → silence_wait
+	time.Sleep(15 * time.Millisecond)
+	if action := sm.ProcessAudio(0.001, 0.02); action != ActionContinue {
+		t.Errorf("held past silence threshold: got %s, want %s", action, ActionContinue)
+	}
+	if sm.CurrentState() != StateSilenceWait {
+		t.Errorf("held state: got %s, want %s", sm.CurrentState(), StateSilenceWait)
+	}
+
+	sm.SetHold(false)
+	if action := sm.ProcessAudio(0.001, 0.02); action != ActionStopRecording {
+		t.Errorf("after release: got %s, want %s", action, ActionStopRecording)
+	}
+}
+
+func TestSetSilenceDuration(t *testing.T) {
+	sm := New(time.Hour, 0)
+	sm.ProcessAudio(0.05, 0.02)  // idle → arming
+	sm.ProcessAudio(0.05, 0.02)  // arming → recording
+	sm.ProcessAudio(0.001, 0.02) // recording → silence_wait
+	sm.SetSilenceDuration(10 * time.Millisecond)
+	time.Sleep(15 * time.Millisecond)
+	if action := sm.ProcessAudio(0.001, 0.02); action != ActionStopRecording {
+		t.Errorf("after shortening silence duration: got %s, want %s", action, ActionStopRecording)
+	}
+}
+
 func TestReset(t *testing.T) {
 	sm := New(10*time.Millisecond, 0)
 
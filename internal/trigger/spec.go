@@ -0,0 +1,79 @@
+package trigger
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Spec is the trigger: block of the configuration file, a tree of policies.
+// An empty Kind leaves start and stop to the audio level logic alone.
+//
+//	kind: audio | mic | app | schedule | any_of | all_of | hold_while
+//	app, in_call:  for app
+//	policies:      for any_of and all_of
+//	policy:        for hold_while
+type Spec struct {
+	Kind     string `yaml:"kind"`
+	App      string `yaml:"app,omitempty"`
+	InCall   bool   `yaml:"in_call,omitempty"`
+	Policies []Spec `yaml:"policies,omitempty"`
+	Policy   *Spec  `yaml:"policy,omitempty"`
+}
+
+// Validate reports whether the spec can be compiled.
+func (s Spec) Validate() error {
+	_, err := Compile(s)
+	return err
+}
+
+// Compile builds the policy described by s. It returns nil, nil when Kind
+// is empty.
+func Compile(s Spec) (Policy, error) {
+	if s.Kind == "" {
+		return nil, nil
+	}
+	return compile(s, "trigger")
+}
+
+func compile(s Spec, path string) (Policy, error) {
+	switch strings.ToLower(s.Kind) {
+	case "audio":
+		return Audio(), nil
+	case "mic":
+		return Mic(), nil
+	case "schedule":
+		return Schedule(), nil
+	case "app":
+		if s.App == "" {
+			return nil, fmt.Errorf("%s: app policy needs app (zoom, teams, meet or a bundle ID)", path)
+		}
+		return App(s.App, s.InCall), nil
+	case "any_of", "all_of":
+		if len(s.Policies) == 0 {
+			return nil, fmt.Errorf("%s: %s needs at least one entry in policies", path, s.Kind)
+		}
+		policies := make([]Policy, len(s.Policies))
+		for i, sub := range s.Policies {
+			p, err := compile(sub, fmt.Sprintf("%s.policies[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			policies[i] = p
+		}
+		if strings.ToLower(s.Kind) == "any_of" {
+			return AnyOf(policies...), nil
+		}
+		return AllOf(policies...), nil
+	case "hold_while":
+		if s.Policy == nil {
+			return nil, fmt.Errorf("%s: hold_while needs policy", path)
+		}
+		p, err := compile(*s.Policy, path+".policy")
+		if err != nil {
+			return nil, err
+		}
+		return HoldWhile(p), nil
+	default:
+		return nil, fmt.Errorf("%s: unknown kind %q", path, s.Kind)
+	}
+}
@@ -0,0 +1,173 @@
+// Package trigger decides when a recording session starts and stops. A
+// Policy looks at one set of Signals (audio, microphone, meeting apps,
+// schedule) and returns a Decision; policies compose with AnyOf, AllOf and
+// HoldWhile. The package has no side effects, so policies can be tested
+// without the engine.
+package trigger
+
+import (
+	"strings"
+	"time"
+)
+
+// Decision is a policy's verdict. Decisions are ordered: AnyOf takes the
+// highest, AllOf the lowest.
+type Decision int
+
+const (
+	// Stop ends a session and prevents one from starting.
+	Stop Decision = iota - 1
+	// None has no reason to record: a session is not started and a
+	// running one ends.
+	None
+	// Hold keeps a running session going but does not start one.
+	Hold
+	// Start starts a session, or keeps the running one going.
+	Start
+)
+
+// String returns the decision's name.
+func (d Decision) String() string {
+	switch d {
+	case Stop:
+		return "stop"
+	case None:
+		return "none"
+	case Hold:
+		return "hold"
+	case Start:
+		return "start"
+	default:
+		return "unknown"
+	}
+}
+
+// Signals is what a policy sees at one evaluation.
+type Signals struct {
+	Now time.Time
+	// Audio is true while the audio level logic wants to record: sound
+	// above the threshold for the activation window, or a session within
+	// its silence timeout (or held by the mic session lock).
+	Audio        bool
+	MicActive    bool     // a meeting app is using the microphone
+	InCall       []string // meeting apps in a call
+	Apps         []string // running meeting apps and bundle IDs using the mic
+	ScheduleOpen bool     // the recording schedule allows recording
+	Recording    bool     // a session is being recorded
+}
+
+// hasApp reports whether app is among the running apps, ignoring case.
+func (s Signals) hasApp(app string) bool {
+	return contains(s.Apps, app)
+}
+
+// inCall reports whether app is among the apps in a call, ignoring case.
+func (s Signals) inCall(app string) bool {
+	return contains(s.InCall, app)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Policy turns signals into a decision.
+type Policy interface {
+	Decide(Signals) Decision
+}
+
+// PolicyFunc adapts a function to a Policy.
+type PolicyFunc func(Signals) Decision
+
+// Decide calls f.
+func (f PolicyFunc) Decide(s Signals) Decision { return f(s) }
+
+// Audio starts a session while the audio level logic wants to record.
+func Audio() Policy {
+	return PolicyFunc(func(s Signals) Decision {
+		if s.Audio {
+			return Start
+		}
+		return None
+	})
+}
+
+// Mic starts a session while a meeting app uses the microphone.
+func Mic() Policy {
+	return PolicyFunc(func(s Signals) Decision {
+		if s.MicActive {
+			return Start
+		}
+		return None
+	})
+}
+
+// App starts a session while app (a monitoring.apps name or a bundle ID)
+// is running. With inCall, the app must be in a call instead, as the
+// monitor reports it.
+func App(app string, inCall bool) Policy {
+	return PolicyFunc(func(s Signals) Decision {
+		running := s.hasApp(app)
+		if inCall {
+			running = s.inCall(app)
+		}
+		if running {
+			return Start
+		}
+		return None
+	})
+}
+
+// Schedule allows recording while the schedule is open and stops it when
+// it closes. Combine it with AllOf; on its own it records all day.
+func Schedule() Policy {
+	return PolicyFunc(func(s Signals) Decision {
+		if s.ScheduleOpen {
+			return Start
+		}
+		return Stop
+	})
+}
+
+// AnyOf returns the highest decision of its policies, None if it has none.
+func AnyOf(policies ...Policy) Policy {
+	return PolicyFunc(func(s Signals) Decision {
+		if len(policies) == 0 {
+			return None
+		}
+		d := Stop
+		for _, p := range policies {
+			d = max(d, p.Decide(s))
+		}
+		return d
+	})
+}
+
+// AllOf returns the lowest decision of its policies, None if it has none.
+func AllOf(policies ...Policy) Policy {
+	return PolicyFunc(func(s Signals) Decision {
+		if len(policies) == 0 {
+			return None
+		}
+		d := Start
+		for _, p := range policies {
+			d = min(d, p.Decide(s))
+		}
+		return d
+	})
+}
+
+// HoldWhile keeps a session going while p would start or hold one, without
+// letting p start a session itself.
+func HoldWhile(p Policy) Policy {
+	return PolicyFunc(func(s Signals) Decision {
+		if p.Decide(s) >= Hold {
+			return Hold
+		}
+		return None
+	})
+}
@@ -0,0 +1,124 @@
+package trigger
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestLeaves(t *testing.T) {
+	tests := []struct {
+		name string
+		p    Policy
+		s    Signals
+		want Decision
+	}{
+		{"audio on", Audio(), Signals{Audio: true}, Start},
+		{"audio off", Audio(), Signals{}, None},
+		{"mic on", Mic(), Signals{MicActive: true}, Start},
+		{"app running", App("teams", false), Signals{Apps: []string{"Teams"}}, Start},
+		{"app not running", App("teams", false), Signals{Apps: []string{"zoom"}}, None},
+		{"bundle id", App("com.microsoft.teams2", false), Signals{Apps: []string{"com.microsoft.teams2"}}, Start},
+		{"zoom open, not in call", App("zoom", true), Signals{Apps: []string{"zoom"}}, None},
+		{"zoom in call", App("zoom", true), Signals{Apps: []string{"zoom"}, InCall: []string{"zoom"}}, Start},
+		{"teams not in call", App("teams", true), Signals{Apps: []string{"teams"}, InCall: []string{"zoom"}}, None},
+		{"teams in call", App("teams", true), Signals{Apps: []string{"teams"}, InCall: []string{"teams"}}, Start},
+		{"schedule open", Schedule(), Signals{ScheduleOpen: true}, Start},
+		{"schedule closed", Schedule(), Signals{}, Stop},
+	}
+	for _, tc := range tests {
+		if got := tc.p.Decide(tc.s); got != tc.want {
+			t.Errorf("%s: Decide() = %s, want %s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestComposites(t *testing.T) {
+	fixed := func(d Decision) Policy { return PolicyFunc(func(Signals) Decision { return d }) }
+	tests := []struct {
+		name string
+		p    Policy
+		want Decision
+	}{
+		{"any_of picks highest", AnyOf(fixed(None), fixed(Hold), fixed(Stop)), Hold},
+		{"any_of start", AnyOf(fixed(Stop), fixed(Start)), Start},
+		{"all_of picks lowest", AllOf(fixed(Start), fixed(Hold)), Hold},
+		{"all_of stop wins", AllOf(fixed(Start), fixed(Stop)), Stop},
+		{"empty any_of", AnyOf(), None},
+		{"empty all_of", AllOf(), None},
+		{"hold_while start", HoldWhile(fixed(Start)), Hold},
+		{"hold_while hold", HoldWhile(fixed(Hold)), Hold},
+		{"hold_while none", HoldWhile(fixed(None)), None},
+		{"hold_while stop", HoldWhile(fixed(Stop)), None},
+	}
+	for _, tc := range tests {
+		if got := tc.p.Decide(Signals{}); got != tc.want {
+			t.Errorf("%s: Decide() = %s, want %s", tc.name, got, tc.want)
+		}
+	}
+}
+
+// "Never start on audio alone": the mic starts a session, audio only keeps
+// it going through the silence timeout.
+func TestNeverStartOnAudioAlone(t *testing.T) {
+	p := AnyOf(Mic(), HoldWhile(Audio()))
+	if got := p.Decide(Signals{Audio: true}); got != Hold {
+		t.Errorf("audio alone = %s, want hold", got)
+	}
+	if got := p.Decide(Signals{MicActive: true}); got != Start {
+		t.Errorf("mic = %s, want start", got)
+	}
+	if got := p.Decide(Signals{}); got != None {
+		t.Errorf("nothing = %s, want none", got)
+	}
+}
+
+func TestCompile(t *testing.T) {
+	src := `
+kind: all_of
+policies:
+  - kind: schedule
+  - kind: any_of
+    policies:
+      - kind: audio
+      - kind: app
+        app: zoom
+        in_call: true
+`
+	var spec Spec
+	if err := yaml.Unmarshal([]byte(src), &spec); err != nil {
+		t.Fatal(err)
+	}
+	p, err := Compile(spec)
+	if err != nil {
+		t.Fatalf("Compile() error: %v", err)
+	}
+	// Zoom in call records even when silent, but not outside the schedule.
+	if got := p.Decide(Signals{InCall: []string{"zoom"}, ScheduleOpen: true}); got != Start {
+		t.Errorf("zoom call, schedule open = %s, want start", got)
+	}
+	if got := p.Decide(Signals{InCall: []string{"zoom"}}); got != Stop {
+		t.Errorf("zoom call, schedule closed = %s, want stop", got)
+	}
+	if got := p.Decide(Signals{ScheduleOpen: true}); got != None {
+		t.Errorf("silent, no call = %s, want none", got)
+	}
+
+	if p, err := Compile(Spec{}); p != nil || err != nil {
+		t.Errorf("Compile(empty) = %v, %v; want nil, nil", p, err)
+	}
+}
+
+func TestCompile_Invalid(t *testing.T) {
+	for _, spec := range []Spec{
+		{Kind: "sometimes"},
+		{Kind: "app"},
+		{Kind: "any_of"},
+		{Kind: "hold_while"},
+		{Kind: "all_of", Policies: []Spec{{Kind: "audio"}, {Kind: "bogus"}}},
+	} {
+		if err := spec.Validate(); err == nil {
+			t.Errorf("Validate(%+v) = nil, want error", spec)
+		}
+	}
+}
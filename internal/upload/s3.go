@@ -0,0 +1,340 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// metaSHA256 is the object metadata holding the file's SHA-256, checked
+// after the upload and kept for later audits.
+const metaSHA256 = "x-amz-meta-sha256"
+
+// S3 uploads to an S3-compatible bucket with Signature Version 4. Files up
+// to PartSize are sent in one PUT, larger ones as a multipart upload.
+type S3 struct {
+	Endpoint      *url.URL
+	Region        string
+	Bucket        string
+	AccessKey     string
+	SecretKey     string
+	VirtualHosted bool
+	PartSize      int64
+	Client        *http.Client
+	now           func() time.Time
+}
+
+func (s *S3) String() string {
+	return "s3://" + s.Bucket
+}
+
+// Upload stores f under key. Every request carries the SHA-256 of its
+// payload and the MD5 of each part, which the server checks; unless the
+// bucket encrypts with KMS or customer keys, whose ETags are not MD5s, the
+// ETags it returns are compared with the local MD5s as well. A final HEAD
+// must report the file's size and SHA-256.
+func (s *S3) Upload(ctx context.Context, key string, f File) error {
+	in, err := os.Open(f.Path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if f.Size <= s.PartSize {
+		body, err := io.ReadAll(in)
+		if err != nil {
+			return err
+		}
+		sum := md5.Sum(body)
+		resp, err := s.do(ctx, http.MethodPut, key, nil, body, http.Header{
+			"Content-Md5": {base64.StdEncoding.EncodeToString(sum[:])},
+			metaSHA256:    {f.SHA256},
+		})
+		if err != nil {
+			return err
+		}
+		if etag := unquote(resp.Header.Get("ETag")); etagIsMD5(resp.Header) && etag != hex.EncodeToString(sum[:]) {
+			return fmt.Errorf("s3 put %s: ETag %q does not match the local MD5", key, etag)
+		}
+	} else if err := s.multipart(ctx, key, f, in); err != nil {
+		return err
+	}
+	return s.verify(ctx, key, f)
+}
+
+func (s *S3) multipart(ctx context.Context, key string, f File, in io.Reader) error {
+	resp, err := s.do(ctx, http.MethodPost, key, url.Values{"uploads": {""}}, nil, http.Header{metaSHA256: {f.SHA256}})
+	if err != nil {
+		return err
+	}
+	var initiated struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.Unmarshal(resp.body, &initiated); err != nil || initiated.UploadID == "" {
+		return fmt.Errorf("s3 initiate %s: no upload ID in response", key)
+	}
+	id := initiated.UploadID
+
+	err = s.sendParts(ctx, key, id, in)
+	if err != nil {
+		// Best effort: the bucket's lifecycle rules clean up otherwise.
+		s.do(context.Background(), http.MethodDelete, key, url.Values{"uploadId": {id}}, nil, nil)
+	}
+	return err
+}
+
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+func (s *S3) sendParts(ctx context.Context, key, id string, in io.Reader) error {
+	buf := make([]byte, s.PartSize)
+	var parts []completedPart
+	var sums []byte
+	for n := 1; ; n++ {
+		k, err := io.ReadFull(in, buf)
+		if err == io.EOF {
+			break
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		sum := md5.Sum(buf[:k])
+		q := url.Values{"partNumber": {strconv.Itoa(n)}, "uploadId": {id}}
+		resp, perr := s.do(ctx, http.MethodPut, key, q, buf[:k], http.Header{
+			"Content-Md5": {base64.StdEncoding.EncodeToString(sum[:])},
+		})
+		if perr != nil {
+			return perr
+		}
+		etag := unquote(resp.Header.Get("ETag"))
+		if etagIsMD5(resp.Header) && etag != hex.EncodeToString(sum[:]) {
+			return fmt.Errorf("s3 part %d of %s: ETag %q does not match the local MD5", n, key, etag)
+		}
+		parts = append(parts, completedPart{PartNumber: n, ETag: `"` + etag + `"`})
+		sums = append(sums, sum[:]...)
+		if err == io.ErrUnexpectedEOF {
+			break
+		}
+	}
+
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(ctx, http.MethodPost, key, url.Values{"uploadId": {id}}, body, nil)
+	if err != nil {
+		return err
+	}
+	// Completion can fail after the 200 status line was sent, with an
+	// Error document as the body.
+	var done struct {
+		XMLName xml.Name
+		ETag    string `xml:"ETag"`
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if err := xml.Unmarshal(resp.body, &done); err != nil {
+		return fmt.Errorf("s3 complete %s: %w", key, err)
+	}
+	if done.XMLName.Local == "Error" {
+		return fmt.Errorf("s3 complete %s: %s: %s", key, done.Code, done.Message)
+	}
+	whole := md5.Sum(sums)
+	if want := fmt.Sprintf("%x-%d", whole, len(parts)); etagIsMD5(resp.Header) && unquote(done.ETag) != want {
+		return fmt.Errorf("s3 complete %s: ETag %q, want %q", key, unquote(done.ETag), want)
+	}
+	return nil
+}
+
+// verify checks the stored object's size and SHA-256 metadata.
+func (s *S3) verify(ctx context.Context, key string, f File) error {
+	resp, err := s.do(ctx, http.MethodHead, key, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	if resp.ContentLength != f.Size {
+		return fmt.Errorf("s3 verify %s: size %d, want %d", key, resp.ContentLength, f.Size)
+	}
+	if got := resp.Header.Get(metaSHA256); got != f.SHA256 {
+		return fmt.Errorf("s3 verify %s: sha256 %q, want %q", key, got, f.SHA256)
+	}
+	return nil
+}
+
+// s3Response is a response with its body read.
+type s3Response struct {
+	*http.Response
+	body []byte
+}
+
+// do sends a signed request and fails on a non-2xx status.
+func (s *S3) do(ctx context.Context, method, key string, query url.Values, body []byte, header http.Header) (*s3Response, error) {
+	u := *s.Endpoint
+	objectPath := "/" + s.Bucket + "/" + key
+	if s.VirtualHosted {
+		u.Host = s.Bucket + "." + u.Host
+		objectPath = "/" + key
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + objectPath
+	u.RawPath = uriEncode(u.Path, false)
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	for k, v := range header {
+		req.Header[http.CanonicalHeaderKey(k)] = v
+	}
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	sum := sha256.Sum256(body)
+	sign(req, hex.EncodeToString(sum[:]), s.AccessKey, s.SecretKey, s.Region, now())
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		var e struct {
+			Code    string `xml:"Code"`
+			Message string `xml:"Message"`
+		}
+		xml.Unmarshal(data, &e)
+		return nil, fmt.Errorf("s3 %s %s: %s %s %s", strings.ToLower(method), key, resp.Status, e.Code, e.Message)
+	}
+	return &s3Response{Response: resp, body: data}, nil
+}
+
+// sign adds Signature Version 4 headers to req. The host, Content-MD5,
+// Range and all x-amz-* headers are signed.
+func sign(req *http.Request, payloadHash, accessKey, secretKey, region string, t time.Time) {
+	t = t.UTC()
+	amzDate := t.Format("20060102T150405Z")
+	day := t.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		lk := strings.ToLower(k)
+		if strings.HasPrefix(lk, "x-amz-") || lk == "content-md5" || lk == "range" {
+			headers[lk] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonHeaders strings.Builder
+	for _, k := range names {
+		canonHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signed := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonHeaders.String(),
+		signed,
+		payloadHash,
+	}, "\n")
+	scope := day + "/" + region + "/s3/aws4_request"
+	hashed := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), day)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	sig := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signed, sig))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// canonicalQuery encodes query sorted by key, as SigV4 requires.
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode percent-encodes everything but unreserved characters and,
+// unless encodeSlash is set, '/'.
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// etagIsMD5 reports whether the ETag of a response with header h is the
+// MD5 of the data (or of the part MD5s), which is not the case for objects
+// encrypted with SSE-KMS or SSE-C.
+func etagIsMD5(h http.Header) bool {
+	if h.Get("X-Amz-Server-Side-Encryption-Customer-Algorithm") != "" {
+		return false
+	}
+	return !strings.HasPrefix(h.Get("X-Amz-Server-Side-Encryption"), "aws:kms")
+}
+
+func unquote(etag string) string {
+	return strings.Trim(etag, `"`)
+}
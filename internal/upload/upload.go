@@ -0,0 +1,425 @@
+// Package upload copies finalized recordings to remote storage. The engine
+// enqueues each recording's files when it finalizes a session; the queue
+// is kept in the output directory, so uploads that fail or are cut short
+// by a restart are retried with backoff until they succeed.
+//
+// A file counts as uploaded once the target has verified the stored copy
+// against the local SHA-256. With delete_local, a recording's files are
+// deleted after all of them are uploaded, its media first and its sidecar
+// last.
+package upload
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tiroq/memofy/internal/config"
+	"github.com/tiroq/memofy/internal/library"
+	"github.com/tiroq/memofy/internal/recpath"
+)
+
+// QueueName is the queue file inside the output directory.
+const QueueName = ".memofy-upload-queue.json"
+
+// File is a local file to upload.
+type File struct {
+	Path   string
+	Size   int64
+	SHA256 string // hex
+}
+
+// Target stores files remotely.
+type Target interface {
+	// Upload stores f under key and returns once the stored copy is
+	// verified.
+	Upload(ctx context.Context, key string, f File) error
+	String() string
+}
+
+// NewTarget returns the target c configures.
+func NewTarget(c config.UploadConfig) (Target, error) {
+	client := &http.Client{Timeout: 30 * time.Minute}
+	switch c.Target {
+	case "s3":
+		u, err := url.Parse(c.S3.Endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("upload.s3.endpoint: %w", err)
+		}
+		return &S3{
+			Endpoint:      u,
+			Region:        c.S3.Region,
+			Bucket:        c.S3.Bucket,
+			AccessKey:     os.ExpandEnv(c.S3.AccessKeyID),
+			SecretKey:     os.ExpandEnv(c.S3.SecretAccessKey),
+			VirtualHosted: c.S3.VirtualHosted,
+			PartSize:      int64(c.S3.PartSizeMB) * 1024 * 1024,
+			Client:        client,
+		}, nil
+	case "webdav":
+		u, err := url.Parse(c.WebDAV.URL)
+		if err != nil {
+			return nil, fmt.Errorf("upload.webdav.url: %w", err)
+		}
+		return &WebDAV{
+			Base:     u,
+			Username: os.ExpandEnv(c.WebDAV.Username),
+			Password: os.ExpandEnv(c.WebDAV.Password),
+			Client:   client,
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown upload target %q", c.Target)
+}
+
+// Item is one recording in the queue.
+type Item struct {
+	Files     []string  `json:"files"` // relative to the output directory, sidecar last
+	Done      int       `json:"done"`  // files uploaded and verified
+	Attempts  int       `json:"attempts"`
+	NextTry   time.Time `json:"next_try"`
+	LastError string    `json:"last_error,omitempty"`
+	FailedAt  time.Time `json:"failed_at,omitempty"`
+	Added     time.Time `json:"added"`
+}
+
+// queue is the content of the queue file.
+type queue struct {
+	Items        []Item    `json:"items"`
+	LastUploaded string    `json:"last_uploaded,omitempty"`
+	LastUploadAt time.Time `json:"last_upload_at,omitempty"`
+}
+
+// Status summarizes the queue.
+type Status struct {
+	Pending      int    // recordings not fully uploaded
+	Failing      int    // of those, recordings whose last attempt failed
+	LastError    string // error of the most recent failure
+	LastUploaded string // most recently uploaded recording
+	LastUploadAt time.Time
+}
+
+// String summarizes s on one line, e.g. for memofy status.
+func (s Status) String() string {
+	if s.Pending == 0 {
+		if s.LastUploaded == "" {
+			return "up to date"
+		}
+		return fmt.Sprintf("up to date, last %s at %s", s.LastUploaded, s.LastUploadAt.Local().Format("Jan 2 15:04"))
+	}
+	str := fmt.Sprintf("%d pending", s.Pending)
+	if s.Failing > 0 {
+		str += fmt.Sprintf(", %d failing: %s", s.Failing, s.LastError)
+	}
+	return str
+}
+
+// Retry delays double from minRetry up to maxRetry.
+const (
+	minRetry = 30 * time.Second
+	maxRetry = time.Hour
+)
+
+// backoff returns the delay after the given number of failed attempts.
+func backoff(attempts int) time.Duration {
+	d := minRetry
+	for i := 1; i < attempts && d < maxRetry; i++ {
+		d *= 2
+	}
+	return min(d, maxRetry)
+}
+
+// Uploader works through the queue of one output directory.
+type Uploader struct {
+	root        string
+	prefix      string
+	deleteLocal bool
+	target      Target
+	logger      *log.Logger
+	now         func() time.Time
+	wake        chan struct{}
+
+	mu sync.Mutex
+	q  queue
+}
+
+// New returns an uploader for the output directory root and loads its
+// queue.
+func New(c config.UploadConfig, root string, logger *log.Logger) (*Uploader, error) {
+	target, err := NewTarget(c)
+	if err != nil {
+		return nil, err
+	}
+	u := newUploader(root, target, logger)
+	u.prefix, u.deleteLocal = c.Prefix, c.DeleteLocal
+	q, err := readQueue(root)
+	if err != nil {
+		return nil, err
+	}
+	u.q = q
+	return u, nil
+}
+
+func newUploader(root string, target Target, logger *log.Logger) *Uploader {
+	return &Uploader{
+		root:   root,
+		target: target,
+		logger: logger,
+		now:    time.Now,
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+func readQueue(root string) (queue, error) {
+	var q queue
+	data, err := os.ReadFile(filepath.Join(root, QueueName))
+	if os.IsNotExist(err) {
+		return q, nil
+	}
+	if err != nil {
+		return q, fmt.Errorf("read upload queue: %w", err)
+	}
+	if err := json.Unmarshal(data, &q); err != nil {
+		return q, fmt.Errorf("read upload queue: %w", err)
+	}
+	return q, nil
+}
+
+// ReadStatus returns the status of the queue in the output directory root
+// as the daemon last saved it.
+func ReadStatus(root string) (Status, error) {
+	q, err := readQueue(root)
+	return q.status(), err
+}
+
+func (q queue) status() Status {
+	st := Status{Pending: len(q.Items), LastUploaded: q.LastUploaded, LastUploadAt: q.LastUploadAt}
+	var last time.Time
+	for _, it := range q.Items {
+		if it.LastError == "" {
+			continue
+		}
+		st.Failing++
+		if !it.FailedAt.Before(last) {
+			last, st.LastError = it.FailedAt, it.LastError
+		}
+	}
+	return st
+}
+
+// Status returns the current queue status.
+func (u *Uploader) Status() Status {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.q.status()
+}
+
+// saveLocked writes the queue file atomically. Caller must hold u.mu.
+func (u *Uploader) saveLocked() error {
+	data, err := json.MarshalIndent(u.q, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(u.root, QueueName)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("write upload queue: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("write upload queue: %w", err)
+	}
+	return nil
+}
+
+// Enqueue adds a recording's files, which must be inside the output
+// directory, with the sidecar last.
+func (u *Uploader) Enqueue(files ...string) error {
+	if len(files) == 0 {
+		return nil
+	}
+	it := Item{Added: u.now()}
+	for _, f := range files {
+		rel, err := filepath.Rel(u.root, f)
+		if err != nil || !filepath.IsLocal(rel) {
+			return fmt.Errorf("upload %s: not inside %s", f, u.root)
+		}
+		it.Files = append(it.Files, rel)
+	}
+	u.mu.Lock()
+	u.q.Items = append(u.q.Items, it)
+	err := u.saveLocked()
+	u.mu.Unlock()
+	select {
+	case u.wake <- struct{}{}:
+	default:
+	}
+	return err
+}
+
+// Run uploads queued recordings until stop is closed. An upload in
+// progress is cancelled then and retried on the next run.
+func (u *Uploader) Run(stop <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-stop
+		cancel()
+	}()
+	for {
+		wait := u.processDue(ctx)
+		timer := time.NewTimer(wait)
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-u.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// processDue uploads every queued recording that is due and returns how
+// long to wait before the next one is.
+func (u *Uploader) processDue(ctx context.Context) time.Duration {
+	wait := maxRetry
+	for i := 0; ; {
+		u.mu.Lock()
+		if i >= len(u.q.Items) {
+			u.mu.Unlock()
+			return wait
+		}
+		it := u.q.Items[i]
+		u.mu.Unlock()
+		if d := it.NextTry.Sub(u.now()); d > 0 {
+			wait = min(wait, d)
+			i++
+			continue
+		}
+		if ctx.Err() != nil {
+			return wait
+		}
+		if !u.process(ctx, &it) {
+			wait = min(wait, it.NextTry.Sub(u.now()))
+			i++
+		}
+	}
+}
+
+// process uploads the remaining files of it and reports whether it left
+// the queue.
+func (u *Uploader) process(ctx context.Context, it *Item) (done bool) {
+	for it.Done < len(it.Files) {
+		rel := it.Files[it.Done]
+		err := u.uploadFile(ctx, rel)
+		if os.IsNotExist(err) {
+			// Deleted by hand or by retention before it was uploaded.
+			u.logger.Printf("[upload] %s is gone, dropping it from the queue", rel)
+			u.finish(it, false)
+			return true
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return false
+			}
+			it.Attempts++
+			it.LastError, it.FailedAt = err.Error(), u.now()
+			it.NextTry = it.FailedAt.Add(backoff(it.Attempts))
+			u.logger.Printf("[upload] %s failed (attempt %d, retry in %s): %v", rel, it.Attempts, backoff(it.Attempts), err)
+			u.update(it)
+			return false
+		}
+		it.Done++
+		u.update(it)
+	}
+	u.logger.Printf("[upload] uploaded %s to %s", it.Files[0], u.target)
+	u.finish(it, true)
+	return true
+}
+
+func (u *Uploader) uploadFile(ctx context.Context, rel string) error {
+	p := filepath.Join(u.root, rel)
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	key := path.Join(u.prefix, filepath.ToSlash(rel))
+	return u.target.Upload(ctx, strings.TrimPrefix(key, "/"), File{Path: p, Size: size, SHA256: hex.EncodeToString(h.Sum(nil))})
+}
+
+// update stores the progress of it in the queue.
+func (u *Uploader) update(it *Item) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for i := range u.q.Items {
+		if u.q.Items[i].Files[0] == it.Files[0] {
+			u.q.Items[i] = *it
+		}
+	}
+	if err := u.saveLocked(); err != nil {
+		u.logger.Printf("[upload] %v", err)
+	}
+}
+
+// finish removes it from the queue. After a successful upload with
+// delete_local, the local files are deleted.
+func (u *Uploader) finish(it *Item, uploaded bool) {
+	if uploaded && u.deleteLocal {
+		u.deleteFiles(it.Files)
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for i := range u.q.Items {
+		if u.q.Items[i].Files[0] == it.Files[0] {
+			u.q.Items = append(u.q.Items[:i], u.q.Items[i+1:]...)
+			break
+		}
+	}
+	if uploaded {
+		u.q.LastUploaded, u.q.LastUploadAt = it.Files[0], u.now()
+	}
+	if err := u.saveLocked(); err != nil {
+		u.logger.Printf("[upload] %v", err)
+	}
+}
+
+// deleteFiles deletes an uploaded recording in order, stopping at the
+// first failure so the sidecar is never deleted before the media, and
+// drops it from the library index.
+func (u *Uploader) deleteFiles(files []string) {
+	for _, rel := range files {
+		if err := os.Remove(filepath.Join(u.root, rel)); err != nil && !os.IsNotExist(err) {
+			u.logger.Printf("[upload] delete_local: %v", err)
+			return
+		}
+	}
+	sidecar := files[len(files)-1]
+	if recpath.Encrypted(sidecar) {
+		os.Remove(library.CompanionPath(filepath.Join(u.root, sidecar)))
+	}
+	ix, err := library.Load(u.root)
+	if err == nil {
+		err = ix.Remove(library.Entry{Sidecar: sidecar})
+	}
+	if err != nil {
+		u.logger.Printf("[upload] delete_local: index: %v", err)
+	}
+	u.logger.Printf("[upload] deleted local copy of %s", files[0])
+}
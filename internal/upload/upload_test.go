@@ -0,0 +1,166 @@
+package upload
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tiroq/memofy/internal/library"
+	"github.com/tiroq/memofy/internal/metadata"
+)
+
+var t0 = time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+
+// fakeTarget records uploads and fails the first fail of them.
+type fakeTarget struct {
+	mu   sync.Mutex
+	keys []string
+	fail int
+}
+
+func (f *fakeTarget) Upload(ctx context.Context, key string, file File) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.fail > 0 {
+		f.fail--
+		return errors.New("connection refused")
+	}
+	f.keys = append(f.keys, key)
+	return nil
+}
+
+func (f *fakeTarget) String() string { return "fake" }
+
+// recording writes a recording and its indexed sidecar under root and
+// returns their paths.
+func recording(t *testing.T, root, name string) (string, string) {
+	t.Helper()
+	path := filepath.Join(root, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("audio"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := metadata.Write(path, metadata.Recording{StartedAt: t0, EndedAt: t0.Add(time.Minute), FinalizationReason: metadata.ReasonSilenceTimeout}); err != nil {
+		t.Fatal(err)
+	}
+	if err := library.Add(root, path); err != nil {
+		t.Fatal(err)
+	}
+	return path, metadata.SidecarPath(path)
+}
+
+func testUploader(t *testing.T, target Target) (*Uploader, *time.Time) {
+	root := t.TempDir()
+	u := newUploader(root, target, log.New(io.Discard, "", 0))
+	now := t0
+	u.now = func() time.Time { return now }
+	return u, &now
+}
+
+func TestQueueRetriesWithBackoff(t *testing.T) {
+	target := &fakeTarget{fail: 2}
+	u, now := testUploader(t, target)
+	u.prefix = "laptop"
+	media, sidecar := recording(t, u.root, "2026/03/a.m4a")
+	if err := u.Enqueue(media, sidecar); err != nil {
+		t.Fatal(err)
+	}
+
+	if wait := u.processDue(context.Background()); wait != minRetry {
+		t.Errorf("wait after first failure = %s, want %s", wait, minRetry)
+	}
+	st, err := ReadStatus(u.root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Pending != 1 || st.Failing != 1 || st.LastError != "connection refused" {
+		t.Errorf("status = %+v", st)
+	}
+
+	// Not due yet: nothing is tried.
+	*now = now.Add(10 * time.Second)
+	u.processDue(context.Background())
+	if target.fail != 1 {
+		t.Fatal("retried before the backoff expired")
+	}
+
+	*now = now.Add(minRetry)
+	if wait := u.processDue(context.Background()); wait != 2*minRetry {
+		t.Errorf("wait after second failure = %s, want %s", wait, 2*minRetry)
+	}
+
+	// A restart picks the queue up from the file.
+	u2 := newUploader(u.root, target, u.logger)
+	u2.prefix, u2.now = u.prefix, u.now
+	if u2.q, err = readQueue(u.root); err != nil {
+		t.Fatal(err)
+	}
+	*now = now.Add(2 * minRetry)
+	u2.processDue(context.Background())
+	want := []string{"laptop/2026/03/a.m4a", "laptop/2026/03/a.json"}
+	if len(target.keys) != 2 || target.keys[0] != want[0] || target.keys[1] != want[1] {
+		t.Errorf("uploaded %q, want %q", target.keys, want)
+	}
+	st, _ = ReadStatus(u.root)
+	if st.Pending != 0 || st.LastUploaded != filepath.Join("2026", "03", "a.m4a") {
+		t.Errorf("status = %+v", st)
+	}
+	if _, err := os.Stat(media); err != nil {
+		t.Error("local file deleted without delete_local")
+	}
+}
+
+func TestDeleteLocal(t *testing.T) {
+	u, _ := testUploader(t, &fakeTarget{})
+	u.deleteLocal = true
+	media, sidecar := recording(t, u.root, "a.m4a")
+	keep, _ := recording(t, u.root, "b.m4a")
+	u.Enqueue(media, sidecar)
+	u.processDue(context.Background())
+
+	for _, p := range []string{media, sidecar} {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("%s not deleted", filepath.Base(p))
+		}
+	}
+	if _, err := os.Stat(keep); err != nil {
+		t.Error("recording not in the queue was deleted")
+	}
+	ix, err := library.Load(u.root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(ix.List(library.Filter{})); got != 1 {
+		t.Errorf("index has %d entries, want 1", got)
+	}
+}
+
+func TestQueueDropsMissingFiles(t *testing.T) {
+	target := &fakeTarget{}
+	u, _ := testUploader(t, target)
+	media, sidecar := recording(t, u.root, "a.m4a")
+	u.Enqueue(media, sidecar)
+	os.Remove(media)
+	u.processDue(context.Background())
+	if st := u.Status(); st.Pending != 0 || st.LastUploaded != "" {
+		t.Errorf("status = %+v", st)
+	}
+	if len(target.keys) != 0 {
+		t.Errorf("uploaded %q", target.keys)
+	}
+}
+
+func TestEnqueueOutsideRoot(t *testing.T) {
+	u, _ := testUploader(t, &fakeTarget{})
+	if err := u.Enqueue(filepath.Join(t.TempDir(), "a.m4a")); err == nil {
+		t.Error("enqueued a file outside the output directory")
+	}
+}
@@ -0,0 +1,97 @@
+package upload
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// WebDAV uploads to a WebDAV collection such as a Nextcloud folder.
+type WebDAV struct {
+	Base     *url.URL // collection the keys are relative to
+	Username string
+	Password string
+	Client   *http.Client
+}
+
+func (w *WebDAV) String() string {
+	return w.Base.Redacted()
+}
+
+// Upload creates the collections above key, PUTs the file and reads it
+// back to compare its SHA-256, since plain WebDAV has no checksums. The
+// OC-Checksum header lets Nextcloud check and store the sum as well.
+func (w *WebDAV) Upload(ctx context.Context, key string, f File) error {
+	dirs := strings.Split(key, "/")
+	for i := 1; i < len(dirs); i++ {
+		resp, err := w.do(ctx, "MKCOL", strings.Join(dirs[:i], "/")+"/", nil, 0, nil)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		// 405 Method Not Allowed: the collection exists.
+		if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusMethodNotAllowed {
+			return fmt.Errorf("webdav mkcol %s: %s", strings.Join(dirs[:i], "/"), resp.Status)
+		}
+	}
+
+	in, err := os.Open(f.Path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	resp, err := w.do(ctx, http.MethodPut, key, in, f.Size, http.Header{"OC-Checksum": {"SHA256:" + f.SHA256}})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webdav put %s: %s", key, resp.Status)
+	}
+
+	resp, err = w.do(ctx, http.MethodGet, key, nil, 0, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("webdav verify %s: %s", key, resp.Status)
+	}
+	h := sha256.New()
+	n, err := io.Copy(h, resp.Body)
+	if err != nil {
+		return fmt.Errorf("webdav verify %s: %w", key, err)
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); n != f.Size || got != f.SHA256 {
+		return fmt.Errorf("webdav verify %s: stored copy differs (%d bytes, sha256 %s)", key, n, got)
+	}
+	return nil
+}
+
+func (w *WebDAV) do(ctx context.Context, method, key string, body io.Reader, size int64, header http.Header) (*http.Response, error) {
+	u := *w.Base
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + key
+	u.RawPath = ""
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = size
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if w.Username != "" {
+		req.SetBasicAuth(w.Username, w.Password)
+	}
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client.Do(req)
+}
@@ -0,0 +1,101 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeDAV is an in-memory WebDAV stand-in with basic auth. Like a real
+// server it refuses PUTs into collections that do not exist.
+type fakeDAV struct {
+	mu      sync.Mutex
+	files   map[string][]byte
+	dirs    map[string]bool
+	corrupt bool
+}
+
+func newFakeDAV(t *testing.T) (*fakeDAV, *WebDAV) {
+	f := &fakeDAV{files: make(map[string][]byte), dirs: map[string]bool{"/dav": true}}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	u, _ := url.Parse(srv.URL + "/dav/")
+	return f, &WebDAV{Base: u, Username: "memofy", Password: "secret", Client: srv.Client()}
+}
+
+func (f *fakeDAV) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if user, pass, ok := r.BasicAuth(); !ok || user != "memofy" || pass != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	p := strings.TrimSuffix(r.URL.Path, "/")
+	switch r.Method {
+	case "MKCOL":
+		switch {
+		case f.dirs[p]:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		case !f.dirs[path.Dir(p)]:
+			w.WriteHeader(http.StatusConflict)
+		default:
+			f.dirs[p] = true
+			w.WriteHeader(http.StatusCreated)
+		}
+	case http.MethodPut:
+		if !f.dirs[path.Dir(p)] {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		if f.corrupt {
+			data = data[:len(data)-1]
+		}
+		f.files[p] = data
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodGet:
+		data, ok := f.files[p]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestWebDAVUpload(t *testing.T) {
+	fake, dav := newFakeDAV(t)
+	f := testFile(t, "a.m4a", 1000)
+	if err := dav.Upload(context.Background(), "2026/03/Q1 planning.m4a", f); err != nil {
+		t.Fatal(err)
+	}
+	// A second recording in the same month finds the collections in place.
+	if err := dav.Upload(context.Background(), "2026/03/b.m4a", f); err != nil {
+		t.Fatal(err)
+	}
+	want, _ := os.ReadFile(f.Path)
+	if !bytes.Equal(fake.files["/dav/2026/03/Q1 planning.m4a"], want) {
+		t.Error("stored file differs")
+	}
+
+	fake.corrupt = true
+	if err := dav.Upload(context.Background(), "2026/03/c.m4a", f); err == nil {
+		t.Error("upload of a truncated file succeeded")
+	}
+
+	fake.corrupt = false
+	dav.Password = "wrong"
+	if err := dav.Upload(context.Background(), "d.m4a", f); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("err = %v, want 401", err)
+	}
+}
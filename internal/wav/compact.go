@@ -0,0 +1,190 @@
+package wav
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"time"
+)
+
+// CompactOptions controls Compact.
+type CompactOptions struct {
+	Threshold  float64       // RMS level below which audio counts as silence
+	MinSilence time.Duration // silences longer than this are shortened
+	KeepGap    time.Duration // length a shortened silence is cut down to
+}
+
+// Segment is a stretch of audio kept by Compact, in frames.
+type Segment struct {
+	Source int64 // first frame in the original recording
+	Dest   int64 // first frame in the compacted file
+	Frames int64
+}
+
+// compactWindow is the analysis window used to find silence.
+const compactWindow = 100 * time.Millisecond
+
+// Compact shortens every silence longer than opts.MinSilence in the WAV file
+// at path down to opts.KeepGap, keeping half of the gap at each end of the
+// silence so speech is not clipped. The file is rewritten through a
+// temporary file and renamed into place. It returns the kept segments, or
+// nil when no silence was long enough and the file is unchanged.
+//
+// Only files produced by Writer can be compacted.
+func Compact(path string, opts CompactOptions) ([]Segment, error) {
+	h, err := ReadHeader(path)
+	if err != nil {
+		return nil, err
+	}
+	format, ok := h.SampleFormat()
+	if !ok {
+		return nil, fmt.Errorf("compact wav: unsupported encoding (tag=%d bits=%d)", h.FormatTag, h.BitsPerSample)
+	}
+	if h.Channels <= 0 || h.SampleRate <= 0 {
+		return nil, fmt.Errorf("compact wav: invalid format (%d ch, %d Hz)", h.Channels, h.SampleRate)
+	}
+
+	src, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("compact wav: %w", err)
+	}
+	defer src.Close()
+
+	silences, total, err := findSilences(src, h, format, opts)
+	if err != nil {
+		return nil, fmt.Errorf("compact wav: %w", err)
+	}
+	segments := keptSegments(silences, total, int64(opts.KeepGap.Seconds()*float64(h.SampleRate)))
+	if segments == nil {
+		return nil, nil
+	}
+
+	tmp := path + ".compact"
+	w, err := Create(tmp, h.SampleRate, h.Channels, WithSampleFormat(format))
+	if err != nil {
+		return nil, fmt.Errorf("compact wav: %w", err)
+	}
+	align := int64(h.BlockAlign())
+	for _, s := range segments {
+		if _, err := src.Seek(h.DataOffset+s.Source*align, io.SeekStart); err == nil {
+			var n int64
+			n, err = io.CopyN(w.f, src, s.Frames*align)
+			w.dataBytes += n
+		}
+		if err != nil {
+			w.Close()
+			os.Remove(tmp)
+			return nil, fmt.Errorf("compact wav: copy audio: %w", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		os.Remove(tmp)
+		return nil, fmt.Errorf("compact wav: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return nil, fmt.Errorf("compact wav: %w", err)
+	}
+	return segments, nil
+}
+
+// span is a half-open range of frames.
+type span struct{ start, end int64 }
+
+// findSilences scans the data chunk in compactWindow steps and returns the
+// silent runs longer than opts.MinSilence, plus the total frame count.
+func findSilences(r io.ReadSeeker, h Header, format SampleFormat, opts CompactOptions) ([]span, int64, error) {
+	if _, err := r.Seek(h.DataOffset, io.SeekStart); err != nil {
+		return nil, 0, err
+	}
+	align := h.BlockAlign()
+	bps := format.BytesPerSample()
+	window := int64(compactWindow.Seconds() * float64(h.SampleRate))
+	if window < 1 {
+		window = 1
+	}
+	minFrames := int64(opts.MinSilence.Seconds() * float64(h.SampleRate))
+
+	var silences []span
+	var total int64
+	runStart := int64(-1)
+	closeRun := func(end int64) {
+		if runStart >= 0 && end-runStart > minFrames {
+			silences = append(silences, span{runStart, end})
+		}
+		runStart = -1
+	}
+
+	br := bufio.NewReader(io.LimitReader(r, h.DataSize))
+	buf := make([]byte, window*int64(align))
+	for {
+		n, err := io.ReadFull(br, buf)
+		frames := int64(n / align)
+		if frames > 0 {
+			var sum float64
+			for i := 0; i < int(frames)*align; i += bps {
+				v := decodeSample(buf[i:], format)
+				sum += v * v
+			}
+			rms := math.Sqrt(sum / float64(frames*int64(h.Channels)))
+			if rms < opts.Threshold {
+				if runStart < 0 {
+					runStart = total
+				}
+			} else {
+				closeRun(total)
+			}
+			total += frames
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+	closeRun(total)
+	return silences, total, nil
+}
+
+// keptSegments returns the audio kept when each silence is cut down to keep
+// frames, or nil when there is nothing to cut.
+func keptSegments(silences []span, total, keep int64) []Segment {
+	var segments []Segment
+	var pos, dest int64
+	for _, s := range silences {
+		cutStart := s.start + keep/2
+		cutEnd := s.end - (keep - keep/2)
+		if cutEnd <= cutStart {
+			continue
+		}
+		if cutStart > pos {
+			segments = append(segments, Segment{Source: pos, Dest: dest, Frames: cutStart - pos})
+			dest += cutStart - pos
+		}
+		pos = cutEnd
+	}
+	if segments == nil && pos == 0 {
+		return nil
+	}
+	if total > pos {
+		segments = append(segments, Segment{Source: pos, Dest: dest, Frames: total - pos})
+	}
+	return segments
+}
+
+// decodeSample returns the first sample in b as a float in [-1, 1].
+func decodeSample(b []byte, format SampleFormat) float64 {
+	switch format {
+	case Float32:
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+	case PCM24:
+		v := int32(b[0]) | int32(b[1])<<8 | int32(int8(b[2]))<<16
+		return float64(v) / 8388607
+	default:
+		return float64(int16(binary.LittleEndian.Uint16(b))) / math.MaxInt16
+	}
+}
@@ -0,0 +1,77 @@
+package wav
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// tone returns n mono frames of a loud square wave.
+func tone(n int) []float32 {
+	s := make([]float32, n)
+	for i := range s {
+		s[i] = 0.5
+		if i%2 == 1 {
+			s[i] = -0.5
+		}
+	}
+	return s
+}
+
+func TestCompact(t *testing.T) {
+	for _, format := range []SampleFormat{PCM16, PCM24, Float32} {
+		path := filepath.Join(t.TempDir(), "session.wav")
+		w, err := Create(path, 1000, 1, WithSampleFormat(format))
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(tone(2000))             // 0-2 s speech
+		w.Write(make([]float32, 10000)) // 2-12 s silence
+		w.Write(tone(1000))             // 12-13 s speech
+		w.Write(make([]float32, 500))   // 13-13.5 s short pause, kept
+		w.Write(tone(1000))             // 13.5-14.5 s speech
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		segments, err := Compact(path, CompactOptions{Threshold: 0.01, MinSilence: 5 * time.Second, KeepGap: 2 * time.Second})
+		if err != nil {
+			t.Fatalf("%s: Compact() error: %v", format, err)
+		}
+		want := []Segment{
+			{Source: 0, Dest: 0, Frames: 3000},
+			{Source: 11000, Dest: 3000, Frames: 3500},
+		}
+		if len(segments) != len(want) {
+			t.Fatalf("%s: segments = %+v, want %+v", format, segments, want)
+		}
+		for i := range want {
+			if segments[i] != want[i] {
+				t.Errorf("%s: segment %d = %+v, want %+v", format, i, segments[i], want[i])
+			}
+		}
+		h, err := ReadHeader(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if frames := h.DataSize / int64(h.BlockAlign()); frames != 6500 {
+			t.Errorf("%s: compacted file has %d frames, want 6500", format, frames)
+		}
+	}
+}
+
+func TestCompactNothingToCut(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.wav")
+	w, _ := Create(path, 1000, 2)
+	w.Write(tone(4000))
+	w.Close()
+	before, _ := ReadHeader(path)
+
+	segments, err := Compact(path, CompactOptions{Threshold: 0.01, MinSilence: time.Second, KeepGap: 500 * time.Millisecond})
+	if err != nil || segments != nil {
+		t.Fatalf("Compact() = %v, %v; want nil, nil", segments, err)
+	}
+	if after, _ := ReadHeader(path); after != before {
+		t.Errorf("file changed: %+v → %+v", before, after)
+	}
+}
@@ -0,0 +1,182 @@
+package wav
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+)
+
+// Cue is a marker at a frame position in a WAV file, with an optional label.
+type Cue struct {
+	Frame int64
+	Label string
+}
+
+// WriteCues stores cues in a finished WAV file as a "cue " chunk followed by
+// a "LIST" chunk of type "adtl" holding a "labl" entry per labelled cue, the
+// layout most audio editors read as markers. Any chunks after the data chunk
+// are replaced, so calling it again rewrites the markers. Cues beyond the
+// 32-bit frame range of the cue chunk are dropped.
+func WriteCues(path string, cues []Cue) error {
+	h, err := ReadHeader(path)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("write cues: %w", err)
+	}
+	defer f.Close()
+
+	end := h.DataOffset + h.DataSize
+	var tail bytes.Buffer
+	if h.DataSize%2 == 1 {
+		tail.WriteByte(0) // pad byte after an odd-sized data chunk
+	}
+	tail.Write(cueChunks(cues))
+
+	if err := f.Truncate(end); err != nil {
+		return fmt.Errorf("write cues: %w", err)
+	}
+	if _, err := f.WriteAt(tail.Bytes(), end); err != nil {
+		return fmt.Errorf("write cues: %w", err)
+	}
+	riffSize := end + int64(tail.Len()) - 8
+	if h.RF64 {
+		// The 64-bit RIFF size is the first field of the ds64 body.
+		var b [8]byte
+		binary.LittleEndian.PutUint64(b[:], uint64(riffSize))
+		_, err = f.WriteAt(b[:], 20)
+	} else {
+		if riffSize > math.MaxUint32 {
+			return fmt.Errorf("write cues: file would exceed 4 GiB")
+		}
+		var b [4]byte
+		binary.LittleEndian.PutUint32(b[:], uint32(riffSize))
+		_, err = f.WriteAt(b[:], 4)
+	}
+	if err != nil {
+		return fmt.Errorf("write cues: update RIFF size: %w", err)
+	}
+	return f.Sync()
+}
+
+// cueChunks encodes the cue and LIST/adtl chunks; empty when cues is.
+func cueChunks(cues []Cue) []byte {
+	var kept []Cue
+	for _, c := range cues {
+		if c.Frame >= 0 && c.Frame <= math.MaxUint32 {
+			kept = append(kept, c)
+		}
+	}
+	if len(kept) == 0 {
+		return nil
+	}
+	le := binary.LittleEndian
+
+	b := []byte("cue ")
+	b = le.AppendUint32(b, uint32(4+24*len(kept)))
+	b = le.AppendUint32(b, uint32(len(kept)))
+	for i, c := range kept {
+		b = le.AppendUint32(b, uint32(i+1)) // cue point ID
+		b = le.AppendUint32(b, uint32(c.Frame))
+		b = append(b, "data"...)
+		b = le.AppendUint32(b, 0) // chunk start
+		b = le.AppendUint32(b, 0) // block start
+		b = le.AppendUint32(b, uint32(c.Frame))
+	}
+
+	adtl := []byte("adtl")
+	for i, c := range kept {
+		if c.Label == "" {
+			continue
+		}
+		size := 4 + len(c.Label) + 1 // cue ID + NUL-terminated text
+		adtl = append(adtl, "labl"...)
+		adtl = le.AppendUint32(adtl, uint32(size))
+		adtl = le.AppendUint32(adtl, uint32(i+1))
+		adtl = append(adtl, c.Label...)
+		adtl = append(adtl, 0)
+		if size%2 == 1 {
+			adtl = append(adtl, 0)
+		}
+	}
+	if len(adtl) > 4 {
+		b = append(b, "LIST"...)
+		b = le.AppendUint32(b, uint32(len(adtl)))
+		b = append(b, adtl...)
+	}
+	return b
+}
+
+// ReadCues returns the cues stored in the WAV file at path, ordered by
+// frame, with the labels from its LIST/adtl chunk. A file without a cue
+// chunk has none.
+func ReadCues(path string) ([]Cue, error) {
+	h, err := ReadHeader(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	pos := h.DataOffset + h.DataSize + h.DataSize%2
+	byID := map[uint32]*Cue{}
+	var ids []uint32
+	labels := map[uint32]string{}
+	chunk := make([]byte, 8)
+	for {
+		if _, err := f.ReadAt(chunk, pos); err != nil {
+			break // end of file
+		}
+		id := string(chunk[0:4])
+		size := int64(binary.LittleEndian.Uint32(chunk[4:8]))
+		body := make([]byte, size)
+		if _, err := f.ReadAt(body, pos+8); err != nil && err != io.EOF {
+			return nil, fmt.Errorf("read cues: %w", err)
+		}
+		switch {
+		case id == "cue " && size >= 4:
+			n := int(binary.LittleEndian.Uint32(body[0:4]))
+			for i := 0; i < n && 4+24*(i+1) <= len(body); i++ {
+				p := body[4+24*i:]
+				cid := binary.LittleEndian.Uint32(p[0:4])
+				byID[cid] = &Cue{Frame: int64(binary.LittleEndian.Uint32(p[20:24]))}
+				ids = append(ids, cid)
+			}
+		case id == "LIST" && size >= 4 && string(body[0:4]) == "adtl":
+			for p := body[4:]; len(p) >= 12; {
+				sub := string(p[0:4])
+				n := int(binary.LittleEndian.Uint32(p[4:8]))
+				if n < 4 || 8+n > len(p) {
+					break
+				}
+				if sub == "labl" {
+					labels[binary.LittleEndian.Uint32(p[8:12])] = string(bytes.TrimRight(p[12:8+n], "\x00"))
+				}
+				if next := 8 + n + n%2; next < len(p) {
+					p = p[next:]
+				} else {
+					break
+				}
+			}
+		}
+		pos += 8 + size + size%2
+	}
+
+	cues := make([]Cue, 0, len(ids))
+	for _, cid := range ids {
+		c := *byID[cid]
+		c.Label = labels[cid]
+		cues = append(cues, c)
+	}
+	sort.SliceStable(cues, func(i, j int) bool { return cues[i].Frame < cues[j].Frame })
+	return cues, nil
+}
@@ -0,0 +1,91 @@
+package wav
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteCues_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cues.wav")
+	w, err := Create(path, 16000, 1)
+	if err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	w.Write(make([]float32, 16000))
+	w.Close()
+
+	cues := []Cue{
+		{Frame: 12000, Label: "action item"},
+		{Frame: 4000},
+		{Frame: 8000, Label: "decision"},
+	}
+	if err := WriteCues(path, cues); err != nil {
+		t.Fatalf("WriteCues() error: %v", err)
+	}
+	got, err := ReadCues(path)
+	if err != nil {
+		t.Fatalf("ReadCues() error: %v", err)
+	}
+	want := []Cue{{4000, ""}, {8000, "decision"}, {12000, "action item"}}
+	if len(got) != len(want) {
+		t.Fatalf("ReadCues() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("cue %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	// The audio and the RIFF size must still be consistent.
+	h, err := ReadHeader(path)
+	if err != nil {
+		t.Fatalf("ReadHeader() error: %v", err)
+	}
+	if h.DataSize != 32000 {
+		t.Errorf("data size = %d, want 32000", h.DataSize)
+	}
+	b, _ := os.ReadFile(path)
+	if riff := int(b[4]) | int(b[5])<<8 | int(b[6])<<16 | int(b[7])<<24; riff != len(b)-8 {
+		t.Errorf("RIFF size = %d, want %d", riff, len(b)-8)
+	}
+
+	// Writing again replaces the markers rather than appending.
+	if err := WriteCues(path, []Cue{{Frame: 100, Label: "only"}}); err != nil {
+		t.Fatalf("WriteCues() again error: %v", err)
+	}
+	got, _ = ReadCues(path)
+	if len(got) != 1 || got[0] != (Cue{100, "only"}) {
+		t.Errorf("after rewrite ReadCues() = %v", got)
+	}
+}
+
+func TestWriteCues_OddDataChunk(t *testing.T) {
+	// 24-bit mono with an odd frame count leaves an odd-sized data chunk.
+	path := filepath.Join(t.TempDir(), "odd.wav")
+	w, err := Create(path, 8000, 1, WithSampleFormat(PCM24))
+	if err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	w.Write(make([]float32, 3))
+	w.Close()
+
+	if err := WriteCues(path, []Cue{{Frame: 1, Label: "odd"}}); err != nil {
+		t.Fatalf("WriteCues() error: %v", err)
+	}
+	got, err := ReadCues(path)
+	if err != nil || len(got) != 1 || got[0] != (Cue{1, "odd"}) {
+		t.Errorf("ReadCues() = %v, %v", got, err)
+	}
+}
+
+func TestReadCues_None(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "plain.wav")
+	w, _ := Create(path, 8000, 1)
+	w.Write(make([]float32, 10))
+	w.Close()
+	got, err := ReadCues(path)
+	if err != nil || len(got) != 0 {
+		t.Errorf("ReadCues() = %v, %v; want none", got, err)
+	}
+}
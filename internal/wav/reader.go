@@ -0,0 +1,132 @@
+package wav
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ErrNotWAV is returned by ReadHeader when the file is not a RIFF/RF64 WAVE file.
+var ErrNotWAV = errors.New("not a WAVE file")
+
+// Header describes the layout of an existing WAV file.
+type Header struct {
+	RF64          bool  // true for RF64/BW64 files (sizes stored in the ds64 chunk)
+	FormatTag     int   // 1 = PCM, 3 = IEEE float
+	Channels      int   // interleaved channel count
+	SampleRate    int   // frames per second
+	BitsPerSample int   // bits per encoded sample
+	DataOffset    int64 // file offset of the first audio byte
+	DataSize      int64 // size of the data chunk as recorded in the header
+}
+
+// SampleFormat returns the writer sample format matching the header, and
+// false if the encoding is not one the Writer produces.
+func (h Header) SampleFormat() (SampleFormat, bool) {
+	switch {
+	case h.FormatTag == formatPCM && h.BitsPerSample == 16:
+		return PCM16, true
+	case h.FormatTag == formatPCM && h.BitsPerSample == 24:
+		return PCM24, true
+	case h.FormatTag == formatIEEEFloat && h.BitsPerSample == 32:
+		return Float32, true
+	default:
+		return PCM16, false
+	}
+}
+
+// BlockAlign returns the size of one interleaved frame in bytes.
+func (h Header) BlockAlign() int {
+	return h.Channels * h.BitsPerSample / 8
+}
+
+// ReadHeader parses the header of the WAV file at path. Both classic RIFF and
+// RF64/BW64 (ds64) layouts are understood. Unknown chunks before the data
+// chunk (JUNK, LIST, fact, ...) are skipped.
+func ReadHeader(path string) (Header, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Header{}, err
+	}
+	defer f.Close()
+	return parseHeader(f)
+}
+
+func parseHeader(r io.ReadSeeker) (Header, error) {
+	var h Header
+	riff := make([]byte, 12)
+	if _, err := io.ReadFull(r, riff); err != nil {
+		return h, fmt.Errorf("%w: short header", ErrNotWAV)
+	}
+	switch string(riff[0:4]) {
+	case "RIFF":
+	case "RF64", "BW64":
+		h.RF64 = true
+	default:
+		return h, fmt.Errorf("%w: missing RIFF marker", ErrNotWAV)
+	}
+	if string(riff[8:12]) != "WAVE" {
+		return h, fmt.Errorf("%w: missing WAVE marker", ErrNotWAV)
+	}
+
+	var ds64DataSize int64 = -1
+	haveFmt := false
+	pos := int64(12)
+	chunk := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, chunk); err != nil {
+			return h, fmt.Errorf("%w: no data chunk", ErrNotWAV)
+		}
+		id := string(chunk[0:4])
+		size := int64(binary.LittleEndian.Uint32(chunk[4:8]))
+		pos += 8
+
+		switch id {
+		case "ds64":
+			body := make([]byte, 16)
+			if size < 16 {
+				return h, fmt.Errorf("%w: truncated ds64 chunk", ErrNotWAV)
+			}
+			if _, err := io.ReadFull(r, body); err != nil {
+				return h, fmt.Errorf("%w: truncated ds64 chunk", ErrNotWAV)
+			}
+			ds64DataSize = int64(binary.LittleEndian.Uint64(body[8:16]))
+			if _, err := r.Seek(pos+size+size%2, io.SeekStart); err != nil {
+				return h, err
+			}
+		case "fmt ":
+			if size < 16 {
+				return h, fmt.Errorf("%w: truncated fmt chunk", ErrNotWAV)
+			}
+			body := make([]byte, 16)
+			if _, err := io.ReadFull(r, body); err != nil {
+				return h, fmt.Errorf("%w: truncated fmt chunk", ErrNotWAV)
+			}
+			h.FormatTag = int(binary.LittleEndian.Uint16(body[0:2]))
+			h.Channels = int(binary.LittleEndian.Uint16(body[2:4]))
+			h.SampleRate = int(binary.LittleEndian.Uint32(body[4:8]))
+			h.BitsPerSample = int(binary.LittleEndian.Uint16(body[14:16]))
+			haveFmt = true
+			if _, err := r.Seek(pos+size+size%2, io.SeekStart); err != nil {
+				return h, err
+			}
+		case "data":
+			if !haveFmt {
+				return h, fmt.Errorf("%w: data chunk before fmt chunk", ErrNotWAV)
+			}
+			h.DataOffset = pos
+			h.DataSize = size
+			if h.RF64 && size == 0xFFFFFFFF && ds64DataSize >= 0 {
+				h.DataSize = ds64DataSize
+			}
+			return h, nil
+		default:
+			if _, err := r.Seek(pos+size+size%2, io.SeekStart); err != nil {
+				return h, err
+			}
+		}
+		pos += size + size%2
+	}
+}
@@ -0,0 +1,97 @@
+package wav
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadHeader_Classic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.wav")
+
+	w, err := Create(path, 44100, 2, WithSampleFormat(PCM24))
+	if err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	w.Write(make([]float32, 10))
+	w.Close()
+
+	h, err := ReadHeader(path)
+	if err != nil {
+		t.Fatalf("ReadHeader() error: %v", err)
+	}
+	if h.RF64 {
+		t.Error("small file should not be RF64")
+	}
+	if h.Channels != 2 || h.SampleRate != 44100 || h.BitsPerSample != 24 {
+		t.Errorf("header = %+v", h)
+	}
+	if h.BlockAlign() != 6 {
+		t.Errorf("block align = %d, want 6", h.BlockAlign())
+	}
+	if h.DataOffset != pcmHeaderLen || h.DataSize != 30 {
+		t.Errorf("data offset/size = %d/%d, want %d/30", h.DataOffset, h.DataSize, pcmHeaderLen)
+	}
+}
+
+// A plain 44-byte header written by other tools, with an odd-sized LIST
+// chunk in front of data to exercise pad-byte skipping.
+func TestReadHeader_SkipsUnknownChunks(t *testing.T) {
+	var b []byte
+	b = append(b, "RIFF"...)
+	b = binary.LittleEndian.AppendUint32(b, 0)
+	b = append(b, "WAVE"...)
+	b = append(b, "fmt "...)
+	b = binary.LittleEndian.AppendUint32(b, 16)
+	b = binary.LittleEndian.AppendUint16(b, 1)
+	b = binary.LittleEndian.AppendUint16(b, 1)
+	b = binary.LittleEndian.AppendUint32(b, 16000)
+	b = binary.LittleEndian.AppendUint32(b, 32000)
+	b = binary.LittleEndian.AppendUint16(b, 2)
+	b = binary.LittleEndian.AppendUint16(b, 16)
+	b = append(b, "LIST"...)
+	b = binary.LittleEndian.AppendUint32(b, 3)
+	b = append(b, 'a', 'b', 'c', 0) // 3 bytes + pad
+	b = append(b, "data"...)
+	b = binary.LittleEndian.AppendUint32(b, 4)
+	offset := len(b)
+	b = append(b, 1, 2, 3, 4)
+
+	path := filepath.Join(t.TempDir(), "list.wav")
+	os.WriteFile(path, b, 0644)
+
+	h, err := ReadHeader(path)
+	if err != nil {
+		t.Fatalf("ReadHeader() error: %v", err)
+	}
+	if h.DataOffset != int64(offset) || h.DataSize != 4 {
+		t.Errorf("data offset/size = %d/%d, want %d/4", h.DataOffset, h.DataSize, offset)
+	}
+	if f, ok := h.SampleFormat(); !ok || f != PCM16 {
+		t.Errorf("sample format = %v/%v, want s16", f, ok)
+	}
+}
+
+func TestReadHeader_Invalid(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"not riff", append([]byte("XXXX\x00\x00\x00\x00WAVE"), make([]byte, 32)...)},
+		{"not wave", append([]byte("RIFF\x00\x00\x00\x00AVI "), make([]byte, 32)...)},
+		{"header only", append([]byte("RIFF\x00\x00\x00\x00WAVE"), make([]byte, 32)...)},
+		{"data before fmt", []byte("RIFF\x00\x00\x00\x00WAVEdata\x00\x00\x00\x00")},
+	}
+	for _, tc := range tests {
+		path := filepath.Join(dir, tc.name+".wav")
+		os.WriteFile(path, tc.data, 0644)
+		if _, err := ReadHeader(path); !errors.Is(err, ErrNotWAV) {
+			t.Errorf("%s: err = %v, want ErrNotWAV", tc.name, err)
+		}
+	}
+}
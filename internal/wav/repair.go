@@ -0,0 +1,61 @@
+package wav
+
+import (
+	"fmt"
+	"os"
+)
+
+// Repair fixes the header of a WAV file that was not closed cleanly, e.g.
+// because the process was killed mid-recording. The data size is recomputed
+// from the file length, a trailing partial frame is truncated, and the
+// header (switching to RF64 if needed) is rewritten in place.
+//
+// Only files produced by Writer can be repaired; other layouts return an
+// error. The returned Header reflects the repaired file.
+func Repair(path string) (Header, error) {
+	h, err := ReadHeader(path)
+	if err != nil {
+		return h, err
+	}
+	format, ok := h.SampleFormat()
+	if !ok {
+		return h, fmt.Errorf("repair wav: unsupported encoding (tag=%d bits=%d)", h.FormatTag, h.BitsPerSample)
+	}
+	if h.Channels <= 0 {
+		return h, fmt.Errorf("repair wav: invalid channel count %d", h.Channels)
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return h, fmt.Errorf("repair wav: %w", err)
+	}
+	defer f.Close()
+
+	w := &Writer{f: f, sampleRate: h.SampleRate, channels: h.Channels, format: format}
+	if h.DataOffset != w.headerLen() {
+		return h, fmt.Errorf("repair wav: unrecognized header layout (data at offset %d)", h.DataOffset)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return h, fmt.Errorf("repair wav: %w", err)
+	}
+	dataSize := info.Size() - h.DataOffset
+	if dataSize < 0 {
+		dataSize = 0
+	}
+	dataSize -= dataSize % int64(h.BlockAlign())
+	if err := f.Truncate(h.DataOffset + dataSize); err != nil {
+		return h, fmt.Errorf("repair wav: truncate partial frame: %w", err)
+	}
+	if err := w.writeHeader(dataSize); err != nil {
+		return h, fmt.Errorf("repair wav: rewrite header: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return h, fmt.Errorf("repair wav: %w", err)
+	}
+
+	h.DataSize = dataSize
+	h.RF64 = h.DataOffset-8+dataSize > maxRIFFSize
+	return h, nil
+}
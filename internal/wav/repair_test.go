@@ -0,0 +1,107 @@
+package wav
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// crash simulates a killed process: the file is left with the placeholder
+// header written by Create.
+func crash(t *testing.T, w *Writer) {
+	t.Helper()
+	if err := w.f.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRepair(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "orphan.wav")
+
+	w, err := Create(path, 8000, 2)
+	if err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	w.Write(make([]float32, 100)) // 50 frames, 200 bytes
+	crash(t, w)
+
+	// Half a frame written just before the crash.
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	f.Write([]byte{1, 2})
+	f.Close()
+
+	if h, _ := ReadHeader(path); h.DataSize != 0 {
+		t.Fatalf("precondition: crashed header data size = %d, want 0", h.DataSize)
+	}
+
+	h, err := Repair(path)
+	if err != nil {
+		t.Fatalf("Repair() error: %v", err)
+	}
+	if h.DataSize != 200 {
+		t.Errorf("repaired data size = %d, want 200", h.DataSize)
+	}
+	info, _ := os.Stat(path)
+	if info.Size() != pcmHeaderLen+200 {
+		t.Errorf("file size = %d, want %d (partial frame truncated)", info.Size(), pcmHeaderLen+200)
+	}
+	reread, err := ReadHeader(path)
+	if err != nil || reread.DataSize != 200 || reread.Channels != 2 {
+		t.Errorf("reread header = %+v, err = %v", reread, err)
+	}
+}
+
+func TestRepair_Float32(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "orphan.wav")
+
+	w, err := Create(path, 48000, 1, WithSampleFormat(Float32))
+	if err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	w.Write([]float32{0.1, 0.2, 0.3})
+	crash(t, w)
+
+	h, err := Repair(path)
+	if err != nil {
+		t.Fatalf("Repair() error: %v", err)
+	}
+	if h.DataSize != 12 || h.DataOffset != floatHeaderLen {
+		t.Errorf("header = %+v, want 12 data bytes at %d", h, floatHeaderLen)
+	}
+}
+
+func TestRepair_SwitchesToRF64(t *testing.T) {
+	old := maxRIFFSize
+	maxRIFFSize = pcmHeaderLen
+	defer func() { maxRIFFSize = old }()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "orphan.wav")
+
+	w, err := Create(path, 8000, 1)
+	if err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	w.Write(make([]float32, 10))
+	crash(t, w)
+
+	h, err := Repair(path)
+	if err != nil {
+		t.Fatalf("Repair() error: %v", err)
+	}
+	reread, _ := ReadHeader(path)
+	if !h.RF64 || !reread.RF64 || reread.DataSize != 20 {
+		t.Errorf("repaired header = %+v, reread = %+v, want RF64 with 20 bytes", h, reread)
+	}
+}
+
+func TestRepair_NotWAV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "junk.wav")
+	os.WriteFile(path, []byte("not audio"), 0644)
+	if _, err := Repair(path); !errors.Is(err, ErrNotWAV) {
+		t.Errorf("err = %v, want ErrNotWAV", err)
+	}
+}
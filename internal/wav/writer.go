@@ -6,24 +6,117 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"math/rand"
 	"os"
 	"sync"
+	"time"
 )
 
+// WAVE format tags used in the fmt chunk.
+const (
+	formatPCM       = 1
+	formatIEEEFloat = 3
+)
+
+// SampleFormat selects how float32 samples are encoded on disk.
+type SampleFormat int
+
+const (
+	// PCM16 is 16-bit signed integer PCM (the default).
+	PCM16 SampleFormat = iota
+	// PCM24 is 24-bit signed integer PCM, packed little-endian.
+	PCM24
+	// Float32 is 32-bit IEEE float (WAVE_FORMAT_IEEE_FLOAT). Samples are
+	// stored unclamped, so no headroom is lost.
+	Float32
+)
+
+// ParseSampleFormat converts a config value ("s16", "s24", "f32") to a
+// SampleFormat. An empty string selects PCM16.
+func ParseSampleFormat(s string) (SampleFormat, error) {
+	switch s {
+	case "", "s16":
+		return PCM16, nil
+	case "s24":
+		return PCM24, nil
+	case "f32":
+		return Float32, nil
+	default:
+		return PCM16, fmt.Errorf("unknown sample format %q (want s16, s24 or f32)", s)
+	}
+}
+
+// String returns the config name of the format.
+func (f SampleFormat) String() string {
+	switch f {
+	case PCM24:
+		return "s24"
+	case Float32:
+		return "f32"
+	default:
+		return "s16"
+	}
+}
+
+// BytesPerSample returns the encoded size of one sample.
+func (f SampleFormat) BytesPerSample() int {
+	switch f {
+	case PCM24:
+		return 3
+	case Float32:
+		return 4
+	default:
+		return 2
+	}
+}
+
+// Option configures a Writer at creation time.
+type Option func(*Writer)
+
+// WithSampleFormat sets the on-disk sample encoding. Default is PCM16.
+func WithSampleFormat(f SampleFormat) Option {
+	return func(w *Writer) { w.format = f }
+}
+
+// WithDither enables TPDF (triangular) dither of ±1 LSB before quantizing.
+// Only applies to the PCM16 path; 24-bit and float output are not dithered.
+func WithDither(enabled bool) Option {
+	return func(w *Writer) { w.dither = enabled }
+}
+
+// WithCheckpointInterval makes Write rewrite the header with the current
+// data size and fsync the file at most once per interval, so a crash loses
+// no more than the last interval of audio. Zero disables checkpoints; the
+// header is then only correct after Close().
+func WithCheckpointInterval(d time.Duration) Option {
+	return func(w *Writer) { w.checkpointEvery = d }
+}
+
 // Writer writes PCM audio data to a WAV file.
 // It writes a placeholder header on creation and updates it on Close()
-// with the actual data size, making it crash-safe for partial writes.
+// with the actual data size. With WithCheckpointInterval the header is also
+// updated periodically while recording; files left behind by a crash can be
+// fixed with Repair. Files larger than 4 GiB are written as RF64.
 type Writer struct {
-	f          *os.File
-	sampleRate int
-	channels   int
-	dataBytes  int64
-	mu         sync.Mutex
-	closed     bool
+	f               *os.File
+	sampleRate      int
+	channels        int
+	format          SampleFormat
+	dither          bool
+	rng             *rand.Rand
+	dataBytes       int64
+	clipped         int64  // samples at or beyond full scale
+	clipRuns        int64  // runs of consecutive clipped samples, per channel
+	inClip          []bool // per channel: previous sample was clipped
+	checkpointEvery time.Duration
+	lastCheckpoint  time.Time
+	mu              sync.Mutex
+	closed          bool
 }
 
-// Create opens a new WAV file for writing.
-func Create(path string, sampleRate, channels int) (*Writer, error) {
+// Create opens a new WAV file for writing. Without options the file is
+// 16-bit PCM.
+func Create(path string, sampleRate, channels int, opts ...Option) (*Writer, error) {
 	f, err := os.Create(path)
 	if err != nil {
 		return nil, fmt.Errorf("create wav: %w", err)
@@ -33,19 +126,28 @@ func Create(path string, sampleRate, channels int) (*Writer, error) {
 		f:          f,
 		sampleRate: sampleRate,
 		channels:   channels,
+		inClip:     make([]bool, max(channels, 1)),
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	if w.dither && w.format == PCM16 {
+		w.rng = rand.New(rand.NewSource(time.Now().UnixNano()))
 	}
 
-	// Write placeholder header (44 bytes), will be updated on Close().
+	// Write placeholder header, will be updated on Close().
 	if err := w.writeHeader(0); err != nil {
 		f.Close()
 		os.Remove(path)
 		return nil, err
 	}
+	w.lastCheckpoint = time.Now()
 
 	return w, nil
 }
 
-// Write appends interleaved float32 samples to the WAV file as 16-bit PCM.
+// Write appends interleaved float32 samples to the WAV file in the
+// writer's sample format.
 func (w *Writer) Write(samples []float32) error {
 	w.mu.Lock()
 	defer w.mu.Unlock()
@@ -54,16 +156,34 @@ func (w *Writer) Write(samples []float32) error {
 		return fmt.Errorf("wav writer is closed")
 	}
 
-	buf := make([]byte, len(samples)*2) // 16-bit = 2 bytes per sample
+	bps := w.format.BytesPerSample()
+	buf := make([]byte, len(samples)*bps)
 	for i, s := range samples {
+		w.countClip(i, s)
+		if w.format == Float32 {
+			binary.LittleEndian.PutUint32(buf[i*4:], math.Float32bits(s))
+			continue
+		}
+		v := float64(s)
+		if w.rng != nil {
+			// TPDF: sum of two uniform variables, ±1 LSB peak.
+			v += (w.rng.Float64() - w.rng.Float64()) / math.MaxInt16
+		}
 		// Clamp to [-1, 1]
-		if s > 1.0 {
-			s = 1.0
-		} else if s < -1.0 {
-			s = -1.0
+		if v > 1.0 {
+			v = 1.0
+		} else if v < -1.0 {
+			v = -1.0
 		}
-		v := int16(s * math.MaxInt16)
-		binary.LittleEndian.PutUint16(buf[i*2:], uint16(v))
+		if w.format == PCM24 {
+			q := int32(math.Round(v * 8388607))
+			buf[i*3] = byte(q)
+			buf[i*3+1] = byte(q >> 8)
+			buf[i*3+2] = byte(q >> 16)
+			continue
+		}
+		q := int16(math.Round(v * math.MaxInt16))
+		binary.LittleEndian.PutUint16(buf[i*2:], uint16(q))
 	}
 
 	n, err := w.f.Write(buf)
@@ -71,9 +191,71 @@ func (w *Writer) Write(samples []float32) error {
 	if err != nil {
 		return fmt.Errorf("write wav data: %w", err)
 	}
+	if w.checkpointEvery > 0 && time.Since(w.lastCheckpoint) >= w.checkpointEvery {
+		if err := w.checkpoint(); err != nil {
+			return fmt.Errorf("checkpoint wav header: %w", err)
+		}
+	}
 	return nil
 }
 
+// countClip updates the clip counters for sample i of an interleaved buffer.
+// Buffers always hold whole frames, so i modulo channels is the channel.
+func (w *Writer) countClip(i int, s float32) {
+	ch := i % len(w.inClip)
+	if s >= 1.0 || s <= -1.0 {
+		w.clipped++
+		if !w.inClip[ch] {
+			w.clipRuns++
+			w.inClip[ch] = true
+		}
+		return
+	}
+	w.inClip[ch] = false
+}
+
+// ClippedSamples returns the number of samples written at or beyond full
+// scale. PCM output clamps them; float output stores them as-is but they
+// clip on any later conversion to integer PCM.
+func (w *Writer) ClippedSamples() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.clipped
+}
+
+// ClipRuns returns the number of distinct clipping events: runs of
+// consecutive clipped samples within a channel.
+func (w *Writer) ClipRuns() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.clipRuns
+}
+
+// Checkpoint rewrites the header with the sizes written so far and flushes
+// the file to disk. The file stays open for further writes.
+func (w *Writer) Checkpoint() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return fmt.Errorf("wav writer is closed")
+	}
+	return w.checkpoint()
+}
+
+func (w *Writer) checkpoint() error {
+	w.lastCheckpoint = time.Now()
+	if _, err := w.f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := w.writeHeader(w.dataBytes); err != nil {
+		return err
+	}
+	if _, err := w.f.Seek(0, io.SeekEnd); err != nil {
+		return err
+	}
+	return w.f.Sync()
+}
+
 // Close finalizes the WAV file by updating the header with actual sizes.
 func (w *Writer) Close() error {
 	w.mu.Lock()
@@ -102,6 +284,21 @@ func (w *Writer) Path() string {
 	return w.f.Name()
 }
 
+// Format returns the on-disk sample format.
+func (w *Writer) Format() SampleFormat {
+	return w.format
+}
+
+// Channels returns the channel count.
+func (w *Writer) Channels() int {
+	return w.channels
+}
+
+// BytesPerSample returns the encoded size of one sample in this file.
+func (w *Writer) BytesPerSample() int {
+	return w.format.BytesPerSample()
+}
+
 // DataBytes returns the number of audio data bytes written so far.
 func (w *Writer) DataBytes() int64 {
 	w.mu.Lock()
@@ -113,39 +310,116 @@ func (w *Writer) DataBytes() int64 {
 func (w *Writer) DurationSeconds() float64 {
 	w.mu.Lock()
 	defer w.mu.Unlock()
-	bytesPerSecond := int64(w.sampleRate) * int64(w.channels) * 2 // 16-bit
+	bytesPerSecond := int64(w.sampleRate) * int64(w.channels) * int64(w.format.BytesPerSample())
 	if bytesPerSecond == 0 {
 		return 0
 	}
 	return float64(w.dataBytes) / float64(bytesPerSecond)
 }
 
-// writeHeader writes a 44-byte WAV header.
+// maxRIFFSize is the largest RIFF chunk size a classic WAV header can hold.
+// Beyond it the writer switches to RF64. A variable so tests can lower it.
+var maxRIFFSize int64 = math.MaxUint32
+
+// ds64Size is the body size of the ds64 chunk (riff size, data size, sample
+// count, table length). A JUNK chunk of the same size is reserved in every
+// file so the header can be converted to RF64 in place.
+const ds64Size = 28
+
+// headerLen returns the size of the header written by writeHeader, which is
+// also the offset of the first audio byte.
+func (w *Writer) headerLen() int64 {
+	n := int64(12 + 8 + ds64Size + 8 + 16 + 8)
+	if w.format == Float32 {
+		n += 2 + 12 // cbSize + fact chunk
+	}
+	return n
+}
+
+// writeHeader writes the RIFF header, JUNK/ds64 chunk, fmt chunk and data
+// chunk header. Float files add the cbSize field to fmt and the fact chunk
+// required for non-PCM formats.
+//
+// While the file fits in 4 GiB it is a classic RIFF WAVE with a JUNK chunk
+// reserved after the WAVE marker. Once the data grows past that limit the
+// header becomes RF64 (EBU Tech 3306): the JUNK chunk is rewritten as ds64
+// holding the 64-bit sizes and the 32-bit size fields are set to 0xFFFFFFFF.
 func (w *Writer) writeHeader(dataSize int64) error {
-	bitsPerSample := 16
-	byteRate := w.sampleRate * w.channels * bitsPerSample / 8
-	blockAlign := w.channels * bitsPerSample / 8
+	bytesPerSample := w.format.BytesPerSample()
+	bitsPerSample := bytesPerSample * 8
+	byteRate := w.sampleRate * w.channels * bytesPerSample
+	blockAlign := w.channels * bytesPerSample
 
-	h := make([]byte, 44)
+	fmtSize := 16
+	formatTag := formatPCM
+	if w.format == Float32 {
+		fmtSize = 18
+		formatTag = formatIEEEFloat
+	}
+	var frames int64
+	if blockAlign > 0 {
+		frames = dataSize / int64(blockAlign)
+	}
 
-	// RIFF header
-	copy(h[0:4], "RIFF")
-	binary.LittleEndian.PutUint32(h[4:8], uint32(36+dataSize))
-	copy(h[8:12], "WAVE")
+	headerLen := w.headerLen()
+	riffSize := headerLen - 8 + dataSize
+	rf64 := riffSize > maxRIFFSize
+
+	size32 := func(v int64) uint32 {
+		if rf64 {
+			return 0xFFFFFFFF
+		}
+		return uint32(v)
+	}
+
+	h := make([]byte, 0, headerLen)
+
+	// RIFF/RF64 header
+	if rf64 {
+		h = append(h, "RF64"...)
+	} else {
+		h = append(h, "RIFF"...)
+	}
+	h = binary.LittleEndian.AppendUint32(h, size32(riffSize))
+	h = append(h, "WAVE"...)
+
+	// ds64 chunk, or JUNK reserving its space
+	if rf64 {
+		h = append(h, "ds64"...)
+		h = binary.LittleEndian.AppendUint32(h, ds64Size)
+		h = binary.LittleEndian.AppendUint64(h, uint64(riffSize))
+		h = binary.LittleEndian.AppendUint64(h, uint64(dataSize))
+		h = binary.LittleEndian.AppendUint64(h, uint64(frames))
+		h = binary.LittleEndian.AppendUint32(h, 0) // no table entries
+	} else {
+		h = append(h, "JUNK"...)
+		h = binary.LittleEndian.AppendUint32(h, ds64Size)
+		h = append(h, make([]byte, ds64Size)...)
+	}
 
 	// fmt chunk
-	copy(h[12:16], "fmt ")
-	binary.LittleEndian.PutUint32(h[16:20], 16) // chunk size
-	binary.LittleEndian.PutUint16(h[20:22], 1)  // PCM format
-	binary.LittleEndian.PutUint16(h[22:24], uint16(w.channels))
-	binary.LittleEndian.PutUint32(h[24:28], uint32(w.sampleRate))
-	binary.LittleEndian.PutUint32(h[28:32], uint32(byteRate))
-	binary.LittleEndian.PutUint16(h[32:34], uint16(blockAlign))
-	binary.LittleEndian.PutUint16(h[34:36], uint16(bitsPerSample))
+	h = append(h, "fmt "...)
+	h = binary.LittleEndian.AppendUint32(h, uint32(fmtSize))
+	h = binary.LittleEndian.AppendUint16(h, uint16(formatTag))
+	h = binary.LittleEndian.AppendUint16(h, uint16(w.channels))
+	h = binary.LittleEndian.AppendUint32(h, uint32(w.sampleRate))
+	h = binary.LittleEndian.AppendUint32(h, uint32(byteRate))
+	h = binary.LittleEndian.AppendUint16(h, uint16(blockAlign))
+	h = binary.LittleEndian.AppendUint16(h, uint16(bitsPerSample))
+	if fmtSize == 18 {
+		h = binary.LittleEndian.AppendUint16(h, 0) // cbSize
+	}
+
+	// fact chunk: sample frames per channel (required for non-PCM).
+	if w.format == Float32 {
+		h = append(h, "fact"...)
+		h = binary.LittleEndian.AppendUint32(h, 4)
+		h = binary.LittleEndian.AppendUint32(h, size32(frames))
+	}
 
 	// data chunk
-	copy(h[36:40], "data")
-	binary.LittleEndian.PutUint32(h[40:44], uint32(dataSize))
+	h = append(h, "data"...)
+	h = binary.LittleEndian.AppendUint32(h, size32(dataSize))
 
 	_, err := w.f.Write(h)
 	return err
@@ -2,9 +2,17 @@ package wav
 
 import (
 	"encoding/binary"
+	"math"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
+)
+
+// Header sizes including the JUNK chunk reserved for ds64.
+const (
+	pcmHeaderLen   = 80
+	floatHeaderLen = 94
 )
 
 func TestCreate_WritesHeader(t *testing.T) {
@@ -21,7 +29,7 @@ func TestCreate_WritesHeader(t *testing.T) {
 	}
 
 	data, _ := os.ReadFile(path)
-	if len(data) < 44 {
+	if len(data) < pcmHeaderLen {
 		t.Fatalf("file too small: %d bytes", len(data))
 	}
 
@@ -32,24 +40,28 @@ func TestCreate_WritesHeader(t *testing.T) {
 	if string(data[8:12]) != "WAVE" {
 		t.Errorf("missing WAVE marker")
 	}
-	if string(data[12:16]) != "fmt " {
+	// JUNK reserves room for a ds64 chunk.
+	if string(data[12:16]) != "JUNK" {
+		t.Errorf("missing JUNK chunk")
+	}
+	if string(data[48:52]) != "fmt " {
 		t.Errorf("missing fmt chunk")
 	}
 
 	// PCM format = 1
-	format := binary.LittleEndian.Uint16(data[20:22])
+	format := binary.LittleEndian.Uint16(data[56:58])
 	if format != 1 {
 		t.Errorf("format = %d, want 1 (PCM)", format)
 	}
 
 	// Channels
-	ch := binary.LittleEndian.Uint16(data[22:24])
+	ch := binary.LittleEndian.Uint16(data[58:60])
 	if ch != 2 {
 		t.Errorf("channels = %d, want 2", ch)
 	}
 
 	// Sample rate
-	sr := binary.LittleEndian.Uint32(data[24:28])
+	sr := binary.LittleEndian.Uint32(data[60:64])
 	if sr != 48000 {
 		t.Errorf("sample rate = %d, want 48000", sr)
 	}
@@ -79,16 +91,16 @@ func TestWrite_AudioData(t *testing.T) {
 		t.Fatalf("Close() error: %v", err)
 	}
 
-	// Check file size: 44 header + 48000*2*2 bytes = 44 + 192000
+	// Check file size: header + 48000*2*2 bytes
 	info, _ := os.Stat(path)
-	expected := int64(44 + 48000*2*2)
+	expected := int64(pcmHeaderLen + 48000*2*2)
 	if info.Size() != expected {
 		t.Errorf("file size = %d, want %d", info.Size(), expected)
 	}
 
 	// Verify header data size matches
 	data, _ := os.ReadFile(path)
-	dataSize := binary.LittleEndian.Uint32(data[40:44])
+	dataSize := binary.LittleEndian.Uint32(data[pcmHeaderLen-4 : pcmHeaderLen])
 	if dataSize != uint32(48000*2*2) {
 		t.Errorf("data chunk size = %d, want %d", dataSize, 48000*2*2)
 	}
@@ -115,8 +127,8 @@ func TestWrite_ClampingValues(t *testing.T) {
 
 	// File should be valid (no panic, no error)
 	info, _ := os.Stat(path)
-	if info.Size() != 44+10 { // 5 samples * 2 bytes
-		t.Errorf("file size = %d, want %d", info.Size(), 44+10)
+	if info.Size() != pcmHeaderLen+10 { // 5 samples * 2 bytes
+		t.Errorf("file size = %d, want %d", info.Size(), pcmHeaderLen+10)
 	}
 }
 
@@ -157,3 +169,295 @@ func TestPath(t *testing.T) {
 		t.Errorf("Path() = %q, want %q", w.Path(), path)
 	}
 }
+
+func TestParseSampleFormat(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    SampleFormat
+		wantErr bool
+	}{
+		{"", PCM16, false},
+		{"s16", PCM16, false},
+		{"s24", PCM24, false},
+		{"f32", Float32, false},
+		{"s32", PCM16, true},
+	}
+	for _, tc := range tests {
+		got, err := ParseSampleFormat(tc.in)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("ParseSampleFormat(%q) error = %v, wantErr %v", tc.in, err, tc.wantErr)
+		}
+		if got != tc.want {
+			t.Errorf("ParseSampleFormat(%q) = %v, want %v", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestWrite_PCM24(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.wav")
+
+	w, err := Create(path, 48000, 1, WithSampleFormat(PCM24))
+	if err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	if err := w.Write([]float32{0, 1.0, -1.0, 0.5}); err != nil {
+		t.Fatalf("Write() error: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if len(data) != pcmHeaderLen+4*3 {
+		t.Fatalf("file size = %d, want %d", len(data), pcmHeaderLen+4*3)
+	}
+	if bits := binary.LittleEndian.Uint16(data[70:72]); bits != 24 {
+		t.Errorf("bits per sample = %d, want 24", bits)
+	}
+	if align := binary.LittleEndian.Uint16(data[68:70]); align != 3 {
+		t.Errorf("block align = %d, want 3", align)
+	}
+	sample := func(i int) int32 {
+		b := data[pcmHeaderLen+i*3:]
+		return int32(uint32(b[0])|uint32(b[1])<<8|uint32(b[2])<<16) << 8 >> 8
+	}
+	want := []int32{0, 8388607, -8388607, 4194304}
+	for i, v := range want {
+		if got := sample(i); got != v {
+			t.Errorf("sample %d = %d, want %d", i, got, v)
+		}
+	}
+}
+
+func TestWrite_Float32(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.wav")
+
+	w, err := Create(path, 48000, 2, WithSampleFormat(Float32))
+	if err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	// Float output keeps values beyond full scale.
+	in := []float32{0.25, -1.5, 2.0, 0}
+	if err := w.Write(in); err != nil {
+		t.Fatalf("Write() error: %v", err)
+	}
+	if dur := w.DurationSeconds(); dur <= 0 {
+		t.Errorf("duration = %f, want > 0", dur)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	// RIFF(12) + JUNK(8+28) + fmt(8+18) + fact(8+4) + data(8) = 94 byte header
+	if len(data) != floatHeaderLen+16 {
+		t.Fatalf("file size = %d, want %d", len(data), floatHeaderLen+16)
+	}
+	if tag := binary.LittleEndian.Uint16(data[56:58]); tag != 3 {
+		t.Errorf("format tag = %d, want 3 (IEEE float)", tag)
+	}
+	if bits := binary.LittleEndian.Uint16(data[70:72]); bits != 32 {
+		t.Errorf("bits per sample = %d, want 32", bits)
+	}
+	if string(data[74:78]) != "fact" {
+		t.Fatalf("missing fact chunk, got %q", data[74:78])
+	}
+	if frames := binary.LittleEndian.Uint32(data[82:86]); frames != 2 {
+		t.Errorf("fact frames = %d, want 2", frames)
+	}
+	if string(data[86:90]) != "data" {
+		t.Fatalf("missing data chunk, got %q", data[86:90])
+	}
+	if size := binary.LittleEndian.Uint32(data[90:94]); size != 16 {
+		t.Errorf("data size = %d, want 16", size)
+	}
+	if riff := binary.LittleEndian.Uint32(data[4:8]); int(riff) != len(data)-8 {
+		t.Errorf("RIFF size = %d, want %d", riff, len(data)-8)
+	}
+	for i, v := range in {
+		got := math.Float32frombits(binary.LittleEndian.Uint32(data[floatHeaderLen+i*4:]))
+		if got != v {
+			t.Errorf("sample %d = %f, want %f", i, got, v)
+		}
+	}
+}
+
+func TestWrite_DitherStaysWithinOneLSB(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.wav")
+
+	w, err := Create(path, 8000, 1, WithDither(true))
+	if err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	samples := make([]float32, 8000)
+	for i := range samples {
+		samples[i] = 0.5
+	}
+	if err := w.Write(samples); err != nil {
+		t.Fatalf("Write() error: %v", err)
+	}
+	w.Close()
+
+	data, _ := os.ReadFile(path)
+	center := int(math.Round(0.5 * math.MaxInt16))
+	varied := false
+	for i := 0; i < len(samples); i++ {
+		v := int(int16(binary.LittleEndian.Uint16(data[pcmHeaderLen+i*2:])))
+		if d := v - center; d < -1 || d > 1 {
+			t.Fatalf("sample %d = %d, more than 1 LSB from %d", i, v, center)
+		}
+		if v != center {
+			varied = true
+		}
+	}
+	if !varied {
+		t.Error("dither enabled but all samples identical")
+	}
+}
+
+func TestWrite_SwitchesToRF64(t *testing.T) {
+	// Lower the RIFF limit so a few samples push the file past it.
+	old := maxRIFFSize
+	maxRIFFSize = pcmHeaderLen - 8 + 100
+	defer func() { maxRIFFSize = old }()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.wav")
+
+	w, err := Create(path, 8000, 1)
+	if err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	if err := w.Write(make([]float32, 60)); err != nil { // 120 bytes
+		t.Fatalf("Write() error: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if len(data) != pcmHeaderLen+120 {
+		t.Fatalf("file size = %d, want %d", len(data), pcmHeaderLen+120)
+	}
+	if string(data[0:4]) != "RF64" {
+		t.Fatalf("marker = %q, want RF64", data[0:4])
+	}
+	if riff := binary.LittleEndian.Uint32(data[4:8]); riff != 0xFFFFFFFF {
+		t.Errorf("RIFF size = %#x, want 0xFFFFFFFF", riff)
+	}
+	if string(data[12:16]) != "ds64" {
+		t.Fatalf("chunk = %q, want ds64", data[12:16])
+	}
+	if riff := binary.LittleEndian.Uint64(data[20:28]); int(riff) != len(data)-8 {
+		t.Errorf("ds64 riff size = %d, want %d", riff, len(data)-8)
+	}
+	if size := binary.LittleEndian.Uint64(data[28:36]); size != 120 {
+		t.Errorf("ds64 data size = %d, want 120", size)
+	}
+	if frames := binary.LittleEndian.Uint64(data[36:44]); frames != 60 {
+		t.Errorf("ds64 sample count = %d, want 60", frames)
+	}
+	if size := binary.LittleEndian.Uint32(data[76:80]); size != 0xFFFFFFFF {
+		t.Errorf("data chunk size = %#x, want 0xFFFFFFFF", size)
+	}
+
+	h, err := ReadHeader(path)
+	if err != nil {
+		t.Fatalf("ReadHeader() error: %v", err)
+	}
+	if !h.RF64 || h.DataSize != 120 || h.DataOffset != pcmHeaderLen {
+		t.Errorf("header = %+v, want RF64 with 120 data bytes at %d", h, pcmHeaderLen)
+	}
+}
+
+func TestWrite_RF64Float32(t *testing.T) {
+	old := maxRIFFSize
+	maxRIFFSize = floatHeaderLen
+	defer func() { maxRIFFSize = old }()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.wav")
+
+	w, err := Create(path, 48000, 2, WithSampleFormat(Float32))
+	if err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	if err := w.Write(make([]float32, 8)); err != nil {
+		t.Fatalf("Write() error: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error: %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if frames := binary.LittleEndian.Uint32(data[82:86]); frames != 0xFFFFFFFF {
+		t.Errorf("fact frames = %#x, want 0xFFFFFFFF", frames)
+	}
+	h, err := ReadHeader(path)
+	if err != nil {
+		t.Fatalf("ReadHeader() error: %v", err)
+	}
+	if f, ok := h.SampleFormat(); !ok || f != Float32 {
+		t.Errorf("sample format = %v/%v, want f32", f, ok)
+	}
+	if h.DataSize != 32 || h.DataOffset != floatHeaderLen {
+		t.Errorf("header = %+v, want 32 data bytes at %d", h, floatHeaderLen)
+	}
+}
+
+func TestWrite_Checkpoint(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.wav")
+
+	w, err := Create(path, 8000, 1, WithCheckpointInterval(time.Nanosecond))
+	if err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	defer w.Close()
+	if err := w.Write(make([]float32, 100)); err != nil {
+		t.Fatalf("Write() error: %v", err)
+	}
+
+	// The header is current without Close().
+	h, err := ReadHeader(path)
+	if err != nil {
+		t.Fatalf("ReadHeader() error: %v", err)
+	}
+	if h.DataSize != 200 {
+		t.Errorf("checkpointed data size = %d, want 200", h.DataSize)
+	}
+
+	// Writes after a checkpoint append, not overwrite.
+	if err := w.Write(make([]float32, 50)); err != nil {
+		t.Fatalf("Write() error: %v", err)
+	}
+	info, _ := os.Stat(path)
+	if info.Size() != pcmHeaderLen+300 {
+		t.Errorf("file size = %d, want %d", info.Size(), pcmHeaderLen+300)
+	}
+}
+
+func TestWrite_CountsClipping(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.wav")
+
+	w, err := Create(path, 8000, 2)
+	if err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	defer w.Close()
+	// Left: one run of three clipped samples split across two buffers.
+	// Right: two separate single-sample runs.
+	w.Write([]float32{0.5, 1.0, 1.2, 0, 1.0, -1.5})
+	w.Write([]float32{-1.0, 0, 0, 0})
+
+	if got := w.ClippedSamples(); got != 5 {
+		t.Errorf("clipped samples = %d, want 5", got)
+	}
+	if got := w.ClipRuns(); got != 3 {
+		t.Errorf("clip runs = %d, want 3", got)
+	}
+}
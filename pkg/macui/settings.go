@@ -18,6 +18,9 @@ type SettingsWindow struct {
 	window    appkit.Window
 	isVisible bool
 
+	// OnSaved, if set, is called with the new configuration after a save.
+	OnSaved func(config.Config)
+
 	// Audio tab controls
 	device         appkit.TextField
 	threshold      appkit.TextField
@@ -278,6 +281,9 @@ func (sw *SettingsWindow) onSave() {
 
 	sw.cfg = cfg
 	log.Println("Settings saved")
+	if sw.OnSaved != nil {
+		sw.OnSaved(cfg)
+	}
 	_ = SendNotification("Memofy", "Settings Saved", "Changes saved. Restart to apply audio changes.")
 	sw.window.OrderOut(nil)
 	sw.isVisible = false
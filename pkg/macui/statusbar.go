@@ -8,6 +8,8 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/progrium/darwinkit/helper/action"
@@ -47,6 +49,10 @@ func NewStatusBarApp(version string, eng *engine.Engine, cfg config.Config) *Sta
 	}
 
 	app.settingsWindow = NewSettingsWindow(cfg)
+	app.settingsWindow.OnSaved = func(c config.Config) {
+		// Thresholds apply immediately; other audio settings need a restart.
+		app.eng.SetThresholds(c.Audio.Threshold, c.Audio.ExitThreshold)
+	}
 	app.aboutWindow = NewAboutWindow(version, checker)
 	app.createStatusBar()
 
@@ -119,6 +125,22 @@ func (app *StatusBarApp) pollAndUpdate() {
 		_ = SendErrorNotification("Memofy Error", status.LastError)
 	}
 
+	// Warn once when the capture develops a dead or identical channel.
+	warnings := strings.Join(status.ChannelWarnings, "; ")
+	if warnings != "" && warnings != strings.Join(app.lastStatus.ChannelWarnings, "; ") {
+		_ = SendNotification("Memofy", "Channel Problem", warnings)
+	}
+
+	// Warn when the output volume runs low on space.
+	if status.DiskLevel != app.lastStatus.DiskLevel {
+		switch status.DiskLevel {
+		case "low":
+			_ = SendNotification("Memofy", "Disk Space Low", "New recordings use a smaller format")
+		case "critical":
+			_ = SendErrorNotification("Memofy: Disk Almost Full", "Recording is paused until space is freed")
+		}
+	}
+
 	app.lastStatus = status
 }
 
@@ -192,6 +214,33 @@ func (app *StatusBarApp) rebuildMenu() {
 		app.menu.AddItem(devItem)
 	}
 
+	// Recording schedule
+	if !status.ScheduleOpen || !status.NextScheduleChange.IsZero() {
+		schedText := "Schedule: outside recording hours"
+		if status.ScheduleOpen {
+			schedText = "Schedule: recording allowed"
+		}
+		if !status.NextScheduleChange.IsZero() {
+			schedText += fmt.Sprintf(" until %s", status.NextScheduleChange.Local().Format("Mon 15:04"))
+		}
+		schedItem := appkit.NewMenuItem()
+		schedItem.SetTitle(schedText)
+		schedItem.SetEnabled(false)
+		app.menu.AddItem(schedItem)
+	}
+
+	// Disk space
+	if status.DiskLevel == "low" || status.DiskLevel == "critical" {
+		diskText := fmt.Sprintf("Disk space %s: %.1f GB free", status.DiskLevel, float64(status.DiskFree)/(1<<30))
+		if status.DiskLevel == "critical" {
+			diskText += " (recording paused)"
+		}
+		diskItem := appkit.NewMenuItem()
+		diskItem.SetTitle(diskText)
+		diskItem.SetEnabled(false)
+		app.menu.AddItem(diskItem)
+	}
+
 	// Format profile
 	profileLabel := status.FormatProfile
 	if profileLabel == "" {
@@ -211,7 +260,7 @@ func (app *StatusBarApp) rebuildMenu() {
 	}
 
 	// Meeting/mic status
-	if status.MicActive || status.ZoomRunning || status.TeamsRunning || status.MeetRunning {
+	if status.MicActive || len(status.Apps) > 0 {
 		app.menu.AddItem(appkit.MenuItem_SeparatorItem())
 		if status.MicActive {
 			micItem := appkit.NewMenuItem()
@@ -219,31 +268,20 @@ func (app *StatusBarApp) rebuildMenu() {
 			micItem.SetEnabled(false)
 			app.menu.AddItem(micItem)
 		}
-		if status.TeamsRunning {
-			teamsItem := appkit.NewMenuItem()
-			if status.MicActive {
-				teamsItem.SetTitle("📞 Teams meeting active")
-			} else {
-				teamsItem.SetTitle("💬 Teams running")
-			}
-			teamsItem.SetEnabled(false)
-			app.menu.AddItem(teamsItem)
+		names := make([]string, 0, len(status.Apps))
+		for name := range status.Apps {
+			names = append(names, name)
 		}
-		if status.ZoomRunning {
-			zoomItem := appkit.NewMenuItem()
-			if status.MicActive {
-				zoomItem.SetTitle("📞 Zoom meeting active")
+		sort.Strings(names)
+		for _, name := range names {
+			appItem := appkit.NewMenuItem()
+			if status.Apps[name].InCall {
+				appItem.SetTitle(fmt.Sprintf("📞 %s meeting active", appDisplayName(name)))
 			} else {
-				zoomItem.SetTitle("💬 Zoom running")
+				appItem.SetTitle(fmt.Sprintf("💬 %s running", appDisplayName(name)))
 			}
-			zoomItem.SetEnabled(false)
-			app.menu.AddItem(zoomItem)
-		}
-		if status.MeetRunning {
-			meetItem := appkit.NewMenuItem()
-			meetItem.SetTitle("💬 Google Meet running")
-			meetItem.SetEnabled(false)
-			app.menu.AddItem(meetItem)
+			appItem.SetEnabled(false)
+			app.menu.AddItem(appItem)
 		}
 	}
 
@@ -367,3 +405,17 @@ func formatDisplayName(profile string) string {
 		return profile
 	}
 }
+
+// appDisplayName returns the menu label for a monitoring.apps name.
+func appDisplayName(name string) string {
+	switch name {
+	case "zoom":
+		return "Zoom"
+	case "teams":
+		return "Teams"
+	case "meet":
+		return "Google Meet"
+	default:
+		return name
+	}
+}